@@ -36,19 +36,24 @@ import (
 const JSSandboxToolDescription = `JavaScript沙盒执行工具，用于在安全的沙盒环境中执行JavaScript代码。
 
 重要限制与说明：
-1. **不支持 async/await**：沙盒环境不支持异步语法，代码必须同步执行。
-2. **执行隔离与返回值**：代码在匿名函数中执行。**必须使用 return 语句返回结果**，否则将返回 undefined。
-3. **错误处理**：大多数操作返回包含 error 字段的对象，建议始终检查 success 或 error 字段。
+1. **支持 async/await**：代码在 async 匿名函数中执行，可以直接使用 await；返回 Promise 时会等待其完成后返回最终值。
+2. **执行隔离与返回值**：代码在匿名函数中执行，每次调用使用独立的运行环境，全局变量不会保留到下一次调用。**必须使用 return 语句返回结果**，否则将返回 undefined。
+3. **ES 模块**：代码中使用 import/export 时按 ES 模块执行（支持顶层 await），此时不能使用 return，**通过 export default 返回结果**。可导入内置模块，如 import { readFile } from 'fs'、import { fetch } from 'http'。
+4. **错误处理**：大多数操作返回包含 error 字段的对象，建议始终检查 success 或 error 字段。
+5. **输出**：console.log 等和 logger 的输出会附加在结果之后（"控制台输出:"），可用于调试；输出过多时会被截断，最终结果仍应通过 return 返回。
 
 主要可用函数：
 - 系统/环境：getCurrentDateTime(), getCPUNum(), getMemorySize(), getDiskSize(), sleep(ms), getEnv(name), readConfig(path)
-- HTTP请求：httpGet(url), httpPost(url, body), httpRequest(url, options), fetch(url, options)
+- 定时器：setTimeout(fn, ms), setInterval(fn, ms), clearTimeout(id), clearInterval(id), queueMicrotask(fn)
+- HTTP请求：httpGet(url), httpPost(url, body), httpRequest(url, options)（同步），await fetch(url, options)（异步，返回 Response，支持 await res.json()/res.text()）
 - 文件系统：readFile(path, options?), writeFile(path, content), appendFile(path, content), readFileHead(path, lines), getFileInfo(path), getFileHash(path, type), readImageBase64(path)
 - 文档读取：readWord(path), readExcel(path), readPPT(path), readPDF(path)
 - 浏览器自动化：createBrowserSession(timeout) -> navigate(url), wait(selector/sec), click(selector), fill(selector, value), evaluate(code), screenshot(path), getHTML(), getURL(), close()
 - 图片处理：imageInfo(path), imageResize(in, out, w, h?), imageCrop(in, out, x, y, w, h), imageRotate(in, out, angle), imageConvert(in, out), imageQuality(in, out, q)
 - 数据验证/处理：validateEmail(email), validateURL(url), validateIP(ip), validatePhone(phone), formatDate(date, fmt), parseDate(str), addDays(date, days)
 - 编码/加密：encodeBase64(data), decodeBase64(str), encryptAES(data, key), decryptAES(enc, key), hashSHA256(data), generateUUID(), generateRandomString(len)
+- 二进制数据：Buffer（与 Node.js 相同）；readFile/decodeBase64/compressGzip 等传入 {encoding: 'binary'}、httpRequest 传入 {responseType: 'arraybuffer'} 时返回 Buffer，图片函数的输出参数为 {format: 'png'} 时返回 Buffer
+- Web 标准：URL, URLSearchParams, TextEncoder, TextDecoder（支持 gbk/gb18030）, atob(str), btoa(str), structuredClone(obj), AbortController, performance.now()
 - 压缩/CSV：compressZip(files, out), extractZip(zip, dir), readCSV(path, opts), writeCSV(path, data), parseCSV(str)
 - 网络/进程：resolveDNS(host), ping(host), checkPort(host, port), execCommand(cmd), listProcesses(), killProcess(pid)
 - 文本/路径：textReplace(text, old, new), textSplit(text, sep), textJoin(parts, sep), textTrim(text), textContains(text, sub), pathJoin(...paths), pathAbs(path)
@@ -61,11 +66,15 @@ const data = JSON.parse(resp.body);
 writeFile('data.json', resp.body);
 return { count: data.length, status: 'success' };`
 
+// throwModeDescription 沙盒配置为 ErrorModeThrow 时附加到工具描述中的错误处理说明
+const throwModeDescription = `注意：当前环境中宿主函数出错时直接抛出 SandboxError 异常（而不是返回 error 字段），` +
+	`异常带有 code 属性（如 FILE_NOT_FOUND、HTTP_ERROR），原因在 cause 属性中。需要处理错误时使用 try/catch。`
+
 // JSSandboxTool JavaScript沙盒工具
 type JSSandboxTool struct {
-	sandbox *jssandbox.Sandbox
-	config  *JSSandboxConfig
-	info    *schema.ToolInfo
+	pool   *jssandbox.SandboxPool
+	config *JSSandboxConfig
+	info   *schema.ToolInfo
 }
 
 // JSSandboxParams 工具参数
@@ -76,8 +85,9 @@ type JSSandboxParams struct {
 
 // JSSandboxConfig 工具配置
 type JSSandboxConfig struct {
-	SandboxConfig  *jssandbox.Config // jssandbox配置
-	DefaultTimeout time.Duration     // 默认超时时间
+	SandboxConfig  *jssandbox.Config     // jssandbox配置
+	PoolConfig     *jssandbox.PoolConfig // 沙盒池配置，为nil时使用默认配置；其中的 Config 为nil时使用 SandboxConfig
+	DefaultTimeout time.Duration         // 默认超时时间
 }
 
 // NewJSSandboxTool 创建新的JavaScript沙盒工具实例
@@ -89,19 +99,38 @@ func NewJSSandboxTool(ctx context.Context, cfg *JSSandboxConfig) (*JSSandboxTool
 		}
 	}
 
-	// 创建沙盒实例
+	// 创建沙盒池，每次执行借出一个沙盒，执行结束后重置，避免不同调用之间共享全局变量
 	sandboxConfig := cfg.SandboxConfig
 	if sandboxConfig == nil {
 		sandboxConfig = jssandbox.DefaultConfig()
 	}
-	sandbox := jssandbox.NewSandboxWithConfig(ctx, sandboxConfig)
+	poolConfig := *jssandbox.DefaultPoolConfig().WithSandboxConfig(nil)
+	if cfg.PoolConfig != nil {
+		poolConfig = *cfg.PoolConfig
+	}
+	if poolConfig.Config == nil {
+		poolConfig.Config = sandboxConfig
+	}
+	pool, err := jssandbox.NewSandboxPool(ctx, &poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("创建沙盒池失败: %w", err)
+	}
+
+	// 自动把宿主扩展的文档附加到工具描述中，让模型知道可以调用哪些自定义函数
+	desc := JSSandboxToolDescription
+	if poolConfig.Config.ErrorMode == jssandbox.ErrorModeThrow {
+		desc += "\n\n" + throwModeDescription
+	}
+	if docs := poolConfig.Config.ExtensionDocs(); docs != "" {
+		desc += "\n\n自定义扩展（全局对象，也可以通过 require/import 获取）：\n\n" + docs
+	}
 
 	return &JSSandboxTool{
-		sandbox: sandbox,
-		config:  cfg,
+		pool:   pool,
+		config: cfg,
 		info: &schema.ToolInfo{
 			Name: "jssandbox",
-			Desc: JSSandboxToolDescription,
+			Desc: desc,
 			ParamsOneOf: schema.NewParamsOneOfByJSONSchema(
 				&jsonschema.Schema{
 					Type:     string(schema.Object),
@@ -144,7 +173,7 @@ func (t *JSSandboxTool) InvokableRun(ctx context.Context, argumentsInJSON string
 	return t.Execute(ctx, param)
 }
 
-// Execute 执行JavaScript代码
+// Execute 执行JavaScript代码，ctx 被取消时中断正在执行的脚本
 func (t *JSSandboxTool) Execute(ctx context.Context, params *JSSandboxParams) (string, error) {
 	// 验证必需参数
 	if params.Code == "" {
@@ -162,27 +191,72 @@ func (t *JSSandboxTool) Execute(ctx context.Context, params *JSSandboxParams) (s
 		}
 	}
 
-	// 包装代码在匿名函数中，以支持 top-level return 并提供执行隔离
-	wrappedCode := "(function(){\n" + params.Code + "\n})()"
+	// 包装代码在 async 匿名函数中，以支持 top-level return、await 并提供执行隔离
+	// 沙盒会运行事件循环直到空闲，并返回 Promise 的最终值
+	wrappedCode := "(async function(){\n" + params.Code + "\n})()"
+
+	// 从沙盒池借出沙盒，执行以 ctx 为父上下文，结果转换为字符串后再归还
+	sandbox, err := t.pool.Get(ctx)
+	if err != nil {
+		return "", fmt.Errorf("获取沙盒失败: %w", err)
+	}
+	defer t.pool.Put(sandbox)
 
-	// 执行JavaScript代码
-	var result goja.Value
-	var err error
-	if timeout > 0 {
-		result, err = t.sandbox.RunWithTimeout(wrappedCode, timeout)
+	// 执行JavaScript代码，使用 import/export 的代码无法放进函数中，按 ES 模块执行并返回默认导出
+	// 同时捕获 console 和 logger 的输出，附加在结果之后，让模型看到脚本打印的内容
+	var res *jssandbox.RunResult
+	isModule := isModuleCode(params.Code, wrappedCode)
+	if isModule {
+		res, err = sandbox.RunModuleWithOutput(params.Code, timeout)
+		if err == nil && res != nil {
+			if ns, ok := res.Value.(*goja.Object); ok {
+				if def := ns.Get("default"); def != nil {
+					res.Value = def
+				}
+			}
+		}
 	} else {
-		result, err = t.sandbox.Run(wrappedCode)
+		res, err = sandbox.RunWithOutput(wrappedCode, timeout)
+	}
+	// 沙盒池在借出后关闭等情况下 res 可能为 nil
+	var output string
+	if res != nil {
+		output = res.Output.String()
 	}
 
 	if err != nil {
+		// 包装代码在原始代码前加了一行，错误位置和源码片段换算回原始代码，便于模型定位和修复
+		var sbErr *jssandbox.SandboxError
+		if !isModule && errors.As(err, &sbErr) && sbErr.Exception != nil {
+			sbErr.Exception.Adjust(params.Code, 1)
+		}
+		if output != "" {
+			return "", fmt.Errorf("执行JavaScript代码失败: %w\n\n控制台输出:\n%s", err, output)
+		}
 		return "", fmt.Errorf("执行JavaScript代码失败: %w", err)
 	}
 
 	// 将结果转换为字符串
-	resultStr := valueToString(result)
+	resultStr := valueToString(res.Value)
+	if output != "" {
+		resultStr += "\n\n控制台输出:\n" + output
+	}
 	return resultStr, nil
 }
 
+// isModuleCode 判断代码是否需要按 ES 模块执行：包装进函数后无法解析，并且使用了模块语法
+func isModuleCode(code, wrappedCode string) bool {
+	if _, err := goja.Parse("", wrappedCode); err == nil {
+		return false
+	}
+	return jssandbox.IsModule(code)
+}
+
+// PoolStats 返回工具使用的沙盒池的运行指标
+func (t *JSSandboxTool) PoolStats() jssandbox.PoolStats {
+	return t.pool.Stats()
+}
+
 // valueToString 将goja.Value转换为字符串
 func valueToString(v goja.Value) string {
 	if v == nil {
@@ -220,8 +294,8 @@ func valueToString(v goja.Value) string {
 
 // Close 关闭工具并清理资源
 func (t *JSSandboxTool) Close() error {
-	if t.sandbox != nil {
-		return t.sandbox.Close()
+	if t.pool != nil {
+		return t.pool.Close()
 	}
 	return nil
 }
@@ -0,0 +1,227 @@
+package jssandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditEvent 一次有副作用的宿主函数调用（写文件、HTTP 请求、执行命令、浏览器操作等）的审计记录
+type AuditEvent struct {
+	// RunID 发起调用的执行的 ID，同一次 Run 中的调用相同
+	RunID string `json:"run_id"`
+	// Time 调用完成的时间
+	Time time.Time `json:"time"`
+	// Module 宿主函数所属的模块，如 "fs"、"http"、"process"、"browser"
+	Module string `json:"module"`
+	// Operation 宿主函数名，如 "writeFile"、"httpRequest"、"session.navigate"
+	Operation string `json:"operation"`
+	// Target 操作对象：文件路径、URL、命令、进程 ID 等
+	Target string `json:"target"`
+	// BytesIn 读取或接收的字节数（如 HTTP 响应体）
+	BytesIn int64 `json:"bytes_in,omitempty"`
+	// BytesOut 写入或发送的字节数（如写入文件、HTTP 请求体）
+	BytesOut int64 `json:"bytes_out,omitempty"`
+	// Success 操作是否成功
+	Success bool `json:"success"`
+	// Error 失败原因
+	Error string `json:"error,omitempty"`
+	// Code 失败时的错误码（如 ACCESS_DENIED、NETWORK_POLICY_VIOLATION）
+	Code ErrorCode `json:"code,omitempty"`
+	// Details 操作相关的其他信息，如 HTTP 方法和状态码、命令的退出码、重命名的目标路径
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditSink 接收审计记录，可能在多个 goroutine 中同时调用
+type AuditSink interface {
+	Audit(event AuditEvent)
+}
+
+// AuditFunc 把函数作为 AuditSink 使用
+type AuditFunc func(event AuditEvent)
+
+// Audit 实现 AuditSink 接口
+func (f AuditFunc) Audit(event AuditEvent) {
+	f(event)
+}
+
+// LoggerAuditSink 把审计记录写入 logrus 日志，未配置 Config.AuditSink 时使用沙盒的 logger
+type LoggerAuditSink struct {
+	logger *logrus.Logger
+}
+
+// NewLoggerAuditSink 创建写入 logger 的审计记录接收器
+func NewLoggerAuditSink(logger *logrus.Logger) *LoggerAuditSink {
+	return &LoggerAuditSink{logger: logger}
+}
+
+// Audit 实现 AuditSink 接口，成功的操作记录为 Info，失败的记录为 Warn
+func (s *LoggerAuditSink) Audit(event AuditEvent) {
+	fields := logrus.Fields{
+		"audit":     true,
+		"run_id":    event.RunID,
+		"module":    event.Module,
+		"operation": event.Operation,
+		"target":    event.Target,
+		"success":   event.Success,
+	}
+	if event.BytesIn > 0 {
+		fields["bytes_in"] = event.BytesIn
+	}
+	if event.BytesOut > 0 {
+		fields["bytes_out"] = event.BytesOut
+	}
+	for k, v := range event.Details {
+		fields[k] = v
+	}
+	entry := s.logger.WithFields(fields).WithTime(event.Time)
+	if !event.Success {
+		entry.WithField("code", event.Code).WithField("error", event.Error).Warn("审计: 操作失败")
+		return
+	}
+	entry.Info("审计: 操作完成")
+}
+
+// JSONLAuditSink 把审计记录以 JSON Lines 格式（每行一个 JSON 对象）写入 io.Writer
+type JSONLAuditSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLAuditSink 创建写入 w 的 JSON Lines 审计记录接收器
+func NewJSONLAuditSink(w io.Writer) *JSONLAuditSink {
+	return &JSONLAuditSink{w: w}
+}
+
+// NewJSONLAuditFile 创建追加写入文件 path 的 JSON Lines 审计记录接收器，文件不存在时创建，用完后需要调用 Close
+func NewJSONLAuditFile(path string) (*JSONLAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, NewSandboxErrorWithCause(ErrCodeFileSystemError, "打开审计日志文件失败: "+path, err)
+	}
+	return &JSONLAuditSink{w: f, closer: f}, nil
+}
+
+// Audit 实现 AuditSink 接口，写入失败时记录日志
+func (s *JSONLAuditSink) Audit(event AuditEvent) {
+	line, err := json.Marshal(event)
+	if err == nil {
+		s.mu.Lock()
+		_, err = s.w.Write(append(line, '\n'))
+		s.mu.Unlock()
+	}
+	if err != nil {
+		GetLogger().WithError(err).Error("写入审计记录失败")
+	}
+}
+
+// Close 关闭 NewJSONLAuditFile 打开的文件
+func (s *JSONLAuditSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// ChannelAuditSink 把审计记录发送到通道，通道已满时等待接收方读取
+type ChannelAuditSink chan<- AuditEvent
+
+// Audit 实现 AuditSink 接口
+func (s ChannelAuditSink) Audit(event AuditEvent) {
+	s <- event
+}
+
+// runIDKey 执行上下文中保存执行 ID 的键
+type runIDKey struct{}
+
+// RunIDFromContext 返回执行上下文（如 HostCall.Context）中的执行 ID，不在执行中时返回空字符串
+func RunIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// withRunID 为一次执行生成 ID 并保存到上下文中
+func withRunID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, runIDKey{}, uuid.New().String())
+}
+
+// auditSink 返回审计记录的接收器
+func (sb *Sandbox) auditSink() AuditSink {
+	if sb.config.AuditSink != nil {
+		return sb.config.AuditSink
+	}
+	return NewLoggerAuditSink(sb.logger)
+}
+
+// audit 记录一次有副作用的操作，ctx 为执行上下文，err 为操作的结果
+// 不访问 JavaScript 运行时，可在异步宿主函数的 goroutine 中调用
+func (sb *Sandbox) audit(ctx context.Context, event AuditEvent, err error) {
+	event.Success = err == nil
+	if err != nil {
+		event.Error = err.Error()
+		var sbErr *SandboxError
+		if errors.As(err, &sbErr) {
+			event.Code = sbErr.Code
+		}
+	}
+	sb.emitAudit(ctx, event)
+}
+
+// auditMap 在宿主函数返回后按 { success, error, code } 结果对象记录操作，
+// 在宿主函数开头使用：defer sb.auditMap(event, &result)
+func (sb *Sandbox) auditMap(event AuditEvent, result *map[string]interface{}) {
+	sb.auditResult(event, *result)
+}
+
+// auditError 与 auditMap 相同，用于以 error 报告失败的宿主函数
+func (sb *Sandbox) auditError(event AuditEvent, err *error) {
+	sb.audit(sb.runContext(), event, *err)
+}
+
+// auditValue 与 auditMap 相同，结果为 JavaScript 值，不是结果对象时视为成功
+func (sb *Sandbox) auditValue(event AuditEvent, result *goja.Value) {
+	if *result == nil {
+		sb.auditResult(event, nil)
+		return
+	}
+	r, ok := (*result).Export().(map[string]interface{})
+	if !ok {
+		r = map[string]interface{}{"success": true}
+	}
+	sb.auditResult(event, r)
+}
+
+// auditResult 见 auditMap，结果为 nil 表示宿主函数抛出了异常
+func (sb *Sandbox) auditResult(event AuditEvent, r map[string]interface{}) {
+	event.Success, _ = r["success"].(bool)
+	if _, ok := r["success"]; !ok && r != nil && r["error"] == nil {
+		event.Success = true
+	}
+	if !event.Success {
+		event.Error = "宿主函数抛出异常"
+		if e, ok := r["error"]; ok {
+			event.Error = fmt.Sprint(e)
+		}
+		if code, ok := r["code"].(string); ok {
+			event.Code = ErrorCode(code)
+		}
+	}
+	sb.emitAudit(sb.runContext(), event)
+}
+
+// emitAudit 补充执行 ID 和时间后发送审计记录
+func (sb *Sandbox) emitAudit(ctx context.Context, event AuditEvent) {
+	event.RunID = RunIDFromContext(ctx)
+	event.Time = time.Now()
+	sb.auditSink().Audit(event)
+}
@@ -0,0 +1,150 @@
+package jssandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// auditRecorder 收集审计记录，用于测试
+type auditRecorder struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (r *auditRecorder) Audit(event AuditEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *auditRecorder) list() []AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AuditEvent(nil), r.events...)
+}
+
+func TestAudit_FileSystem(t *testing.T) {
+	rec := &auditRecorder{}
+	config := DefaultConfig().WithAuditSink(rec).WithFileSystemRoot(t.TempDir()).WithReadOnlyMount("/ro", t.TempDir())
+	sb := NewSandboxWithConfig(context.Background(), config)
+	defer sb.Close()
+
+	const path = "/a.txt"
+	if _, err := sb.Run(`writeFile('/a.txt', 'hello'); readFile('/a.txt'); deleteFile('/a.txt'); writeFile('/ro/a.txt', 'x')`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := sb.Run(`appendFile('/a.txt', 'abc')`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	events := rec.list()
+	if len(events) != 4 {
+		t.Fatalf("审计记录 = %+v", events)
+	}
+	expected := []struct {
+		op       string
+		target   string
+		bytesOut int64
+		success  bool
+		code     ErrorCode
+	}{
+		{"writeFile", path, 5, true, ""},
+		{"deleteFile", path, 0, true, ""},
+		{"writeFile", "/ro/a.txt", 1, false, ErrCodeAccessDenied},
+		{"appendFile", path, 3, true, ""},
+	}
+	for i, want := range expected {
+		got := events[i]
+		if got.Module != "fs" || got.Operation != want.op || got.Target != want.target || got.BytesOut != want.bytesOut ||
+			got.Success != want.success || got.Code != want.code {
+			t.Errorf("记录 %d = %+v, want %+v", i, got, want)
+		}
+		if got.RunID == "" || got.Time.IsZero() {
+			t.Errorf("记录 %d 缺少执行 ID 或时间: %+v", i, got)
+		}
+	}
+	if events[2].Error == "" {
+		t.Error("失败的记录应包含错误信息")
+	}
+	if events[0].RunID != events[2].RunID || events[0].RunID == events[3].RunID {
+		t.Errorf("同一次执行的记录应共享执行 ID，不同执行不同: %q %q %q", events[0].RunID, events[2].RunID, events[3].RunID)
+	}
+}
+
+func TestAudit_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created!"))
+	}))
+	defer server.Close()
+
+	events := make(chan AuditEvent, 4)
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithPrivateNetwork(true).WithAuditSink(ChannelAuditSink(events)))
+	defer sb.Close()
+	sb.Set("url", server.URL)
+
+	if _, err := sb.Run(`httpPost(url, '{"a":1}')`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := sb.Run(`fetch(url + '/async').then(r => r.text())`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	close(events)
+
+	var got []AuditEvent
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 2 {
+		t.Fatalf("审计记录 = %+v", got)
+	}
+	post := got[0]
+	if post.Module != "http" || post.Operation != "httpRequest" || post.Target != server.URL || !post.Success ||
+		post.BytesOut != 7 || post.BytesIn != 8 || post.Details["method"] != "POST" || post.Details["status"] != http.StatusCreated {
+		t.Errorf("httpPost 记录 = %+v", post)
+	}
+	if fetch := got[1]; fetch.Operation != "fetch" || fetch.Target != server.URL+"/async" || fetch.RunID == "" || fetch.RunID == post.RunID {
+		t.Errorf("fetch 记录 = %+v", fetch)
+	}
+}
+
+func TestAudit_JSONLAndDefault(t *testing.T) {
+	var buf bytes.Buffer
+	config := DefaultConfig().WithAuditSink(NewJSONLAuditSink(&buf)).WithFileSystemRoot(t.TempDir())
+	sb := NewSandboxWithConfig(context.Background(), config)
+	defer sb.Close()
+
+	if _, err := sb.Run(`makeDir('/sub'); removeDir('/sub')`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("JSONL 输出 = %q", buf.String())
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatalf("解析 JSONL 失败: %v", err)
+	}
+	if event["operation"] != "removeDir" || event["target"] != "/sub" || event["success"] != true || event["run_id"] == "" {
+		t.Errorf("JSONL 记录 = %v", event)
+	}
+
+	// 未配置接收器时写入沙盒的 logger
+	var logs bytes.Buffer
+	logger := NewLogger()
+	logger.SetOutput(&logs)
+	sb2 := NewSandboxWithLoggerAndConfig(context.Background(), logger, DefaultConfig().WithFileSystemRoot(t.TempDir()))
+	defer sb2.Close()
+	if _, err := sb2.Run(`writeFile('/b.txt', 'x')`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if out := logs.String(); !strings.Contains(out, "审计: 操作完成") || !strings.Contains(out, "writeFile") {
+		t.Errorf("日志输出 = %q", out)
+	}
+}
@@ -2,11 +2,15 @@ package jssandbox
 
 import (
 	"context"
-	"os"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 	"github.com/dop251/goja"
 	"github.com/sirupsen/logrus"
@@ -19,6 +23,7 @@ type BrowserSession struct {
 	sb      *Sandbox
 	mu      sync.Mutex
 	closed  bool
+	started bool
 	timeout time.Duration
 }
 
@@ -31,12 +36,13 @@ func init() {
 
 // getOrCreateBrowserAllocator 获取或创建共享的浏览器 allocator
 // 这确保所有会话使用同一个浏览器进程，避免打开多个窗口
-func (sb *Sandbox) getOrCreateBrowserAllocator() context.Context {
+// 第二个返回值表示浏览器是否通过出站代理联网
+func (sb *Sandbox) getOrCreateBrowserAllocator() (context.Context, bool) {
 	sb.browserMu.Lock()
 	defer sb.browserMu.Unlock()
 
 	if sb.browserInit && sb.browserAllocator != nil {
-		return sb.browserAllocator
+		return sb.browserAllocator, sb.browserProxy != nil
 	}
 
 	// 创建 allocator 选项，配置反检测参数
@@ -56,12 +62,24 @@ func (sb *Sandbox) getOrCreateBrowserAllocator() context.Context {
 		opts = append(opts, chromedp.Flag("disable-gpu", true))
 	}
 
+	// 浏览器的所有连接都经过本地出站代理，由代理在连接时检查实际地址
+	proxy, err := startEgressProxy(sb.egress)
+	if err != nil {
+		sb.logger.WithError(err).Error("启动浏览器出站代理失败，浏览器将无法访问网络")
+	} else {
+		sb.browserProxy = proxy
+		opts = append(opts,
+			chromedp.ProxyServer("http://"+proxy.Addr()),
+			chromedp.Flag("proxy-bypass-list", "<-loopback>"), // 回环地址默认不走代理，取消该例外
+		)
+	}
+
 	allocCtx, cancel := chromedp.NewExecAllocator(sb.ctx, opts...)
 	sb.browserAllocator = allocCtx
 	sb.browserCancel = cancel
 	sb.browserInit = true
 
-	return allocCtx
+	return allocCtx, sb.browserProxy != nil
 }
 
 // createBrowserContext 创建配置了反检测选项的浏览器上下文
@@ -69,14 +87,77 @@ func (sb *Sandbox) getOrCreateBrowserAllocator() context.Context {
 // 使用共享的 allocator，确保只打开一个浏览器窗口
 func (sb *Sandbox) createBrowserContext() (context.Context, context.CancelFunc) {
 	// 获取或创建共享的 allocator（浏览器进程）
-	allocCtx := sb.getOrCreateBrowserAllocator()
+	allocCtx, proxied := sb.getOrCreateBrowserAllocator()
 
 	// 为每个会话创建新的 context（标签页），但共享同一个 allocator（浏览器进程）
 	ctx, cancel := chromedp.NewContext(allocCtx)
+	sb.interceptBrowserRequests(ctx, proxied)
 
 	return ctx, cancel
 }
 
+// checkBrowserURL 按出站策略检查浏览器请求的 URL
+// about:、data:、blob: 不产生网络请求，始终允许
+func (sb *Sandbox) checkBrowserURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return NewSandboxErrorWithCause(ErrCodeInvalidInput, "无效的URL", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "about", "data", "blob":
+		return nil
+	}
+	return sb.egress.checkURL(u)
+}
+
+// interceptBrowserRequests 通过 Fetch 域拦截标签页的所有请求（包括重定向），不符合出站策略的请求直接失败
+// 出站代理不可用时拒绝所有请求，避免浏览器绕过连接地址检查
+func (sb *Sandbox) interceptBrowserRequests(ctx context.Context, proxied bool) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		e, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// 监听回调中不能阻塞，命令需要在新的 goroutine 中执行
+		go func() {
+			c := chromedp.FromContext(ctx)
+			if c == nil || c.Target == nil {
+				return
+			}
+			execCtx := cdp.WithExecutor(ctx, c.Target)
+			err := sb.checkBrowserURL(e.Request.URL)
+			if err == nil && !proxied {
+				err = newNetworkPolicyError("浏览器出站代理不可用")
+			}
+			if err != nil {
+				sb.logger.WithError(err).WithField("url", e.Request.URL).Warn("浏览器请求被拒绝")
+				_ = fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(execCtx)
+				return
+			}
+			_ = fetch.ContinueRequest(e.RequestID).Do(execCtx)
+		}()
+	})
+}
+
+// enableRequestInterception 启用请求拦截
+// WebSocket 连接不经过 Fetch 拦截，未允许 ws/wss 协议时直接屏蔽
+func (sb *Sandbox) enableRequestInterception() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var blocked []string
+		for _, scheme := range []string{"ws", "wss"} {
+			if !sb.egress.schemes[scheme] {
+				blocked = append(blocked, scheme+"://*")
+			}
+		}
+		if len(blocked) > 0 {
+			if err := network.SetBlockedURLS(blocked).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: "*"}}).Do(ctx)
+	})
+}
+
 // injectStealthScript 注入反检测脚本，隐藏webdriver特征
 // 在页面加载后立即执行，修改navigator对象
 func injectStealthScript() chromedp.Action {
@@ -134,6 +215,22 @@ func (sb *Sandbox) createBrowserSession(timeoutSeconds float64) *BrowserSession
 		sb:      sb,
 		timeout: timeout,
 	}
+	// 会话的上下文在关闭、超时或沙盒关闭时结束，此时会话不再可用
+	sb.browserMu.Lock()
+	if sb.browserSessions == nil {
+		sb.browserSessions = make(map[*BrowserSession]struct{})
+	}
+	sb.browserSessions[session] = struct{}{}
+	sb.browserMu.Unlock()
+	context.AfterFunc(ctx, func() {
+		sb.browserMu.Lock()
+		delete(sb.browserSessions, session)
+		sb.browserMu.Unlock()
+	})
+	if m := sb.config.Metrics; m != nil {
+		m.addBrowserSessions(1)
+		context.AfterFunc(ctx, func() { m.addBrowserSessions(-1) })
+	}
 
 	// chromedp.NewContext 创建后，浏览器会在第一次执行操作时自动启动
 	// 不需要提前初始化，让第一次导航时自动触发浏览器启动
@@ -141,6 +238,19 @@ func (sb *Sandbox) createBrowserSession(timeoutSeconds float64) *BrowserSession
 	return session
 }
 
+// closeBrowserSessions 关闭脚本打开的所有浏览器会话
+func (sb *Sandbox) closeBrowserSessions() {
+	sb.browserMu.Lock()
+	sessions := make([]*BrowserSession, 0, len(sb.browserSessions))
+	for session := range sb.browserSessions {
+		sessions = append(sessions, session)
+	}
+	sb.browserMu.Unlock()
+	for _, session := range sessions {
+		session.Close()
+	}
+}
+
 // Close 关闭浏览器会话并清理资源
 func (bs *BrowserSession) Close() {
 	bs.mu.Lock()
@@ -151,8 +261,44 @@ func (bs *BrowserSession) Close() {
 	}
 }
 
+// actionContext 返回单次浏览器操作使用的上下文
+// 它派生自会话上下文，并在沙盒当前执行超时或被取消时一并取消，
+// 从而中止正在进行的浏览器操作
+func (bs *BrowserSession) actionContext() (context.Context, context.CancelFunc, error) {
+	// chromedp 会把首次 Run 时传入的上下文作为标签页的生命周期，
+	// 因此必须先在会话上下文上启动标签页，之后的操作才能使用可取消的子上下文
+	if !bs.started {
+		if err := chromedp.Run(bs.ctx, bs.sb.enableRequestInterception()); err != nil {
+			return nil, nil, err
+		}
+		bs.started = true
+	}
+
+	ctx, cancel := context.WithCancel(bs.ctx)
+	stop := context.AfterFunc(bs.sb.runContext(), cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}, nil
+}
+
+// beginAction 返回单次浏览器操作使用的上下文和取消函数（见 actionContext），
+// 启动浏览器失败时记录日志并返回错误结果
+func (bs *BrowserSession) beginAction() (context.Context, context.CancelFunc, map[string]interface{}) {
+	ctx, cancel, err := bs.actionContext()
+	if err != nil {
+		bs.sb.logger.WithError(err).Error("启动浏览器失败")
+		return nil, nil, map[string]interface{}{
+			"success": false,
+			"error":   "启动浏览器失败: " + err.Error(),
+		}
+	}
+	return ctx, cancel, nil
+}
+
 // Navigate 导航到指定URL
-func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
+func (bs *BrowserSession) Navigate(url string) (result map[string]interface{}) {
+	defer bs.sb.auditMap(AuditEvent{Module: "browser", Operation: "session.navigate", Target: url}, &result)
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
 
@@ -163,12 +309,23 @@ func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
 		}
 	}
 
+	if err := bs.sb.checkBrowserURL(url); err != nil {
+		bs.sb.logger.WithError(err).WithField("url", url).Warn("导航被拒绝")
+		return bs.sb.errorResult(err)
+	}
+
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
 	bs.sb.logger.WithField("url", url).Debug("开始导航到页面")
 
 	// 执行导航（使用会话的上下文，会话已经有超时设置）
 	// chromedp.Navigate 会自动等待浏览器准备好
 	// 第一次执行时会自动启动浏览器进程
-	err := chromedp.Run(bs.ctx,
+	err := chromedp.Run(actionCtx,
 		// 先等待一小段时间，确保浏览器进程已启动（如果是第一次）
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			time.Sleep(500 * time.Millisecond)
@@ -177,10 +334,15 @@ func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
 		chromedp.Navigate(url),
 	)
 	if err != nil {
+		// 请求被拦截时 Chrome 返回 net::ERR_BLOCKED_BY_CLIENT，如重定向到被禁止的地址
+		if strings.Contains(err.Error(), "ERR_BLOCKED_BY_CLIENT") {
+			bs.sb.logger.WithError(err).WithField("url", url).Warn("导航被网络出站策略拒绝")
+			return bs.sb.errorResult(newNetworkPolicyError("导航被网络出站策略拒绝: %s", url))
+		}
 		bs.sb.logger.WithError(err).WithField("url", url).Error("浏览器导航失败")
 		// 检查当前URL，看是否至少导航到了某个页面
 		var currentURL string
-		if getURLErr := chromedp.Run(bs.ctx, chromedp.Location(&currentURL)); getURLErr == nil {
+		if getURLErr := chromedp.Run(actionCtx, chromedp.Location(&currentURL)); getURLErr == nil {
 			bs.sb.logger.WithField("url", url).WithField("currentURL", currentURL).Debug("导航失败后的当前URL")
 			if currentURL != "" && currentURL != "about:blank" {
 				// 如果已经导航到某个页面（即使不是目标页面），也算部分成功
@@ -199,7 +361,7 @@ func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
 	bs.sb.logger.WithField("url", url).Debug("导航命令已执行，等待页面加载")
 
 	// 等待页面加载完成（使用较短的超时，避免阻塞太久）
-	waitCtx, waitCancel := context.WithTimeout(bs.ctx, 20*time.Second)
+	waitCtx, waitCancel := context.WithTimeout(actionCtx, 20*time.Second)
 	defer waitCancel()
 
 	// 等待body元素出现，并检查URL是否改变
@@ -212,7 +374,7 @@ func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
 	if err != nil {
 		bs.sb.logger.WithError(err).WithField("url", url).Warn("等待页面加载超时，尝试获取当前URL")
 		// 即使等待失败，也尝试获取URL
-		_ = chromedp.Run(bs.ctx, chromedp.Location(&currentURL))
+		_ = chromedp.Run(actionCtx, chromedp.Location(&currentURL))
 	}
 
 	bs.sb.logger.WithField("url", url).WithField("currentURL", currentURL).Debug("导航后的当前URL")
@@ -222,7 +384,7 @@ func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
 		bs.sb.logger.WithField("url", url).Warn("导航后仍在 about:blank，尝试重新导航...")
 
 		// 再次尝试导航（使用较短的超时）
-		retryCtx, retryCancel := context.WithTimeout(bs.ctx, 20*time.Second)
+		retryCtx, retryCancel := context.WithTimeout(actionCtx, 20*time.Second)
 		defer retryCancel()
 
 		err = chromedp.Run(retryCtx,
@@ -249,7 +411,7 @@ func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
 	}
 
 	// 等待页面readyState至少为interactive
-	readyCtx, readyCancel := context.WithTimeout(bs.ctx, 10*time.Second)
+	readyCtx, readyCancel := context.WithTimeout(actionCtx, 10*time.Second)
 	var readyState string
 	_ = chromedp.Run(readyCtx, chromedp.Evaluate("document.readyState", &readyState))
 	readyCancel()
@@ -258,15 +420,15 @@ func (bs *BrowserSession) Navigate(url string) map[string]interface{} {
 	}
 
 	// 等待一小段时间让JavaScript执行
-	_ = chromedp.Run(bs.ctx, chromedp.Sleep(1*time.Second))
+	_ = chromedp.Run(actionCtx, chromedp.Sleep(1*time.Second))
 
 	bs.sb.logger.WithField("url", url).Debug("页面基本加载完成")
 
 	// 注入反检测脚本（失败不影响导航结果）
-	_ = chromedp.Run(bs.ctx, injectStealthScript())
+	_ = chromedp.Run(actionCtx, injectStealthScript())
 
 	// 再次确认URL（防止在等待过程中URL改变）
-	_ = chromedp.Run(bs.ctx, chromedp.Location(&currentURL))
+	_ = chromedp.Run(actionCtx, chromedp.Location(&currentURL))
 	bs.sb.logger.WithField("url", url).WithField("currentURL", currentURL).Debug("导航完成，最终URL")
 
 	return map[string]interface{}{
@@ -287,16 +449,22 @@ func (bs *BrowserSession) Wait(selectorOrSeconds interface{}) map[string]interfa
 		}
 	}
 
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
 	var err error
 	switch v := selectorOrSeconds.(type) {
 	case string:
 		// 等待元素出现
-		err = chromedp.Run(bs.ctx,
+		err = chromedp.Run(actionCtx,
 			chromedp.WaitVisible(v, chromedp.ByQuery),
 		)
 	case float64:
 		// 等待指定秒数
-		err = chromedp.Run(bs.ctx,
+		err = chromedp.Run(actionCtx,
 			chromedp.Sleep(time.Duration(v*float64(time.Second))),
 		)
 	default:
@@ -308,10 +476,7 @@ func (bs *BrowserSession) Wait(selectorOrSeconds interface{}) map[string]interfa
 
 	if err != nil {
 		bs.sb.logger.WithError(err).Error("等待操作失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	return map[string]interface{}{
@@ -320,7 +485,8 @@ func (bs *BrowserSession) Wait(selectorOrSeconds interface{}) map[string]interfa
 }
 
 // Click 点击指定选择器的元素
-func (bs *BrowserSession) Click(selector string) map[string]interface{} {
+func (bs *BrowserSession) Click(selector string) (result map[string]interface{}) {
+	defer bs.sb.auditMap(AuditEvent{Module: "browser", Operation: "session.click", Target: selector}, &result)
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
 
@@ -331,17 +497,20 @@ func (bs *BrowserSession) Click(selector string) map[string]interface{} {
 		}
 	}
 
-	err := chromedp.Run(bs.ctx,
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
+	err := chromedp.Run(actionCtx,
 		chromedp.WaitVisible(selector, chromedp.ByQuery),
 		chromedp.Click(selector, chromedp.ByQuery),
 	)
 
 	if err != nil {
 		bs.sb.logger.WithError(err).WithField("selector", selector).Error("点击元素失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	return map[string]interface{}{
@@ -350,7 +519,8 @@ func (bs *BrowserSession) Click(selector string) map[string]interface{} {
 }
 
 // Fill 填充表单字段
-func (bs *BrowserSession) Fill(selector, value string) map[string]interface{} {
+func (bs *BrowserSession) Fill(selector, value string) (result map[string]interface{}) {
+	defer bs.sb.auditMap(AuditEvent{Module: "browser", Operation: "session.fill", Target: selector, BytesOut: int64(len(value))}, &result)
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
 
@@ -361,7 +531,13 @@ func (bs *BrowserSession) Fill(selector, value string) map[string]interface{} {
 		}
 	}
 
-	err := chromedp.Run(bs.ctx,
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
+	err := chromedp.Run(actionCtx,
 		chromedp.WaitVisible(selector, chromedp.ByQuery),
 		chromedp.Clear(selector, chromedp.ByQuery),
 		chromedp.SendKeys(selector, value, chromedp.ByQuery),
@@ -369,10 +545,7 @@ func (bs *BrowserSession) Fill(selector, value string) map[string]interface{} {
 
 	if err != nil {
 		bs.sb.logger.WithError(err).WithField("selector", selector).Error("填充表单失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	return map[string]interface{}{
@@ -381,7 +554,8 @@ func (bs *BrowserSession) Fill(selector, value string) map[string]interface{} {
 }
 
 // Evaluate 在页面中执行JavaScript代码
-func (bs *BrowserSession) Evaluate(jsCode string) map[string]interface{} {
+func (bs *BrowserSession) Evaluate(jsCode string) (result map[string]interface{}) {
+	defer bs.sb.auditMap(AuditEvent{Module: "browser", Operation: "session.evaluate", Target: jsCode}, &result)
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
 
@@ -392,22 +566,25 @@ func (bs *BrowserSession) Evaluate(jsCode string) map[string]interface{} {
 		}
 	}
 
-	var result interface{}
-	err := chromedp.Run(bs.ctx,
-		chromedp.Evaluate(jsCode, &result),
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
+	var value interface{}
+	err := chromedp.Run(actionCtx,
+		chromedp.Evaluate(jsCode, &value),
 	)
 
 	if err != nil {
 		bs.sb.logger.WithError(err).Error("执行浏览器脚本失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	return map[string]interface{}{
 		"success": true,
-		"result":  result,
+		"result":  value,
 	}
 }
 
@@ -423,17 +600,20 @@ func (bs *BrowserSession) GetHTML() map[string]interface{} {
 		}
 	}
 
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
 	var html string
-	err := chromedp.Run(bs.ctx,
+	err := chromedp.Run(actionCtx,
 		chromedp.OuterHTML("html", &html),
 	)
 
 	if err != nil {
 		bs.sb.logger.WithError(err).Error("获取HTML失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	return map[string]interface{}{
@@ -443,7 +623,11 @@ func (bs *BrowserSession) GetHTML() map[string]interface{} {
 }
 
 // Screenshot 截取当前页面截图
-func (bs *BrowserSession) Screenshot(outputPath string) map[string]interface{} {
+func (bs *BrowserSession) Screenshot(outputPath string) (result map[string]interface{}) {
+	var buf []byte
+	defer func() {
+		bs.sb.auditMap(AuditEvent{Module: "browser", Operation: "session.screenshot", Target: outputPath, BytesOut: int64(len(buf))}, &result)
+	}()
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
 
@@ -454,17 +638,24 @@ func (bs *BrowserSession) Screenshot(outputPath string) map[string]interface{} {
 		}
 	}
 
-	var buf []byte
-	err := chromedp.Run(bs.ctx,
+	hostPath, err := bs.sb.resolvePath(outputPath, fsWrite)
+	if err != nil {
+		return bs.sb.errorResult(err)
+	}
+
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
+	err = chromedp.Run(actionCtx,
 		chromedp.CaptureScreenshot(&buf),
 	)
 
 	if err != nil {
 		bs.sb.logger.WithError(err).Error("截图失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	// 检查截图数据是否为空
@@ -477,9 +668,9 @@ func (bs *BrowserSession) Screenshot(outputPath string) map[string]interface{} {
 	}
 
 	// 确保输出目录存在
-	dir := filepath.Dir(outputPath)
+	dir := filepath.Dir(hostPath)
 	if dir != "." && dir != "" {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := bs.sb.fs.MkdirAll(dir, 0755); err != nil {
 			bs.sb.logger.WithError(err).WithField("dir", dir).Error("创建目录失败")
 			return map[string]interface{}{
 				"success": false,
@@ -488,8 +679,12 @@ func (bs *BrowserSession) Screenshot(outputPath string) map[string]interface{} {
 		}
 	}
 
+	if err := bs.sb.checkFileWrite(hostPath, buf); err != nil {
+		return bs.sb.errorResult(err)
+	}
+
 	// 保存截图
-	err = os.WriteFile(outputPath, buf, 0644)
+	err = writeFileFS(bs.sb.fs, hostPath, buf, 0644)
 	if err != nil {
 		bs.sb.logger.WithError(err).WithField("path", outputPath).Error("保存截图文件失败")
 		return map[string]interface{}{
@@ -499,7 +694,7 @@ func (bs *BrowserSession) Screenshot(outputPath string) map[string]interface{} {
 	}
 
 	// 验证文件是否真的被写入
-	if fileInfo, err := os.Stat(outputPath); err != nil {
+	if fileInfo, err := bs.sb.fs.Stat(hostPath); err != nil {
 		bs.sb.logger.WithError(err).WithField("path", outputPath).Error("验证截图文件失败")
 		return map[string]interface{}{
 			"success": false,
@@ -531,17 +726,20 @@ func (bs *BrowserSession) GetURL() map[string]interface{} {
 		}
 	}
 
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
 	var url string
-	err := chromedp.Run(bs.ctx,
+	err := chromedp.Run(actionCtx,
 		chromedp.Location(&url),
 	)
 
 	if err != nil {
 		bs.sb.logger.WithError(err).Error("获取URL失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	return map[string]interface{}{
@@ -562,12 +760,18 @@ func (bs *BrowserSession) WaitForURL(pattern string, timeoutSeconds float64) map
 		}
 	}
 
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
 	timeout := 10 * time.Second
 	if timeoutSeconds > 0 {
 		timeout = time.Duration(timeoutSeconds * float64(time.Second))
 	}
 
-	ctx, cancel := context.WithTimeout(bs.ctx, timeout)
+	ctx, cancel := context.WithTimeout(actionCtx, timeout)
 	defer cancel()
 
 	startTime := time.Now()
@@ -585,10 +789,7 @@ func (bs *BrowserSession) WaitForURL(pattern string, timeoutSeconds float64) map
 		// 获取当前URL
 		err := chromedp.Run(ctx, chromedp.Location(&url))
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return bs.sb.errorResult(err)
 		}
 
 		// 检查URL是否包含模式
@@ -626,12 +827,18 @@ func (bs *BrowserSession) WaitForText(text string, timeoutSeconds float64) map[s
 		}
 	}
 
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
 	timeout := 10 * time.Second
 	if timeoutSeconds > 0 {
 		timeout = time.Duration(timeoutSeconds * float64(time.Second))
 	}
 
-	ctx, cancel := context.WithTimeout(bs.ctx, timeout)
+	ctx, cancel := context.WithTimeout(actionCtx, timeout)
 	defer cancel()
 
 	// 转义文本中的单引号，避免JavaScript注入
@@ -656,6 +863,13 @@ func (bs *BrowserSession) WaitForText(text string, timeoutSeconds float64) map[s
 				"error":   "等待文本超时: " + text,
 			}
 		}
+		// 沙盒执行被中断时不再继续等待
+		if actionCtx.Err() != nil {
+			return map[string]interface{}{
+				"success": false,
+				"error":   actionCtx.Err().Error(),
+			}
+		}
 
 		// 执行JavaScript检查文本是否存在
 		var result bool
@@ -678,7 +892,8 @@ func (bs *BrowserSession) WaitForText(text string, timeoutSeconds float64) map[s
 }
 
 // Clear 清空指定输入框的内容
-func (bs *BrowserSession) Clear(selector string) map[string]interface{} {
+func (bs *BrowserSession) Clear(selector string) (result map[string]interface{}) {
+	defer bs.sb.auditMap(AuditEvent{Module: "browser", Operation: "session.clear", Target: selector}, &result)
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
 
@@ -689,17 +904,20 @@ func (bs *BrowserSession) Clear(selector string) map[string]interface{} {
 		}
 	}
 
-	err := chromedp.Run(bs.ctx,
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
+	err := chromedp.Run(actionCtx,
 		chromedp.WaitVisible(selector, chromedp.ByQuery),
 		chromedp.Clear(selector, chromedp.ByQuery),
 	)
 
 	if err != nil {
 		bs.sb.logger.WithError(err).WithField("selector", selector).Error("清空输入框失败")
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return bs.sb.errorResult(err)
 	}
 
 	return map[string]interface{}{
@@ -708,7 +926,8 @@ func (bs *BrowserSession) Clear(selector string) map[string]interface{} {
 }
 
 // Submit 提交表单（通过点击提交按钮或按Enter键）
-func (bs *BrowserSession) Submit(selector string) map[string]interface{} {
+func (bs *BrowserSession) Submit(selector string) (result map[string]interface{}) {
+	defer bs.sb.auditMap(AuditEvent{Module: "browser", Operation: "session.submit", Target: selector}, &result)
 	bs.mu.Lock()
 	defer bs.mu.Unlock()
 
@@ -719,6 +938,13 @@ func (bs *BrowserSession) Submit(selector string) map[string]interface{} {
 		}
 	}
 
+	actionCtx, cancelAction, failed := bs.beginAction()
+	if failed != nil {
+		return failed
+	}
+	defer cancelAction()
+
+	var err error
 	// 如果selector为空，尝试提交当前表单（通过JavaScript模拟Enter键）
 	if selector == "" {
 		jsCode := `
@@ -731,28 +957,22 @@ func (bs *BrowserSession) Submit(selector string) map[string]interface{} {
 			});
 			document.activeElement && document.activeElement.dispatchEvent(event);
 		`
-		err := chromedp.Run(bs.ctx,
+		err = chromedp.Run(actionCtx,
 			chromedp.Evaluate(jsCode, nil),
 		)
 		if err != nil {
 			bs.sb.logger.WithError(err).Error("提交表单失败")
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return bs.sb.errorResult(err)
 		}
 	} else {
 		// 点击提交按钮
-		err := chromedp.Run(bs.ctx,
+		err = chromedp.Run(actionCtx,
 			chromedp.WaitVisible(selector, chromedp.ByQuery),
 			chromedp.Click(selector, chromedp.ByQuery),
 		)
 		if err != nil {
 			bs.sb.logger.WithError(err).WithField("selector", selector).Error("点击提交按钮失败")
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return bs.sb.errorResult(err)
 		}
 	}
 
@@ -0,0 +1,552 @@
+package jssandbox
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/dop251/goja"
+)
+
+// bufferSource 定义与 Node.js 兼容的 Buffer 类（Uint8Array 的子类），utf8、hex、base64 编解码由 native 中的宿主函数完成
+const bufferSource = `(function (native) {
+	'use strict';
+
+	var encodings = {
+		utf8: 'utf8', 'utf-8': 'utf8', hex: 'hex', base64: 'base64', base64url: 'base64url',
+		latin1: 'latin1', binary: 'latin1', ascii: 'ascii',
+		ucs2: 'utf16le', 'ucs-2': 'utf16le', utf16le: 'utf16le', 'utf-16le': 'utf16le'
+	};
+	var toTag = Object.prototype.toString;
+	var chunkSize = 4096;
+
+	function normalize(encoding) {
+		if (encoding === undefined || encoding === null) {
+			return 'utf8';
+		}
+		var e = encodings[String(encoding).toLowerCase()];
+		if (e === undefined) {
+			var err = new TypeError('Unknown encoding: ' + encoding);
+			err.code = 'ERR_UNKNOWN_ENCODING';
+			throw err;
+		}
+		return e;
+	}
+
+	function rangeError(message) {
+		var err = new RangeError(message);
+		err.code = 'ERR_OUT_OF_RANGE';
+		return err;
+	}
+
+	function checkSize(size) {
+		if (typeof size !== 'number' || size < 0 || size !== size) {
+			var err = new TypeError('The "size" argument must be of type number and a non-negative value. Received ' + String(size));
+			err.code = 'ERR_INVALID_ARG_TYPE';
+			throw err;
+		}
+		return Math.floor(size);
+	}
+
+	// encode 把字符串按编码转换为字节
+	function encode(string, encoding) {
+		var out, i, c;
+		switch (encoding) {
+		case 'latin1':
+		case 'ascii':
+			out = new Uint8Array(string.length);
+			for (i = 0; i < string.length; i++) {
+				out[i] = string.charCodeAt(i);
+			}
+			return out;
+		case 'utf16le':
+			out = new Uint8Array(string.length * 2);
+			for (i = 0; i < string.length; i++) {
+				c = string.charCodeAt(i);
+				out[i * 2] = c & 0xff;
+				out[i * 2 + 1] = c >> 8;
+			}
+			return out;
+		default:
+			return new Uint8Array(native.encode(string, encoding));
+		}
+	}
+
+	// decode 把字节按编码转换为字符串
+	function decode(bytes, encoding) {
+		var parts = [], i, j, codes;
+		switch (encoding) {
+		case 'latin1':
+		case 'ascii':
+			for (i = 0; i < bytes.length; i += chunkSize) {
+				codes = Array.prototype.slice.call(bytes.subarray(i, i + chunkSize));
+				if (encoding === 'ascii') {
+					codes = codes.map(function (b) { return b & 0x7f; });
+				}
+				parts.push(String.fromCharCode.apply(null, codes));
+			}
+			return parts.join('');
+		case 'utf16le':
+			for (i = 0; i + 1 < bytes.length; i += chunkSize * 2) {
+				codes = [];
+				for (j = i; j + 1 < bytes.length && j < i + chunkSize * 2; j += 2) {
+					codes.push(bytes[j] | (bytes[j + 1] << 8));
+				}
+				parts.push(String.fromCharCode.apply(null, codes));
+			}
+			return parts.join('');
+		default:
+			return native.decode(bytes, encoding);
+		}
+	}
+
+	function indexOf(haystack, needle, from) {
+		outer:
+		for (var i = from; i <= haystack.length - needle.length; i++) {
+			for (var j = 0; j < needle.length; j++) {
+				if (haystack[i + j] !== needle[j]) {
+					continue outer;
+				}
+			}
+			return i;
+		}
+		return -1;
+	}
+
+	function compare(a, b) {
+		var n = Math.min(a.length, b.length);
+		for (var i = 0; i < n; i++) {
+			if (a[i] !== b[i]) {
+				return a[i] < b[i] ? -1 : 1;
+			}
+		}
+		return a.length === b.length ? 0 : (a.length < b.length ? -1 : 1);
+	}
+
+	function toBytes(value, encoding) {
+		if (typeof value === 'string') {
+			return encode(value, normalize(encoding));
+		}
+		if (value instanceof Uint8Array) {
+			return value;
+		}
+		if (ArrayBuffer.isView(value)) {
+			return new Uint8Array(value.buffer, value.byteOffset, value.byteLength);
+		}
+		var err = new TypeError('The "value" argument must be one of type string, Buffer, or Uint8Array.');
+		err.code = 'ERR_INVALID_ARG_TYPE';
+		throw err;
+	}
+
+	class Buffer extends Uint8Array {
+		static from(value, encodingOrOffset, length) {
+			if (typeof value === 'string') {
+				return fromBytes(encode(value, normalize(encodingOrOffset)));
+			}
+			var tag = toTag.call(value);
+			if (tag === '[object ArrayBuffer]' || tag === '[object SharedArrayBuffer]') {
+				// 与 ArrayBuffer 共享内存
+				var offset = encodingOrOffset === undefined ? 0 : Math.floor(Number(encodingOrOffset)) || 0;
+				if (offset < 0 || offset > value.byteLength) {
+					throw rangeError('"offset" is outside of buffer bounds');
+				}
+				var len = length === undefined ? value.byteLength - offset : Math.floor(Number(length)) || 0;
+				if (len < 0 || offset + len > value.byteLength) {
+					throw rangeError('"length" is outside of buffer bounds');
+				}
+				return new Buffer(value, offset, len);
+			}
+			if (value instanceof Uint8Array) {
+				return fromBytes(value);
+			}
+			if (value !== null && typeof value === 'object') {
+				// 与 Node.js 一致，先按 valueOf 的结果转换（如 new String('abc')）
+				var valueOf = value.valueOf();
+				if (valueOf !== null && valueOf !== undefined && valueOf !== value) {
+					return Buffer.from(valueOf, encodingOrOffset, length);
+				}
+				if (value.type === 'Buffer' && Array.isArray(value.data)) {
+					return fromBytes(value.data);
+				}
+				if (typeof value.length === 'number' || ArrayBuffer.isView(value)) {
+					// 其他 TypedArray 和类数组对象按元素取值，截断为 0-255
+					return fromBytes(value);
+				}
+				if (typeof value[Symbol.toPrimitive] === 'function') {
+					var primitive = value[Symbol.toPrimitive]('string');
+					return Buffer.from(primitive, encodingOrOffset, length);
+				}
+			}
+			var err = new TypeError('The first argument must be of type string or an instance of Buffer, ArrayBuffer, or Array or an Array-like Object.');
+			err.code = 'ERR_INVALID_ARG_TYPE';
+			throw err;
+		}
+		static alloc(size, fill, encoding) {
+			var out = new Buffer(checkSize(size));
+			if (fill !== undefined && fill !== 0 && out.length > 0) {
+				out.fill(fill, encoding);
+			}
+			return out;
+		}
+		static allocUnsafe(size) {
+			return new Buffer(checkSize(size));
+		}
+		static allocUnsafeSlow(size) {
+			return new Buffer(checkSize(size));
+		}
+		static isBuffer(obj) {
+			return obj instanceof Buffer;
+		}
+		static isEncoding(encoding) {
+			return typeof encoding === 'string' && encodings[encoding.toLowerCase()] !== undefined;
+		}
+		static byteLength(value, encoding) {
+			if (typeof value !== 'string') {
+				if (ArrayBuffer.isView(value) || toTag.call(value) === '[object ArrayBuffer]') {
+					return value.byteLength;
+				}
+				var err = new TypeError('The "string" argument must be of type string or an instance of Buffer or ArrayBuffer.');
+				err.code = 'ERR_INVALID_ARG_TYPE';
+				throw err;
+			}
+			return encode(value, normalize(encoding)).length;
+		}
+		static concat(list, totalLength) {
+			if (!Array.isArray(list)) {
+				var err = new TypeError('The "list" argument must be an instance of Array.');
+				err.code = 'ERR_INVALID_ARG_TYPE';
+				throw err;
+			}
+			if (totalLength === undefined) {
+				totalLength = list.reduce(function (n, b) { return n + b.length; }, 0);
+			}
+			var out = Buffer.alloc(totalLength);
+			var pos = 0;
+			for (var i = 0; i < list.length && pos < out.length; i++) {
+				var b = toBytes(list[i]);
+				var n = Math.min(b.length, out.length - pos);
+				out.set(n < b.length ? b.subarray(0, n) : b, pos);
+				pos += n;
+			}
+			return out;
+		}
+		static compare(a, b) {
+			return compare(toBytes(a), toBytes(b));
+		}
+
+		get parent() { return this.buffer; }
+		get offset() { return this.byteOffset; }
+
+		toString(encoding, start, end) {
+			start = start === undefined ? 0 : Math.max(0, Math.floor(start) || 0);
+			end = end === undefined ? this.length : Math.min(this.length, Math.floor(end) || 0);
+			if (end <= start) {
+				return '';
+			}
+			return decode(this.subarray(start, end), normalize(encoding));
+		}
+		toLocaleString(encoding, start, end) {
+			return this.toString(encoding, start, end);
+		}
+		toJSON() {
+			return { type: 'Buffer', data: Array.prototype.slice.call(this) };
+		}
+		equals(other) {
+			return compare(this, toBytes(other)) === 0;
+		}
+		compare(target, targetStart, targetEnd, sourceStart, sourceEnd) {
+			target = toBytes(target);
+			return compare(
+				this.subarray(sourceStart || 0, sourceEnd === undefined ? this.length : sourceEnd),
+				target.subarray(targetStart || 0, targetEnd === undefined ? target.length : targetEnd));
+		}
+		write(string, offset, length, encoding) {
+			if (typeof offset === 'string') {
+				encoding = offset;
+				offset = 0;
+				length = this.length;
+			} else if (typeof length === 'string') {
+				encoding = length;
+				length = this.length - (offset || 0);
+			}
+			offset = offset === undefined ? 0 : offset >>> 0;
+			if (offset > this.length) {
+				throw rangeError('The value of "offset" is out of range.');
+			}
+			var remaining = this.length - offset;
+			length = length === undefined ? remaining : Math.min(remaining, length >>> 0);
+			var bytes = encode(String(string), normalize(encoding));
+			var n = Math.min(bytes.length, length);
+			this.set(bytes.subarray(0, n), offset);
+			return n;
+		}
+		fill(value, offset, end, encoding) {
+			if (typeof offset === 'string') {
+				encoding = offset;
+				offset = 0;
+				end = this.length;
+			} else if (typeof end === 'string') {
+				encoding = end;
+				end = this.length;
+			}
+			offset = offset === undefined ? 0 : offset >>> 0;
+			end = end === undefined ? this.length : Math.min(this.length, end >>> 0);
+			if (typeof value === 'number' || typeof value === 'boolean') {
+				return Uint8Array.prototype.fill.call(this, Number(value) & 0xff, offset, end);
+			}
+			var bytes = toBytes(value, encoding);
+			if (bytes.length === 0) {
+				return Uint8Array.prototype.fill.call(this, 0, offset, end);
+			}
+			for (var i = offset; i < end; i++) {
+				this[i] = bytes[(i - offset) % bytes.length];
+			}
+			return this;
+		}
+		slice(start, end) {
+			// 与 Node.js 一致，slice 与原 Buffer 共享内存
+			return this.subarray(start, end);
+		}
+		indexOf(value, byteOffset, encoding) {
+			if (typeof byteOffset === 'string') {
+				encoding = byteOffset;
+				byteOffset = 0;
+			}
+			byteOffset = byteOffset === undefined ? 0 : Math.floor(byteOffset) || 0;
+			if (byteOffset < 0) {
+				byteOffset = Math.max(0, this.length + byteOffset);
+			}
+			if (typeof value === 'number') {
+				return Uint8Array.prototype.indexOf.call(this, value & 0xff, byteOffset);
+			}
+			var needle = toBytes(value, encoding);
+			if (needle.length === 0) {
+				return Math.min(byteOffset, this.length);
+			}
+			return indexOf(this, needle, byteOffset);
+		}
+		includes(value, byteOffset, encoding) {
+			return this.indexOf(value, byteOffset, encoding) !== -1;
+		}
+		copy(target, targetStart, sourceStart, sourceEnd) {
+			targetStart = targetStart === undefined ? 0 : targetStart >>> 0;
+			sourceStart = sourceStart === undefined ? 0 : sourceStart >>> 0;
+			sourceEnd = sourceEnd === undefined ? this.length : Math.min(this.length, sourceEnd >>> 0);
+			if (targetStart >= target.length || sourceStart >= sourceEnd) {
+				return 0;
+			}
+			var n = Math.min(sourceEnd - sourceStart, target.length - targetStart);
+			target.set(this.subarray(sourceStart, sourceStart + n), targetStart);
+			return n;
+		}
+		swap16() { return swap(this, 2); }
+		swap32() { return swap(this, 4); }
+		swap64() { return swap(this, 8); }
+	}
+
+	function fromBytes(source) {
+		var out = new Buffer(source.length >>> 0);
+		for (var i = 0; i < out.length; i++) {
+			out[i] = source[i];
+		}
+		return out;
+	}
+
+	function swap(buf, size) {
+		if (buf.length % size !== 0) {
+			throw rangeError('Buffer size must be a multiple of ' + (size * 8) + '-bits');
+		}
+		for (var i = 0; i < buf.length; i += size) {
+			buf.subarray(i, i + size).reverse();
+		}
+		return buf;
+	}
+
+	// 定义 readUInt16LE、writeInt32BE 等按字节序读写数值的方法
+	[
+		['UInt8', 1, 'Uint8', 0, 255], ['Int8', 1, 'Int8', -128, 127],
+		['UInt16', 2, 'Uint16', 0, 65535], ['Int16', 2, 'Int16', -32768, 32767],
+		['UInt32', 4, 'Uint32', 0, 4294967295], ['Int32', 4, 'Int32', -2147483648, 2147483647],
+		['Float', 4, 'Float32'], ['Double', 8, 'Float64'],
+		['BigUInt64', 8, 'BigUint64'], ['BigInt64', 8, 'BigInt64']
+	].forEach(function (spec) {
+		var name = spec[0], size = spec[1], type = spec[2], min = spec[3], max = spec[4];
+		(size === 1 ? [''] : ['LE', 'BE']).forEach(function (order) {
+			var little = order === 'LE';
+			function check(buf, offset) {
+				offset = offset === undefined ? 0 : offset;
+				if (typeof offset !== 'number' || offset % 1 !== 0 || offset < 0 || offset + size > buf.length) {
+					throw rangeError('The value of "offset" is out of range. It must be >= 0 and <= ' + (buf.length - size) + '. Received ' + offset);
+				}
+				return new DataView(buf.buffer, buf.byteOffset + offset, size);
+			}
+			function read(offset) {
+				return check(this, offset)['get' + type](0, little);
+			}
+			function write(value, offset) {
+				var view = check(this, offset);
+				if (min !== undefined && (value < min || value > max)) {
+					throw rangeError('The value of "value" is out of range. It must be >= ' + min + ' and <= ' + max + '. Received ' + value);
+				}
+				view['set' + type](0, value, little);
+				return (offset === undefined ? 0 : offset) + size;
+			}
+			[name, name.replace('UInt', 'Uint')].forEach(function (alias) {
+				Object.defineProperty(Buffer.prototype, 'read' + alias + order, { value: read, writable: true, configurable: true });
+				Object.defineProperty(Buffer.prototype, 'write' + alias + order, { value: write, writable: true, configurable: true });
+			});
+		});
+	});
+
+	return Buffer;
+})`
+
+// registerBuffer 注册 Node.js 兼容的 Buffer 类，宿主函数通过它与脚本交换二进制数据
+func (sb *Sandbox) registerBuffer() {
+	native := sb.vm.NewObject()
+	native.Set("encode", func(s, encoding string) goja.ArrayBuffer {
+		return sb.vm.NewArrayBuffer(encodeBufferString(s, encoding))
+	})
+	native.Set("decode", func(data []byte, encoding string) string {
+		return decodeBufferBytes(data, encoding)
+	})
+
+	define, err := sb.vm.RunScript("<buffer>", bufferSource)
+	if err != nil {
+		panic(err)
+	}
+	fn, _ := goja.AssertFunction(define)
+	class, err := fn(goja.Undefined(), native)
+	if err != nil {
+		panic(err)
+	}
+	sb.bufferClass = class.ToObject(sb.vm)
+	sb.vm.Set("Buffer", sb.bufferClass)
+}
+
+// newBuffer 把字节数据包装为脚本中的 Buffer，不复制数据
+func (sb *Sandbox) newBuffer(data []byte) goja.Value {
+	buf, err := sb.vm.New(sb.bufferClass, sb.vm.ToValue(sb.vm.NewArrayBuffer(data)))
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// exportBytes 返回 ArrayBuffer、Buffer 或其他 TypedArray 中数据的副本，v 不是二进制数据时返回 false
+func (sb *Sandbox) exportBytes(v goja.Value) ([]byte, bool) {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil, false
+	}
+	switch data := obj.Export().(type) {
+	case goja.ArrayBuffer:
+		return append([]byte{}, data.Bytes()...), true
+	case []byte:
+		return append([]byte{}, data...), true
+	}
+	// 其他 TypedArray 按底层 ArrayBuffer 中的字节读取
+	if !sb.isBinary(obj) {
+		return nil, false
+	}
+	ab, ok := obj.Get("buffer").Export().(goja.ArrayBuffer)
+	if !ok {
+		return nil, false
+	}
+	offset, length := obj.Get("byteOffset").ToInteger(), obj.Get("byteLength").ToInteger()
+	return append([]byte{}, ab.Bytes()[offset:offset+length]...), true
+}
+
+// bytesArg 返回参数中的二进制数据，字符串等其他值按 UTF-8 编码
+func (sb *Sandbox) bytesArg(v goja.Value) []byte {
+	if data, ok := sb.exportBytes(v); ok {
+		return data
+	}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	return []byte(v.String())
+}
+
+// isBinary 判断参数是否为 ArrayBuffer、Buffer 或其他 TypedArray
+func (sb *Sandbox) isBinary(v goja.Value) bool {
+	obj, ok := v.(*goja.Object)
+	return ok && (obj.ExportType() == arrayBufferType || isTypedArray(obj))
+}
+
+// binaryOption 判断选项对象是否要求以 Buffer 返回数据：{encoding: 'binary'} 或 {responseType: 'arraybuffer'}
+func (sb *Sandbox) binaryOption(v goja.Value) bool {
+	obj, ok := v.(*goja.Object)
+	if !ok || sb.isBinary(v) {
+		return false
+	}
+	return sb.safeGet(obj, "encoding") == "binary" || sb.safeGet(obj, "responseType") == "arraybuffer"
+}
+
+// bytesResult 按 binary 返回 Buffer 或字符串
+func (sb *Sandbox) bytesResult(data []byte, binary bool) interface{} {
+	if binary {
+		return sb.newBuffer(data)
+	}
+	return string(data)
+}
+
+// encodeBufferString 按 Buffer 编码把字符串转换为字节，latin1、ascii、utf16le 在脚本中处理
+func encodeBufferString(s, encoding string) []byte {
+	switch encoding {
+	case "hex":
+		out := make([]byte, 0, len(s)/2)
+		for i := 0; i+1 < len(s); i += 2 {
+			b, err := hex.DecodeString(s[i : i+2])
+			if err != nil {
+				// 与 Node.js 一致，遇到第一个无效字符时停止
+				break
+			}
+			out = append(out, b[0])
+		}
+		return out
+	case "base64", "base64url":
+		return decodeBase64Loose(s)
+	default:
+		return []byte(s)
+	}
+}
+
+// decodeBufferBytes 按 Buffer 编码把字节转换为字符串，无效的 UTF-8 序列替换为 U+FFFD
+func decodeBufferBytes(data []byte, encoding string) string {
+	switch encoding {
+	case "hex":
+		return hex.EncodeToString(data)
+	case "base64":
+		return base64.StdEncoding.EncodeToString(data)
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(data)
+	default:
+		s, _, _ := decodeUTF8(data, false)
+		return s
+	}
+}
+
+// decodeBase64Loose 按 Node.js 的宽松规则解码 base64，同时接受 URL 安全字母表，忽略空白和无效字符，在 "=" 处结束
+func decodeBase64Loose(s string) []byte {
+	clean := make([]byte, 0, len(s))
+loop:
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '=':
+			break loop
+		case c == '-':
+			c = '+'
+		case c == '_':
+			c = '/'
+		}
+		if c == '+' || c == '/' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%4 == 1 {
+		clean = clean[:len(clean)-1]
+	}
+	out, _ := base64.RawStdEncoding.DecodeString(string(clean))
+	return out
+}
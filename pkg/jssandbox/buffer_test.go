@@ -0,0 +1,183 @@
+package jssandbox
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestBuffer(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"编码", `var b = Buffer.from('hé😀');
+			[b.length, b.toString('hex'), b.toString('base64'), b.toString('base64url'), b.toString()].join('|')`,
+			"7|68c3a9f09f9880|aMOp8J+YgA==|aMOp8J-YgA|hé😀"},
+		{"解码", `[Buffer.from('68c3a9zz', 'hex').toString(), Buffer.from('aM Op\n', 'base64').toString(), Buffer.from('aMOp', 'base64url').toString(),
+			Buffer.from('é', 'latin1').toString('hex'), Buffer.from('€', 'utf16le').toString('hex'), Buffer.from([0xff, 0x61]).toString()].join('|')`,
+			"hé|hé|hé|e9|ac20|�a"},
+		{"未知编码", `try { Buffer.from('a', 'utf7') } catch (e) { e.name + ' ' + e.code }`, "TypeError ERR_UNKNOWN_ENCODING"},
+		{"from 数组与 TypedArray", `[Buffer.from([1, 2, 300]).join(), Buffer.from(new Uint16Array([256, 1])).join(),
+			Buffer.from({ type: 'Buffer', data: [7] }).join(), Buffer.from(new String('ab')).toString()].join('|')`, "1,2,44|0,1|7|ab"},
+		{"共享内存", `var ab = new ArrayBuffer(4); var b = Buffer.from(ab, 1, 2); b[0] = 9;
+			var s = b.slice(1); s[0] = 8; [new Uint8Array(ab).join(), b.join()].join('|')`, "0,9,8,0|9,8"},
+		{"复制", `var src = Buffer.from('abc'); var c = Buffer.from(src); c[0] = 0x7a; src.toString() + c.toString()`, "abczbc"},
+		{"alloc 与 fill", `[Buffer.alloc(5, 'ab').toString(), Buffer.alloc(3, 1).join(), Buffer.alloc(2).fill('ff', 'hex').join()].join('|')`,
+			"ababa|1,1,1|255,255"},
+		{"静态方法", `[Buffer.isBuffer(Buffer.alloc(1)), Buffer.isBuffer(new Uint8Array(1)), Buffer.isEncoding('UTF-8'), Buffer.isEncoding('x'),
+			Buffer.byteLength('你好'), Buffer.concat([Buffer.from('a'), new Uint8Array([98, 99])]).toString(),
+			Buffer.concat([Buffer.from('abc')], 2).toString(), Buffer.compare(Buffer.from('a'), Buffer.from('b'))].join('|')`,
+			"true|false|true|false|6|abc|ab|-1"},
+		{"比较与查找", `var b = Buffer.from('hello world');
+			[b.equals(Buffer.from('hello world')), b.indexOf('o'), b.indexOf('o', 5), b.indexOf(0x77), b.includes('wor'), b.indexOf('xyz'),
+			b.compare(Buffer.from('hello'))].join('|')`, "true|4|7|6|true|-1|1"},
+		{"write 与 copy", `var b = Buffer.alloc(6); var n = b.write('héllo', 1); var t = Buffer.alloc(3); var m = b.copy(t, 1, 1);
+			[n, b.toString('hex'), m, t.toString('hex')].join('|')`, "5|0068c3a96c6c|2|0068c3"},
+		{"数值读写", `var b = Buffer.alloc(22);
+			var end = b.writeUInt16BE(0x1234, 0); b.writeInt32LE(-2, 2); b.writeDoubleBE(1.5, 6); b.writeBigUInt64LE(1n, 14);
+			[end, b.readUInt16BE(0), b.readUint16LE(0), b.readInt32LE(2), b.readDoubleBE(6), b.readUInt8(0), b.readBigUInt64LE(14)].join('|')`,
+			"2|4660|13330|-2|1.5|18|1"},
+		{"数值越界", `var b = Buffer.alloc(2); var out = [];
+			try { b.readUInt32LE(0) } catch (e) { out.push(e.name, e.code) }
+			try { b.writeUInt8(256) } catch (e) { out.push(e.code) }
+			out.join('|')`, "RangeError|ERR_OUT_OF_RANGE|ERR_OUT_OF_RANGE"},
+		{"swap", `Buffer.from([1, 2, 3, 4]).swap16().join() + '|' + Buffer.from([1, 2, 3, 4]).swap32().join()`, "2,1,4,3|4,3,2,1"},
+		{"TypedArray 方法", `var b = Buffer.from('abc'); [b instanceof Uint8Array, b.map(function (c) { return c + 1; }) instanceof Buffer,
+			b.subarray(1).toString(), Array.from(b.entries()).length].join('|')`, "true|true|bc|3"},
+		{"JSON", `var j = JSON.stringify(Buffer.from('ab')); j + '|' + Buffer.from(JSON.parse(j)).toString()`, `{"type":"Buffer","data":[97,98]}|ab`},
+		{"toString 范围", `Buffer.from('hello').toString('utf8', 1, 3) + Buffer.from('hello').toString('latin1', 3)`, "ello"},
+		{"require", `require('buffer').Buffer === Buffer`, "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := NewSandbox(context.Background())
+			defer sb.Close()
+			v, err := sb.Run(tt.code)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := v.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuffer_HostFunctions(t *testing.T) {
+	dir := t.TempDir()
+	binFile := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(binFile, []byte{0x00, 0xff, 0x80, 0x41}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"readFile", `var r = readFile(` + quoteJSString(binFile) + `, { encoding: 'binary' });
+			[Buffer.isBuffer(r.data), r.data.toString('hex'), r.length].join('|')`, "true|00ff8041|4"},
+		{"writeFile 与 appendFile", `var p = ` + quoteJSString(filepath.Join(dir, "out.bin")) + `;
+			writeFile(p, new Uint8Array([0, 255])); appendFile(p, Buffer.from([0x80]).buffer);
+			readFile(p, { encoding: 'binary' }).data.toString('hex')`, "00ff80"},
+		{"Base64", `var e = encodeBase64(Buffer.from([0, 255])).data; var d = decodeBase64(e, { encoding: 'binary' }).data;
+			[e, Buffer.isBuffer(d), d.join(), typeof decodeBase64('aGk=').data].join('|')`, "AP8=|true|0,255|string"},
+		{"GZIP", `var bytes = Buffer.from([0, 1, 2, 255, 254]);
+			var c = compressGzip(bytes, { encoding: 'binary' }).data;
+			var d = decompressGzip(c, { encoding: 'binary' }).data;
+			[Buffer.isBuffer(c), c[0], c[1], d.toString('hex'), decompressGzip(compressGzip('文本', { encoding: 'binary' }).data).data].join('|')`,
+			"true|31|139|000102fffe|文本"},
+		{"AES", `var key = Buffer.from('0123456789abcdef');
+			var c = encryptAES(Buffer.from([0, 255]), key, { encoding: 'binary' }).data;
+			var p = decryptAES(c, key, { encoding: 'binary' }).data;
+			var s = decryptAES(encryptAES('你好', key).data, '0123456789abcdef').data;
+			[Buffer.isBuffer(c), c.length, p.join(), s].join('|')`, "true|30|0,255|你好"},
+		{"SHA256", `var h = hashSHA256(Buffer.from('abc'), { encoding: 'binary' }).hash;
+			[h.length, h.toString('hex') === hashSHA256('abc').hash, hashSHA256(new Uint8Array([0xff])).hash].join('|')`,
+			"32|true|a8100ae6aa1940d0b663bb31cd466142ebbdbd5187131b92d93818987832eb89"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := NewSandbox(context.Background())
+			defer sb.Close()
+			v, err := sb.Run(tt.code)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := v.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuffer_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.Write(append([]byte{0xff, 0x00}, body...))
+	}))
+	defer server.Close()
+
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithPrivateNetwork(true))
+	defer sb.Close()
+
+	if _, err := sb.Run(`
+		var url = ` + quoteJSString(server.URL) + `;
+		var r = httpRequest(url, { method: 'POST', body: Buffer.from([0x80, 0x81]), responseType: 'arraybuffer' });
+		var p = httpPost(url, new Uint8Array([1]));
+		var out = [Buffer.isBuffer(r.body), r.body.toString('hex'), p.headers['X-Content-Type']];
+		fetch(url).then(function (res) { return res.arrayBuffer(); }).then(function (ab) {
+			out.push(Object.prototype.toString.call(ab), new Uint8Array(ab).join());
+		});
+	`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got, err := sb.Run(`out.join('|')`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "true|ff008081|application/octet-stream|[object ArrayBuffer]|255,0"; got.String() != want {
+		t.Errorf("got %q, want %q", got.String(), want)
+	}
+}
+
+func TestBuffer_Image(t *testing.T) {
+	var src bytes.Buffer
+	if err := imaging.Encode(&src, imaging.New(40, 20, color.White), imaging.PNG); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "out.jpg")
+
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	sb.vm.Set("png", sb.newBuffer(src.Bytes()))
+
+	v, err := sb.Run(`
+		var r = imageResize(png, { format: 'jpeg' }, 20, 10);
+		var info = imageInfo(r.data);
+		var f = imageFlip(png, ` + quoteJSString(outPath) + `, 'horizontal');
+		var bad = imageConvert(png, { format: 'xyz' }, 0);
+		[r.success, Buffer.isBuffer(r.data), r.format, info.width, info.height, info.format, f.success, bad.success].join('|')
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "true|true|jpeg|20|10|jpeg|true|false"; v.String() != want {
+		t.Errorf("got %q, want %q", v.String(), want)
+	}
+	if img, err := imaging.Open(outPath); err != nil || img.Bounds().Dx() != 40 {
+		t.Errorf("imageFlip() 输出文件不正确: %v", err)
+	}
+}
@@ -2,10 +2,12 @@ package jssandbox
 
 import (
 	"archive/zip"
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -15,7 +17,8 @@ import (
 // registerCompress 注册压缩/解压缩功能到JavaScript运行时
 func (sb *Sandbox) registerCompress() {
 	// 压缩为ZIP
-	sb.vm.Set("compressZip", func(call goja.FunctionCall) goja.Value {
+	sb.vm.Set("compressZip", func(call goja.FunctionCall) (result goja.Value) {
+		defer sb.auditValue(AuditEvent{Module: "compress", Operation: "compressZip", Target: call.Argument(1).String(), Details: map[string]interface{}{"files": call.Argument(0).Export()}}, &result)
 		if len(call.Arguments) < 2 {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": "需要提供文件列表和输出路径参数",
@@ -48,28 +51,44 @@ func (sb *Sandbox) registerCompress() {
 		}
 
 		outputPath := call.Arguments[1].String()
-
-		// 创建ZIP文件
-		zipFile, err := os.Create(outputPath)
+		hostOutput, err := sb.resolvePath(outputPath, fsWrite)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": fmt.Sprintf("创建ZIP文件失败: %v", err),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkOutputName(hostOutput); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
-		defer zipFile.Close()
 
-		zipWriter := zip.NewWriter(zipFile)
-		defer zipWriter.Close()
+		// 在内存中生成ZIP，校验通过后才写入输出文件，避免覆盖原有文件后再删除
+		var buf bytes.Buffer
+		zipWriter := zip.NewWriter(&buf)
 
 		// 添加文件到ZIP
 		for _, file := range files {
 			if err := sb.addFileToZip(zipWriter, file); err != nil {
+				if isSandboxError(err) {
+					return sb.vm.ToValue(sb.errorResult(err))
+				}
 				return sb.vm.ToValue(map[string]interface{}{
 					"error": fmt.Sprintf("添加文件 %s 失败: %v", file, err),
 				})
 			}
 		}
 
+		if err := zipWriter.Close(); err != nil {
+			return sb.vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("写入ZIP文件失败: %v", err),
+			})
+		}
+		if err := sb.writeCheckedFile(hostOutput, buf.Bytes()); err != nil {
+			if isSandboxError(err) {
+				return sb.vm.ToValue(sb.errorResult(err))
+			}
+			return sb.vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("创建ZIP文件失败: %v", err),
+			})
+		}
+
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
 			"path":    outputPath,
@@ -77,79 +96,103 @@ func (sb *Sandbox) registerCompress() {
 	})
 
 	// 解压ZIP
-	sb.vm.Set("extractZip", func(call goja.FunctionCall) goja.Value {
+	sb.vm.Set("extractZip", func(call goja.FunctionCall) (result goja.Value) {
+		defer sb.auditValue(AuditEvent{Module: "compress", Operation: "extractZip", Target: call.Argument(1).String(), Details: map[string]interface{}{"source": call.Argument(0).String()}}, &result)
 		if len(call.Arguments) < 2 {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": "需要提供ZIP文件路径和输出目录参数",
 			})
 		}
 
-		zipPath := call.Arguments[0].String()
+		zipPath, err := sb.resolvePath(call.Arguments[0].String(), fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 		outputDir := call.Arguments[1].String()
+		hostOutputDir, err := sb.resolvePath(outputDir, fsWrite)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		if err := sb.checkFileRead(zipPath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 
 		// 打开ZIP文件
-		zipReader, err := zip.OpenReader(zipPath)
+		zipFile, err := sb.fs.Open(zipPath)
+		if err != nil {
+			return sb.vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("打开ZIP文件失败: %v", err),
+			})
+		}
+		defer zipFile.Close()
+		zipInfo, err := zipFile.Stat()
+		if err != nil {
+			return sb.vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("打开ZIP文件失败: %v", err),
+			})
+		}
+		zipReader, err := zip.NewReader(zipFile, zipInfo.Size())
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("打开ZIP文件失败: %v", err),
 			})
 		}
-		defer zipReader.Close()
+
+		// 解压前检查所有条目，拒绝路径穿越（zip-slip）和符号链接条目
+		for _, file := range zipReader.File {
+			if err := checkZipEntry(file); err != nil {
+				return sb.vm.ToValue(sb.errorResult(err))
+			}
+		}
 
 		// 创建输出目录
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
+		if err := sb.fs.MkdirAll(hostOutputDir, 0755); err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("创建输出目录失败: %v", err),
 			})
 		}
 
+		realOutputDir, err := sb.realPath(hostOutputDir)
+		if err != nil {
+			return sb.vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("解析输出目录失败: %v", err),
+			})
+		}
+
 		// 解压所有文件
 		var extractedFiles []string
 		for _, file := range zipReader.File {
-			path := filepath.Join(outputDir, file.Name)
-
-			// 检查路径安全性
-			if !strings.HasPrefix(path, filepath.Clean(outputDir)+string(os.PathSeparator)) {
-				continue
+			// 条目路径同样经过文件系统隔离解析，并展开符号链接，防止通过输出目录中已有的符号链接逃逸
+			entryPath := filepath.Join(outputDir, filepath.FromSlash(file.Name))
+			target, err := sb.resolvePath(entryPath, fsWrite)
+			if err != nil {
+				return sb.vm.ToValue(sb.errorResult(err))
+			}
+			realTarget, err := sb.realPath(target)
+			if err != nil || !pathWithin(realTarget, realOutputDir) {
+				return sb.vm.ToValue(sb.errorResult(newAccessDeniedError("ZIP条目路径超出输出目录: %s", file.Name)))
 			}
 
 			if file.FileInfo().IsDir() {
-				os.MkdirAll(path, file.FileInfo().Mode())
+				sb.fs.MkdirAll(target, file.FileInfo().Mode())
 				continue
 			}
 
-			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			if err := sb.fs.MkdirAll(filepath.Dir(target), 0755); err != nil {
 				return sb.vm.ToValue(map[string]interface{}{
 					"error": fmt.Sprintf("创建目录失败: %v", err),
 				})
 			}
 
-			outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.FileInfo().Mode())
-			if err != nil {
-				return sb.vm.ToValue(map[string]interface{}{
-					"error": fmt.Sprintf("创建文件失败: %v", err),
-				})
-			}
-
-			rc, err := file.Open()
-			if err != nil {
-				outFile.Close()
-				return sb.vm.ToValue(map[string]interface{}{
-					"error": fmt.Sprintf("打开ZIP内文件失败: %v", err),
-				})
-			}
-
-			_, err = io.Copy(outFile, rc)
-			outFile.Close()
-			rc.Close()
-
-			if err != nil {
-				return sb.vm.ToValue(map[string]interface{}{
-					"error": fmt.Sprintf("解压文件失败: %v", err),
-				})
+			if err := sb.extractZipEntry(file, target); err != nil {
+				if isSandboxError(err) {
+					return sb.vm.ToValue(sb.errorResult(err))
+				}
+				return sb.vm.ToValue(sb.errorResult(err))
 			}
 
-			extractedFiles = append(extractedFiles, path)
+			extractedFiles = append(extractedFiles, entryPath)
 		}
 
 		return sb.vm.ToValue(map[string]interface{}{
@@ -158,11 +201,11 @@ func (sb *Sandbox) registerCompress() {
 		})
 	})
 
-	// GZIP压缩
-	sb.vm.Set("compressGzip", func(data string) goja.Value {
-		var buf strings.Builder
+	// GZIP压缩，数据可以是字符串或二进制数据；选项为 {encoding: 'binary'} 时以 Buffer 返回压缩结果
+	sb.vm.Set("compressGzip", func(data, options goja.Value) goja.Value {
+		var buf bytes.Buffer
 		writer := gzip.NewWriter(&buf)
-		if _, err := writer.Write([]byte(data)); err != nil {
+		if _, err := writer.Write(sb.bytesArg(data)); err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("压缩失败: %v", err),
 			})
@@ -175,13 +218,13 @@ func (sb *Sandbox) registerCompress() {
 
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
-			"data":    buf.String(),
+			"data":    sb.bytesResult(buf.Bytes(), sb.binaryOption(options)),
 		})
 	})
 
-	// GZIP解压
-	sb.vm.Set("decompressGzip", func(compressed string) goja.Value {
-		reader, err := gzip.NewReader(strings.NewReader(compressed))
+	// GZIP解压，压缩数据可以是字符串或二进制数据；选项为 {encoding: 'binary'} 时以 Buffer 返回解压结果
+	sb.vm.Set("decompressGzip", func(compressed, options goja.Value) goja.Value {
+		reader, err := gzip.NewReader(bytes.NewReader(sb.bytesArg(compressed)))
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("创建解压器失败: %v", err),
@@ -189,8 +232,11 @@ func (sb *Sandbox) registerCompress() {
 		}
 		defer reader.Close()
 
-		data, err := io.ReadAll(reader)
+		data, err := sb.readAllLimited("GZIP解压数据", reader)
 		if err != nil {
+			if isSandboxError(err) {
+				return sb.vm.ToValue(sb.errorResult(err))
+			}
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("解压失败: %v", err),
 			})
@@ -198,14 +244,79 @@ func (sb *Sandbox) registerCompress() {
 
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
-			"data":    string(data),
+			"data":    sb.bytesResult(data, sb.binaryOption(options)),
 		})
 	})
 }
 
+// extractZipEntry 解压单个ZIP条目到 path
+// 写入前按声明大小和文件头校验文件策略，写入时按实际解压大小限制，防止压缩炸弹
+func (sb *Sandbox) extractZipEntry(file *zip.File, path string) error {
+	if err := sb.checkFileSize(file.Name, int64(file.UncompressedSize64)); err != nil {
+		return err
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("打开ZIP内文件失败: %v", err)
+	}
+	defer rc.Close()
+
+	header := make([]byte, fileHeaderSize)
+	n, err := io.ReadFull(rc, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("解压文件失败: %v", err)
+	}
+	header = header[:n]
+	if err := sb.checkFileType(file.Name, header); err != nil {
+		return err
+	}
+
+	outFile, err := sb.fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.FileInfo().Mode())
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+
+	var src io.Reader = io.MultiReader(bytes.NewReader(header), rc)
+	if max := sb.config.MaxFileSize; max > 0 {
+		src = io.LimitReader(src, max+1)
+	}
+	written, err := io.Copy(outFile, src)
+	outFile.Close()
+	if err != nil {
+		return fmt.Errorf("解压文件失败: %v", err)
+	}
+	if err := sb.checkFileSize(file.Name, written); err != nil {
+		sb.fs.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// checkZipEntry 检查ZIP条目名称，拒绝绝对路径、".." 路径穿越和符号链接
+func checkZipEntry(file *zip.File) error {
+	name := strings.ReplaceAll(file.Name, "\\", "/")
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || filepath.VolumeName(file.Name) != "" || clean == ".." || strings.HasPrefix(clean, "../") {
+		return newAccessDeniedError("ZIP条目路径不安全: %s", file.Name)
+	}
+	if file.Mode()&os.ModeSymlink != 0 {
+		return newAccessDeniedError("不支持解压符号链接条目: %s", file.Name)
+	}
+	return nil
+}
+
 // addFileToZip 添加文件到ZIP
 func (sb *Sandbox) addFileToZip(zipWriter *zip.Writer, filePath string) error {
-	file, err := os.Open(filePath)
+	hostPath, err := sb.resolvePath(filePath, fsRead)
+	if err != nil {
+		return err
+	}
+	if err := sb.checkFileRead(hostPath); err != nil {
+		return err
+	}
+
+	file, err := sb.fs.Open(hostPath)
 	if err != nil {
 		return err
 	}
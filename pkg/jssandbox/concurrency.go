@@ -0,0 +1,259 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// ConcurrencyMode 沙盒正在执行时，其他 goroutine 再调用 Run 等执行方法的处理方式
+// goja.Runtime 不是并发安全的，同一时刻只能有一个 goroutine 使用沙盒的运行时
+type ConcurrencyMode string
+
+const (
+	// ConcurrencyQueue 排队等待正在进行的执行结束（默认）
+	ConcurrencyQueue ConcurrencyMode = "queue"
+	// ConcurrencyFailFast 立即返回 ErrCodeBusy 错误
+	ConcurrencyFailFast ConcurrencyMode = "fail-fast"
+	// ConcurrencySpill 从溢出沙盒池借出一个沙盒执行，池的大小见 Config.OverflowPoolSize。
+	// 溢出沙盒使用相同的配置，但没有通过 Set 设置的全局变量和之前执行留下的状态；
+	// 返回值中的对象会导出为 Go 值后重新包装，不再关联溢出沙盒的运行时
+	ConcurrencySpill ConcurrencyMode = "spill"
+)
+
+// defaultOverflowPoolSize 未设置 Config.OverflowPoolSize 时溢出沙盒池的大小
+const defaultOverflowPoolSize = 4
+
+// runLock 沙盒运行时的执行锁，Run、Set、Get 等访问运行时的方法都需要先持有它
+// 锁不可重入，宿主代码用收到的执行上下文调用 SetContext 等方法，见 Sandbox.ownsLock
+type runLock struct {
+	sem chan struct{}
+}
+
+func newRunLock() *runLock {
+	return &runLock{sem: make(chan struct{}, 1)}
+}
+
+// tryLock 尝试获取执行锁，锁被占用时立即返回 false
+func (l *runLock) tryLock() bool {
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// lock 等待获取执行锁
+func (l *runLock) lock() {
+	l.sem <- struct{}{}
+}
+
+// unlock 释放执行锁
+func (l *runLock) unlock() {
+	<-l.sem
+}
+
+// lockOwner 标识持有执行锁的一次执行，放在执行的上下文中交给宿主代码，见 ownsLock
+type lockOwner struct{}
+
+// lockOwnerKey 执行的上下文中 *lockOwner 的键
+type lockOwnerKey struct{}
+
+// withLockOwner 在执行的上下文中记录持有执行锁的执行，不在持有执行锁的执行中时原样返回
+func (sb *Sandbox) withLockOwner(ctx context.Context) context.Context {
+	if owner := sb.owner.Load(); owner != nil {
+		return context.WithValue(ctx, lockOwnerKey{}, owner)
+	}
+	return ctx
+}
+
+// ownsLock 判断 ctx 是否为当前持有执行锁的执行的上下文
+// 宿主代码（扩展方法、通过 Set 设置的 Go 函数）收到的 ctx 满足该条件，用它调用 SetContext 等方法时不等待执行锁，
+// 其他 goroutine 的 ctx 不带标记，仍然等待执行结束。宿主代码不能把 ctx 交给其他 goroutine 访问沙盒
+func (sb *Sandbox) ownsLock(ctx context.Context) bool {
+	owner, _ := ctx.Value(lockOwnerKey{}).(*lockOwner)
+	return owner != nil && owner == sb.owner.Load()
+}
+
+// hostFunc 第一个参数为 context.Context 的 Go 函数包装为调用时自动传入当前执行的上下文，
+// 脚本看到的参数不包括 ctx；其他值原样返回
+func (sb *Sandbox) hostFunc(value interface{}) interface{} {
+	fn := reflect.ValueOf(value)
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return value
+	}
+	t := fn.Type()
+	if t.NumIn() == 0 || t.In(0) != contextType {
+		return value
+	}
+	in := make([]reflect.Type, t.NumIn()-1)
+	for i := range in {
+		in[i] = t.In(i + 1)
+	}
+	out := make([]reflect.Type, t.NumOut())
+	for i := range out {
+		out[i] = t.Out(i)
+	}
+	return reflect.MakeFunc(reflect.FuncOf(in, out, t.IsVariadic()), func(args []reflect.Value) []reflect.Value {
+		args = append([]reflect.Value{reflect.ValueOf(sb.runContext())}, args...)
+		if t.IsVariadic() {
+			return fn.CallSlice(args)
+		}
+		return fn.Call(args)
+	}).Interface()
+}
+
+// lockRuntime 等待获取执行锁并返回释放函数，不受 Config.Concurrency 影响，用于 Set、Get 等方法
+func (sb *Sandbox) lockRuntime() func() {
+	sb.lock.lock()
+	return sb.lock.unlock
+}
+
+// lockRuntimeContext 与 lockRuntime 相同，ctx 为持有执行锁的执行的上下文时不等待（见 ownsLock），
+// 等待期间 ctx 或沙盒的上下文结束时返回 ErrCodeCanceled 等错误
+func (sb *Sandbox) lockRuntimeContext(ctx context.Context) (func(), error) {
+	if sb.ownsLock(ctx) {
+		return func() {}, nil
+	}
+	if err := sb.waitLock(ctx); err != nil {
+		return nil, err
+	}
+	return sb.lock.unlock, nil
+}
+
+// waitLock 等待获取执行锁，ctx 或沙盒的上下文结束时停止等待
+func (sb *Sandbox) waitLock(ctx context.Context) error {
+	select {
+	case sb.lock.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx, 0)
+	case <-sb.ctx.Done():
+		return contextError(sb.ctx, 0)
+	}
+}
+
+// exclusive 持有执行锁调用 fn，fn 的参数是实际执行的沙盒，op 为执行方法名，用于执行的 span
+// 锁被其他 goroutine 占用时按 Config.Concurrency 排队、返回 ErrCodeBusy 或在溢出沙盒上执行；
+// 排队不计入执行超时，沙盒的上下文结束（包括 Close）时停止排队并返回 ErrCodeCanceled。
+// 执行锁不可重入，宿主代码中再次执行脚本要使用 RunContext，见 Sandbox.ownsLock
+func exclusive[T any](sb *Sandbox, op string, fn func(sb *Sandbox) (T, error)) (T, error) {
+	return exclusiveContext(sb, sb.ctx, op, fn)
+}
+
+// exclusiveContext 与 exclusive 相同，ctx 结束时也停止排队
+func exclusiveContext[T any](sb *Sandbox, ctx context.Context, op string, fn func(sb *Sandbox) (T, error)) (T, error) {
+	var zero T
+	if sb.ctx.Err() != nil {
+		return zero, contextError(sb.ctx, 0)
+	}
+	mode := sb.config.Concurrency
+	locked := sb.lock.tryLock()
+	if !locked && mode != ConcurrencyFailFast && mode != ConcurrencySpill {
+		if err := sb.waitLock(ctx); err != nil {
+			return zero, err
+		}
+		locked = true
+	}
+	if locked {
+		defer sb.lock.unlock()
+		sb.owner.Store(new(lockOwner))
+		defer sb.owner.Store(nil)
+		start := time.Now()
+		endSpan := sb.startRunSpan(op)
+		result, err := fn(sb)
+		endSpan(err)
+		sb.config.Metrics.observeRun(err, time.Since(start))
+		return result, err
+	}
+	if mode == ConcurrencyFailFast {
+		err := NewSandboxError(ErrCodeBusy, "沙盒正在执行其他脚本")
+		sb.config.Metrics.observeRun(err, 0)
+		return zero, err
+	}
+
+	pool, err := sb.overflowPool()
+	if err != nil {
+		return zero, err
+	}
+	other, err := pool.Get(ctx)
+	if err != nil {
+		return zero, err
+	}
+	defer pool.Put(other)
+	other.borrowCtx = sb.callerContext()
+	sb.logger.Debug("沙盒正在执行其他脚本，使用溢出沙盒执行")
+	result, err := exclusiveContext(other, ctx, op, fn)
+	return detachResult(result), detachError(err)
+}
+
+// overflowPool 返回 ConcurrencySpill 使用的溢出沙盒池，第一次溢出时创建
+func (sb *Sandbox) overflowPool() (*SandboxPool, error) {
+	sb.overflowMu.Lock()
+	defer sb.overflowMu.Unlock()
+	if sb.overflow != nil {
+		return sb.overflow, nil
+	}
+	if sb.ctx.Err() != nil {
+		return nil, contextError(sb.ctx, 0)
+	}
+	size := sb.config.OverflowPoolSize
+	if size <= 0 {
+		size = defaultOverflowPoolSize
+	}
+	config := *sb.config
+	config.Concurrency = ConcurrencyQueue
+	pool, err := NewSandboxPool(sb.ctx, &PoolConfig{
+		Config:      &config,
+		MaxSize:     size,
+		IdleTimeout: DefaultPoolConfig().IdleTimeout,
+		Name:        "overflow",
+	})
+	if err != nil {
+		return nil, err
+	}
+	sb.overflow = pool
+	return pool, nil
+}
+
+// detachResult 把溢出沙盒返回的对象导出为 Go 值，再包装到独立的运行时中，
+// 溢出沙盒归还后会被重置和复用，调用方不能再访问其中的对象
+func detachResult[T any](result T) T {
+	switch r := any(result).(type) {
+	case *RunResult:
+		if r != nil {
+			r.Value = detachValue(r.Value)
+		}
+	case goja.Value:
+		v, _ := any(detachValue(r)).(T)
+		return v
+	}
+	return result
+}
+
+// detachValue 见 detachResult，原始类型的值不属于任何运行时，原样返回
+func detachValue(v goja.Value) goja.Value {
+	obj, ok := v.(*goja.Object)
+	if !ok || obj == nil {
+		return v
+	}
+	return goja.New().ToValue(obj.Export())
+}
+
+// detachError 把错误原因中引用溢出沙盒运行时的 goja 异常和 Promise 拒绝原因替换为其文本
+func detachError(err error) error {
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || sbErr.Cause == nil {
+		return err
+	}
+	var ex *goja.Exception
+	var rejected *PromiseRejectedError
+	if errors.As(sbErr.Cause, &ex) || errors.As(sbErr.Cause, &rejected) {
+		sbErr.Cause = errors.New(sbErr.Cause.Error())
+	}
+	return err
+}
@@ -0,0 +1,295 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// blockingSandbox 创建一个沙盒，脚本调用 block() 时通知 started 并阻塞到 release 被关闭
+func blockingSandbox(t *testing.T, config *Config) (sb *Sandbox, started <-chan struct{}, release chan struct{}) {
+	t.Helper()
+	sb = NewSandboxWithConfig(context.Background(), config)
+	t.Cleanup(func() { sb.Close() })
+	start := make(chan struct{}, 1)
+	release = make(chan struct{})
+	sb.Set("block", func() {
+		start <- struct{}{}
+		<-release
+	})
+	return sb, start, release
+}
+
+func TestConcurrentRun_Queue(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	sb.Set("counter", 0)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n*2)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := sb.Run(`counter = counter + 1`); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := sb.RunWithOutput(`console.log(counter)`, time.Second); err != nil {
+				errs <- err
+			}
+			sb.Get("counter")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("并发执行失败: %v", err)
+	}
+	if got := sb.Get("counter").ToInteger(); got != n {
+		t.Errorf("counter = %d, want %d", got, n)
+	}
+}
+
+func TestConcurrentRun_QueueTimeout(t *testing.T) {
+	sb, started, release := blockingSandbox(t, DefaultConfig())
+	done := make(chan error, 1)
+	go func() {
+		_, err := sb.Run(`block()`)
+		done <- err
+	}()
+	<-started
+
+	// 排队等待的时间不计入超时
+	queued := make(chan error, 1)
+	go func() {
+		_, err := sb.RunWithTimeout(`1 + 1`, 50*time.Millisecond)
+		queued <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := <-queued; err != nil {
+		t.Errorf("排队的 RunWithTimeout() error = %v", err)
+	}
+}
+
+func TestConcurrentRun_FailFast(t *testing.T) {
+	sb, started, release := blockingSandbox(t, DefaultConfig().WithConcurrency(ConcurrencyFailFast))
+	done := make(chan error, 1)
+	go func() {
+		_, err := sb.Run(`block()`)
+		done <- err
+	}()
+	<-started
+
+	_, err := sb.RunWithTimeout(`1`, time.Second)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsBusy() {
+		t.Errorf("RunWithTimeout() error = %v, want %s", err, ErrCodeBusy)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if v, err := sb.Run(`2`); err != nil || v.ToInteger() != 2 {
+		t.Errorf("Run() = %v, %v", v, err)
+	}
+}
+
+func TestConcurrentRun_Spill(t *testing.T) {
+	sb, started, release := blockingSandbox(t, DefaultConfig().WithConcurrency(ConcurrencySpill).WithOverflowPoolSize(2))
+	done := make(chan error, 1)
+	go func() {
+		_, err := sb.Run(`block()`)
+		done <- err
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := sb.Run(`({ answer: 42, list: [1, 2], blocked: typeof block })`)
+			if err != nil {
+				t.Errorf("溢出执行失败: %v", err)
+				return
+			}
+			obj := v.Export().(map[string]interface{})
+			if obj["answer"] != int64(42) || obj["blocked"] != "undefined" {
+				t.Errorf("溢出执行结果 = %v", obj)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, err := sb.RunWithTimeout(`throw new Error('boom')`, time.Second)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsScriptError() || sbErr.Exception.Message != "boom" {
+		t.Errorf("溢出执行的异常 = %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if stats := sb.overflow.Stats(); stats.Created == 0 || stats.MaxSize != 2 {
+		t.Errorf("溢出沙盒池 = %+v", stats)
+	}
+}
+
+func TestConcurrentRun_Reentrant(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	sb.Set("hostGet", func(ctx context.Context, name string) (interface{}, error) {
+		if err := sb.SetContext(ctx, "visited", true); err != nil {
+			return nil, err
+		}
+		v, err := sb.GetContext(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return v.Export(), nil
+	})
+	v, err := sb.RunWithTimeout(`var secret = 'x'; hostGet('secret') + visited`, time.Second)
+	if err != nil {
+		t.Fatalf("RunWithTimeout() error = %v", err)
+	}
+	if v.String() != "xtrue" {
+		t.Errorf("got %q", v.String())
+	}
+}
+
+func TestConcurrentRun_SetWaitsForHostCall(t *testing.T) {
+	sb, started, release := blockingSandbox(t, DefaultConfig())
+	done := make(chan error, 1)
+	go func() {
+		_, err := sb.Run(`var seen = []; block(); seen.push(typeof late); seen.join()`)
+		done <- err
+	}()
+	<-started
+
+	// 宿主函数执行期间其他 goroutine 调用 Set 要等待执行结束，不能与执行同时访问运行时
+	set := make(chan struct{})
+	go func() {
+		sb.Set("late", 1)
+		close(set)
+	}()
+	select {
+	case <-set:
+		t.Fatal("宿主函数执行期间 Set 没有等待执行结束")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 其他 goroutine 持有的上下文不带执行标记，同样要等待
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := sb.SetContext(ctx, "other", 1); err == nil {
+		t.Error("SetContext() 在执行期间应等待到 ctx 结束")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	<-set
+	if v, _ := sb.Run(`seen.join() + ',' + late`); v.String() != "undefined,1" {
+		t.Errorf("got %q", v.String())
+	}
+}
+
+func TestConcurrentRun_Close(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	done := make(chan error, 2)
+	go func() {
+		_, err := sb.Run(`while (true) {}`)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		_, err := sb.Run(`1`)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := sb.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			var sbErr *SandboxError
+			if !errors.As(err, &sbErr) || !sbErr.IsCanceled() {
+				t.Errorf("Close 后的执行 error = %v, want %s", err, ErrCodeCanceled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Close 没有中断执行")
+		}
+	}
+	if _, err := sb.Run(`1`); err == nil {
+		t.Error("Close 后 Run() 应返回错误")
+	}
+}
+
+func TestConcurrentRun_ReentrantRun(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	sb.Set("nested", func(ctx context.Context, code string) (interface{}, error) {
+		v, err := sb.RunContext(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		return v.Export(), nil
+	})
+
+	done := make(chan struct{})
+	var v interface{}
+	var err error
+	go func() {
+		defer close(done)
+		var result goja.Value
+		result, err = sb.RunWithTimeout(`var base = 40; nested('base + 2')`, time.Second)
+		if err == nil {
+			v = result.Export()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("宿主函数中的 RunContext 没有返回")
+	}
+	if err != nil || v != int64(42) {
+		t.Errorf("RunWithTimeout() = %v, %v", v, err)
+	}
+
+	_, err = sb.Run(`nested('throw new Error("inner")')`)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !strings.Contains(err.Error(), "inner") {
+		t.Errorf("嵌套执行的异常 = %v", err)
+	}
+}
+
+func TestConcurrentRun_RunContextQueue(t *testing.T) {
+	sb, started, release := blockingSandbox(t, DefaultConfig())
+	go sb.Run(`block()`)
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := sb.RunContext(ctx, `1`)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsTimeout() {
+		t.Errorf("排队中 ctx 到期的 RunContext() error = %v, want %s", err, ErrCodeTimeout)
+	}
+}
@@ -1,6 +1,11 @@
 package jssandbox
 
-import "time"
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
 
 // Config 沙盒配置
 type Config struct {
@@ -14,6 +19,60 @@ type Config struct {
 	MaxFileSize int64
 	// AllowedFileTypes 允许的文件类型列表，空列表表示不限制
 	AllowedFileTypes []string
+	// FileSystemRoot 文件系统根目录，非空时脚本看到的 "/" 映射到该宿主机目录，
+	// 所有文件访问都被限制在根目录和挂载点内
+	FileSystemRoot string
+	// Mounts 额外的挂载点，设置后同样启用文件系统隔离
+	Mounts []Mount
+	// FileSystem 文件操作使用的文件系统，为 nil 时直接访问宿主机磁盘。
+	// FileSystemRoot 和 Mounts 只对宿主机文件系统生效
+	FileSystem FileSystem
+	// ModuleRoot 本地模块的根目录，require('./lib/util') 等相对路径在该宿主机目录中解析，
+	// 不能越过根目录；为空时只能加载内置模块和自定义模块
+	ModuleRoot string
+	// Egress 网络出站策略，默认禁止访问回环、私有网段等内部地址
+	Egress EgressPolicy
+	// Permissions 宿主函数的权限集合（文件读写、网络、命令、环境变量、终止进程、系统信息），
+	// 为 nil 时不做限制
+	Permissions *Permissions
+	// MaxMemory 单次执行允许的堆增长（字节），0 表示不限制，默认 512MB。
+	// 统计的是整个进程的堆增长，不是本沙盒的内存：宿主其他 goroutine 的分配和其他沙盒的执行都会计入，
+	// 只是粗略的兜底，用于拦截 MaxStringLength、MaxArrayLength 检查不到的增长
+	MaxMemory int64
+	// MaxStringLength 字符串的最大长度，0 表示不限制。
+	// 只在 repeat、padStart、padEnd、concat、join 等内置方法中检查，+ 运算符和模板字符串的拼接检查不到
+	MaxStringLength int
+	// MaxArrayLength 数组的最大长度，0 表示不限制。
+	// 只在 Array.from、fill、concat、push、unshift 等内置方法中检查，给 length 或下标赋值检查不到
+	MaxArrayLength int
+	// MaxCallStackSize 函数调用栈的最大深度，0 表示不限制
+	MaxCallStackSize int
+	// MaxOutputSize RunWithOutput 等方法捕获的输出消息的总字节数，超出部分被截断，0 表示不限制。
+	// 输出通常会交给大模型，需要避免占满上下文
+	MaxOutputSize int
+	// MaxOutputEntries RunWithOutput 等方法捕获的输出记录数，0 表示不限制
+	MaxOutputEntries int
+	// Extensions 创建沙盒时注册的宿主扩展（见 NewExtension），无效的扩展记录日志后跳过
+	Extensions []*Extension
+	// Interceptors 宿主函数拦截器，所有内置宿主函数和扩展函数的调用都会依次经过它们（见 HostInterceptor）
+	Interceptors []HostInterceptor
+	// AuditSink 接收有副作用的宿主函数调用（写文件、HTTP 请求、执行命令、浏览器操作等）的审计记录，
+	// 为 nil 时以 Info/Warn 级别写入沙盒的 logger（见 LoggerAuditSink）
+	AuditSink AuditSink
+	// Metrics 指标注册表，为 nil 时不统计；可由多个沙盒和沙盒池共用（见 Metrics）
+	Metrics *Metrics
+	// TracerProvider 为执行和宿主函数调用创建 OpenTelemetry span，为 nil 时不创建；
+	// 执行的 span 以创建沙盒的 ctx（从沙盒池借出时为 Get 的 ctx）中的 span 为父 span
+	TracerProvider trace.TracerProvider
+	// Propagator 向 HTTP 请求头注入追踪信息的传播器，为 nil 时使用 W3C Trace Context 和 Baggage
+	Propagator propagation.TextMapPropagator
+	// ErrorMode 宿主函数向脚本报告错误的方式，默认（空值）为 ErrorModeResult 返回 { success: false, error } 结果对象；
+	// ErrorModeThrow 时所有内置宿主函数出错都抛出 SandboxError 异常，未单独设置错误模式的扩展也使用该模式
+	ErrorMode ErrorMode
+	// Concurrency 沙盒正在执行时其他 goroutine 调用 Run 等执行方法的处理方式，默认（空值）为 ConcurrencyQueue 排队等待
+	Concurrency ConcurrencyMode
+	// OverflowPoolSize Concurrency 为 ConcurrencySpill 时溢出沙盒池的大小，0 表示使用默认值 4
+	OverflowPoolSize int
 	// EnableBrowser 是否启用浏览器功能
 	EnableBrowser bool
 	// EnableFileSystem 是否启用文件系统功能
@@ -30,6 +89,16 @@ type Config struct {
 	Headless bool
 }
 
+// Mount 文件系统挂载点，把沙盒内的虚拟路径映射到宿主机目录
+type Mount struct {
+	// VirtualPath 沙盒内的挂载路径，如 "/data"
+	VirtualPath string
+	// HostPath 宿主机目录
+	HostPath string
+	// ReadOnly 是否只读
+	ReadOnly bool
+}
+
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
@@ -38,6 +107,12 @@ func DefaultConfig() *Config {
 		BrowserTimeout:        60 * time.Second,
 		MaxFileSize:           100 * 1024 * 1024, // 100MB
 		AllowedFileTypes:      []string{},
+		MaxMemory:             512 * 1024 * 1024, // 512MB
+		MaxStringLength:       256 * 1024 * 1024,
+		MaxArrayLength:        10000000,
+		MaxCallStackSize:      10000,
+		MaxOutputSize:         64 * 1024, // 64KB
+		MaxOutputEntries:      1000,
 		EnableBrowser:         true,
 		EnableFileSystem:      true,
 		EnableHTTP:            true,
@@ -78,6 +153,180 @@ func (c *Config) WithAllowedFileTypes(types []string) *Config {
 	return c
 }
 
+// WithMaxMemory 设置单次执行允许的堆增长（字节）
+func (c *Config) WithMaxMemory(size int64) *Config {
+	c.MaxMemory = size
+	return c
+}
+
+// WithMaxStringLength 设置字符串的最大长度
+func (c *Config) WithMaxStringLength(length int) *Config {
+	c.MaxStringLength = length
+	return c
+}
+
+// WithMaxArrayLength 设置数组的最大长度
+func (c *Config) WithMaxArrayLength(length int) *Config {
+	c.MaxArrayLength = length
+	return c
+}
+
+// WithMaxCallStackSize 设置函数调用栈的最大深度
+func (c *Config) WithMaxCallStackSize(size int) *Config {
+	c.MaxCallStackSize = size
+	return c
+}
+
+// WithMaxOutputSize 设置捕获的输出消息的总字节数
+func (c *Config) WithMaxOutputSize(size int) *Config {
+	c.MaxOutputSize = size
+	return c
+}
+
+// WithMaxOutputEntries 设置捕获的输出记录数
+func (c *Config) WithMaxOutputEntries(n int) *Config {
+	c.MaxOutputEntries = n
+	return c
+}
+
+// WithFileSystemRoot 设置文件系统根目录，脚本中的所有路径都相对于该目录解析
+func (c *Config) WithFileSystemRoot(root string) *Config {
+	c.FileSystemRoot = root
+	return c
+}
+
+// WithMount 添加可读写的挂载点
+func (c *Config) WithMount(virtualPath, hostPath string) *Config {
+	c.Mounts = append(c.Mounts, Mount{VirtualPath: virtualPath, HostPath: hostPath})
+	return c
+}
+
+// WithReadOnlyMount 添加只读挂载点
+func (c *Config) WithReadOnlyMount(virtualPath, hostPath string) *Config {
+	c.Mounts = append(c.Mounts, Mount{VirtualPath: virtualPath, HostPath: hostPath, ReadOnly: true})
+	return c
+}
+
+// WithFileSystem 设置文件操作使用的文件系统，如 NewMemFileSystem() 或 NewOverlayFileSystem(dir)
+func (c *Config) WithFileSystem(fsys FileSystem) *Config {
+	c.FileSystem = fsys
+	return c
+}
+
+// WithModuleRoot 设置本地模块的根目录，脚本可以通过 require 加载其中的 .js/.json 文件
+func (c *Config) WithModuleRoot(dir string) *Config {
+	c.ModuleRoot = dir
+	return c
+}
+
+// WithAllowedHosts 添加允许访问的主机名，设置后只能访问列表中的主机
+func (c *Config) WithAllowedHosts(hosts ...string) *Config {
+	c.Egress.AllowedHosts = append(c.Egress.AllowedHosts, hosts...)
+	return c
+}
+
+// WithDeniedHosts 添加禁止访问的主机名
+func (c *Config) WithDeniedHosts(hosts ...string) *Config {
+	c.Egress.DeniedHosts = append(c.Egress.DeniedHosts, hosts...)
+	return c
+}
+
+// WithAllowedCIDRs 添加允许访问的网段，可用于放行特定的内部地址
+func (c *Config) WithAllowedCIDRs(cidrs ...string) *Config {
+	c.Egress.AllowedCIDRs = append(c.Egress.AllowedCIDRs, cidrs...)
+	return c
+}
+
+// WithDeniedCIDRs 添加禁止访问的网段
+func (c *Config) WithDeniedCIDRs(cidrs ...string) *Config {
+	c.Egress.DeniedCIDRs = append(c.Egress.DeniedCIDRs, cidrs...)
+	return c
+}
+
+// WithAllowedPorts 添加允许访问的端口，设置后只能访问列表中的端口
+func (c *Config) WithAllowedPorts(ports ...int) *Config {
+	c.Egress.AllowedPorts = append(c.Egress.AllowedPorts, ports...)
+	return c
+}
+
+// WithDeniedPorts 添加禁止访问的端口
+func (c *Config) WithDeniedPorts(ports ...int) *Config {
+	c.Egress.DeniedPorts = append(c.Egress.DeniedPorts, ports...)
+	return c
+}
+
+// WithAllowedSchemes 设置允许的 URL 协议，默认只允许 http 和 https
+func (c *Config) WithAllowedSchemes(schemes ...string) *Config {
+	c.Egress.AllowedSchemes = schemes
+	return c
+}
+
+// WithPrivateNetwork 设置是否允许访问回环、私有网段等内部地址
+func (c *Config) WithPrivateNetwork(allow bool) *Config {
+	c.Egress.AllowPrivateNetwork = allow
+	return c
+}
+
+// WithPermissions 设置宿主函数的权限集合，如 NewPermissions().AllowRead("/data").AllowNet("api.example.com")
+func (c *Config) WithPermissions(p *Permissions) *Config {
+	c.Permissions = p
+	return c
+}
+
+// WithExtension 添加创建沙盒时注册的宿主扩展
+func (c *Config) WithExtension(exts ...*Extension) *Config {
+	c.Extensions = append(c.Extensions, exts...)
+	return c
+}
+
+// WithAuditSink 设置审计记录的接收器
+func (c *Config) WithAuditSink(sink AuditSink) *Config {
+	c.AuditSink = sink
+	return c
+}
+
+// WithMetrics 设置记录执行和宿主函数调用指标的注册表
+func (c *Config) WithMetrics(m *Metrics) *Config {
+	c.Metrics = m
+	return c
+}
+
+// WithTracerProvider 设置创建 OpenTelemetry span 的 TracerProvider
+func (c *Config) WithTracerProvider(tp trace.TracerProvider) *Config {
+	c.TracerProvider = tp
+	return c
+}
+
+// WithPropagator 设置向 HTTP 请求头注入追踪信息的传播器
+func (c *Config) WithPropagator(p propagation.TextMapPropagator) *Config {
+	c.Propagator = p
+	return c
+}
+
+// WithInterceptor 添加宿主函数拦截器，先添加的在外层
+func (c *Config) WithInterceptor(interceptors ...HostInterceptor) *Config {
+	c.Interceptors = append(c.Interceptors, interceptors...)
+	return c
+}
+
+// WithErrorMode 设置宿主函数报告错误的方式
+func (c *Config) WithErrorMode(mode ErrorMode) *Config {
+	c.ErrorMode = mode
+	return c
+}
+
+// WithConcurrency 设置并发调用执行方法时的处理方式
+func (c *Config) WithConcurrency(mode ConcurrencyMode) *Config {
+	c.Concurrency = mode
+	return c
+}
+
+// WithOverflowPoolSize 设置溢出沙盒池的大小
+func (c *Config) WithOverflowPoolSize(n int) *Config {
+	c.OverflowPoolSize = n
+	return c
+}
+
 // DisableBrowser 禁用浏览器功能
 func (c *Config) DisableBrowser() *Config {
 	c.EnableBrowser = false
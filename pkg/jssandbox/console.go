@@ -0,0 +1,788 @@
+package jssandbox
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// inspectDepth 对象嵌套超过该深度时显示为 [Object]、[Array]，与 Node.js 的默认值相同
+	inspectDepth = 2
+	// inspectBreakLength 对象的单行形式超过该宽度时每个属性换行显示
+	inspectBreakLength = 80
+	// inspectMaxItems 数组、Map、Set 最多显示的元素个数
+	inspectMaxItems = 100
+	// inspectMaxBytes Buffer、ArrayBuffer 最多显示的字节数，与 Node.js 的 buffer.INSPECT_MAX_BYTES 相同
+	inspectMaxBytes = 50
+	// consoleIndent console.group 每一层的缩进
+	consoleIndent = "  "
+)
+
+// promiseType Promise 对象导出的类型，先比较类型可以避免导出普通对象时调用它的 getter
+var promiseType = reflect.TypeOf((*goja.Promise)(nil))
+
+// arrayBufferType ArrayBuffer 对象导出的类型
+var arrayBufferType = reflect.TypeOf(goja.ArrayBuffer{})
+
+// consoleState console 在多次调用之间保存的状态（计时器、计数器、分组缩进），沙盒池重置运行时时清空
+type consoleState struct {
+	timers map[string]time.Time
+	counts map[string]int
+	indent string
+	// 以下为注册时保存的内置函数，不受脚本覆盖全局对象的影响
+	getOwnPropertyDescriptor goja.Callable
+	stringify                goja.Callable
+	mapCtor, setCtor         *goja.Object
+}
+
+func (c *consoleState) reset() {
+	c.timers = make(map[string]time.Time)
+	c.counts = make(map[string]int)
+	c.indent = ""
+}
+
+// registerConsole 注册与 Node.js 兼容的 console 对象
+// 参数的格式化规则与 Node.js 的 util.format 相同：支持 %s、%d、%i、%f、%j、%o、%O、%c 占位符，对象按 util.inspect 的形式显示
+func (sb *Sandbox) registerConsole() {
+	state := &consoleState{}
+	state.reset()
+	state.getOwnPropertyDescriptor, _ = goja.AssertFunction(sb.vm.Get("Reflect").ToObject(sb.vm).Get("getOwnPropertyDescriptor"))
+	state.stringify, _ = goja.AssertFunction(sb.vm.Get("JSON").ToObject(sb.vm).Get("stringify"))
+	state.mapCtor, state.setCtor = sb.vm.Get("Map").ToObject(sb.vm), sb.vm.Get("Set").ToObject(sb.vm)
+	sb.console = state
+
+	console := sb.vm.NewObject()
+	write := func(level string, text string) {
+		if state.indent != "" {
+			text = state.indent + strings.ReplaceAll(text, "\n", "\n"+state.indent)
+		}
+		sb.logScript("console", level, nil, text)
+	}
+
+	for _, level := range []string{"log", "info", "debug", "warn", "error"} {
+		level := level
+		console.Set(level, func(call goja.FunctionCall) goja.Value {
+			write(level, sb.formatArgs(call.Arguments))
+			return goja.Undefined()
+		})
+	}
+	console.Set("dirxml", console.Get("log"))
+
+	console.Set("dir", func(obj goja.Value, options goja.Value) {
+		depth := inspectDepth
+		if opts, ok := options.(*goja.Object); ok {
+			if d := opts.Get("depth"); d != nil && goja.IsNumber(d) {
+				depth = int(d.ToInteger())
+			} else if d != nil && goja.IsNull(d) {
+				depth = math.MaxInt32
+			}
+		}
+		write("log", sb.newInspector(depth).inspect(obj, 0, ""))
+	})
+
+	console.Set("trace", func(call goja.FunctionCall) goja.Value {
+		text := "Trace"
+		if len(call.Arguments) > 0 {
+			text += ": " + sb.formatArgs(call.Arguments)
+		}
+		// 第一帧是 console.trace 自身
+		var buf bytes.Buffer
+		for _, f := range sb.vm.CaptureCallStack(0, nil)[1:] {
+			buf.WriteString("\tat ")
+			f.Write(&buf)
+			buf.WriteByte('\n')
+		}
+		for _, f := range parseStack(buf.String()) {
+			text += "\n    at " + f.String()
+		}
+		write("trace", text)
+		return goja.Undefined()
+	})
+
+	console.Set("assert", func(call goja.FunctionCall) goja.Value {
+		if call.Argument(0).ToBoolean() {
+			return goja.Undefined()
+		}
+		text := "Assertion failed"
+		if args := call.Arguments; len(args) > 1 {
+			if goja.IsString(args[1]) {
+				text += ": " + sb.formatArgs(args[1:])
+			} else {
+				text += " " + sb.formatArgs(args[1:])
+			}
+		}
+		write("error", text)
+		return goja.Undefined()
+	})
+
+	console.Set("count", func(label goja.Value) {
+		name := consoleLabel(label)
+		state.counts[name]++
+		write("log", fmt.Sprintf("%s: %d", name, state.counts[name]))
+	})
+	console.Set("countReset", func(label goja.Value) {
+		name := consoleLabel(label)
+		if _, ok := state.counts[name]; !ok {
+			write("warn", fmt.Sprintf("Warning: Count for '%s' does not exist", name))
+			return
+		}
+		state.counts[name] = 0
+	})
+
+	console.Set("time", func(label goja.Value) {
+		name := consoleLabel(label)
+		if _, ok := state.timers[name]; ok {
+			write("warn", fmt.Sprintf("Warning: Label '%s' already exists for console.time()", name))
+			return
+		}
+		state.timers[name] = time.Now()
+	})
+	timeLog := func(method string, end bool) func(call goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			name := consoleLabel(call.Argument(0))
+			start, ok := state.timers[name]
+			if !ok {
+				write("warn", fmt.Sprintf("Warning: No such label '%s' for console.%s()", name, method))
+				return goja.Undefined()
+			}
+			text := name + ": " + formatElapsed(time.Since(start))
+			if end {
+				delete(state.timers, name)
+			} else if len(call.Arguments) > 1 {
+				text += " " + sb.formatArgs(call.Arguments[1:])
+			}
+			write("log", text)
+			return goja.Undefined()
+		}
+	}
+	console.Set("timeEnd", timeLog("timeEnd", true))
+	console.Set("timeLog", timeLog("timeLog", false))
+
+	group := func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) > 0 {
+			write("log", sb.formatArgs(call.Arguments))
+		}
+		state.indent += consoleIndent
+		return goja.Undefined()
+	}
+	console.Set("group", group)
+	console.Set("groupCollapsed", group)
+	console.Set("groupEnd", func() {
+		state.indent = strings.TrimSuffix(state.indent, consoleIndent)
+	})
+
+	console.Set("table", func(data goja.Value, columns goja.Value) {
+		if obj, ok := data.(*goja.Object); ok {
+			if _, isFunc := goja.AssertFunction(obj); !isFunc {
+				write("log", sb.formatTable(obj, columns))
+				return
+			}
+		}
+		write("log", sb.formatArgs([]goja.Value{data}))
+	})
+
+	// 浏览器中的 console.clear 清空控制台，沙盒中没有可清空的终端
+	console.Set("clear", func() {})
+
+	sb.vm.Set("console", console)
+}
+
+// consoleLabel 返回 console.count、console.time 等方法的标签，默认为 "default"
+func consoleLabel(label goja.Value) string {
+	if label == nil || goja.IsUndefined(label) {
+		return "default"
+	}
+	return label.String()
+}
+
+// formatElapsed 按 Node.js 的格式显示 console.timeEnd 的耗时，如 "1.234ms"、"2.500s"、"1:02.345 (m:ss.mmm)"
+func formatElapsed(d time.Duration) string {
+	ms := float64(d) / float64(time.Millisecond)
+	switch {
+	case ms < 1000:
+		return fmt.Sprintf("%.3fms", ms)
+	case ms < 60000:
+		return fmt.Sprintf("%.3fs", ms/1000)
+	default:
+		return fmt.Sprintf("%d:%06.3f (m:ss.mmm)", int(ms/60000), math.Mod(ms, 60000)/1000)
+	}
+}
+
+// formatArgs 按 Node.js 的 util.format 格式化 console 的参数
+// 第一个参数是字符串时替换其中的占位符，剩余参数以空格连接；字符串原样输出，其他值按 inspect 的形式显示
+func (sb *Sandbox) formatArgs(args []goja.Value) string {
+	if len(args) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	rest := args
+	if goja.IsString(args[0]) {
+		rest = args[1:]
+		format := args[0].String()
+		for i := 0; i < len(format); i++ {
+			c := format[i]
+			if c != '%' || i+1 == len(format) {
+				b.WriteByte(c)
+				continue
+			}
+			verb := format[i+1]
+			if verb == '%' {
+				b.WriteByte('%')
+				i++
+				continue
+			}
+			if !strings.ContainsRune("sdifjoOc", rune(verb)) || len(rest) == 0 {
+				b.WriteByte(c)
+				continue
+			}
+			arg := rest[0]
+			rest = rest[1:]
+			i++
+			b.WriteString(sb.formatVerb(verb, arg))
+		}
+	}
+	for i, arg := range rest {
+		if i > 0 || len(rest) < len(args) {
+			b.WriteByte(' ')
+		}
+		if goja.IsString(arg) {
+			b.WriteString(arg.String())
+		} else {
+			b.WriteString(sb.newInspector(inspectDepth).inspect(arg, 0, ""))
+		}
+	}
+	return b.String()
+}
+
+// formatVerb 按占位符格式化一个参数
+func (sb *Sandbox) formatVerb(verb byte, arg goja.Value) string {
+	switch verb {
+	case 's':
+		if _, ok := arg.(*goja.Object); ok {
+			return sb.newInspector(0).inspect(arg, 0, "")
+		}
+		if goja.IsString(arg) {
+			return arg.String()
+		}
+		return sb.newInspector(0).inspect(arg, 0, "")
+	case 'd', 'i', 'f':
+		if goja.IsBigInt(arg) {
+			return arg.String() + "n"
+		}
+		if _, ok := arg.(*goja.Object); ok && verb == 'd' {
+			return "NaN"
+		}
+		if _, ok := arg.(*goja.Symbol); ok {
+			return "NaN"
+		}
+		n := arg.ToFloat()
+		if verb == 'i' && !math.IsNaN(n) && !math.IsInf(n, 0) {
+			n = math.Trunc(n)
+		}
+		return formatNumber(sb.vm.ToValue(n))
+	case 'j':
+		s, err := sb.console.stringify(goja.Undefined(), arg)
+		if err != nil {
+			return "[Circular]"
+		}
+		return s.String()
+	case 'o':
+		return sb.newInspector(4).inspect(arg, 0, "")
+	case 'O':
+		return sb.newInspector(inspectDepth).inspect(arg, 0, "")
+	}
+	return "" // %c 的 CSS 样式在终端中没有意义，直接丢弃
+}
+
+// formatNumber 返回数字的 JavaScript 字符串形式，-0 显示为 "-0"
+func formatNumber(v goja.Value) string {
+	if f := v.ToFloat(); f == 0 && math.Signbit(f) {
+		return "-0"
+	}
+	return v.String()
+}
+
+// formatTable 按 Node.js 的 console.table 格式把对象或数组的每个元素显示为一行
+// 元素是对象时每个属性为一列，否则显示在 Values 列中；columns 为要显示的列名数组
+func (sb *Sandbox) formatTable(data *goja.Object, columns goja.Value) string {
+	in := sb.newInspector(1)
+	var rowKeys []string
+	var rows []goja.Value
+	if isArray(data) {
+		for i := int64(0); i < data.Get("length").ToInteger(); i++ {
+			rowKeys = append(rowKeys, strconv.FormatInt(i, 10))
+			rows = append(rows, data.Get(strconv.FormatInt(i, 10)))
+		}
+	} else {
+		for _, key := range data.Keys() {
+			rowKeys = append(rowKeys, key)
+			rows = append(rows, data.Get(key))
+		}
+	}
+
+	var cols []string
+	fixedCols := false
+	if colObj, ok := columns.(*goja.Object); ok && isArray(colObj) {
+		fixedCols = true
+		for i := int64(0); i < colObj.Get("length").ToInteger(); i++ {
+			cols = append(cols, colObj.Get(strconv.FormatInt(i, 10)).String())
+		}
+	}
+	hasValues := false
+	cells := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		cells[i] = map[string]string{}
+		obj, ok := row.(*goja.Object)
+		if _, isFunc := goja.AssertFunction(row); !ok || isFunc {
+			hasValues = true
+			cells[i][""] = in.inspect(row, 1, "")
+			continue
+		}
+		for _, key := range obj.Keys() {
+			if !fixedCols && !containsString(cols, key) {
+				cols = append(cols, key)
+			}
+			cells[i][key] = in.inspect(obj.Get(key), 1, "")
+		}
+	}
+
+	header := append([]string{"(index)"}, cols...)
+	if hasValues {
+		header = append(header, "Values")
+	}
+	table := make([][]string, len(rows))
+	for i := range rows {
+		line := []string{rowKeys[i]}
+		for _, col := range cols {
+			line = append(line, cells[i][col])
+		}
+		if hasValues {
+			line = append(line, cells[i][""])
+		}
+		table[i] = line
+	}
+	return renderTable(header, table)
+}
+
+// renderTable 用制表符绘制表格，列宽按显示宽度计算（中文等宽字符占两列）
+func renderTable(header []string, rows [][]string) string {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = runewidth.StringWidth(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], runewidth.StringWidth(cell))
+		}
+	}
+	border := func(left, mid, right string) string {
+		parts := make([]string, len(widths))
+		for i, w := range widths {
+			parts[i] = strings.Repeat("─", w+2)
+		}
+		return left + strings.Join(parts, mid) + right
+	}
+	line := func(cells []string) string {
+		parts := make([]string, len(widths))
+		for i, w := range widths {
+			parts[i] = " " + cells[i] + strings.Repeat(" ", w-runewidth.StringWidth(cells[i])) + " "
+		}
+		return "│" + strings.Join(parts, "│") + "│"
+	}
+	lines := []string{border("┌", "┬", "┐"), line(header), border("├", "┼", "┤")}
+	for _, row := range rows {
+		lines = append(lines, line(row))
+	}
+	lines = append(lines, border("└", "┴", "┘"))
+	return strings.Join(lines, "\n")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isArray(obj *goja.Object) bool {
+	return obj.ClassName() == "Array"
+}
+
+// isTypedArray 判断对象是否为 Uint8Array、Float64Array 等 TypedArray（包括 Buffer），它们导出为数值切片
+func isTypedArray(obj *goja.Object) bool {
+	t := obj.ExportType()
+	if t == nil || t.Kind() != reflect.Slice || isArray(obj) {
+		return false
+	}
+	switch t.Elem().Kind() {
+	case reflect.Int8, reflect.Uint8, reflect.Int16, reflect.Uint16, reflect.Int32, reflect.Uint32,
+		reflect.Int64, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// inspector 按 Node.js util.inspect 的形式显示 JavaScript 值
+type inspector struct {
+	sb       *Sandbox
+	maxDepth int
+	// seen 当前路径上的对象，用于检测循环引用
+	seen []*goja.Object
+}
+
+func (sb *Sandbox) newInspector(depth int) *inspector {
+	return &inspector{sb: sb, maxDepth: depth}
+}
+
+// inspect 返回 v 的显示形式，depth 为当前嵌套深度，indent 为当前行的缩进；顶层以外的字符串带引号
+func (in *inspector) inspect(v goja.Value, depth int, indent string) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	case goja.IsString(v):
+		return quoteJSString(v.String())
+	case goja.IsNumber(v):
+		return formatNumber(v)
+	case goja.IsBigInt(v):
+		return v.String() + "n"
+	}
+	if sym, ok := v.(*goja.Symbol); ok {
+		return "Symbol(" + sym.String() + ")"
+	}
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return v.String() // 布尔值
+	}
+	for _, s := range in.seen {
+		if s == obj {
+			return "[Circular]"
+		}
+	}
+
+	if fn, ok := goja.AssertFunction(obj); ok && fn != nil {
+		name := in.sb.safeGet(obj, "name")
+		if name == "" {
+			return "[Function (anonymous)]"
+		}
+		return "[Function: " + name + "]"
+	}
+
+	prefix := in.prefix(obj)
+	switch obj.ClassName() {
+	case "Error":
+		return in.inspectError(obj, depth, indent)
+	case "Date":
+		return in.callString(obj, "toISOString", "Invalid Date")
+	case "RegExp":
+		return obj.String()
+	case "String", "Number", "Boolean":
+		// 包装对象显示为 [String: 'abc']
+		if fn, ok := goja.AssertFunction(obj.Get("valueOf")); ok {
+			if v, err := fn(obj); err == nil {
+				return "[" + obj.ClassName() + ": " + in.inspect(v, depth, indent) + "]"
+			}
+		}
+	}
+	if in.sb.bufferClass != nil && in.sb.vm.InstanceOf(obj, in.sb.bufferClass) {
+		data, _ := in.sb.exportBytes(obj)
+		return "<Buffer " + formatBytes(data) + ">"
+	}
+	if obj.ExportType() == arrayBufferType {
+		data := obj.Export().(goja.ArrayBuffer).Bytes()
+		return fmt.Sprintf("ArrayBuffer { [Uint8Contents]: <%s>, byteLength: %d }", formatBytes(data), len(data))
+	}
+	if obj.ExportType() == promiseType {
+		p := obj.Export().(*goja.Promise)
+		switch p.State() {
+		case goja.PromiseStatePending:
+			return "Promise { <pending> }"
+		case goja.PromiseStateRejected:
+			return in.wrap("Promise {", "}", []string{"<rejected> " + in.nested(p.Result(), depth, indent)}, indent)
+		default:
+			return in.wrap("Promise {", "}", []string{in.nested(p.Result(), depth, indent)}, indent)
+		}
+	}
+
+	if depth > in.maxDepth {
+		if isArray(obj) {
+			return "[Array]"
+		}
+		switch name := strings.TrimSuffix(prefix, " "); {
+		case name == "":
+			return "[Object]"
+		case strings.HasPrefix(name, "["):
+			return name
+		default:
+			return "[" + name + "]"
+		}
+	}
+
+	in.seen = append(in.seen, obj)
+	defer func() { in.seen = in.seen[:len(in.seen)-1] }()
+
+	var items []string
+	start, end := "{", "}"
+	vm := in.sb.vm
+	switch isMap := vm.InstanceOf(obj, in.sb.console.mapCtor); {
+	case isArray(obj) || isTypedArray(obj):
+		start, end = "[", "]"
+		length := obj.Get("length").ToInteger()
+		for i := int64(0); i < length && i < inspectMaxItems; i++ {
+			items = append(items, in.nested(obj.Get(strconv.FormatInt(i, 10)), depth, indent))
+		}
+		if length > inspectMaxItems {
+			items = append(items, fmt.Sprintf("... %d more items", length-inspectMaxItems))
+		}
+		if prefix == "Array " {
+			prefix = ""
+		} else if !isArray(obj) {
+			// TypedArray 显示为 Uint8Array(3) [ 1, 2, 3 ]
+			prefix = fmt.Sprintf("%s(%d) ", strings.TrimSuffix(prefix, " "), length)
+		}
+	case isMap || vm.InstanceOf(obj, in.sb.console.setCtor):
+		size := obj.Get("size").ToInteger()
+		class, method := "Set", "values"
+		if isMap {
+			class, method = "Map", "entries"
+		}
+		prefix = fmt.Sprintf("%s(%d) ", class, size)
+		in.iterate(obj, method, func(entry goja.Value) {
+			if !isMap {
+				items = append(items, in.nested(entry, depth, indent))
+				return
+			}
+			pair := entry.ToObject(vm)
+			items = append(items, in.nested(pair.Get("0"), depth, indent)+" => "+in.nested(pair.Get("1"), depth, indent))
+		})
+		if size > inspectMaxItems {
+			items = append(items, fmt.Sprintf("... %d more items", size-inspectMaxItems))
+		}
+	}
+	items = append(items, in.properties(obj, depth, indent)...)
+
+	if len(items) == 0 {
+		return prefix + start + end
+	}
+	return in.wrap(prefix+start, end, items, indent)
+}
+
+// nested 显示嵌套的值
+func (in *inspector) nested(v goja.Value, depth int, indent string) string {
+	return in.inspect(v, depth+1, indent+consoleIndent)
+}
+
+// wrap 把元素放在一行中，超过 inspectBreakLength 或元素包含换行时每个元素单独一行
+func (in *inspector) wrap(start, end string, items []string, indent string) string {
+	total := len(indent) + len(start) + len(end) + 2
+	multiline := strings.Contains(start, "\n")
+	for _, item := range items {
+		total += runewidth.StringWidth(item) + 2
+		if strings.Contains(item, "\n") {
+			multiline = true
+		}
+	}
+	if !multiline && total <= inspectBreakLength {
+		return start + " " + strings.Join(items, ", ") + " " + end
+	}
+	inner := indent + consoleIndent
+	return start + "\n" + inner + strings.Join(items, ",\n"+inner) + "\n" + indent + end
+}
+
+// properties 显示对象自身的可枚举属性（数组只显示非索引属性），访问器属性显示为 [Getter]/[Setter]，不会调用 getter
+func (in *inspector) properties(obj *goja.Object, depth int, indent string) []string {
+	var items []string
+	array := isArray(obj) || isTypedArray(obj)
+	for _, key := range obj.Keys() {
+		if array {
+			if _, err := strconv.ParseUint(key, 10, 32); err == nil {
+				continue
+			}
+		}
+		name := key
+		if !identifierPattern.MatchString(key) {
+			name = quoteJSString(key)
+		}
+		items = append(items, name+": "+in.property(obj, in.sb.vm.ToValue(key), depth, indent))
+	}
+	for _, sym := range obj.Symbols() {
+		items = append(items, "[Symbol("+sym.String()+")]: "+in.property(obj, sym, depth, indent))
+	}
+	return items
+}
+
+// property 显示一个属性的值
+func (in *inspector) property(obj *goja.Object, key goja.Value, depth int, indent string) string {
+	desc, err := in.sb.console.getOwnPropertyDescriptor(goja.Undefined(), obj, key)
+	d, ok := desc.(*goja.Object)
+	if err != nil || !ok {
+		return "undefined"
+	}
+	getter, setter := d.Get("get"), d.Get("set")
+	hasGetter := getter != nil && !goja.IsUndefined(getter)
+	hasSetter := setter != nil && !goja.IsUndefined(setter)
+	switch {
+	case hasGetter && hasSetter:
+		return "[Getter/Setter]"
+	case hasGetter:
+		return "[Getter]"
+	case hasSetter:
+		return "[Setter]"
+	}
+	return in.nested(d.Get("value"), depth, indent)
+}
+
+// inspectError 显示 Error 对象：调用栈（与 Node.js 相同的 "    at" 格式）以及 code、cause 等附加属性
+func (in *inspector) inspectError(obj *goja.Object, depth int, indent string) string {
+	name, message := in.sb.safeGet(obj, "name"), in.sb.safeGet(obj, "message")
+	text := name
+	if message != "" {
+		text = name + ": " + message
+	}
+	stack := in.sb.safeGet(obj, "stack")
+	if stack == "" {
+		text = "[" + text + "]"
+	}
+	for _, f := range parseStack(stack) {
+		text += "\n" + indent + "    at " + f.String()
+	}
+	if depth > in.maxDepth {
+		return text
+	}
+	in.seen = append(in.seen, obj)
+	defer func() { in.seen = in.seen[:len(in.seen)-1] }()
+	var items []string
+	for _, item := range in.properties(obj, depth, indent) {
+		if !strings.HasPrefix(item, "stack: ") && !strings.HasPrefix(item, "message: ") {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return text
+	}
+	return in.wrap(text+" {", "}", items, indent)
+}
+
+// prefix 返回对象的类型前缀：类的实例为 "类名 "，没有原型的对象为 "[Object: null prototype] "，普通对象为空
+func (in *inspector) prefix(obj *goja.Object) string {
+	proto := obj.Prototype()
+	if proto == nil {
+		return "[Object: null prototype] "
+	}
+	ctor, ok := proto.Get("constructor").(*goja.Object)
+	if !ok {
+		return ""
+	}
+	name := in.sb.safeGet(ctor, "name")
+	if name == "" || name == "Object" {
+		return ""
+	}
+	return name + " "
+}
+
+// callString 调用对象的无参方法并返回结果的字符串形式，出错时返回 fallback
+func (in *inspector) callString(obj *goja.Object, method, fallback string) string {
+	fn, ok := goja.AssertFunction(obj.Get(method))
+	if !ok {
+		return fallback
+	}
+	v, err := fn(obj)
+	if err != nil {
+		return fallback
+	}
+	return v.String()
+}
+
+// iterate 调用 Map/Set 的迭代方法，最多处理 inspectMaxItems 个元素
+func (in *inspector) iterate(obj *goja.Object, method string, fn func(goja.Value)) {
+	iterFn, ok := goja.AssertFunction(obj.Get(method))
+	if !ok {
+		return
+	}
+	iter, err := iterFn(obj)
+	if err != nil {
+		return
+	}
+	iterObj := iter.ToObject(in.sb.vm)
+	next, ok := goja.AssertFunction(iterObj.Get("next"))
+	if !ok {
+		return
+	}
+	for i := 0; i < inspectMaxItems; i++ {
+		res, err := next(iterObj)
+		if err != nil {
+			return
+		}
+		r := res.ToObject(in.sb.vm)
+		if r.Get("done").ToBoolean() {
+			return
+		}
+		fn(r.Get("value"))
+	}
+}
+
+// formatBytes 以空格分隔的十六进制显示字节，超过 inspectMaxBytes 的部分显示为剩余字节数
+func formatBytes(data []byte) string {
+	n := len(data)
+	if n > inspectMaxBytes {
+		n = inspectMaxBytes
+	}
+	parts := make([]string, n, n+1)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("%02x", data[i])
+	}
+	if len(data) > n {
+		parts = append(parts, fmt.Sprintf("... %d more bytes", len(data)-n))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteJSString 按 Node.js 的规则给字符串加引号：默认使用单引号，包含单引号时改用双引号或反引号
+func quoteJSString(s string) string {
+	quote := byte('\'')
+	if strings.ContainsRune(s, '\'') {
+		if !strings.ContainsRune(s, '"') {
+			quote = '"'
+		} else if !strings.ContainsRune(s, '`') && !strings.Contains(s, "${") {
+			quote = '`'
+		}
+	}
+	var b strings.Builder
+	b.WriteByte(quote)
+	for _, r := range s {
+		switch {
+		case r == rune(quote) || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '\v':
+			b.WriteString(`\v`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte(quote)
+	return b.String()
+}
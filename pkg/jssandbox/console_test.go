@@ -0,0 +1,115 @@
+package jssandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func runConsole(t *testing.T, code string) *RunResult {
+	t.Helper()
+	sb := NewSandbox(context.Background())
+	t.Cleanup(func() { sb.Close() })
+	res, err := sb.RunWithOutput(code, 0)
+	if err != nil {
+		t.Fatalf("RunWithOutput() error = %v", err)
+	}
+	return res
+}
+
+func TestConsole_Format(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"对象", `console.log({ a: 1, b: 'x', 'c-d': [1, { e: { f: { g: 1 } } }], n: null })`, "{ a: 1, b: 'x', 'c-d': [ 1, { e: [Object] } ], n: null }"},
+		{"字符串与数字", `console.log('a', 1, -0, undefined, true)`, "a 1 -0 undefined true"},
+		{"占位符", `console.log('%s=%d %i%% %j', 'x', 42.5, 3.9, { a: [1] }, 'rest')`, `x=42.5 3% {"a":[1]} rest`},
+		{"函数与类实例", `class Foo { constructor() { this.x = 1; } } console.log(function bar() {}, () => 1, new Foo())`, "[Function: bar] [Function (anonymous)] Foo { x: 1 }"},
+		{"集合", `console.log(new Map([['a', 1]]), new Set([1, 'x']), [])`, "Map(1) { 'a' => 1 } Set(2) { 1, 'x' } []"},
+		{"循环引用", `var o = { a: 1 }; o.self = o; console.log(o)`, "{ a: 1, self: [Circular] }"},
+		{"访问器", `console.log({ get g() { throw new Error('不应调用'); } })`, "{ g: [Getter] }"},
+		{"日期与引号", `console.log(new Date(0), ["it's"])`, `1970-01-01T00:00:00.000Z [ "it's" ]`},
+		{"二进制数据", `console.log(Buffer.from('hi'), new Uint8Array([1, 2]), new ArrayBuffer(1))`,
+			"<Buffer 68 69> Uint8Array(2) [ 1, 2 ] ArrayBuffer { [Uint8Contents]: <00>, byteLength: 1 }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runConsole(t, tt.code).Stdout; got != tt.want+"\n" {
+				t.Errorf("Stdout = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsole_Error(t *testing.T) {
+	res := runConsole(t, "var e = new TypeError('boom');\ne.code = 'X';\nconsole.error(e);")
+	want := "TypeError: boom\n    at <eval>:1:9 {\n  code: 'X'\n}\n"
+	if res.Stderr != want {
+		t.Errorf("Stderr = %q, want %q", res.Stderr, want)
+	}
+}
+
+func TestConsole_Methods(t *testing.T) {
+	res := runConsole(t, `
+		console.group('G');
+		console.log('a\nb');
+		console.groupEnd();
+		console.count(); console.count(); console.count('x'); console.countReset(); console.count();
+		console.time('t'); console.timeEnd('t'); console.timeEnd('t');
+		console.assert(true, 'ok'); console.assert(false, 'bad %s', 'thing');
+		console.dir('s');
+		function f() { console.trace('here'); }
+		f();
+	`)
+	lines := strings.Split(res.Output.String(), "\n")
+	want := []string{
+		"G", "  a", "  b",
+		"default: 1", "default: 2", "x: 1", "default: 1",
+		"", // timeEnd 的耗时不固定，单独检查
+		"[warn] Warning: No such label 't' for console.timeEnd()",
+		"[error] Assertion failed: bad thing",
+		"'s'",
+		"[trace] Trace: here",
+		"    at f (<eval>:9:31)",
+		"    at <eval>:10:4",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("输出 = \n%s", res.Output.String())
+	}
+	for i := range want {
+		if i == 7 {
+			if !strings.HasPrefix(lines[i], "t: ") || !strings.HasSuffix(lines[i], "ms") {
+				t.Errorf("第 %d 行 = %q, want t: <耗时>ms", i, lines[i])
+			}
+			continue
+		}
+		if lines[i] != want[i] {
+			t.Errorf("第 %d 行 = %q, want %q", i, lines[i], want[i])
+		}
+	}
+	if !strings.Contains(res.Stderr, "Trace: here") {
+		t.Errorf("console.trace 应该写入 Stderr: %q", res.Stderr)
+	}
+}
+
+func TestConsole_Table(t *testing.T) {
+	res := runConsole(t, `console.table([{ a: 1, b: '中文' }, { a: 2, c: true }])`)
+	want := strings.Join([]string{
+		"┌─────────┬───┬────────┬──────┐",
+		"│ (index) │ a │ b      │ c    │",
+		"├─────────┼───┼────────┼──────┤",
+		"│ 0       │ 1 │ '中文' │      │",
+		"│ 1       │ 2 │        │ true │",
+		"└─────────┴───┴────────┴──────┘",
+	}, "\n") + "\n"
+	if res.Stdout != want {
+		t.Errorf("Stdout = \n%s\nwant\n%s", res.Stdout, want)
+	}
+
+	res = runConsole(t, `console.table({ x: { a: 1, b: 2 } }, ['b'])`)
+	if !strings.Contains(res.Stdout, "│ (index) │ b │") || !strings.Contains(res.Stdout, "│ x       │ 2 │") {
+		t.Errorf("Stdout = \n%s", res.Stdout)
+	}
+}
@@ -17,7 +17,7 @@ import (
 
 // registerCrypto 注册加密/解密功能到JavaScript运行时
 func (sb *Sandbox) registerCrypto() {
-	// AES加密
+	// AES加密，数据和密钥可以是字符串或二进制数据；选项为 {encoding: 'binary'} 时以 Buffer 返回密文，否则返回 base64 字符串
 	sb.vm.Set("encryptAES", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 2 {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -25,11 +25,11 @@ func (sb *Sandbox) registerCrypto() {
 			})
 		}
 
-		data := call.Arguments[0].String()
-		key := call.Arguments[1].String()
+		data := sb.bytesArg(call.Arguments[0])
+		key := sb.bytesArg(call.Arguments[1])
 
 		// 将密钥转换为32字节（AES-256）
-		keyHash := sha256.Sum256([]byte(key))
+		keyHash := sha256.Sum256(key)
 		block, err := aes.NewCipher(keyHash[:])
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -54,16 +54,21 @@ func (sb *Sandbox) registerCrypto() {
 		}
 
 		// 加密
-		ciphertext := gcm.Seal(nonce, nonce, []byte(data), nil)
-		encrypted := base64.StdEncoding.EncodeToString(ciphertext)
+		ciphertext := gcm.Seal(nonce, nonce, data, nil)
+		if sb.binaryOption(call.Argument(2)) {
+			return sb.vm.ToValue(map[string]interface{}{
+				"success": true,
+				"data":    sb.newBuffer(ciphertext),
+			})
+		}
 
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
-			"data":    encrypted,
+			"data":    base64.StdEncoding.EncodeToString(ciphertext),
 		})
 	})
 
-	// AES解密
+	// AES解密，密文可以是 base64 字符串或二进制数据；选项为 {encoding: 'binary'} 时以 Buffer 返回明文
 	sb.vm.Set("decryptAES", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 2 {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -71,19 +76,22 @@ func (sb *Sandbox) registerCrypto() {
 			})
 		}
 
-		encrypted := call.Arguments[0].String()
-		key := call.Arguments[1].String()
-
-		// 解码base64
-		ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
-		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": fmt.Sprintf("解码base64失败: %v", err),
-			})
+		key := sb.bytesArg(call.Arguments[1])
+
+		ciphertext, ok := sb.exportBytes(call.Arguments[0])
+		if !ok {
+			// 解码base64
+			var err error
+			ciphertext, err = base64.StdEncoding.DecodeString(call.Arguments[0].String())
+			if err != nil {
+				return sb.vm.ToValue(map[string]interface{}{
+					"error": fmt.Sprintf("解码base64失败: %v", err),
+				})
+			}
 		}
 
 		// 将密钥转换为32字节（AES-256）
-		keyHash := sha256.Sum256([]byte(key))
+		keyHash := sha256.Sum256(key)
 		block, err := aes.NewCipher(keyHash[:])
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -119,13 +127,19 @@ func (sb *Sandbox) registerCrypto() {
 
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
-			"data":    string(plaintext),
+			"data":    sb.bytesResult(plaintext, sb.binaryOption(call.Argument(2))),
 		})
 	})
 
-	// SHA256哈希
-	sb.vm.Set("hashSHA256", func(data string) goja.Value {
-		hash := sha256.Sum256([]byte(data))
+	// SHA256哈希，数据可以是字符串或二进制数据；选项为 {encoding: 'binary'} 时以 Buffer 返回摘要，否则返回十六进制字符串
+	sb.vm.Set("hashSHA256", func(data, options goja.Value) goja.Value {
+		hash := sha256.Sum256(sb.bytesArg(data))
+		if sb.binaryOption(options) {
+			return sb.vm.ToValue(map[string]interface{}{
+				"success": true,
+				"hash":    sb.newBuffer(hash[:]),
+			})
+		}
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
 			"hash":    hex.EncodeToString(hash[:]),
@@ -1,9 +1,9 @@
 package jssandbox
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/dop251/goja"
@@ -45,7 +45,15 @@ func (sb *Sandbox) registerCSV() {
 			}
 		}
 
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		file, err := sb.fs.Open(filePath)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("打开文件失败: %v", err),
@@ -91,7 +99,8 @@ func (sb *Sandbox) registerCSV() {
 	})
 
 	// 写入CSV文件
-	sb.vm.Set("writeCSV", func(call goja.FunctionCall) goja.Value {
+	sb.vm.Set("writeCSV", func(call goja.FunctionCall) (result goja.Value) {
+		defer sb.auditValue(AuditEvent{Module: "csv", Operation: "writeCSV", Target: call.Argument(0).String()}, &result)
 		if len(call.Arguments) < 2 {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": "需要提供文件路径和数据参数",
@@ -131,15 +140,8 @@ func (sb *Sandbox) registerCSV() {
 			}
 		}
 
-		file, err := os.Create(filePath)
-		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": fmt.Sprintf("创建文件失败: %v", err),
-			})
-		}
-		defer file.Close()
-
-		writer := csv.NewWriter(file)
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
 		writer.Comma = delimiter
 
 		if err := writer.WriteAll(rows); err != nil {
@@ -155,6 +157,20 @@ func (sb *Sandbox) registerCSV() {
 			})
 		}
 
+		// 写入文件前校验文件策略
+		hostPath, err := sb.resolvePath(filePath, fsWrite)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileWrite(hostPath, buf.Bytes()); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := writeFileFS(sb.fs, hostPath, buf.Bytes(), 0644); err != nil {
+			return sb.vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("创建文件失败: %v", err),
+			})
+		}
+
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
 			"path":    filePath,
@@ -2,6 +2,7 @@ package jssandbox
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/ZeroHawkeye/wordZero/pkg/document"
 	"github.com/dop251/goja"
@@ -16,7 +17,14 @@ func (sb *Sandbox) registerDocx() {
 
 	// 打开现有文档
 	sb.vm.Set("docxOpen", func(filePath string) (goja.Value, error) {
-		doc, err := document.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return nil, err
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return nil, err
+		}
+		doc, err := sb.openDocx(filePath)
 		if err != nil {
 			return nil, err
 		}
@@ -24,11 +32,19 @@ func (sb *Sandbox) registerDocx() {
 	})
 
 	// 保存文档
-	sb.vm.Set("docxSave", func(doc *document.Document, filePath string) error {
+	sb.vm.Set("docxSave", func(doc *document.Document, filePath string) (err error) {
+		defer sb.auditError(AuditEvent{Module: "docx", Operation: "docxSave", Target: filePath}, &err)
 		if doc == nil {
 			return fmt.Errorf("文档对象不能为空")
 		}
-		return doc.Save(filePath)
+		filePath, err = sb.resolvePath(filePath, fsWrite)
+		if err != nil {
+			return err
+		}
+		if err := sb.checkOutputName(filePath); err != nil {
+			return err
+		}
+		return sb.saveDocx(doc, filePath)
 	})
 
 	// 添加普通段落
@@ -165,7 +181,14 @@ func (sb *Sandbox) registerDocx() {
 
 	// 获取文档中的所有文本（包含表格内容）
 	sb.vm.Set("docxReadText", func(filePath string) (string, error) {
-		doc, err := document.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return "", err
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return "", err
+		}
+		doc, err := sb.openDocx(filePath)
 		if err != nil {
 			return "", err
 		}
@@ -196,3 +219,24 @@ func (sb *Sandbox) registerDocx() {
 		return result, nil
 	})
 }
+
+// openDocx 通过沙盒文件系统打开 Word 文档
+func (sb *Sandbox) openDocx(filePath string) (*document.Document, error) {
+	file, err := sb.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return document.OpenFromMemory(file)
+}
+
+// saveDocx 把 Word 文档写入沙盒文件系统，与 Save 一样自动创建父目录；内容不符合文件策略时不写入
+func (sb *Sandbox) saveDocx(doc *document.Document, filePath string) error {
+	data, err := doc.ToBytes()
+	if err != nil {
+		return err
+	}
+	if err := sb.fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	return sb.writeCheckedFile(filePath, data)
+}
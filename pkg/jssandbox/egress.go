@@ -0,0 +1,332 @@
+package jssandbox
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EgressPolicy 网络出站策略
+//
+// 策略统一作用于 httpRequest/fetch、浏览器会话以及 resolveDNS/ping/checkPort 等网络工具，检查顺序为：
+//   - 协议不在 AllowedSchemes 中时拒绝（为空时只允许 http 和 https）
+//   - 主机名匹配 DeniedHosts，或 AllowedHosts 非空且不匹配时拒绝
+//   - 端口在 DeniedPorts 中，或 AllowedPorts 非空且不包含该端口时拒绝
+//   - 目标地址在 DeniedCIDRs 中时拒绝，在 AllowedCIDRs 中时允许
+//   - 其余回环、私有、链路本地（含云元数据地址 169.254.169.254）等内部地址，AllowPrivateNetwork 为 false 时拒绝
+//
+// 地址检查在建立连接时针对实际连接的 IP 进行，因此 DNS 重绑定也无法绕过。
+// 主机名不区分大小写，"*.example.com" 匹配 example.com 的所有子域名（不含 example.com 本身）。
+type EgressPolicy struct {
+	// AllowedHosts 允许访问的主机名，为空表示不限制
+	AllowedHosts []string
+	// DeniedHosts 禁止访问的主机名
+	DeniedHosts []string
+	// AllowedCIDRs 允许访问的网段，可用于放行特定的内部地址，如 "10.1.0.0/16"、"192.168.1.10"
+	AllowedCIDRs []string
+	// DeniedCIDRs 禁止访问的网段，优先于 AllowedCIDRs
+	DeniedCIDRs []string
+	// AllowedPorts 允许访问的端口，为空表示不限制
+	AllowedPorts []int
+	// DeniedPorts 禁止访问的端口
+	DeniedPorts []int
+	// AllowedSchemes 允许的 URL 协议，为空时只允许 http 和 https
+	AllowedSchemes []string
+	// AllowPrivateNetwork 是否允许访问回环、私有网段等内部地址
+	AllowPrivateNetwork bool
+}
+
+// internalPrefixes 除 netip 已能识别的回环、私有、链路本地、组播地址之外的内部或保留网段
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"),   // 运营商级 NAT，部分云厂商的元数据服务使用该网段
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF 协议分配
+	netip.MustParsePrefix("192.0.2.0/24"),    // 文档示例
+	netip.MustParsePrefix("198.18.0.0/15"),   // 基准测试
+	netip.MustParsePrefix("198.51.100.0/24"), // 文档示例
+	netip.MustParsePrefix("203.0.113.0/24"),  // 文档示例
+	netip.MustParsePrefix("240.0.0.0/4"),     // 保留地址和广播地址
+	netip.MustParsePrefix("2001:db8::/32"),   // 文档示例
+}
+
+// nat64Prefix NAT64 地址前缀，地址的最后 4 个字节是嵌入的 IPv4 地址
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// isInternalAddr 判断地址是否属于回环、私有、链路本地等内部或保留地址
+func isInternalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if nat64Prefix.Contains(addr) {
+		b := addr.As16()
+		return isInternalAddr(netip.AddrFrom4([4]byte(b[12:])))
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, p := range internalPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// newNetworkPolicyError 创建网络出站策略错误
+func newNetworkPolicyError(format string, args ...interface{}) *SandboxError {
+	return NewSandboxError(ErrCodeNetworkPolicy, fmt.Sprintf(format, args...))
+}
+
+// policyError 返回错误链中的沙盒错误（违反出站策略、缺少网络权限等），不存在时返回 nil
+// HTTP 客户端和拨号器会把这类错误包装在 url.Error、net.OpError 中，取出后错误信息更清晰
+func policyError(err error) *SandboxError {
+	var sbErr *SandboxError
+	if errors.As(err, &sbErr) {
+		return sbErr
+	}
+	return nil
+}
+
+// egressGuard 编译后的网络出站策略
+type egressGuard struct {
+	allowedHosts []string
+	deniedHosts  []string
+	allowedNets  []netip.Prefix
+	deniedNets   []netip.Prefix
+	allowedPorts map[int]bool
+	deniedPorts  map[int]bool
+	schemes      map[string]bool
+	allowPrivate bool
+	// permit 额外的主机访问检查，沙盒用它检查网络权限，为 nil 时不检查
+	permit func(host string, port int) error
+}
+
+// newEgressGuard 根据配置编译网络出站策略，无法解析的网段会被忽略并记录警告
+func newEgressGuard(policy EgressPolicy, logger *logrus.Logger) *egressGuard {
+	g := &egressGuard{
+		allowedHosts: normalizeHosts(policy.AllowedHosts),
+		deniedHosts:  normalizeHosts(policy.DeniedHosts),
+		allowedNets:  parsePrefixes(policy.AllowedCIDRs, logger),
+		deniedNets:   parsePrefixes(policy.DeniedCIDRs, logger),
+		allowedPorts: make(map[int]bool),
+		deniedPorts:  make(map[int]bool),
+		schemes:      make(map[string]bool),
+		allowPrivate: policy.AllowPrivateNetwork,
+	}
+	for _, p := range policy.AllowedPorts {
+		g.allowedPorts[p] = true
+	}
+	for _, p := range policy.DeniedPorts {
+		g.deniedPorts[p] = true
+	}
+	schemes := policy.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, s := range schemes {
+		g.schemes[strings.ToLower(strings.TrimSuffix(s, ":"))] = true
+	}
+	return g
+}
+
+// normalizeHost 规范化主机名：转为小写，去掉 IPv6 方括号和末尾的点
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(host, ".")
+}
+
+func normalizeHosts(hosts []string) []string {
+	var result []string
+	for _, h := range hosts {
+		if h = normalizeHost(h); h != "" {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// parsePrefixes 解析网段列表，单个 IP 视为只包含该地址的网段
+func parsePrefixes(cidrs []string, logger *logrus.Logger) []netip.Prefix {
+	var result []netip.Prefix
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if p, err := netip.ParsePrefix(c); err == nil {
+			if p.Addr().Is4In6() {
+				p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+			}
+			result = append(result, p.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(c); err == nil {
+			addr = addr.Unmap().WithZone("")
+			result = append(result, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		logger.WithField("cidr", c).Warn("无法解析网络出站策略中的网段，已忽略")
+	}
+	return result
+}
+
+// matchHost 判断主机名是否匹配模式，"*.example.com" 匹配所有子域名
+func matchHost(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return pattern == host
+}
+
+// defaultPort 返回协议的默认端口，未知协议返回 0
+func defaultPort(scheme string) int {
+	switch scheme {
+	case "http", "ws":
+		return 80
+	case "https", "wss":
+		return 443
+	case "ftp":
+		return 21
+	}
+	return 0
+}
+
+// checkURL 检查 URL 的协议、主机名和端口，主机名是 IP 地址时同时检查地址
+func (g *egressGuard) checkURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !g.schemes[scheme] {
+		return newNetworkPolicyError("不允许的协议: %s", u.Scheme)
+	}
+	port := defaultPort(scheme)
+	if p := u.Port(); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return newNetworkPolicyError("无效的端口: %s", p)
+		}
+		port = n
+	}
+	return g.checkHostPort(u.Hostname(), port)
+}
+
+// checkHostPort 检查主机名和端口，port 为 0 时不检查端口（如 DNS 解析）
+func (g *egressGuard) checkHostPort(host string, port int) error {
+	if err := g.checkHost(host); err != nil {
+		return err
+	}
+	if port != 0 {
+		if err := g.checkPort(port); err != nil {
+			return err
+		}
+	}
+	if g.permit != nil {
+		return g.permit(host, port)
+	}
+	return nil
+}
+
+// checkHost 检查主机名是否在允许和禁止列表中，主机名是 IP 地址时同时检查地址
+func (g *egressGuard) checkHost(host string) error {
+	host = normalizeHost(host)
+	if host == "" {
+		return newNetworkPolicyError("缺少主机名")
+	}
+	for _, pattern := range g.deniedHosts {
+		if matchHost(pattern, host) {
+			return newNetworkPolicyError("禁止访问主机: %s", host)
+		}
+	}
+	if len(g.allowedHosts) > 0 {
+		allowed := false
+		for _, pattern := range g.allowedHosts {
+			if matchHost(pattern, host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return newNetworkPolicyError("主机不在允许列表中: %s", host)
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return g.checkAddr(addr)
+	}
+	return nil
+}
+
+// checkPort 检查端口是否在允许和禁止列表中
+func (g *egressGuard) checkPort(port int) error {
+	if g.deniedPorts[port] || (len(g.allowedPorts) > 0 && !g.allowedPorts[port]) {
+		return newNetworkPolicyError("禁止访问端口: %d", port)
+	}
+	return nil
+}
+
+// checkAddr 检查 IP 地址是否允许访问
+func (g *egressGuard) checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap().WithZone("")
+	for _, p := range g.deniedNets {
+		if p.Contains(addr) {
+			return newNetworkPolicyError("禁止访问地址: %s", addr)
+		}
+	}
+	for _, p := range g.allowedNets {
+		if p.Contains(addr) {
+			return nil
+		}
+	}
+	if !g.allowPrivate && isInternalAddr(addr) {
+		return newNetworkPolicyError("禁止访问内部网络地址: %s", addr)
+	}
+	return nil
+}
+
+// dialControl 在建立连接前检查实际连接的地址和端口，用作 net.Dialer.Control
+// 此时域名已经解析完成，检查的是最终连接的 IP，可以防止 DNS 重绑定
+func (g *egressGuard) dialControl(network, address string, _ syscall.RawConn) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return newNetworkPolicyError("无法识别的连接地址: %s", address)
+	}
+	if err := g.checkAddr(addr); err != nil {
+		return err
+	}
+	port, _ := strconv.Atoi(portStr)
+	return g.checkPort(port)
+}
+
+// dialer 创建在连接时执行地址检查的拨号器
+func (g *egressGuard) dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   g.dialControl,
+	}
+}
+
+// newTransport 创建受出站策略约束的 HTTP Transport
+// 不使用环境变量中的代理，否则实际连接的是代理服务器，地址检查会失效
+func (g *egressGuard) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = g.dialer(30 * time.Second).DialContext
+	return transport
+}
+
+// checkRedirect 检查重定向目标，用作 http.Client.CheckRedirect
+func (g *egressGuard) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return g.checkURL(req.URL)
+}
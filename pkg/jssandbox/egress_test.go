@@ -0,0 +1,281 @@
+package jssandbox
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// expectNetworkPolicy 断言结果对象是违反网络出站策略的错误
+func expectNetworkPolicy(t *testing.T, sb *Sandbox, code string) {
+	t.Helper()
+	result, err := sb.Run(code)
+	if err != nil {
+		t.Fatalf("%s error = %v", code, err)
+	}
+	resultObj := result.ToObject(sb.vm)
+	if c := resultObj.Get("code"); c == nil || c.String() != string(ErrCodeNetworkPolicy) {
+		t.Errorf("%s 应该返回 code=%s, got %v", code, ErrCodeNetworkPolicy, resultObj.Export())
+	}
+}
+
+func TestIsInternalAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.100.100.200", true},
+		{"0.0.0.0", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"fd00:ec2::254", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"8.8.8.8", false},
+		{"64:ff9b::808:808", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := isInternalAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isInternalAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestEgressGuard_CheckURL(t *testing.T) {
+	config := DefaultConfig().
+		WithAllowedHosts("*.example.com", "api.test", "8.8.8.8", "10.0.0.5").
+		WithDeniedHosts("admin.example.com").
+		WithAllowedCIDRs("10.0.0.0/24").
+		WithDeniedCIDRs("10.0.0.5").
+		WithDeniedPorts(8443)
+	guard := newEgressGuard(config.Egress, GetLogger())
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://www.example.com/path", true},
+		{"http://API.TEST./", true},
+		{"https://example.com", false},
+		{"https://admin.example.com", false},
+		{"https://www.example.com:8443", false},
+		{"ftp://www.example.com", false},
+		{"file:///etc/passwd", false},
+		{"http://other.test", false},
+		{"http://8.8.8.8", true},
+		{"http://10.0.0.5", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		err := guard.checkURL(u)
+		if (err == nil) != tt.allowed {
+			t.Errorf("checkURL(%s) error = %v, allowed %v", tt.url, err, tt.allowed)
+		}
+		if err != nil && !policyError(err).IsNetworkPolicyViolation() {
+			t.Errorf("checkURL(%s) 应该返回网络出站策略错误, got %v", tt.url, err)
+		}
+	}
+
+	t.Run("放行的内部网段", func(t *testing.T) {
+		if err := guard.dialControl("tcp", "10.0.0.8:80", nil); err != nil {
+			t.Errorf("AllowedCIDRs 中的地址应该允许连接, got %v", err)
+		}
+		if err := guard.dialControl("tcp", "10.0.1.8:80", nil); err == nil {
+			t.Error("AllowedCIDRs 之外的内部地址应该被拒绝")
+		}
+	})
+}
+
+func TestEgressPolicy_HTTP(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+	ctx := context.Background()
+
+	t.Run("默认禁止访问内部地址", func(t *testing.T) {
+		sb := NewSandbox(ctx)
+		defer sb.Close()
+
+		expectNetworkPolicy(t, sb, `httpRequest("`+server.URL+`")`)
+		// 域名解析到回环地址时在连接阶段被拦截
+		expectNetworkPolicy(t, sb, `httpRequest("http://localhost:`+serverURL.Port()+`")`)
+		if n := atomic.LoadInt32(&hits); n != 0 {
+			t.Errorf("被拒绝的请求不应该到达服务器, got %d", n)
+		}
+	})
+
+	t.Run("允许的网段", func(t *testing.T) {
+		sb := NewSandboxWithConfig(ctx, DefaultConfig().WithAllowedCIDRs("127.0.0.0/8", "::1"))
+		defer sb.Close()
+
+		result, err := sb.Run(`httpRequest("` + server.URL + `").body`)
+		if err != nil || result.String() != "ok" {
+			t.Errorf("AllowedCIDRs 中的地址应该允许访问, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("端口和主机名", func(t *testing.T) {
+		sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true).WithDeniedPorts(port))
+		defer sb.Close()
+		expectNetworkPolicy(t, sb, `httpRequest("`+server.URL+`")`)
+
+		sb2 := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true).WithAllowedHosts("example.com"))
+		defer sb2.Close()
+		expectNetworkPolicy(t, sb2, `httpRequest("`+server.URL+`")`)
+
+		sb3 := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true).WithAllowedSchemes("https"))
+		defer sb3.Close()
+		expectNetworkPolicy(t, sb3, `httpRequest("`+server.URL+`")`)
+	})
+
+	t.Run("重定向目标同样受限", func(t *testing.T) {
+		sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true).WithDeniedHosts("localhost"))
+		defer sb.Close()
+
+		before := atomic.LoadInt32(&hits)
+		target := url.QueryEscape("http://localhost:" + serverURL.Port() + "/target")
+		expectNetworkPolicy(t, sb, `httpRequest("`+server.URL+`/redirect?to=`+target+`")`)
+		if n := atomic.LoadInt32(&hits) - before; n != 1 {
+			t.Errorf("重定向目标不应该被请求, got %d 次请求", n)
+		}
+	})
+
+	t.Run("fetch", func(t *testing.T) {
+		sb := NewSandbox(ctx)
+		defer sb.Close()
+
+		result, err := sb.RunWithTimeout(`
+			fetch("`+server.URL+`").then(
+				function() { return "resolved"; },
+				function(e) { return e instanceof TypeError ? e.code : "wrong error"; }
+			);
+		`, 5*time.Second)
+		if err != nil {
+			t.Fatalf("fetch() error = %v", err)
+		}
+		if result.String() != string(ErrCodeNetworkPolicy) {
+			t.Errorf("fetch()应该以带 code 的 TypeError 拒绝, got %s", result.String())
+		}
+	})
+}
+
+func TestEgressPolicy_NetworkTools(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听端口失败: %v", err)
+	}
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	ctx := context.Background()
+
+	t.Run("默认禁止访问内部地址", func(t *testing.T) {
+		sb := NewSandbox(ctx)
+		defer sb.Close()
+
+		expectNetworkPolicy(t, sb, `checkPort("127.0.0.1", `+port+`)`)
+		expectNetworkPolicy(t, sb, `checkPort("localhost", `+port+`)`)
+		expectNetworkPolicy(t, sb, `ping("169.254.169.254", 1)`)
+		expectNetworkPolicy(t, sb, `resolveDNS("localhost")`)
+	})
+
+	t.Run("允许访问内部地址", func(t *testing.T) {
+		sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
+		defer sb.Close()
+
+		result, err := sb.Run(`checkPort("127.0.0.1", ` + port + `).open`)
+		if err != nil || !result.ToBoolean() {
+			t.Errorf("允许访问内部地址时端口应该是开放的, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("端口限制", func(t *testing.T) {
+		sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true).WithAllowedPorts(443))
+		defer sb.Close()
+
+		expectNetworkPolicy(t, sb, `checkPort("127.0.0.1", `+port+`)`)
+	})
+}
+
+func TestEgressProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls"))
+	}))
+	defer tlsServer.Close()
+
+	newClient := func(t *testing.T, policy EgressPolicy) *http.Client {
+		proxy, err := startEgressProxy(newEgressGuard(policy, GetLogger()))
+		if err != nil {
+			t.Fatalf("startEgressProxy() error = %v", err)
+		}
+		t.Cleanup(func() { proxy.Close() })
+		transport := tlsServer.Client().Transport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: proxy.Addr()})
+		return &http.Client{Transport: transport, Timeout: 5 * time.Second}
+	}
+
+	t.Run("拒绝内部地址", func(t *testing.T) {
+		client := newClient(t, EgressPolicy{})
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("代理应该返回 403, got %d", resp.StatusCode)
+		}
+		if _, err := client.Get(tlsServer.URL); err == nil {
+			t.Error("CONNECT 到内部地址应该失败")
+		}
+	})
+
+	t.Run("允许内部地址", func(t *testing.T) {
+		client := newClient(t, EgressPolicy{AllowPrivateNetwork: true})
+		for _, target := range []string{server.URL, tlsServer.URL} {
+			resp, err := client.Get(target)
+			if err != nil {
+				t.Fatalf("Get(%s) error = %v", target, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Get(%s) status = %d", target, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestBrowserNavigate_EgressPolicy(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	// 导航前先检查 URL，被拒绝时不会启动浏览器
+	for _, target := range []string{"file:///etc/passwd", "http://169.254.169.254/latest/meta-data/", "http://127.0.0.1:9222/json"} {
+		expectNetworkPolicy(t, sb, `createBrowserSession().navigate("`+target+`")`)
+	}
+}
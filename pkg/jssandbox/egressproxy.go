@@ -0,0 +1,148 @@
+package jssandbox
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// egressProxy 浏览器使用的本地出站代理
+//
+// Chrome 自行解析域名并建立连接，请求拦截只能检查 URL。让浏览器的所有连接都经过该代理，
+// 由受出站策略约束的拨号器在连接时检查实际地址，浏览器同样不会受到 DNS 重绑定的影响。
+type egressProxy struct {
+	guard    *egressGuard
+	listener net.Listener
+	server   *http.Server
+	proxy    *httputil.ReverseProxy
+	dialer   *net.Dialer
+	// ctx 在代理关闭时取消，用于断开被劫持的隧道连接
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startEgressProxy 在回环地址的随机端口上启动出站代理
+func startEgressProxy(guard *egressGuard) (*egressProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	transport := guard.newTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &egressProxy{
+		guard:    guard,
+		listener: listener,
+		dialer:   guard.dialer(30 * time.Second),
+		ctx:      ctx,
+		cancel:   cancel,
+		proxy: &httputil.ReverseProxy{
+			// 代理请求的 URL 已经是绝对地址，无需改写
+			Rewrite:      func(*httputil.ProxyRequest) {},
+			Transport:    transport,
+			ErrorHandler: proxyErrorHandler,
+		},
+	}
+	p.server = &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go p.server.Serve(listener)
+	return p, nil
+}
+
+// Addr 返回代理的监听地址
+func (p *egressProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Close 关闭代理和所有正在转发的连接
+func (p *egressProxy) Close() error {
+	p.cancel()
+	err := p.server.Close()
+	p.wg.Wait()
+	p.proxy.Transport.(*http.Transport).CloseIdleConnections()
+	return err
+}
+
+// ServeHTTP 处理 CONNECT 隧道和普通 HTTP 代理请求
+func (p *egressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "只支持代理请求", http.StatusBadRequest)
+		return
+	}
+	if err := p.guard.checkURL(r.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	p.proxy.ServeHTTP(w, r)
+}
+
+// serveConnect 建立 TCP 隧道，用于 HTTPS 和 WebSocket 连接
+func (p *egressProxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	host, portStr, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	port, _ := strconv.Atoi(portStr)
+	if err := p.guard.checkHostPort(host, port); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		proxyErrorHandler(w, r, err)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "不支持 CONNECT", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	// 被劫持的连接不受 server.Close 管理，代理关闭时需要主动断开
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		done := make(chan struct{}, 2)
+		go func() { io.Copy(upstream, client); done <- struct{}{} }()
+		go func() { io.Copy(client, upstream); done <- struct{}{} }()
+		select {
+		case <-done:
+		case <-p.ctx.Done():
+		}
+		client.Close()
+		upstream.Close()
+	}()
+}
+
+// proxyErrorHandler 把上游错误转换为代理响应，违反出站策略时返回 403
+func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if policyErr := policyError(err); policyErr != nil {
+		http.Error(w, policyErr.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
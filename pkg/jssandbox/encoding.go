@@ -10,7 +10,7 @@ import (
 
 // registerEncoding 注册编码/解码增强功能到JavaScript运行时
 func (sb *Sandbox) registerEncoding() {
-	// Base64编码（增强版，支持文件）
+	// Base64编码（增强版，支持文件），数据可以是字符串或 Buffer、ArrayBuffer 等二进制数据
 	sb.vm.Set("encodeBase64", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 1 {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -18,12 +18,7 @@ func (sb *Sandbox) registerEncoding() {
 			})
 		}
 
-		var data string
-		if arg := call.Arguments[0]; arg != nil && !goja.IsUndefined(arg) {
-			data = arg.String()
-		}
-
-		encoded := base64.StdEncoding.EncodeToString([]byte(data))
+		encoded := base64.StdEncoding.EncodeToString(sb.bytesArg(call.Arguments[0]))
 
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
@@ -31,8 +26,8 @@ func (sb *Sandbox) registerEncoding() {
 		})
 	})
 
-	// Base64解码
-	sb.vm.Set("decodeBase64", func(encoded string) goja.Value {
+	// Base64解码，选项为 {encoding: 'binary'} 时以 Buffer 返回数据
+	sb.vm.Set("decodeBase64", func(encoded string, options goja.Value) goja.Value {
 		decoded, err := base64.StdEncoding.DecodeString(encoded)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -42,7 +37,7 @@ func (sb *Sandbox) registerEncoding() {
 
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
-			"data":    string(decoded),
+			"data":    sb.bytesResult(decoded, sb.binaryOption(options)),
 		})
 	})
 
@@ -87,4 +82,7 @@ func (sb *Sandbox) registerEncoding() {
 			"data":    decoded,
 		})
 	})
+
+	// URL、TextEncoder、atob 等 Web 标准全局对象
+	sb.registerWebGlobals()
 }
@@ -13,6 +13,9 @@ import (
 func (sb *Sandbox) registerEnv() {
 	// 获取环境变量
 	sb.vm.Set("getEnv", func(name string) goja.Value {
+		if err := sb.checkPermission(PermEnv, name); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 		value := os.Getenv(name)
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
@@ -22,14 +25,18 @@ func (sb *Sandbox) registerEnv() {
 	})
 
 	// 获取所有环境变量
+	// 没有全部环境变量的权限（"*"）时只返回允许读取的变量，不逐个询问
 	sb.vm.Set("getEnvAll", func() goja.Value {
+		all := sb.checkPermission(PermEnv, "*") == nil
 		env := make(map[string]string)
 		for _, e := range os.Environ() {
 			for i := 0; i < len(e); i++ {
 				if e[i] == '=' {
 					key := e[:i]
 					value := e[i+1:]
-					env[key] = value
+					if all || sb.perms.allows(PermEnv, key) {
+						env[key] = value
+					}
 					break
 				}
 			}
@@ -42,7 +49,14 @@ func (sb *Sandbox) registerEnv() {
 
 	// 读取配置文件（支持JSON和YAML）
 	sb.vm.Set("readConfig", func(filePath string) goja.Value {
-		data, err := os.ReadFile(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		data, err := readFileFS(sb.fs, filePath)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("读取文件失败: %v", err),
@@ -0,0 +1,217 @@
+package jssandbox
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+
+	"github.com/dop251/goja"
+)
+
+// plainObjectType 普通 JavaScript 对象和 Go map 导出的类型，用于区分宿主函数返回的 Go 对象（如 Excel 文件）
+var plainObjectType = reflect.TypeOf(map[string]interface{}(nil))
+
+// moduleErrorCodes 内置模块的默认错误码，ErrorModeThrow 下宿主函数返回的错误没有 code 时使用
+var moduleErrorCodes = map[string]ErrorCode{
+	"system":     ErrCodeSystemError,
+	"process":    ErrCodeSystemError,
+	"env":        ErrCodeSystemError,
+	"network":    ErrCodeSystemError,
+	"logger":     ErrCodeInvalidInput,
+	"crypto":     ErrCodeInvalidInput,
+	"compress":   ErrCodeInvalidInput,
+	"csv":        ErrCodeInvalidInput,
+	"validation": ErrCodeInvalidInput,
+	"datetime":   ErrCodeInvalidInput,
+	"encoding":   ErrCodeInvalidInput,
+	"path":       ErrCodeInvalidInput,
+	"text":       ErrCodeInvalidInput,
+	"http":       ErrCodeHTTPError,
+	"fs":         ErrCodeFileSystemError,
+	"filetype":   ErrCodeFileSystemError,
+	"browser":    ErrCodeBrowserError,
+	"pdf":        ErrCodeDocumentError,
+	"docx":       ErrCodeDocumentError,
+	"excel":      ErrCodeDocumentError,
+	"goquery":    ErrCodeDocumentError,
+	"image":      ErrCodeImageError,
+}
+
+// sandboxErrorSource 定义脚本中的 SandboxError 类型：继承 Error，带 code 和 cause 属性
+// 脚本也可以自己创建：new SandboxError('INVALID_INPUT', '参数错误', { cause: e })
+const sandboxErrorSource = `(function (base) {
+	function SandboxError(code, message, options) {
+		var err = Reflect.construct(Error, [message], new.target || SandboxError);
+		err.code = code === undefined ? 'UNKNOWN_ERROR' : String(code);
+		if (options && 'cause' in options) {
+			err.cause = options.cause;
+		}
+		return err;
+	}
+	SandboxError.prototype = Object.create(base, {
+		constructor: { value: SandboxError, writable: true, configurable: true },
+		name: { value: 'SandboxError', writable: true, configurable: true }
+	});
+	return SandboxError;
+})`
+
+// registerSandboxError 注册全局 SandboxError 构造函数
+// 原型继承自 goja 的 GoError，Go 代码可以通过 errors.As 从未捕获的异常中取回原始的 *SandboxError
+func (sb *Sandbox) registerSandboxError() {
+	// 使用单独的文件名，使构造函数的调用栈帧不会被当作用户代码中的出错位置
+	define, err := sb.vm.RunScript("<sandbox>", sandboxErrorSource)
+	if err != nil {
+		panic(err)
+	}
+	fn, _ := goja.AssertFunction(define)
+	base := sb.vm.NewGoError(errors.New("")).Prototype()
+	class, err := fn(goja.Undefined(), base)
+	if err != nil {
+		panic(err)
+	}
+	sb.errorClass = class.ToObject(sb.vm)
+	sb.throwMark = goja.NewSymbol("sandbox.throw")
+	sb.interceptMark = goja.NewSymbol("sandbox.intercept")
+	sb.vm.Set("SandboxError", sb.errorClass)
+}
+
+// newJSError 把 Go 错误转换为 JavaScript SandboxError 异常，错误码取自 SandboxError，没有时为 ErrCodeUnknown
+func (sb *Sandbox) newJSError(err error) *goja.Object {
+	return sb.newJSErrorWithCode(err, ErrCodeUnknown)
+}
+
+// newJSErrorWithCode 把 Go 错误转换为 JavaScript SandboxError 异常，错误链中没有 SandboxError 时使用 code
+// （文件不存在时为 ErrCodeFileNotFound）；errors.Unwrap 得到的原因依次转换为 cause 属性，原始 Go 错误保存在不可枚举的 value 属性中
+func (sb *Sandbox) newJSErrorWithCode(err error, code ErrorCode) *goja.Object {
+	message := err.Error()
+	cause := errors.Unwrap(err)
+	var sbErr *SandboxError
+	if errors.As(err, &sbErr) {
+		code = sbErr.Code
+		if err == error(sbErr) {
+			message, cause = sbErr.Message, sbErr.Cause
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		code = ErrCodeFileNotFound
+	}
+	jsErr := sb.newSandboxError(code, message, cause)
+	jsErr.DefineDataProperty("value", sb.vm.ToValue(err), goja.FLAG_FALSE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	return jsErr
+}
+
+// newSandboxError 创建 SandboxError 异常对象，cause 不为 nil 时转换为 cause 属性
+func (sb *Sandbox) newSandboxError(code ErrorCode, message string, cause error) *goja.Object {
+	args := []goja.Value{sb.vm.ToValue(string(code)), sb.vm.ToValue(message)}
+	if cause != nil {
+		options := sb.vm.NewObject()
+		options.Set("cause", sb.jsCause(cause))
+		args = append(args, options)
+	}
+	jsErr, err := sb.vm.New(sb.errorClass, args...)
+	if err != nil {
+		panic(err)
+	}
+	return jsErr
+}
+
+// jsCause 把错误链中的原因转换为 JavaScript 值：SandboxError 转换为 SandboxError，其他错误转换为 Error
+func (sb *Sandbox) jsCause(err error) goja.Value {
+	if sbErr, ok := err.(*SandboxError); ok {
+		return sb.newJSErrorWithCode(sbErr, sbErr.Code)
+	}
+	jsErr := sb.vm.NewGoError(err)
+	if inner := errors.Unwrap(err); inner != nil {
+		jsErr.Set("cause", sb.jsCause(inner))
+	}
+	return jsErr
+}
+
+// throwOnError 在 ErrorModeThrow 下包装内置模块 module 注册的全局函数或对象中的函数
+func (sb *Sandbox) throwOnError(module string, v goja.Value) goja.Value {
+	code, ok := moduleErrorCodes[module]
+	if !ok {
+		code = ErrCodeUnknown
+	}
+	if _, ok := goja.AssertConstructor(v); ok {
+		// 构造函数（如 URL、TextDecoder）和脚本定义的函数按标准抛出异常，不需要包装
+		return v
+	}
+	if fn, ok := goja.AssertFunction(v); ok {
+		return sb.throwingFunc(code, fn)
+	}
+	if obj, ok := v.(*goja.Object); ok && obj.ExportType() == plainObjectType {
+		sb.wrapMethods(code, obj)
+	}
+	return v
+}
+
+// throwingFunc 包装宿主函数：返回 { error } 结果对象或抛出 Go 错误时改为抛出 SandboxError，
+// 参数类型错误等 JavaScript 异常原样抛出；返回对象中的方法（如浏览器会话）同样被包装
+func (sb *Sandbox) throwingFunc(code ErrorCode, fn goja.Callable) goja.Value {
+	wrapped := sb.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		result, err := fn(call.This, call.Arguments...)
+		if err != nil {
+			panic(sb.hostException(code, err))
+		}
+		obj, ok := result.(*goja.Object)
+		if !ok || obj.ExportType() != plainObjectType {
+			return result
+		}
+		if jsErr := sb.resultError(code, obj); jsErr != nil {
+			panic(jsErr)
+		}
+		sb.wrapMethods(code, obj)
+		return result
+	}).ToObject(sb.vm)
+	wrapped.DefineDataPropertySymbol(sb.throwMark, sb.vm.ToValue(true), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return wrapped
+}
+
+// wrapMethods 包装对象中尚未包装的函数属性
+func (sb *Sandbox) wrapMethods(code ErrorCode, obj *goja.Object) {
+	for _, key := range obj.Keys() {
+		method, ok := obj.Get(key).(*goja.Object)
+		if !ok || method.GetSymbol(sb.throwMark) != nil {
+			continue
+		}
+		if fn, ok := goja.AssertFunction(method); ok {
+			obj.Set(key, sb.throwingFunc(code, fn))
+		}
+	}
+}
+
+// hostException 把宿主函数抛出的错误转换为 panic 的值
+// Go 错误转换为 SandboxError，已经是 SandboxError 的异常和脚本自身的异常原样抛出，中断和调用栈溢出保持不可捕获
+func (sb *Sandbox) hostException(code ErrorCode, err error) interface{} {
+	if isUncatchable(err) {
+		return err
+	}
+	var ex *goja.Exception
+	if !errors.As(err, &ex) {
+		return sb.newJSErrorWithCode(err, code)
+	}
+	goErr := ex.Unwrap()
+	if goErr == nil || sb.vm.InstanceOf(ex.Value(), sb.errorClass) {
+		return ex
+	}
+	return sb.newJSErrorWithCode(goErr, code)
+}
+
+// resultError 若 obj 是 { error } 形式的失败结果，返回对应的 SandboxError 异常，否则返回 nil
+// errorResult 生成的结果以原始错误作为 cause，其他结果对象只有错误信息，异常没有 cause
+// 结果中的 code 优先于模块的默认错误码，status 等其他属性复制到异常对象上
+func (sb *Sandbox) resultError(code ErrorCode, obj *goja.Object) *goja.Object {
+	err := sb.resultObjectError(code, obj)
+	if err == nil {
+		return nil
+	}
+	jsErr := sb.newJSErrorWithCode(err, err.(*SandboxError).Code)
+	for _, key := range obj.Keys() {
+		switch key {
+		case "success", "error", "code":
+		default:
+			jsErr.Set(key, obj.Get(key))
+		}
+	}
+	return jsErr
+}
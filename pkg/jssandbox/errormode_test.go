@@ -0,0 +1,150 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestErrorMode_Throw(t *testing.T) {
+	root := t.TempDir()
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().
+		WithFileSystemRoot(root).
+		WithReadOnlyMount("/ro", t.TempDir()).
+		WithDeniedHosts("blocked.example.com").
+		WithErrorMode(ErrorModeThrow))
+	defer sb.Close()
+
+	result, err := sb.Run(`
+		function capture(fn) {
+			try { fn(); return 'not thrown'; } catch (e) {
+				return [e instanceof SandboxError, e instanceof Error, e.name, e.code].join(',');
+			}
+		}
+		writeFile('/a.txt', 'hello');
+		[
+			readFile('/a.txt').data,
+			capture(function () { readFile('/missing.txt'); }),
+			capture(function () { require('fs').readFile('/missing.txt'); }),
+			capture(function () { writeFile('/ro/a.txt', 'x'); }),
+			capture(function () { httpGet('http://blocked.example.com/'); }),
+			capture(function () { excelOpen('/missing.xlsx'); }),
+			capture(function () { parseDate('not a date'); })
+		]
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{
+		"hello",
+		"true,true,SandboxError,FILE_NOT_FOUND",
+		"true,true,SandboxError,FILE_NOT_FOUND",
+		"true,true,SandboxError,ACCESS_DENIED",
+		"true,true,SandboxError,NETWORK_POLICY_VIOLATION",
+		"true,true,SandboxError,FILE_NOT_FOUND",
+		"true,true,SandboxError,INVALID_INPUT",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// 未捕获的异常可以在 Go 中取回错误码
+	_, err = sb.Run(`readFile('/missing.txt')`)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || sbErr.Exception == nil || sbErr.Exception.Code != string(ErrCodeFileNotFound) {
+		t.Errorf("error = %v, want %s", err, ErrCodeFileNotFound)
+	}
+	// 异常的 cause 保留文件系统的原始错误
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("errors.Is(%v, fs.ErrNotExist) = false", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("error = %v, want *fs.PathError in chain", err)
+	}
+	var inner *SandboxError
+	for e := errors.Unwrap(err); e != nil; e = errors.Unwrap(e) {
+		if errors.As(e, &inner) {
+			break
+		}
+	}
+	if inner == nil || inner.Code != ErrCodeFileNotFound {
+		t.Errorf("内层 SandboxError = %v, want code %s", inner, ErrCodeFileNotFound)
+	}
+
+	result, err = sb.Run(`
+		try { readFile('/missing.txt'); } catch (e) { [e.code, e.cause !== undefined].join(',') }
+	`)
+	if err != nil || result.String() != "FILE_NOT_FOUND,true" {
+		t.Errorf("Run() = %v, %v, want FILE_NOT_FOUND,true", result, err)
+	}
+}
+
+func TestErrorMode_ResultUnchanged(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithFileSystemRoot(t.TempDir()))
+	defer sb.Close()
+
+	result, err := sb.Run(`
+		var r = readFile('/missing.txt');
+		[typeof SandboxError, r.success, r.code, typeof r.error]
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{"function", false, string(ErrCodeFileNotFound), "string"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestErrorMode_CauseChain(t *testing.T) {
+	ext := NewExtension("store", "").
+		Func("save", func() error {
+			inner := NewSandboxErrorWithCause(ErrCodeFileSystemError, "写入失败", os.ErrPermission)
+			return fmt.Errorf("保存失败: %w", inner)
+		}, "").
+		Func("plain", func() error { return errors.New("出错了") }, "")
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().
+		WithExtension(ext).
+		WithErrorMode(ErrorModeThrow))
+	defer sb.Close()
+
+	result, err := sb.Run(`
+		var out = [];
+		try { store.save(); } catch (e) {
+			out.push(e.code, e.message, e.cause instanceof SandboxError, e.cause.code, e.cause.message, e.cause.cause.message);
+		}
+		try { store.plain(); } catch (e) { out.push(e.code, e.cause === undefined); }
+		var custom = new SandboxError('INVALID_INPUT', '自定义', { cause: 'root' });
+		out.push(custom.code, custom.cause, String(custom));
+		out
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{
+		string(ErrCodeFileSystemError), "保存失败: [FILE_SYSTEM_ERROR] 写入失败: permission denied",
+		true, string(ErrCodeFileSystemError), "写入失败", "permission denied",
+		string(ErrCodeUnknown), true,
+		string(ErrCodeInvalidInput), "root", "SandboxError: 自定义",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if doc := DefaultConfig().WithErrorMode(ErrorModeThrow).WithExtension(ext).ExtensionDocs(); !strings.Contains(doc, "抛出 SandboxError") {
+		t.Errorf("文档应该说明抛出异常:\n%s", doc)
+	}
+}
@@ -1,6 +1,11 @@
 package jssandbox
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
 
 // ErrorCode 错误代码类型
 type ErrorCode string
@@ -8,6 +13,8 @@ type ErrorCode string
 const (
 	// ErrCodeTimeout 执行超时
 	ErrCodeTimeout ErrorCode = "TIMEOUT"
+	// ErrCodeCanceled 执行被取消
+	ErrCodeCanceled ErrorCode = "CANCELED"
 	// ErrCodeInvalidInput 无效输入
 	ErrCodeInvalidInput ErrorCode = "INVALID_INPUT"
 	// ErrCodeFileNotFound 文件未找到
@@ -16,6 +23,20 @@ const (
 	ErrCodeHTTPError ErrorCode = "HTTP_ERROR"
 	// ErrCodeFileSystemError 文件系统错误
 	ErrCodeFileSystemError ErrorCode = "FILE_SYSTEM_ERROR"
+	// ErrCodeFilePolicy 违反文件策略（超过 MaxFileSize 或类型不在 AllowedFileTypes 中）
+	ErrCodeFilePolicy ErrorCode = "FILE_POLICY_VIOLATION"
+	// ErrCodeAccessDenied 访问被拒绝（路径超出沙盒文件系统、写入只读挂载点等）
+	ErrCodeAccessDenied ErrorCode = "ACCESS_DENIED"
+	// ErrCodeNetworkPolicy 违反网络出站策略（目标主机、地址、端口或协议不被允许）
+	ErrCodeNetworkPolicy ErrorCode = "NETWORK_POLICY_VIOLATION"
+	// ErrCodePermissionDenied 缺少执行该操作所需的权限（见 Config.Permissions）
+	ErrCodePermissionDenied ErrorCode = "PERMISSION_DENIED"
+	// ErrCodeResourceLimit 超出资源限制（内存增长、字符串或数组长度、调用栈深度），执行被终止
+	ErrCodeResourceLimit ErrorCode = "RESOURCE_LIMIT_EXCEEDED"
+	// ErrCodeModuleNotFound require 找不到模块
+	ErrCodeModuleNotFound ErrorCode = "MODULE_NOT_FOUND"
+	// ErrCodeModuleCycle 本地模块之间存在循环依赖
+	ErrCodeModuleCycle ErrorCode = "MODULE_CYCLE"
 	// ErrCodeBrowserError 浏览器操作错误
 	ErrCodeBrowserError ErrorCode = "BROWSER_ERROR"
 	// ErrCodeDocumentError 文档处理错误
@@ -24,19 +45,40 @@ const (
 	ErrCodeImageError ErrorCode = "IMAGE_ERROR"
 	// ErrCodeSystemError 系统操作错误
 	ErrCodeSystemError ErrorCode = "SYSTEM_ERROR"
+	// ErrCodeSyntaxError 代码有语法错误，无法编译（包括 TypeScript 转译和模块打包失败）
+	ErrCodeSyntaxError ErrorCode = "SYNTAX_ERROR"
+	// ErrCodeScriptError 脚本执行时抛出了未捕获的异常（包括顶层 Promise 被拒绝）
+	ErrCodeScriptError ErrorCode = "SCRIPT_ERROR"
+	// ErrCodeBusy 沙盒正在执行其他脚本（Config.Concurrency 为 ConcurrencyFailFast 时）
+	ErrCodeBusy ErrorCode = "SANDBOX_BUSY"
 	// ErrCodeUnknown 未知错误
 	ErrCodeUnknown ErrorCode = "UNKNOWN_ERROR"
 )
 
+// ErrorMode 宿主函数向脚本报告错误的方式
+type ErrorMode string
+
+const (
+	// ErrorModeResult 出错时返回 { success: false, error, code } 结果对象，成功时返回 { success: true, data }
+	ErrorModeResult ErrorMode = "result"
+	// ErrorModeThrow 出错时抛出带 code 属性的 JavaScript 异常，成功时直接返回结果
+	ErrorModeThrow ErrorMode = "throw"
+)
+
 // SandboxError 沙盒错误类型
 type SandboxError struct {
 	Code    ErrorCode
 	Message string
 	Cause   error
+	// Exception 语法错误和脚本异常（ErrCodeSyntaxError、ErrCodeScriptError）的详细信息，其他错误为 nil
+	Exception *ScriptException
 }
 
 // Error 实现 error 接口
 func (e *SandboxError) Error() string {
+	if e.Exception != nil {
+		return fmt.Sprintf("[%s] %s: %s", e.Code, e.Message, e.Exception)
+	}
 	if e.Cause != nil {
 		return fmt.Sprintf("[%s] %s: %v", e.Code, e.Message, e.Cause)
 	}
@@ -70,8 +112,73 @@ func (e *SandboxError) IsTimeout() bool {
 	return e.Code == ErrCodeTimeout
 }
 
+// IsCanceled 判断是否为取消错误
+func (e *SandboxError) IsCanceled() bool {
+	return e.Code == ErrCodeCanceled
+}
+
 // IsFileNotFound 判断是否为文件未找到错误
 func (e *SandboxError) IsFileNotFound() bool {
 	return e.Code == ErrCodeFileNotFound
 }
 
+// IsFilePolicyViolation 判断是否为违反文件策略的错误
+func (e *SandboxError) IsFilePolicyViolation() bool {
+	return e.Code == ErrCodeFilePolicy
+}
+
+// IsAccessDenied 判断是否为访问被拒绝错误
+func (e *SandboxError) IsAccessDenied() bool {
+	return e.Code == ErrCodeAccessDenied
+}
+
+// IsNetworkPolicyViolation 判断是否为违反网络出站策略的错误
+func (e *SandboxError) IsNetworkPolicyViolation() bool {
+	return e.Code == ErrCodeNetworkPolicy
+}
+
+// IsPermissionDenied 判断是否为缺少权限的错误
+func (e *SandboxError) IsPermissionDenied() bool {
+	return e.Code == ErrCodePermissionDenied
+}
+
+// IsSyntaxError 判断是否为语法错误
+func (e *SandboxError) IsSyntaxError() bool {
+	return e.Code == ErrCodeSyntaxError
+}
+
+// IsScriptError 判断是否为脚本抛出的未捕获异常
+func (e *SandboxError) IsScriptError() bool {
+	return e.Code == ErrCodeScriptError
+}
+
+// IsResourceLimit 判断是否为超出资源限制的错误
+func (e *SandboxError) IsResourceLimit() bool {
+	return e.Code == ErrCodeResourceLimit
+}
+
+// IsBusy 判断是否为沙盒正在执行其他脚本的错误
+func (e *SandboxError) IsBusy() bool {
+	return e.Code == ErrCodeBusy
+}
+
+// PromiseRejectedError 表示顶层 Promise 被拒绝
+type PromiseRejectedError struct {
+	// Reason 拒绝原因
+	Reason goja.Value
+}
+
+// Error 实现 error 接口
+func (e *PromiseRejectedError) Error() string {
+	if e.Reason == nil {
+		return "Promise 被拒绝"
+	}
+	return "Promise 被拒绝: " + e.Reason.String()
+}
+
+// isUncatchable 判断错误是否为脚本无法捕获的中断或调用栈溢出，这类错误需要原样向上传播
+func isUncatchable(err error) bool {
+	var interrupted *goja.InterruptedError
+	var stackOverflow *goja.StackOverflowError
+	return errors.As(err, &interrupted) || errors.As(err, &stackOverflow)
+}
@@ -0,0 +1,311 @@
+package jssandbox
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// esbuild 插件使用的命名空间
+const (
+	esmEntryNamespace = "sandbox-entry"
+	esmMainNamespace  = "sandbox-main"
+	esmFileNamespace  = "sandbox-file"
+	esmHostNamespace  = "sandbox-host"
+)
+
+// esmMainPath 代码字符串作为入口模块时的虚拟路径，其中的相对导入相对于模块根目录解析
+const esmMainPath = "/main.js"
+
+// esmNamespaceVar 接收入口模块命名空间对象的变量
+const esmNamespaceVar = "__sandboxModuleNamespace"
+
+// RunModule 把 code 作为 ES 模块执行，返回模块的命名空间对象（包含所有导出，默认导出为 "default"）
+//
+// 支持 import/export 语法、顶层 await 和 import()。静态导入和字面量路径的 import() 在执行前解析：
+// 内置模块（如 import { readFile } from 'fs'）、RegisterModule 注册的自定义模块，
+// 以及 Config.ModuleRoot 中的 .js/.json 文件；路径在运行时才确定的 import() 通过 require 加载。
+// 父上下文被取消时会中断正在执行的脚本
+func (sb *Sandbox) RunModule(code string) (*goja.Object, error) {
+	return exclusive(sb, "RunModule", func(sb *Sandbox) (*goja.Object, error) {
+		parent, release := sb.runParent()
+		defer release()
+		ns, err := sb.runModuleCode(parent, moduleEntry{code: code, path: esmMainPath, loader: api.LoaderJS})
+		return moduleResult(parent, ns, err, 0)
+	})
+}
+
+// RunModuleWithTimeout 在指定超时时间内把 code 作为 ES 模块执行，超时处理与 RunWithTimeout 相同
+func (sb *Sandbox) RunModuleWithTimeout(code string, timeout time.Duration) (*goja.Object, error) {
+	return exclusive(sb, "RunModuleWithTimeout", func(sb *Sandbox) (*goja.Object, error) {
+		return sb.runModuleWithTimeout(code, timeout)
+	})
+}
+
+// runModuleWithTimeout 实现 RunModuleWithTimeout，调用方需持有执行锁
+func (sb *Sandbox) runModuleWithTimeout(code string, timeout time.Duration) (*goja.Object, error) {
+	if timeout == 0 {
+		timeout = sb.config.DefaultTimeout
+	}
+
+	parent, release := sb.runParent()
+	defer release()
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	ns, err := sb.runModuleCode(ctx, moduleEntry{code: code, path: esmMainPath, loader: api.LoaderJS})
+	return moduleResult(ctx, ns, err, timeout)
+}
+
+// moduleResult 转换执行模块返回的错误，被中断时按 ctx 返回超时或取消错误，见 runError
+func moduleResult(ctx context.Context, ns *goja.Object, err error, timeout time.Duration) (*goja.Object, error) {
+	if errors.Is(err, errRunInterrupted) {
+		return nil, contextError(ctx, timeout)
+	}
+	var sbErr *SandboxError
+	if errors.As(err, &sbErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, NewSandboxErrorWithCause(ErrCodeUnknown, "执行JavaScript模块失败", err)
+	}
+	return ns, nil
+}
+
+// IsModule 判断代码是否使用了 ES 模块语法（import/export 声明、import.meta 等），
+// 这样的代码无法通过 Run 执行，需要使用 RunModule；代码有语法错误时返回 false
+func IsModule(code string) bool {
+	return isModuleSource(code, api.LoaderJS)
+}
+
+// isModuleSource 判断使用指定加载器（JavaScript、TypeScript 等）的代码是否为 ES 模块
+func isModuleSource(code string, loader api.Loader) bool {
+	result := api.Build(api.BuildOptions{
+		Stdin:     &api.StdinOptions{Contents: code, Loader: loader},
+		Write:     false,
+		Metafile:  true,
+		LogLevel:  api.LogLevelSilent,
+		Supported: map[string]bool{"top-level-await": true},
+	})
+	if len(result.Errors) > 0 {
+		return false
+	}
+	var meta struct {
+		Inputs map[string]struct {
+			Format string `json:"format"`
+		} `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(result.Metafile), &meta); err != nil {
+		return false
+	}
+	for _, input := range meta.Inputs {
+		if input.Format == "esm" {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleEntry 入口模块，path 为其虚拟路径，用于解析相对导入和错误位置
+type moduleEntry struct {
+	code   string
+	path   string
+	loader api.Loader
+}
+
+// runModuleCode 打包模块及其依赖并在异步函数中执行，等待顶层 await 完成后返回命名空间对象
+func (sb *Sandbox) runModuleCode(ctx context.Context, entry moduleEntry) (*goja.Object, error) {
+	bundle, err := sb.bundleModule(entry)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sb.runString(ctx, bundle)
+	if err != nil {
+		return nil, sb.scriptError(err, entry.path, entry.code)
+	}
+	ns, ok := result.(*goja.Object)
+	if !ok {
+		return nil, NewSandboxError(ErrCodeUnknown, "模块没有返回命名空间对象")
+	}
+	return ns, nil
+}
+
+// bundleModule 用 esbuild 把入口模块和它导入的模块打包为一个异步函数调用，不含 import/export，
+// 返回值为入口模块的命名空间对象；代码末尾内联 source map，错误位置对应模块的原始文件和行号
+func (sb *Sandbox) bundleModule(entry moduleEntry) (string, error) {
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{esmEntryNamespace},
+		Outfile:     "bundle.js",
+		Bundle:      true,
+		Write:       false,
+		Format:      api.FormatESModule,
+		Platform:    api.PlatformNeutral,
+		Target:      api.ES2017,
+		Supported: map[string]bool{
+			"top-level-await": true,
+			// 无法在打包时解析的 import() 转换为 require
+			"dynamic-import": false,
+		},
+		Banner:    map[string]string{"js": "(async function () {\nvar " + esmNamespaceVar + ";"},
+		Footer:    map[string]string{"js": "return " + esmNamespaceVar + ";\n})()"},
+		Sourcemap: api.SourceMapExternal,
+		LogLevel:  api.LogLevelSilent,
+		Plugins:   []api.Plugin{sb.esmResolverPlugin(entry)},
+	})
+	if len(result.Errors) > 0 {
+		return "", buildError("模块编译失败", result.Errors)
+	}
+	var code, sourceMap []byte
+	for _, file := range result.OutputFiles {
+		if strings.HasSuffix(file.Path, ".map") {
+			sourceMap = file.Contents
+		} else {
+			code = file.Contents
+		}
+	}
+	if code == nil {
+		return "", NewSandboxError(ErrCodeUnknown, "模块打包没有生成代码")
+	}
+	return inlineSourceMap(code, sourceMap), nil
+}
+
+// inlineSourceMap 去掉 source map 中插件命名空间的前缀，使错误位置显示为模块的虚拟路径，
+// 然后把它以 data URL 的形式内联到代码末尾，替换 esbuild 生成的外部引用
+func inlineSourceMap(code, sourceMap []byte) string {
+	js := string(code)
+	if i := strings.LastIndex(js, "//# sourceMappingURL="); i >= 0 {
+		js = js[:i]
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(sourceMap, &m); err != nil {
+		return js
+	}
+	if sources, ok := m["sources"].([]interface{}); ok {
+		for i, source := range sources {
+			name, _ := source.(string)
+			for _, ns := range []string{esmEntryNamespace, esmMainNamespace, esmFileNamespace, esmHostNamespace} {
+				name = strings.TrimPrefix(name, ns+":")
+			}
+			sources[i] = name
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return js
+	}
+	return js + "//# sourceMappingURL=data:application/json;base64," + base64.StdEncoding.EncodeToString(data) + "\n"
+}
+
+// buildError 把 esbuild 的错误信息转换为沙盒错误，格式为 "文件:行:列: 信息"
+// 由插件解析失败时为 ErrCodeModuleNotFound，否则（语法错误等）为 ErrCodeSyntaxError
+func buildError(message string, msgs []api.Message) *SandboxError {
+	code := ErrCodeSyntaxError
+	lines := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.PluginName != "" {
+			code = ErrCodeModuleNotFound
+		}
+		if msg.Location != nil {
+			lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column+1, msg.Text))
+		} else {
+			lines = append(lines, msg.Text)
+		}
+	}
+	err := NewSandboxError(code, message+": "+strings.Join(lines, "; "))
+	if code == ErrCodeSyntaxError && len(msgs) > 0 {
+		err.Exception = buildException(msgs[0])
+	}
+	return err
+}
+
+// buildException 把 esbuild 的第一条错误转换为语法错误的详细信息，源码片段只包含出错行
+func buildException(msg api.Message) *ScriptException {
+	exc := &ScriptException{Name: "SyntaxError", Message: msg.Text}
+	if loc := msg.Location; loc != nil {
+		exc.File, exc.main = loc.File, loc.File
+		exc.Line = loc.Line
+		exc.Column = utf8.RuneCountInString(loc.LineText[:min(loc.Column, len(loc.LineText))]) + 1
+		exc.Snippet = formatSnippet([]string{loc.LineText}, loc.Line, loc.Line, exc.Column)
+	}
+	return exc
+}
+
+// esmResolverPlugin 返回负责解析和加载模块的 esbuild 插件
+// 插件回调在 esbuild 的 goroutine 中执行，只读取模块注册表，不访问 goja 运行时
+func (sb *Sandbox) esmResolverPlugin(entry moduleEntry) api.Plugin {
+	r := sb.modules
+	return api.Plugin{
+		Name: "jssandbox-modules",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: ".*"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				switch {
+				case args.Kind == api.ResolveEntryPoint:
+					return api.OnResolveResult{Path: esmEntryNamespace, Namespace: esmEntryNamespace}, nil
+				case args.Namespace == esmEntryNamespace:
+					return api.OnResolveResult{Path: entry.path, Namespace: esmMainNamespace}, nil
+				case args.Namespace == esmHostNamespace:
+					// 内置模块和自定义模块的 require 保留到运行时执行
+					return api.OnResolveResult{Path: args.Path, External: true}, nil
+				case !isLocalModule(args.Path):
+					if !r.hasNamedModule(args.Path) {
+						return api.OnResolveResult{}, fmt.Errorf("找不到模块 '%s'", args.Path)
+					}
+					return api.OnResolveResult{Path: args.Path, Namespace: esmHostNamespace}, nil
+				}
+
+				dir := path.Dir(args.Importer)
+				if r.root == nil {
+					return api.OnResolveResult{}, fmt.Errorf("找不到模块 '%s'：未配置模块根目录 (Config.ModuleRoot)", args.Path)
+				}
+				id, hostPath, err := r.resolveFile(path.Join(dir, args.Path))
+				if err != nil {
+					return api.OnResolveResult{}, err
+				}
+				if hostPath == "" {
+					return api.OnResolveResult{}, fmt.Errorf("找不到模块 '%s'（从 %s 引用）", args.Path, dir)
+				}
+				return api.OnResolveResult{Path: id, Namespace: esmFileNamespace, PluginData: hostPath}, nil
+			})
+
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: esmEntryNamespace}, func(api.OnLoadArgs) (api.OnLoadResult, error) {
+				stub := fmt.Sprintf("import * as ns from %q;\n%s = ns;\n", entry.path, esmNamespaceVar)
+				return api.OnLoadResult{Contents: &stub, Loader: api.LoaderJS}, nil
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: esmMainNamespace}, func(api.OnLoadArgs) (api.OnLoadResult, error) {
+				return api.OnLoadResult{Contents: &entry.code, Loader: entry.loader}, nil
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: esmHostNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				// 内置模块和自定义模块在运行时通过 require 获取，与 CommonJS 共用同一份缓存
+				stub := fmt.Sprintf("module.exports = require(%q);\n", args.Path)
+				return api.OnLoadResult{Contents: &stub, Loader: api.LoaderJS}, nil
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: esmFileNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				src, err := os.ReadFile(args.PluginData.(string))
+				if err != nil {
+					return api.OnLoadResult{}, fmt.Errorf("读取模块失败: %s: %v", args.Path, err)
+				}
+				contents := string(src)
+				return api.OnLoadResult{Contents: &contents, Loader: sourceLoader(args.Path)}, nil
+			})
+		},
+	}
+}
+
+// hasNamedModule 判断是否存在指定名称的内置模块或自定义模块
+func (r *moduleRegistry) hasNamedModule(name string) bool {
+	if _, ok := r.custom[name]; ok {
+		return true
+	}
+	_, ok := r.builtins[name]
+	return ok
+}
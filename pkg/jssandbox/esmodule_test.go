@@ -0,0 +1,175 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunModule_HostModules(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	if err := sb.RegisterModule("greeter", map[string]interface{}{
+		"greet": func(name string) string { return "hello " + name },
+	}); err != nil {
+		t.Fatalf("RegisterModule() error = %v", err)
+	}
+
+	ns, err := sb.RunModule(`
+		import { readFile } from 'fs';
+		import * as http from 'http';
+		import logger from 'logger';
+		import { greet } from 'greeter';
+
+		export const types = [typeof readFile, typeof http.fetch, typeof logger.info];
+		export const greeting = greet('esm');
+		export default readFile === globalThis.readFile;
+	`)
+	if err != nil {
+		t.Fatalf("RunModule() error = %v", err)
+	}
+	exports := ns.Export().(map[string]interface{})
+	if exports["default"] != true {
+		t.Errorf("default = %v, want true", exports["default"])
+	}
+	if exports["greeting"] != "hello esm" {
+		t.Errorf("greeting = %v", exports["greeting"])
+	}
+	types := exports["types"].([]interface{})
+	for i, typ := range types {
+		if typ != "function" {
+			t.Errorf("types[%d] = %v, want function", i, typ)
+		}
+	}
+}
+
+func TestRunModule_TopLevelAwaitAndDynamicImport(t *testing.T) {
+	sb := newModuleSandbox(t, map[string]string{
+		"lib/math.js": "export function add(a, b) { return a + b; }\nexport const PI = 3;",
+	})
+
+	ns, err := sb.RunModule(`
+		const delayed = await new Promise(function (resolve) { setTimeout(function () { resolve(40); }, 10); });
+		const math = await import('./lib/math.js');
+		const name = 'path';
+		const path = await import(name);
+		export const answer = math.add(delayed, 2);
+		export const hasPathJoin = typeof path.pathJoin === 'function';
+	`)
+	if err != nil {
+		t.Fatalf("RunModule() error = %v", err)
+	}
+	if got := ns.Get("answer").ToInteger(); got != 42 {
+		t.Errorf("answer = %d, want 42", got)
+	}
+	if !ns.Get("hasPathJoin").ToBoolean() {
+		t.Error("运行时确定路径的 import() 应该可以加载内置模块")
+	}
+}
+
+func TestRunModule_LocalModules(t *testing.T) {
+	sb := newModuleSandbox(t, map[string]string{
+		"lib/util.js":   "import config from '../config.json';\nimport { twice } from './cjs';\nexport const label = config.name + ':' + twice(21);",
+		"lib/cjs.js":    "exports.twice = function (n) { return n * 2; };",
+		"config.json":   `{"name": "demo"}`,
+		"lib/index.js":  "export { label as default } from './util';",
+		"uses/state.js": "export let count = 0;\nexport function inc() { count++; }",
+	})
+
+	ns, err := sb.RunModule(`
+		import label from './lib';
+		import { count, inc } from './uses/state.js';
+		inc(); inc();
+		export { label };
+		export const live = count;
+	`)
+	if err != nil {
+		t.Fatalf("RunModule() error = %v", err)
+	}
+	if got := ns.Get("label").String(); got != "demo:42" {
+		t.Errorf("label = %q, want demo:42", got)
+	}
+	if got := ns.Get("live").ToInteger(); got != 2 {
+		t.Errorf("live = %d, want 2", got)
+	}
+}
+
+func TestRunModule_Errors(t *testing.T) {
+	sb := newModuleSandbox(t, map[string]string{
+		"throws.js": "throw new Error('boom');",
+	})
+
+	tests := []struct {
+		name     string
+		code     string
+		wantCode ErrorCode
+		contains string
+	}{
+		{"找不到内置模块", `import x from 'lodash';`, ErrCodeModuleNotFound, "找不到模块 'lodash'"},
+		{"找不到本地模块", `import x from './missing.js';`, ErrCodeModuleNotFound, "找不到模块 './missing.js'"},
+		{"语法错误", `export const = 1;`, ErrCodeSyntaxError, "/main.js:1:"},
+		{"模块抛出异常", `import './throws.js';`, "", "boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sb.RunModule(tt.code)
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("error = %v, 应该包含 %q", err, tt.contains)
+			}
+			var sbErr *SandboxError
+			if tt.wantCode != "" && (!errors.As(err, &sbErr) || sbErr.Code != tt.wantCode) {
+				t.Errorf("error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRunModuleWithTimeout(t *testing.T) {
+	// 被中断的沙盒不再复用（与 SandboxPool 的处理一致），每个用例使用新的沙盒
+	for _, code := range []string{
+		`export default 1; while (true) {}`,
+		`await Promise.resolve(); while (true) {}`,
+	} {
+		sb := NewSandbox(context.Background())
+		_, err := sb.RunModuleWithTimeout(code, 100*time.Millisecond)
+		sb.Close()
+		var sbErr *SandboxError
+		if !errors.As(err, &sbErr) || !sbErr.IsTimeout() {
+			t.Errorf("RunModuleWithTimeout(%q) error = %v, want timeout", code, err)
+		}
+	}
+
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	ns, err := sb.RunModuleWithTimeout(`export default await Promise.resolve('ok');`, time.Second)
+	if err != nil {
+		t.Fatalf("RunModuleWithTimeout() error = %v", err)
+	}
+	if ns.Get("default").String() != "ok" {
+		t.Errorf("default = %v, want ok", ns.Get("default"))
+	}
+}
+
+func TestIsModule(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{`import { readFile } from 'fs';`, true},
+		{`export default 1;`, true},
+		{`import.meta.url`, true},
+		{`var x = 1; x + 1`, false},
+		{`return 1`, false},
+		{`import x from`, false},
+	}
+	for _, tt := range tests {
+		if got := IsModule(tt.code); got != tt.want {
+			t.Errorf("IsModule(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
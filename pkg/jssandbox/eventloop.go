@@ -0,0 +1,284 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// eventLoop 沙盒的事件循环，负责定时器和异步宿主函数的回调调度
+// 所有任务都在执行 JavaScript 的 goroutine 上运行，其他 goroutine 只能通过 enqueue 投递任务
+type eventLoop struct {
+	mu     sync.Mutex
+	queue  []func() error
+	gen    uint64 // 每次 reset 递增，用于丢弃上一次执行遗留的任务
+	wakeup chan struct{}
+
+	// 以下字段只在执行 JavaScript 的 goroutine 上访问
+	timers  map[int64]*loopTimer
+	nextID  int64
+	pending int   // 尚未完成的异步操作数量（定时器、进行中的宿主调用）
+	err     error // 回调中未捕获的异常
+}
+
+// loopTimer 表示一个 setTimeout/setInterval 定时器
+type loopTimer struct {
+	timer  *time.Timer
+	fn     goja.Callable
+	args   []goja.Value
+	delay  time.Duration
+	repeat bool
+	// unref 为 true 时定时器不阻止事件循环结束，见 unrefTimer
+	unref bool
+}
+
+// newEventLoop 创建事件循环
+func newEventLoop() *eventLoop {
+	return &eventLoop{
+		wakeup: make(chan struct{}, 1),
+		timers: make(map[int64]*loopTimer),
+	}
+}
+
+// enqueue 投递一个任务，可在任意 goroutine 调用
+// gen 与当前代数不一致时（执行已结束或被中断）任务会被丢弃
+func (l *eventLoop) enqueue(gen uint64, task func() error) {
+	l.mu.Lock()
+	if gen != l.gen {
+		l.mu.Unlock()
+		return
+	}
+	l.queue = append(l.queue, task)
+	l.mu.Unlock()
+
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// generation 返回当前代数
+func (l *eventLoop) generation() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.gen
+}
+
+// startAsync 登记一个异步操作，返回用于提交完成回调的函数
+// 返回的函数可在任意 goroutine 调用且只应调用一次，回调会在事件循环上执行
+func (l *eventLoop) startAsync() func(task func() error) {
+	l.pending++
+	gen := l.generation()
+	return func(task func() error) {
+		l.enqueue(gen, func() error {
+			l.pending--
+			return task()
+		})
+	}
+}
+
+// setTimer 创建定时器，返回定时器ID
+func (l *eventLoop) setTimer(fn goja.Callable, delay time.Duration, args []goja.Value, repeat bool) int64 {
+	if delay < 0 {
+		delay = 0
+	}
+	l.nextID++
+	id := l.nextID
+	t := &loopTimer{
+		fn:     fn,
+		args:   args,
+		delay:  delay,
+		repeat: repeat,
+	}
+	l.timers[id] = t
+	l.pending++
+	l.schedule(id, t)
+	return id
+}
+
+// schedule 启动定时器，到期后把回调投递到事件循环
+func (l *eventLoop) schedule(id int64, t *loopTimer) {
+	gen := l.generation()
+	t.timer = time.AfterFunc(t.delay, func() {
+		l.enqueue(gen, func() error {
+			return l.fire(id)
+		})
+	})
+}
+
+// fire 执行定时器回调
+func (l *eventLoop) fire(id int64) error {
+	t, ok := l.timers[id]
+	if !ok {
+		// 定时器已被清除
+		return nil
+	}
+	if t.repeat {
+		l.schedule(id, t)
+	} else {
+		delete(l.timers, id)
+		if !t.unref {
+			l.pending--
+		}
+	}
+	_, err := t.fn(goja.Undefined(), t.args...)
+	return err
+}
+
+// clearTimer 清除定时器
+func (l *eventLoop) clearTimer(id int64) {
+	t, ok := l.timers[id]
+	if !ok {
+		return
+	}
+	t.timer.Stop()
+	delete(l.timers, id)
+	if !t.unref {
+		l.pending--
+	}
+}
+
+// unrefTimer 使定时器不再阻止事件循环结束（与 Node.js 的 timer.unref() 相同），
+// 事件循环空闲时仍未到期的定时器在执行结束时被丢弃
+func (l *eventLoop) unrefTimer(id int64) {
+	if t, ok := l.timers[id]; ok && !t.unref {
+		t.unref = true
+		l.pending--
+	}
+}
+
+// fail 记录回调中未捕获的异常，事件循环会在下一次调度时停止
+func (l *eventLoop) fail(err error) {
+	if l.err == nil {
+		l.err = err
+	}
+}
+
+// run 执行队列中的任务直到事件循环空闲
+// 回调抛出未捕获的异常或上下文结束时返回错误
+func (l *eventLoop) run(ctx context.Context) error {
+	for {
+		if l.err != nil {
+			return l.err
+		}
+
+		l.mu.Lock()
+		tasks := l.queue
+		l.queue = nil
+		l.mu.Unlock()
+
+		for _, task := range tasks {
+			if err := task(); err != nil {
+				return err
+			}
+			if l.err != nil {
+				return l.err
+			}
+		}
+		if len(tasks) > 0 {
+			continue
+		}
+
+		if l.pending == 0 {
+			return nil
+		}
+
+		select {
+		case <-l.wakeup:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// reset 停止所有定时器并丢弃未执行的任务，使运行时回到空闲状态
+func (l *eventLoop) reset() {
+	l.mu.Lock()
+	l.gen++
+	l.queue = nil
+	l.mu.Unlock()
+
+	for id, t := range l.timers {
+		t.timer.Stop()
+		delete(l.timers, id)
+	}
+	l.pending = 0
+	l.err = nil
+}
+
+// registerEventLoop 注册定时器和微任务相关的全局函数到JavaScript运行时
+func (sb *Sandbox) registerEventLoop() {
+	setTimer := func(call goja.FunctionCall, repeat bool) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(sb.vm.NewTypeError("回调参数必须是函数"))
+		}
+		delay := time.Duration(call.Argument(1).ToInteger()) * time.Millisecond
+		var args []goja.Value
+		if len(call.Arguments) > 2 {
+			args = append(args, call.Arguments[2:]...)
+		}
+		return sb.vm.ToValue(sb.loop.setTimer(fn, delay, args, repeat))
+	}
+	clearTimer := func(call goja.FunctionCall) goja.Value {
+		if id := call.Argument(0); !goja.IsUndefined(id) && !goja.IsNull(id) {
+			sb.loop.clearTimer(id.ToInteger())
+		}
+		return goja.Undefined()
+	}
+
+	sb.vm.Set("setTimeout", func(call goja.FunctionCall) goja.Value {
+		return setTimer(call, false)
+	})
+	sb.vm.Set("setInterval", func(call goja.FunctionCall) goja.Value {
+		return setTimer(call, true)
+	})
+	sb.vm.Set("clearTimeout", clearTimer)
+	sb.vm.Set("clearInterval", clearTimer)
+
+	// queueMicrotask 通过 Promise 任务队列执行回调，回调中未捕获的异常会终止本次执行
+	sb.vm.Set("queueMicrotask", func(call goja.FunctionCall) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(sb.vm.NewTypeError("回调参数必须是函数"))
+		}
+		promise, resolve, _ := sb.vm.NewPromise()
+		then, _ := goja.AssertFunction(sb.vm.ToValue(promise).ToObject(sb.vm).Get("then"))
+		_, err := then(sb.vm.ToValue(promise), sb.vm.ToValue(func(goja.FunctionCall) goja.Value {
+			if _, err := fn(goja.Undefined()); err != nil {
+				sb.loop.fail(err)
+			}
+			return goja.Undefined()
+		}))
+		if err != nil {
+			panic(err)
+		}
+		if err := resolve(nil); err != nil {
+			panic(err)
+		}
+		return goja.Undefined()
+	})
+}
+
+// settle 若执行结果是 Promise，则返回其最终值
+// 事件循环空闲后 Promise 仍未完成或被拒绝时返回错误
+func (sb *Sandbox) settle(result goja.Value) (goja.Value, error) {
+	if result == nil {
+		return result, nil
+	}
+	promise, ok := result.Export().(*goja.Promise)
+	if !ok {
+		return result, nil
+	}
+	switch promise.State() {
+	case goja.PromiseStateFulfilled:
+		return promise.Result(), nil
+	case goja.PromiseStateRejected:
+		return nil, &PromiseRejectedError{Reason: promise.Result()}
+	default:
+		return nil, errors.New("事件循环已空闲，但 Promise 仍未完成")
+	}
+}
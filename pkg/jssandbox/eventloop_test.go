@@ -0,0 +1,159 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEventLoop_SetTimeout(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	code := `
+		var order = [];
+		setTimeout(function() { order.push("b"); }, 20);
+		setTimeout(function(x) { order.push(x); }, 5, "a");
+		order.push("sync");
+	`
+	if _, err := sb.RunWithTimeout(code, 2*time.Second); err != nil {
+		t.Fatalf("RunWithTimeout() error = %v", err)
+	}
+
+	result, err := sb.Run(`order.join(",")`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.String() != "sync,a,b" {
+		t.Errorf("定时器执行顺序不正确, got %s, want sync,a,b", result.String())
+	}
+}
+
+func TestEventLoop_ClearTimeout(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	code := `
+		var fired = false;
+		var id = setTimeout(function() { fired = true; }, 10);
+		clearTimeout(id);
+		new Promise(function(resolve) { setTimeout(function() { resolve(fired); }, 30); });
+	`
+	result, err := sb.RunWithTimeout(code, 2*time.Second)
+	if err != nil {
+		t.Fatalf("RunWithTimeout() error = %v", err)
+	}
+	if result.ToBoolean() {
+		t.Error("clearTimeout()后回调不应该执行")
+	}
+}
+
+func TestEventLoop_SetInterval(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	code := `
+		var count = 0;
+		var id = setInterval(function() {
+			count++;
+			if (count === 3) clearInterval(id);
+		}, 1);
+	`
+	if _, err := sb.RunWithTimeout(code, 2*time.Second); err != nil {
+		t.Fatalf("RunWithTimeout() error = %v", err)
+	}
+	if got := sb.Get("count").ToInteger(); got != 3 {
+		t.Errorf("setInterval执行次数不正确, got %d, want 3", got)
+	}
+}
+
+func TestEventLoop_QueueMicrotask(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	code := `
+		var order = [];
+		setTimeout(function() { order.push("timeout"); }, 0);
+		queueMicrotask(function() { order.push("microtask"); });
+		order.push("sync");
+	`
+	if _, err := sb.RunWithTimeout(code, 2*time.Second); err != nil {
+		t.Fatalf("RunWithTimeout() error = %v", err)
+	}
+	if got := sb.Get("order").String(); got != "sync,microtask,timeout" {
+		t.Errorf("微任务执行顺序不正确, got %s", got)
+	}
+}
+
+func TestEventLoop_AsyncAwait(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	code := `
+		(async function() {
+			await new Promise(function(resolve) { setTimeout(resolve, 10); });
+			return 42;
+		})();
+	`
+	result, err := sb.RunWithTimeout(code, 2*time.Second)
+	if err != nil {
+		t.Fatalf("RunWithTimeout() error = %v", err)
+	}
+	if result.ToInteger() != 42 {
+		t.Errorf("Promise最终值不正确, got %v, want 42", result)
+	}
+}
+
+func TestEventLoop_PromiseErrors(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	t.Run("Promise被拒绝", func(t *testing.T) {
+		_, err := sb.Run(`Promise.reject(new Error("boom"))`)
+		var rejected *PromiseRejectedError
+		if !errors.As(err, &rejected) {
+			t.Fatalf("应该返回PromiseRejectedError, got %v", err)
+		}
+	})
+
+	t.Run("Promise永不完成", func(t *testing.T) {
+		_, err := sb.Run(`new Promise(function() {})`)
+		if err == nil {
+			t.Fatal("未完成的Promise应该返回错误")
+		}
+	})
+
+	t.Run("定时器回调抛出异常", func(t *testing.T) {
+		_, err := sb.Run(`setTimeout(function() { throw new Error("callback"); }, 1); 1`)
+		if err == nil {
+			t.Fatal("回调中未捕获的异常应该返回错误")
+		}
+	})
+}
+
+func TestEventLoop_ResetAfterTimeout(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	_, err := sb.RunWithTimeout(`var ticks = 0; setInterval(function() { ticks++; }, 1);`, 100*time.Millisecond)
+	if err == nil {
+		t.Fatal("未清除的setInterval应该导致超时")
+	}
+
+	// 超时后定时器应被清理，后续执行不受影响
+	result, err := sb.RunWithTimeout("1 + 1", time.Second)
+	if err != nil {
+		t.Fatalf("超时后再次执行失败: %v", err)
+	}
+	if result.ToInteger() != 2 {
+		t.Errorf("执行结果不正确, got %d, want 2", result.ToInteger())
+	}
+}
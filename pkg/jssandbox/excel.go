@@ -1,6 +1,7 @@
 package jssandbox
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/dop251/goja"
@@ -16,7 +17,14 @@ func (sb *Sandbox) registerExcel() {
 
 	// 打开现有 Excel 文件
 	sb.vm.Set("excelOpen", func(filePath string) (goja.Value, error) {
-		f, err := excelize.OpenFile(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return nil, err
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return nil, err
+		}
+		f, err := sb.openExcel(filePath)
 		if err != nil {
 			return nil, err
 		}
@@ -24,11 +32,19 @@ func (sb *Sandbox) registerExcel() {
 	})
 
 	// 保存 Excel 文件
-	sb.vm.Set("excelSave", func(f *excelize.File, filePath string) error {
+	sb.vm.Set("excelSave", func(f *excelize.File, filePath string) (err error) {
+		defer sb.auditError(AuditEvent{Module: "excel", Operation: "excelSave", Target: filePath}, &err)
 		if f == nil {
 			return fmt.Errorf("Excel 对象不能为空")
 		}
-		return f.SaveAs(filePath)
+		filePath, err = sb.resolvePath(filePath, fsWrite)
+		if err != nil {
+			return err
+		}
+		if err := sb.checkOutputName(filePath); err != nil {
+			return err
+		}
+		return sb.saveExcel(f, filePath)
 	})
 
 	// 关闭 Excel 文件
@@ -147,7 +163,14 @@ func (sb *Sandbox) registerExcel() {
 	// readExcel 读取 Excel 文件（支持分页和指定工作表）
 	// options: { sheet: string, page: int, pageSize: int }
 	sb.vm.Set("readExcel", func(filePath string, options map[string]interface{}) (map[string]interface{}, error) {
-		f, err := excelize.OpenFile(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return nil, err
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return nil, err
+		}
+		f, err := sb.openExcel(filePath)
 		if err != nil {
 			return nil, err
 		}
@@ -208,3 +231,28 @@ func (sb *Sandbox) registerExcel() {
 		}, nil
 	})
 }
+
+// openExcel 通过沙盒文件系统打开 Excel 文件
+func (sb *Sandbox) openExcel(filePath string) (*excelize.File, error) {
+	file, err := sb.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	f.Path = filePath
+	return f, nil
+}
+
+// saveExcel 把 Excel 文件写入沙盒文件系统，与 SaveAs 一样根据扩展名校验格式；内容不符合文件策略时不写入
+func (sb *Sandbox) saveExcel(f *excelize.File, filePath string) error {
+	f.Path = filePath
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return err
+	}
+	return sb.writeCheckedFile(filePath, buf.Bytes())
+}
@@ -0,0 +1,522 @@
+package jssandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// Extension 宿主扩展：注册在同一个命名空间下的一组 Go 函数和值
+// 脚本中通过同名全局对象（如 billing.quote('A1', 2)）、require('billing') 或 import { quote } from 'billing' 访问
+//
+// 函数的参数和返回值通过反射转换：
+//   - 参数个数必须与 Go 函数一致（可变参数函数至少传入固定参数），类型不符时报告 ErrCodeInvalidInput
+//   - 第一个参数为 context.Context 时自动传入当前执行的上下文，执行超时或被取消时结束
+//   - 字符串、数字、布尔值直接转换，结构体、map、切片按 JSON 规则转换（遵循 json 标签）
+//   - 返回值可以是 ()、(T)、(error) 或 (T, error)，结构体等返回值转换为普通 JavaScript 对象
+//
+// Go 函数返回的错误按 ErrorMode 报告给脚本，SandboxError 的错误码会保留
+type Extension struct {
+	name        string
+	description string
+	errorMode   ErrorMode
+	members     []*extensionMember
+}
+
+// extensionMember 扩展中的一个函数或值
+type extensionMember struct {
+	name        string
+	description string
+	// params 函数参数在文档和错误信息中显示的名称
+	params []string
+	// isFunc 是否为函数成员，函数保存在 fn 中，值保存在 value 中
+	isFunc bool
+	fn     reflect.Value
+	value  interface{}
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	gojaValType = reflect.TypeOf((*goja.Value)(nil)).Elem()
+	// identifierPattern 合法的 JavaScript 标识符（仅 ASCII）
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+)
+
+// NewExtension 创建宿主扩展，name 为脚本中的命名空间
+func NewExtension(name, description string) *Extension {
+	return &Extension{name: name, description: description}
+}
+
+// Name 返回扩展的命名空间
+func (e *Extension) Name() string {
+	return e.name
+}
+
+// Func 添加函数，params 为参数在文档和错误信息中的名称（不包括 context.Context 参数）
+func (e *Extension) Func(name string, fn interface{}, description string, params ...string) *Extension {
+	e.members = append(e.members, &extensionMember{name: name, description: description, params: params, isFunc: true, fn: reflect.ValueOf(fn)})
+	return e
+}
+
+// Value 添加值，结构体等值转换为普通 JavaScript 对象
+func (e *Extension) Value(name string, value interface{}, description string) *Extension {
+	e.members = append(e.members, &extensionMember{name: name, description: description, value: value})
+	return e
+}
+
+// Methods 把 receiver 的所有导出方法添加为函数，函数名为首字母小写的方法名（如 Quote 对应 quote）
+func (e *Extension) Methods(receiver interface{}) *Extension {
+	rv := reflect.ValueOf(receiver)
+	for i := 0; i < rv.NumMethod(); i++ {
+		e.Func(lowerFirst(rv.Type().Method(i).Name), rv.Method(i).Interface(), "")
+	}
+	return e
+}
+
+// WithErrorMode 设置扩展中的函数报告错误的方式，未设置时使用 Config.ErrorMode
+func (e *Extension) WithErrorMode(mode ErrorMode) *Extension {
+	e.errorMode = mode
+	return e
+}
+
+// lowerFirst 把标识符的首字母转换为小写
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+// validate 检查命名空间、成员名称和函数签名
+func (e *Extension) validate() error {
+	if !identifierPattern.MatchString(e.name) {
+		return NewSandboxError(ErrCodeInvalidInput, fmt.Sprintf("无效的扩展名: %q", e.name))
+	}
+	switch e.errorMode {
+	case "", ErrorModeResult, ErrorModeThrow:
+	default:
+		return NewSandboxError(ErrCodeInvalidInput, fmt.Sprintf("扩展 %s 的错误模式无效: %q", e.name, e.errorMode))
+	}
+	seen := make(map[string]bool)
+	for _, m := range e.members {
+		if !identifierPattern.MatchString(m.name) {
+			return NewSandboxError(ErrCodeInvalidInput, fmt.Sprintf("扩展 %s 的成员名无效: %q", e.name, m.name))
+		}
+		if seen[m.name] {
+			return NewSandboxError(ErrCodeInvalidInput, fmt.Sprintf("扩展 %s 的成员重复: %s", e.name, m.name))
+		}
+		seen[m.name] = true
+		if !m.isFunc {
+			continue
+		}
+		if m.fn.Kind() != reflect.Func || m.fn.IsNil() {
+			return NewSandboxError(ErrCodeInvalidInput, fmt.Sprintf("%s.%s 不是函数", e.name, m.name))
+		}
+		t := m.fn.Type()
+		if t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+			return NewSandboxError(ErrCodeInvalidInput,
+				fmt.Sprintf("%s.%s 的返回值必须是 ()、(T)、(error) 或 (T, error)", e.name, m.name))
+		}
+	}
+	return nil
+}
+
+// RegisterExtension 注册宿主扩展，同时作为全局对象和可以 require/import 的模块
+// 命名空间与已有的全局变量同名或扩展定义无效时返回 ErrCodeInvalidInput 错误
+func (sb *Sandbox) RegisterExtension(ext *Extension) error {
+	if err := ext.validate(); err != nil {
+		return err
+	}
+	defer sb.lockRuntime()()
+	if existing := sb.vm.Get(ext.name); existing != nil && !goja.IsUndefined(existing) {
+		return NewSandboxError(ErrCodeInvalidInput, fmt.Sprintf("全局变量已存在，不能注册扩展: %s", ext.name))
+	}
+
+	mode := sb.config.extensionErrorMode(ext)
+	obj := sb.vm.NewObject()
+	for _, m := range ext.members {
+		if m.isFunc {
+			obj.Set(m.name, sb.bindFunc(ext.name, m, mode))
+		} else {
+			obj.Set(m.name, sb.toJSValue(reflect.ValueOf(m.value)))
+		}
+	}
+	sb.vm.Set(ext.name, obj)
+	sb.modules.builtins[ext.name] = []moduleExport{{name: ext.name, value: obj}}
+	delete(sb.modules.cache, ext.name)
+	return nil
+}
+
+// registerConfigExtensions 注册 Config.Extensions 中的扩展，无效的扩展记录日志后跳过
+func (sb *Sandbox) registerConfigExtensions() {
+	for _, ext := range sb.config.Extensions {
+		if err := sb.RegisterExtension(ext); err != nil {
+			sb.logger.WithError(err).Warn("注册宿主扩展失败")
+		}
+	}
+}
+
+// extensionErrorMode 返回扩展使用的错误模式，扩展未设置时使用 Config.ErrorMode
+func (c *Config) extensionErrorMode(ext *Extension) ErrorMode {
+	if ext.errorMode != "" {
+		return ext.errorMode
+	}
+	if c.ErrorMode == ErrorModeThrow {
+		return ErrorModeThrow
+	}
+	return ErrorModeResult
+}
+
+// bindFunc 把 Go 函数包装为 JavaScript 函数，调用经过拦截器，按 mode 报告错误
+func (sb *Sandbox) bindFunc(namespace string, m *extensionMember, mode ErrorMode) func(call goja.FunctionCall) goja.Value {
+	qualified := namespace + "." + m.name
+	invoke := func(call *HostCall) (result goja.Value, err error) {
+		if ex := sb.vm.Try(func() {
+			result, err = sb.callHostFunc(qualified, m, goja.FunctionCall{This: call.This, Arguments: call.Args})
+		}); ex != nil {
+			return nil, ex
+		}
+		return result, err
+	}
+	return func(call goja.FunctionCall) goja.Value {
+		var result goja.Value
+		var err error
+		if sb.intercepting() {
+			result, err = sb.invokeHost(sb.newHostCall(namespace, qualified, call), 0, invoke)
+			if err != nil && result != nil {
+				err = nil
+			}
+			rethrow(err)
+		} else {
+			result, err = sb.callHostFunc(qualified, m, call)
+		}
+		if mode == ErrorModeThrow {
+			if err != nil {
+				panic(sb.newJSError(err))
+			}
+			return result
+		}
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		return sb.vm.ToValue(map[string]interface{}{
+			"success": true,
+			"data":    result,
+		})
+	}
+}
+
+// callHostFunc 转换参数并调用 Go 函数
+func (sb *Sandbox) callHostFunc(qualified string, m *extensionMember, call goja.FunctionCall) (result goja.Value, err error) {
+	t := m.fn.Type()
+	in := make([]reflect.Value, 0, t.NumIn())
+	first := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		in = append(in, reflect.ValueOf(sb.runContext()))
+		first = 1
+	}
+	fixed := t.NumIn() - first
+	if t.IsVariadic() {
+		fixed--
+		if len(call.Arguments) < fixed {
+			return nil, NewSandboxError(ErrCodeInvalidInput,
+				fmt.Sprintf("%s 至少需要 %d 个参数，实际传入 %d 个", qualified, fixed, len(call.Arguments)))
+		}
+	} else if len(call.Arguments) != fixed {
+		return nil, NewSandboxError(ErrCodeInvalidInput,
+			fmt.Sprintf("%s 需要 %d 个参数，实际传入 %d 个", qualified, fixed, len(call.Arguments)))
+	}
+
+	for i, arg := range call.Arguments {
+		var argType reflect.Type
+		if i >= fixed {
+			argType = t.In(t.NumIn() - 1).Elem()
+		} else {
+			argType = t.In(first + i)
+		}
+		v, err := sb.convertArg(arg, argType)
+		if err != nil {
+			return nil, NewSandboxError(ErrCodeInvalidInput,
+				fmt.Sprintf("%s 的参数 %s 类型错误: %v", qualified, m.paramName(i), err))
+		}
+		in = append(in, v)
+	}
+
+	// Go 函数中的 panic 转换为错误；脚本回调抛出的异常、中断和调用栈溢出继续向上传播
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				var ex *goja.Exception
+				if errors.As(e, &ex) || isUncatchable(e) {
+					panic(r)
+				}
+			}
+			result, err = nil, NewSandboxError(ErrCodeUnknown, fmt.Sprintf("%s 执行失败: %v", qualified, r))
+		}
+	}()
+	out := m.fn.Call(in)
+
+	if n := len(out); n > 0 && t.Out(n-1) == errorType {
+		if errVal := out[n-1]; !errVal.IsNil() {
+			err := errVal.Interface().(error)
+			if isUncatchable(err) {
+				panic(err)
+			}
+			return nil, err
+		}
+		out = out[:n-1]
+	}
+	if len(out) == 0 {
+		return goja.Undefined(), nil
+	}
+	return sb.toJSValue(out[0]), nil
+}
+
+// paramName 返回第 i 个参数在错误信息中的名称
+func (m *extensionMember) paramName(i int) string {
+	if i < len(m.params) {
+		return fmt.Sprintf("%d (%s)", i+1, m.params[i])
+	}
+	return fmt.Sprintf("%d", i+1)
+}
+
+// convertArg 把 JavaScript 值转换为 Go 函数参数的类型，类型不符时返回错误
+func (sb *Sandbox) convertArg(v goja.Value, t reflect.Type) (reflect.Value, error) {
+	if t == gojaValType {
+		return reflect.ValueOf(&v).Elem(), nil
+	}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("期望 %s，实际为 %s", jsTypeName(t), jsValueType(v))
+	}
+
+	actual := jsValueType(v)
+	expect := func(want string) error {
+		if actual != want {
+			return fmt.Errorf("期望 %s，实际为 %s", jsTypeName(t), actual)
+		}
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			return reflect.Value{}, fmt.Errorf("不支持的参数类型 %s", t)
+		}
+		return reflect.ValueOf(v.Export()), nil
+	case reflect.String:
+		if err := expect("string"); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v.String()).Convert(t), nil
+	case reflect.Bool:
+		if err := expect("boolean"); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v.ToBoolean()).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if err := expect("number"); err != nil {
+			return reflect.Value{}, err
+		}
+		f := v.ToFloat()
+		if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) {
+			return reflect.Value{}, fmt.Errorf("期望整数，实际为 %v", f)
+		}
+		// 先按目标类型的范围检查再转换，超出范围的浮点数转换为整数的结果没有定义
+		out := reflect.New(t).Elem()
+		if t.Kind() >= reflect.Uint {
+			if f < 0 || f >= math.Ldexp(1, t.Bits()) {
+				return reflect.Value{}, fmt.Errorf("数值超出 %s 的范围: %v", t, f)
+			}
+			out.SetUint(uint64(f))
+		} else {
+			if limit := math.Ldexp(1, t.Bits()-1); f < -limit || f >= limit {
+				return reflect.Value{}, fmt.Errorf("数值超出 %s 的范围: %v", t, f)
+			}
+			out.SetInt(int64(f))
+		}
+		return out, nil
+	case reflect.Float32, reflect.Float64:
+		if err := expect("number"); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v.ToFloat()).Convert(t), nil
+	case reflect.Func:
+		if err := expect("function"); err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(t)
+		if err := sb.vm.ExportTo(v, out.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return out.Elem(), nil
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+		if err := expect("object"); err != nil {
+			return reflect.Value{}, err
+		}
+		data, err := json.Marshal(v.Export())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(t)
+		if err := json.Unmarshal(data, out.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return out.Elem(), nil
+	}
+	return reflect.Value{}, fmt.Errorf("不支持的参数类型 %s", t)
+}
+
+// toJSValue 把 Go 返回值转换为 JavaScript 值，结构体、map、切片按 JSON 规则转换为普通对象和数组
+func (sb *Sandbox) toJSValue(rv reflect.Value) goja.Value {
+	if !rv.IsValid() {
+		return goja.Null()
+	}
+	if v, ok := rv.Interface().(goja.Value); ok {
+		return v
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if rv.IsNil() {
+			return goja.Null()
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Array:
+		if data, err := json.Marshal(rv.Interface()); err == nil {
+			var plain interface{}
+			if err := json.Unmarshal(data, &plain); err == nil {
+				return sb.vm.ToValue(plain)
+			}
+		}
+	}
+	return sb.vm.ToValue(rv.Interface())
+}
+
+// jsValueType 返回 JavaScript 值的类型名（null 单独区分）
+func jsValueType(v goja.Value) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	}
+	if _, ok := goja.AssertFunction(v); ok {
+		return "function"
+	}
+	if _, ok := v.(*goja.Object); ok {
+		return "object"
+	}
+	switch v.ExportType().Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int64, reflect.Float64:
+		return "number"
+	}
+	return v.ExportType().String()
+}
+
+// jsTypeName 返回 Go 类型在文档和错误信息中对应的 JavaScript 类型名
+func jsTypeName(t reflect.Type) string {
+	if t == gojaValType {
+		return "any"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return jsTypeName(t.Elem()) + "[]"
+	case reflect.Ptr:
+		return jsTypeName(t.Elem())
+	case reflect.Func:
+		return "function"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return "any"
+}
+
+// Doc 生成扩展的 Markdown 文档，包含每个成员的签名、说明和错误报告方式
+func (e *Extension) Doc() string {
+	return e.doc(DefaultConfig().extensionErrorMode(e))
+}
+
+// doc 按指定的错误模式生成文档
+func (e *Extension) doc(mode ErrorMode) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", e.name)
+	if e.description != "" {
+		fmt.Fprintf(&b, "%s\n\n", e.description)
+	}
+	for _, m := range e.members {
+		if m.isFunc {
+			fmt.Fprintf(&b, "- `%s.%s`", e.name, m.signature())
+		} else {
+			fmt.Fprintf(&b, "- `%s.%s: %s`", e.name, m.name, jsTypeName(reflect.TypeOf(m.value)))
+		}
+		if m.description != "" {
+			fmt.Fprintf(&b, " %s", m.description)
+		}
+		b.WriteString("\n")
+	}
+	if mode == ErrorModeThrow {
+		b.WriteString("\n函数出错时抛出 SandboxError 异常（带 code 属性）。\n")
+	} else {
+		b.WriteString("\n函数返回 { success, data }，出错时返回 { success: false, error, code }。\n")
+	}
+	return b.String()
+}
+
+// signature 返回函数成员的 TypeScript 风格签名，如 quote(sku: string, qty: number): object
+func (m *extensionMember) signature() string {
+	t := m.fn.Type()
+	first := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		first = 1
+	}
+	params := make([]string, 0, t.NumIn()-first)
+	for i := first; i < t.NumIn(); i++ {
+		name := fmt.Sprintf("arg%d", i-first+1)
+		if i-first < len(m.params) {
+			name = m.params[i-first]
+		}
+		typ := jsTypeName(t.In(i))
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			name = "..." + name
+		}
+		params = append(params, name+": "+typ)
+	}
+	ret := "void"
+	if t.NumOut() > 0 && t.Out(0) != errorType {
+		ret = jsTypeName(t.Out(0))
+	}
+	return fmt.Sprintf("%s(%s): %s", m.name, strings.Join(params, ", "), ret)
+}
+
+// ExtensionDocs 生成 Extensions 中所有扩展的 Markdown 文档，可以附加到提供给大模型的工具说明中
+func (c *Config) ExtensionDocs() string {
+	docs := make([]string, 0, len(c.Extensions))
+	for _, ext := range c.Extensions {
+		docs = append(docs, ext.doc(c.extensionErrorMode(ext)))
+	}
+	return strings.Join(docs, "\n")
+}
@@ -0,0 +1,280 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testQuote struct {
+	SKU   string  `json:"sku"`
+	Qty   int     `json:"qty"`
+	Total float64 `json:"total"`
+}
+
+type testInventory struct {
+	stock map[string]int
+}
+
+func (inv *testInventory) Stock(sku string) int {
+	return inv.stock[sku]
+}
+
+func (inv *testInventory) Reserve(sku string, qty int) error {
+	if inv.stock[sku] < qty {
+		return NewSandboxError(ErrCodeInvalidInput, "库存不足: "+sku)
+	}
+	inv.stock[sku] -= qty
+	return nil
+}
+
+func newTestExtension() *Extension {
+	return NewExtension("billing", "计费相关的宿主函数").
+		Func("quote", func(sku string, qty int) (testQuote, error) {
+			if qty <= 0 {
+				return testQuote{}, errors.New("数量必须大于 0")
+			}
+			return testQuote{SKU: sku, Qty: qty, Total: float64(qty) * 9.5}, nil
+		}, "计算报价", "sku", "qty").
+		Func("sum", func(nums ...float64) float64 {
+			var total float64
+			for _, n := range nums {
+				total += n
+			}
+			return total
+		}, "求和", "nums").
+		Func("totalOf", func(ctx context.Context, quotes []testQuote) float64 {
+			var total float64
+			for _, q := range quotes {
+				total += q.Total
+			}
+			return total
+		}, "汇总报价", "quotes").
+		Func("mapSKUs", func(skus []string, fn func(string) string) []string {
+			out := make([]string, len(skus))
+			for i, sku := range skus {
+				out[i] = fn(sku)
+			}
+			return out
+		}, "转换 SKU", "skus", "fn").
+		Value("currency", "CNY", "默认币种").
+		Value("defaults", testQuote{SKU: "A1", Qty: 1}, "默认报价参数")
+}
+
+func TestExtension_ResultMode(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithExtension(newTestExtension()))
+	defer sb.Close()
+
+	result, err := sb.Run(`
+		var q = billing.quote('A1', 2);
+		var bad = billing.quote('A1', 0);
+		var wrongType = billing.quote('A1', '2');
+		var fraction = billing.quote('A1', 1.5);
+		var missing = billing.quote('A1');
+		[
+			q.success, q.data.sku, q.data.total,
+			bad.success, bad.error,
+			wrongType.code, fraction.code, missing.code,
+			billing.sum().data, billing.sum(1, 2, 3.5).data,
+			billing.totalOf([{sku: 'A', qty: 1, total: 2}, {sku: 'B', qty: 1, total: 3}]).data,
+			billing.mapSKUs(['a', 'b'], function (s) { return s.toUpperCase(); }).data.join(','),
+			billing.currency, billing.defaults.sku,
+			require('billing') === billing
+		]
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{
+		true, "A1", int64(19),
+		false, "数量必须大于 0",
+		string(ErrCodeInvalidInput), string(ErrCodeInvalidInput), string(ErrCodeInvalidInput),
+		int64(0), 6.5,
+		int64(5),
+		"A,B",
+		"CNY", "A1",
+		true,
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExtension_ThrowMode(t *testing.T) {
+	inv := &testInventory{stock: map[string]int{"A1": 3}}
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	if err := sb.RegisterExtension(NewExtension("inventory", "库存").Methods(inv).WithErrorMode(ErrorModeThrow)); err != nil {
+		t.Fatalf("RegisterExtension() error = %v", err)
+	}
+
+	result, err := sb.Run(`
+		inventory.reserve('A1', 2);
+		var code, message;
+		try { inventory.reserve('A1', 2); } catch (e) { code = e.code; message = e.message; }
+		var typeCode;
+		try { inventory.stock(42); } catch (e) { typeCode = e.code; }
+		[inventory.stock('A1'), code, message, typeCode]
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	if got[0] != int64(1) || got[1] != string(ErrCodeInvalidInput) || got[3] != string(ErrCodeInvalidInput) {
+		t.Errorf("result = %v", got)
+	}
+	if msg, _ := got[2].(string); !strings.Contains(msg, "库存不足: A1") {
+		t.Errorf("message = %q", got[2])
+	}
+}
+
+func TestExtension_IntegerArgs(t *testing.T) {
+	ext := NewExtension("ints", "").
+		Func("i8", func(n int8) int8 { return n }, "").
+		Func("u32", func(n uint32) uint32 { return n }, "").
+		Func("i64", func(n int64) int64 { return n }, "").
+		Func("u64", func(n uint64) uint64 { return n }, "")
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithExtension(ext))
+	defer sb.Close()
+
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{`ints.i8(-128)`, true},
+		{`ints.i8(127)`, true},
+		{`ints.i8(128)`, false},
+		{`ints.i8(-129)`, false},
+		{`ints.u32(4294967295)`, true},
+		{`ints.u32(4294967296)`, false},
+		{`ints.u32(-1)`, false},
+		{`ints.i64(-(2 ** 63))`, true},
+		{`ints.i64(2 ** 63)`, false},
+		{`ints.i64(1e20)`, false},
+		{`ints.u64(2 ** 64)`, false},
+		{`ints.u64(1e20)`, false},
+		{`ints.i64(Infinity)`, false},
+		{`ints.i64(-Infinity)`, false},
+		{`ints.i64(NaN)`, false},
+		{`ints.i64(1.5)`, false},
+	}
+	for _, tt := range tests {
+		result, err := sb.Run(tt.code + `.success`)
+		if err != nil {
+			t.Fatalf("%s: Run() error = %v", tt.code, err)
+		}
+		if result.ToBoolean() != tt.ok {
+			t.Errorf("%s: success = %v, want %v", tt.code, result, tt.ok)
+		}
+	}
+}
+
+func TestExtension_CallbackErrorPropagates(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithExtension(newTestExtension()))
+	defer sb.Close()
+
+	result, err := sb.Run(`
+		try {
+			billing.mapSKUs(['a'], function () { throw new RangeError('bad sku'); });
+			'not thrown';
+		} catch (e) { e.name + ': ' + e.message }
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.String() != "RangeError: bad sku" {
+		t.Errorf("result = %v", result)
+	}
+}
+
+func TestExtension_Import(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithExtension(newTestExtension()))
+	defer sb.Close()
+
+	ns, err := sb.RunModule(`
+		import { quote, currency } from 'billing';
+		export default quote('B2', 1).data.total + ' ' + currency;
+	`)
+	if err != nil {
+		t.Fatalf("RunModule() error = %v", err)
+	}
+	if got := ns.Get("default").String(); got != "9.5 CNY" {
+		t.Errorf("default = %q", got)
+	}
+}
+
+func TestExtension_Invalid(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	tests := []struct {
+		name string
+		ext  *Extension
+	}{
+		{"无效的命名空间", NewExtension("my-ext", "")},
+		{"与全局变量同名", NewExtension("logger", "")},
+		{"不是函数", NewExtension("ext1", "").Func("f", "not a func", "")},
+		{"返回值无效", NewExtension("ext2", "").Func("f", func() (int, int) { return 0, 0 }, "")},
+		{"成员重复", NewExtension("ext3", "").Value("a", 1, "").Value("a", 2, "")},
+		{"错误模式无效", NewExtension("ext4", "").WithErrorMode("panic")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sb.RegisterExtension(tt.ext)
+			var sbErr *SandboxError
+			if !errors.As(err, &sbErr) || sbErr.Code != ErrCodeInvalidInput {
+				t.Errorf("RegisterExtension() error = %v, want %s", err, ErrCodeInvalidInput)
+			}
+		})
+	}
+}
+
+func TestExtension_Pool(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1).WithMaxSize(1).
+		WithSandboxConfig(DefaultConfig().WithExtension(newTestExtension())))
+
+	for i := 0; i < 2; i++ {
+		err := pool.Do(context.Background(), func(sb *Sandbox) error {
+			result, err := sb.Run(`billing.currency = 'USD'; billing.sum(1, 2).data`)
+			if err != nil {
+				return err
+			}
+			if result.ToFloat() != 3 {
+				t.Errorf("result = %v, want 3", result)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+}
+
+func TestExtension_Doc(t *testing.T) {
+	doc := DefaultConfig().WithExtension(newTestExtension()).ExtensionDocs()
+	for _, want := range []string{
+		"### billing",
+		"计费相关的宿主函数",
+		"- `billing.quote(sku: string, qty: number): object` 计算报价",
+		"- `billing.sum(...nums: number[]): number` 求和",
+		"- `billing.totalOf(quotes: object[]): number` 汇总报价",
+		"- `billing.mapSKUs(skus: string[], fn: function): string[]` 转换 SKU",
+		"- `billing.currency: string` 默认币种",
+		"{ success: false, error, code }",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("文档缺少 %q:\n%s", want, doc)
+		}
+	}
+
+	doc = NewExtension("inventory", "").Methods(&testInventory{}).WithErrorMode(ErrorModeThrow).Doc()
+	for _, want := range []string{"inventory.reserve(arg1: string, arg2: number): void", "inventory.stock(arg1: string): number", "抛出"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("文档缺少 %q:\n%s", want, doc)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package jssandbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/h2non/filetype"
+	"github.com/h2non/filetype/types"
+)
+
+// fileHeaderSize 文件类型检测需要读取的头部字节数
+const fileHeaderSize = 261
+
+// 文件策略（Config.MaxFileSize 与 Config.AllowedFileTypes）的集中校验
+//
+// 所有读写文件的宿主函数都应在访问文件前调用这里的检查函数：
+//   - 读取前调用 checkFileRead，校验文件大小和类型
+//   - 写入已知内容前调用 checkFileWrite / checkFileAppend，或通过 writeCheckedFile 校验后写入
+//   - 由第三方库生成文件时，生成前调用 checkOutputName，生成的内容通过 writeCheckedFile 写入
+//   - 读取数据流（HTTP响应体、解压数据）时使用 readAllLimited 限制大小
+//
+// 文件类型优先通过文件头检测（与 detectFileType 使用相同的 filetype 库），
+// 无法识别时回退到扩展名，避免通过修改扩展名绕过限制。
+
+// readFileHeader 读取文件头部用于类型检测
+func (sb *Sandbox) readFileHeader(filePath string) ([]byte, error) {
+	file, err := sb.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, fileHeaderSize)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// newFilePolicyError 创建文件策略错误
+func newFilePolicyError(format string, args ...interface{}) *SandboxError {
+	return NewSandboxError(ErrCodeFilePolicy, fmt.Sprintf(format, args...))
+}
+
+// errorResult 将沙盒错误转换为返回给JavaScript的结果对象，SandboxError 会附带 code 字段，
+// 文件不存在的错误附带 FILE_NOT_FOUND；原始错误记录在沙盒中，见 resultCause
+func (sb *Sandbox) errorResult(err error) map[string]interface{} {
+	result := map[string]interface{}{
+		"success": false,
+		"error":   err.Error(),
+	}
+	var sbErr *SandboxError
+	if errors.As(err, &sbErr) {
+		result["code"] = string(sbErr.Code)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		result["code"] = string(ErrCodeFileNotFound)
+	}
+	sb.lastFailure.Store(&failedResult{result: result, err: err})
+	return result
+}
+
+// failedResult errorResult 生成的失败结果和它的原始错误
+type failedResult struct {
+	result map[string]interface{}
+	err    error
+}
+
+// resultCause 返回失败结果对象的原始错误：obj 是最近一次 errorResult 生成的结果时返回其错误，否则返回 nil
+// ErrorModeThrow 和拦截器据此保留错误链，宿主函数返回的结果对象在脚本中是对 Go map 的包装，导出时得到同一个 map
+func (sb *Sandbox) resultCause(obj *goja.Object) error {
+	last := sb.lastFailure.Load()
+	if last == nil {
+		return nil
+	}
+	m, ok := obj.Export().(map[string]interface{})
+	if !ok || reflect.ValueOf(m).Pointer() != reflect.ValueOf(last.result).Pointer() {
+		return nil
+	}
+	return last.err
+}
+
+// isSandboxError 判断错误是否为沙盒策略产生的 SandboxError（文件策略、访问控制等）
+func isSandboxError(err error) bool {
+	var sbErr *SandboxError
+	return errors.As(err, &sbErr)
+}
+
+// checkFileSize 校验大小是否超过 MaxFileSize
+func (sb *Sandbox) checkFileSize(name string, size int64) error {
+	if max := sb.config.MaxFileSize; max > 0 && size > max {
+		return newFilePolicyError("文件大小超过限制: %s (%d 字节，最大允许 %d 字节)", name, size, max)
+	}
+	return nil
+}
+
+// checkFileType 根据文件头（优先）或扩展名校验文件类型是否在 AllowedFileTypes 中
+func (sb *Sandbox) checkFileType(name string, header []byte) error {
+	if len(sb.config.AllowedFileTypes) == 0 {
+		return nil
+	}
+	if kind, err := filetype.Match(header); err == nil && kind != filetype.Unknown {
+		if sb.fileTypeAllowed(kind.Extension, kind.MIME) {
+			return nil
+		}
+		return newFilePolicyError("不允许的文件类型: %s (检测为 %s)", name, kind.MIME.Value)
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if ext == "" {
+		return newFilePolicyError("不允许的文件类型: %s (无法识别文件类型)", name)
+	}
+	var mimeType types.MIME
+	if value, _, err := mime.ParseMediaType(mime.TypeByExtension("." + ext)); err == nil {
+		mimeType = types.NewMIME(value)
+	}
+	if sb.fileTypeAllowed(ext, mimeType) {
+		return nil
+	}
+	return newFilePolicyError("不允许的文件类型: %s", name)
+}
+
+// fileTypeAllowed 判断扩展名或 MIME 类型是否匹配 AllowedFileTypes 中的任一项
+// 支持的写法：扩展名（"pdf" 或 ".pdf"）、完整 MIME（"application/pdf"）、
+// MIME 通配（"image/*"）以及 MIME 主类型（"image"）
+func (sb *Sandbox) fileTypeAllowed(ext string, mimeType types.MIME) bool {
+	for _, allowed := range sb.config.AllowedFileTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		allowed = strings.TrimPrefix(allowed, ".")
+		if allowed == "" {
+			continue
+		}
+		switch {
+		case allowed == ext:
+			return true
+		case mimeType.Value != "" && allowed == mimeType.Value:
+			return true
+		case mimeType.Type != "" && (allowed == mimeType.Type || allowed == mimeType.Type+"/*"):
+			return true
+		}
+	}
+	return false
+}
+
+// checkDataType 仅当数据内容可被识别为已知类型时校验类型
+// 用于 HTTP 响应体等没有可靠文件名的数据，避免把普通文本响应误判为违规
+func (sb *Sandbox) checkDataType(name string, data []byte) error {
+	if len(sb.config.AllowedFileTypes) == 0 {
+		return nil
+	}
+	if kind, err := filetype.Match(data); err != nil || kind == filetype.Unknown {
+		return nil
+	}
+	return sb.checkFileType(name, data)
+}
+
+// checkFileRead 读取文件前校验文件大小和类型
+func (sb *Sandbox) checkFileRead(filePath string) error {
+	if sb.config.MaxFileSize <= 0 && len(sb.config.AllowedFileTypes) == 0 {
+		return nil
+	}
+	info, err := sb.fs.Stat(filePath)
+	if err != nil {
+		// 文件不存在等错误交给调用方按原有逻辑处理
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	if err := sb.checkFileSize(filePath, info.Size()); err != nil {
+		return err
+	}
+	if len(sb.config.AllowedFileTypes) == 0 {
+		return nil
+	}
+	header, err := sb.readFileHeader(filePath)
+	if err != nil {
+		return nil
+	}
+	return sb.checkFileType(filePath, header)
+}
+
+// checkFileReads 依次校验多个待读取文件
+func (sb *Sandbox) checkFileReads(filePaths ...string) error {
+	for _, filePath := range filePaths {
+		if err := sb.checkFileRead(filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFileWrite 写入文件前校验待写入数据的大小和类型
+func (sb *Sandbox) checkFileWrite(filePath string, data []byte) error {
+	if err := sb.checkFileSize(filePath, int64(len(data))); err != nil {
+		return err
+	}
+	return sb.checkFileType(filePath, data)
+}
+
+// checkFileAppend 追加写入前校验追加后的文件大小和类型
+func (sb *Sandbox) checkFileAppend(filePath string, data []byte) error {
+	var size int64
+	header := data
+	if info, err := sb.fs.Stat(filePath); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+		if size > 0 {
+			if h, err := sb.readFileHeader(filePath); err == nil {
+				header = h
+			}
+		}
+	}
+	if err := sb.checkFileSize(filePath, size+int64(len(data))); err != nil {
+		return err
+	}
+	return sb.checkFileType(filePath, header)
+}
+
+// checkOutputName 由第三方库写文件前，根据输出路径的扩展名校验类型
+func (sb *Sandbox) checkOutputName(filePath string) error {
+	return sb.checkFileType(filePath, nil)
+}
+
+// writeCheckedFile 校验数据的大小和类型后写入文件，不符合策略时不会创建或覆盖目标文件
+func (sb *Sandbox) writeCheckedFile(filePath string, data []byte) error {
+	if err := sb.checkFileWrite(filePath, data); err != nil {
+		return err
+	}
+	return writeFileFS(sb.fs, filePath, data, 0644)
+}
+
+// readAllLimited 读取 r 的全部内容，超过 MaxFileSize 时返回文件策略错误
+func (sb *Sandbox) readAllLimited(name string, r io.Reader) ([]byte, error) {
+	max := sb.config.MaxFileSize
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return data, err
+	}
+	if int64(len(data)) > max {
+		return nil, newFilePolicyError("数据大小超过限制: %s (最大允许 %d 字节)", name, max)
+	}
+	return data, nil
+}
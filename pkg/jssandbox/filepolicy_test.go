@@ -0,0 +1,339 @@
+package jssandbox
+
+import (
+	"archive/zip"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// pngHeader PNG 文件头，用于类型检测测试
+var pngHeader = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0, 0, 0, 0x0d, 'I', 'H', 'D', 'R'}
+
+// expectPolicyViolation 断言结果对象是文件策略错误
+func expectPolicyViolation(t *testing.T, vm *goja.Runtime, result goja.Value) {
+	t.Helper()
+	resultObj := result.ToObject(vm)
+	if code := resultObj.Get("code"); code == nil || code.String() != string(ErrCodeFilePolicy) {
+		t.Errorf("应该返回 code=%s, got %v", ErrCodeFilePolicy, resultObj.Export())
+	}
+	if success := resultObj.Get("success"); success == nil || success.ToBoolean() {
+		t.Error("违反文件策略时应该返回success: false")
+	}
+}
+
+func TestFilePolicy_MaxFileSize(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithMaxFileSize(16))
+	defer sb.Close()
+
+	tempDir := t.TempDir()
+	small := filepath.Join(tempDir, "small.txt")
+	large := filepath.Join(tempDir, "large.txt")
+	os.WriteFile(small, []byte("0123456789"), 0644)
+	os.WriteFile(large, []byte(strings.Repeat("x", 32)), 0644)
+
+	t.Run("读取未超限文件", func(t *testing.T) {
+		result, err := sb.Run(`readFile("` + small + `")`)
+		if err != nil {
+			t.Fatalf("readFile() error = %v", err)
+		}
+		if data := result.ToObject(sb.vm).Get("data"); data.String() != "0123456789" {
+			t.Errorf("readFile()内容不正确, got %s", data.String())
+		}
+	})
+
+	for _, fn := range []string{"readFile", "readFileTail", "getFileHash", "readImageBase64", "readConfig"} {
+		t.Run(fn+"超限", func(t *testing.T) {
+			result, err := sb.Run(fn + `("` + large + `")`)
+			if err != nil {
+				t.Fatalf("%s() error = %v", fn, err)
+			}
+			expectPolicyViolation(t, sb.vm, result)
+		})
+	}
+
+	t.Run("writeFile超限", func(t *testing.T) {
+		target := filepath.Join(tempDir, "write.txt")
+		result, err := sb.Run(`writeFile("` + target + `", "` + strings.Repeat("y", 17) + `")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+		if _, err := os.Stat(target); !os.IsNotExist(err) {
+			t.Error("超限时不应该创建文件")
+		}
+	})
+
+	t.Run("appendFile累计超限", func(t *testing.T) {
+		result, err := sb.Run(`appendFile("` + small + `", "0123456789")`)
+		if err != nil {
+			t.Fatalf("appendFile() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+		data, _ := os.ReadFile(small)
+		if string(data) != "0123456789" {
+			t.Errorf("超限时文件内容不应该被修改, got %s", string(data))
+		}
+	})
+
+	t.Run("decompressGzip超限", func(t *testing.T) {
+		result, err := sb.Run(`decompressGzip(compressGzip("` + strings.Repeat("z", 64) + `").data)`)
+		if err != nil {
+			t.Fatalf("decompressGzip() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+}
+
+func TestFilePolicy_AllowedFileTypes(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithAllowedFileTypes([]string{"txt", "image/*"}))
+	defer sb.Close()
+
+	tempDir := t.TempDir()
+
+	t.Run("允许的扩展名", func(t *testing.T) {
+		result, err := sb.Run(`writeFile("` + filepath.Join(tempDir, "ok.txt") + `", "hello")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		if !result.ToObject(sb.vm).Get("success").ToBoolean() {
+			t.Errorf("写入txt文件应该成功, got %v", result.Export())
+		}
+	})
+
+	t.Run("不允许的扩展名", func(t *testing.T) {
+		result, err := sb.Run(`writeFile("` + filepath.Join(tempDir, "script.sh") + `", "echo hi")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+
+	t.Run("按文件头识别伪装的文件", func(t *testing.T) {
+		result, err := sb.Run(`writeFile("` + filepath.Join(tempDir, "fake.txt") + `", "%PDF-1.4 fake")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+
+	t.Run("MIME通配允许图片", func(t *testing.T) {
+		imgPath := filepath.Join(tempDir, "image.dat")
+		os.WriteFile(imgPath, pngHeader, 0644)
+		result, err := sb.Run(`readImageBase64("` + imgPath + `")`)
+		if err != nil {
+			t.Fatalf("readImageBase64() error = %v", err)
+		}
+		if base64 := result.ToObject(sb.vm).Get("base64"); base64 == nil || base64.String() == "" {
+			t.Errorf("读取图片应该成功, got %v", result.Export())
+		}
+	})
+
+	t.Run("不允许的读取类型", func(t *testing.T) {
+		zipPath := filepath.Join(tempDir, "archive.txt")
+		writeTestZip(t, zipPath, map[string]string{"a.txt": "a"})
+		result, err := sb.Run(`readFile("` + zipPath + `")`)
+		if err != nil {
+			t.Fatalf("readFile() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+}
+
+func TestFilePolicy_ExtractZip(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+
+	t.Run("条目超过大小限制", func(t *testing.T) {
+		sb := NewSandboxWithConfig(ctx, DefaultConfig().WithMaxFileSize(1024))
+		defer sb.Close()
+
+		zipPath := filepath.Join(tempDir, "bomb.zip")
+		writeTestZip(t, zipPath, map[string]string{"big.txt": strings.Repeat("0", 64*1024)})
+		outDir := filepath.Join(tempDir, "bomb")
+
+		result, err := sb.Run(`extractZip("` + zipPath + `", "` + outDir + `")`)
+		if err != nil {
+			t.Fatalf("extractZip() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+		if _, err := os.Stat(filepath.Join(outDir, "big.txt")); !os.IsNotExist(err) {
+			t.Error("超限的条目不应该被解压")
+		}
+	})
+
+	t.Run("条目类型不允许", func(t *testing.T) {
+		sb := NewSandboxWithConfig(ctx, DefaultConfig().WithAllowedFileTypes([]string{"zip", "txt"}))
+		defer sb.Close()
+
+		zipPath := filepath.Join(tempDir, "mixed.zip")
+		writeTestZip(t, zipPath, map[string]string{"run.sh": "#!/bin/sh"})
+		outDir := filepath.Join(tempDir, "mixed")
+
+		result, err := sb.Run(`extractZip("` + zipPath + `", "` + outDir + `")`)
+		if err != nil {
+			t.Fatalf("extractZip() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+}
+
+func TestFilePolicy_ImageAndDocuments(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithAllowedFileTypes([]string{"png"}))
+	defer sb.Close()
+
+	tempDir := t.TempDir()
+	input := filepath.Join(tempDir, "input.png")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatalf("创建图片失败: %v", err)
+	}
+	png.Encode(file, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	file.Close()
+
+	t.Run("允许的输出类型", func(t *testing.T) {
+		result, err := sb.Run(`imageResize("` + input + `", "` + filepath.Join(tempDir, "out.png") + `", 2)`)
+		if err != nil {
+			t.Fatalf("imageResize() error = %v", err)
+		}
+		if !result.ToObject(sb.vm).Get("success").ToBoolean() {
+			t.Errorf("imageResize()应该成功, got %v", result.Export())
+		}
+	})
+
+	t.Run("不允许的输出类型", func(t *testing.T) {
+		output := filepath.Join(tempDir, "out.jpg")
+		result, err := sb.Run(`imageResize("` + input + `", "` + output + `", 2)`)
+		if err != nil {
+			t.Fatalf("imageResize() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+		if _, err := os.Stat(output); !os.IsNotExist(err) {
+			t.Error("不允许的输出文件不应该被创建")
+		}
+	})
+
+	t.Run("excelOpen不允许的类型", func(t *testing.T) {
+		xlsx := filepath.Join(tempDir, "book.xlsx")
+		os.WriteFile(xlsx, []byte("not really excel"), 0644)
+		_, err := sb.Run(`excelOpen("` + xlsx + `")`)
+		if err == nil || !strings.Contains(err.Error(), string(ErrCodeFilePolicy)) {
+			t.Errorf("excelOpen()应该抛出文件策略错误, got %v", err)
+		}
+	})
+
+	t.Run("pdfMerge不允许的输入", func(t *testing.T) {
+		pdf := filepath.Join(tempDir, "a.pdf")
+		os.WriteFile(pdf, []byte("%PDF-1.4"), 0644)
+		result, err := sb.Run(`pdfMerge(["` + pdf + `"], "` + filepath.Join(tempDir, "merged.png") + `")`)
+		if err != nil {
+			t.Fatalf("pdfMerge() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+
+	t.Run("违规输出不覆盖已有文件", func(t *testing.T) {
+		// 扩展名允许但内容检测为 zip，写入被拒绝时原有的 png 文件保持不变
+		kept := filepath.Join(tempDir, "kept.png")
+		original, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatalf("读取图片失败: %v", err)
+		}
+		os.WriteFile(kept, original, 0644)
+		result, err := sb.Run(`compressZip(["` + input + `"], "` + kept + `")`)
+		if err != nil {
+			t.Fatalf("compressZip() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+		if data, err := os.ReadFile(kept); err != nil || string(data) != string(original) {
+			t.Errorf("原有文件应该保持不变, err = %v", err)
+		}
+	})
+}
+
+func TestFilePolicy_HTTPBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 128)))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithMaxFileSize(64).WithPrivateNetwork(true))
+	defer sb.Close()
+
+	t.Run("响应体超限", func(t *testing.T) {
+		result, err := sb.Run(`httpRequest("` + server.URL + `")`)
+		if err != nil {
+			t.Fatalf("httpRequest() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+
+	t.Run("请求体超限", func(t *testing.T) {
+		result, err := sb.Run(`httpRequest("` + server.URL + `", {method: "POST", body: "` + strings.Repeat("b", 65) + `"})`)
+		if err != nil {
+			t.Fatalf("httpRequest() error = %v", err)
+		}
+		expectPolicyViolation(t, sb.vm, result)
+	})
+}
+
+func TestFileTypeAllowed(t *testing.T) {
+	sb := &Sandbox{config: DefaultConfig().WithAllowedFileTypes([]string{".PDF", "text/plain", "image/*", "video"})}
+
+	tests := []struct {
+		name    string
+		file    string
+		header  []byte
+		allowed bool
+	}{
+		{"带点和大写的扩展名", "doc.pdf", nil, true},
+		{"完整MIME", "notes.txt", nil, true},
+		{"MIME通配", "photo", pngHeader, true},
+		{"MIME主类型", "clip.mp4", nil, true},
+		{"不在列表中", "data.json", nil, false},
+		{"无扩展名且无法识别", "README", []byte("hello"), false},
+		{"文件头优先于扩展名", "photo.pdf", []byte("PK\x03\x04"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sb.checkFileType(tt.file, tt.header)
+			if (err == nil) != tt.allowed {
+				t.Errorf("checkFileType(%s) error = %v, allowed %v", tt.file, err, tt.allowed)
+			}
+		})
+	}
+}
+
+// writeTestZip 创建包含指定条目的ZIP文件
+func writeTestZip(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("创建ZIP文件失败: %v", err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for name, content := range entries {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("创建ZIP条目失败: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("写入ZIP文件失败: %v", err)
+	}
+}
@@ -23,7 +23,22 @@ import (
 // registerFileSystem 注册文件系统操作功能到JavaScript运行时
 func (sb *Sandbox) registerFileSystem() {
 	// 使用操作系统默认软件打开文件
-	sb.vm.Set("openFile", func(filePath string) map[string]interface{} {
+	sb.vm.Set("openFile", func(filePath string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "fs", Operation: "openFile", Target: filePath}, &result)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.errorResult(err)
+		}
+		if !isOSFileSystem(sb.fs) {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "当前文件系统不在宿主机磁盘上，无法使用系统软件打开文件",
+			}
+		}
+
 		var cmd *exec.Cmd
 		switch runtime.GOOS {
 		case "darwin":
@@ -38,14 +53,14 @@ func (sb *Sandbox) registerFileSystem() {
 				"error":   fmt.Sprintf("不支持的操作系统: %s", runtime.GOOS),
 			}
 		}
+		if err := sb.checkPermission(PermRun, cmd.Args[0]); err != nil {
+			return sb.errorResult(err)
+		}
 
-		err := cmd.Run()
+		err = cmd.Run()
 		if err != nil {
 			sb.logger.WithError(err).WithField("path", filePath).Error("打开文件失败")
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 
 		return map[string]interface{}{
@@ -55,11 +70,14 @@ func (sb *Sandbox) registerFileSystem() {
 
 	// 读取文件元信息
 	sb.vm.Set("getFileInfo", func(filePath string) goja.Value {
-		info, err := os.Stat(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		info, err := sb.fs.Stat(filePath)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		result := map[string]interface{}{
@@ -69,9 +87,12 @@ func (sb *Sandbox) registerFileSystem() {
 			"isDir": info.IsDir(),
 		}
 
-		// 获取时间信息
-		t, err := times.Stat(filePath)
-		if err == nil {
+		// 获取时间信息，创建时间和访问时间只能从宿主机文件系统获取
+		var t times.Timespec
+		if isOSFileSystem(sb.fs) {
+			t, _ = times.Stat(filePath)
+		}
+		if t != nil {
 			if t.HasBirthTime() {
 				result["birthTime"] = t.BirthTime().Format("2006-01-02 15:04:05")
 			}
@@ -93,7 +114,7 @@ func (sb *Sandbox) registerFileSystem() {
 		result["extension"] = ext
 
 		// 尝试使用filetype库检测
-		file, err := os.Open(filePath)
+		file, err := sb.fs.Open(filePath)
 		if err == nil {
 			buf := make([]byte, 261)
 			n, _ := file.Read(buf)
@@ -117,20 +138,27 @@ func (sb *Sandbox) registerFileSystem() {
 	})
 
 	// 重命名文件
-	sb.vm.Set("renameFile", func(oldPath, newPath string) map[string]interface{} {
-		err := os.Rename(oldPath, newPath)
+	sb.vm.Set("renameFile", func(oldPath, newPath string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "fs", Operation: "renameFile", Target: oldPath, Details: map[string]interface{}{"newPath": newPath}}, &result)
+		oldPath, err := sb.resolvePath(oldPath, fsRemove)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		newPath, err = sb.resolvePath(newPath, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+
+		err = sb.fs.Rename(oldPath, newPath)
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
 		}
 	})
 
-	// 读取文件内容（支持分页）
+	// 读取文件内容（支持分页），选项为 {encoding: 'binary'} 时以 Buffer 返回数据
 	sb.vm.Set("readFile", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 1 {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -141,6 +169,7 @@ func (sb *Sandbox) registerFileSystem() {
 		filePath := call.Arguments[0].String()
 		offset := int64(0)
 		limit := int64(1024 * 1024) // 默认1MB
+		binary := sb.binaryOption(call.Argument(1))
 
 		if len(call.Arguments) > 1 {
 			options := call.Arguments[1].ToObject(sb.vm)
@@ -152,11 +181,20 @@ func (sb *Sandbox) registerFileSystem() {
 			}
 		}
 
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if max := sb.config.MaxFileSize; max > 0 && limit > max {
+			limit = max
+		}
+
+		file, err := sb.fs.Open(filePath)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		defer file.Close()
 
@@ -167,13 +205,11 @@ func (sb *Sandbox) registerFileSystem() {
 		buffer := make([]byte, limit)
 		n, err := file.Read(buffer)
 		if err != nil && err != io.EOF {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		return sb.vm.ToValue(map[string]interface{}{
-			"data":   string(buffer[:n]),
+			"data":   sb.bytesResult(buffer[:n], binary),
 			"length": n,
 			"offset": offset,
 		})
@@ -181,11 +217,17 @@ func (sb *Sandbox) registerFileSystem() {
 
 	// 读取文本文件的前几行
 	sb.vm.Set("readFileHead", func(filePath string, lines int) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		file, err := sb.fs.Open(filePath)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		defer file.Close()
 
@@ -199,9 +241,7 @@ func (sb *Sandbox) registerFileSystem() {
 		}
 
 		if err := scanner.Err(); err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		return sb.vm.ToValue(map[string]interface{}{
@@ -212,11 +252,17 @@ func (sb *Sandbox) registerFileSystem() {
 
 	// 读取文本文件的后几行
 	sb.vm.Set("readFileTail", func(filePath string, lines int) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		file, err := sb.fs.Open(filePath)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		defer file.Close()
 
@@ -228,9 +274,7 @@ func (sb *Sandbox) registerFileSystem() {
 		}
 
 		if err := scanner.Err(); err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		start := len(allLines) - lines
@@ -259,11 +303,17 @@ func (sb *Sandbox) registerFileSystem() {
 			hashType = strings.ToLower(call.Arguments[1].String())
 		}
 
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		file, err := sb.fs.Open(filePath)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		defer file.Close()
 
@@ -299,19 +349,23 @@ func (sb *Sandbox) registerFileSystem() {
 
 	// 读取图片文件的base64编码
 	sb.vm.Set("readImageBase64", func(filePath string) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		file, err := sb.fs.Open(filePath)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		defer file.Close()
 
 		data, err := io.ReadAll(file)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		ext := strings.ToLower(filepath.Ext(filePath))
@@ -335,37 +389,48 @@ func (sb *Sandbox) registerFileSystem() {
 		})
 	})
 
-	// 写入文件
-	sb.vm.Set("writeFile", func(filePath string, content string) map[string]interface{} {
-		err := os.WriteFile(filePath, []byte(content), 0644)
+	// 写入文件，内容可以是字符串或 Buffer、ArrayBuffer 等二进制数据
+	sb.vm.Set("writeFile", func(filePath string, contentVal goja.Value) (result map[string]interface{}) {
+		content := sb.bytesArg(contentVal)
+		defer sb.auditMap(AuditEvent{Module: "fs", Operation: "writeFile", Target: filePath, BytesOut: int64(len(content))}, &result)
+		filePath, err := sb.resolvePath(filePath, fsWrite)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileWrite(filePath, content); err != nil {
+			return sb.errorResult(err)
+		}
+
+		err = writeFileFS(sb.fs, filePath, content, 0644)
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
 		}
 	})
 
-	// 追加文件
-	sb.vm.Set("appendFile", func(filePath string, content string) map[string]interface{} {
-		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// 追加文件，内容可以是字符串或二进制数据
+	sb.vm.Set("appendFile", func(filePath string, contentVal goja.Value) (result map[string]interface{}) {
+		content := sb.bytesArg(contentVal)
+		defer sb.auditMap(AuditEvent{Module: "fs", Operation: "appendFile", Target: filePath, BytesOut: int64(len(content))}, &result)
+		filePath, err := sb.resolvePath(filePath, fsWrite)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileAppend(filePath, content); err != nil {
+			return sb.errorResult(err)
+		}
+
+		file, err := sb.fs.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		defer file.Close()
 
-		_, err = file.WriteString(content)
+		_, err = file.Write(content)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 
 		return map[string]interface{}{
@@ -374,7 +439,9 @@ func (sb *Sandbox) registerFileSystem() {
 	})
 
 	// 创建临时文件
-	sb.vm.Set("createTempFile", func(call goja.FunctionCall) goja.Value {
+	sb.vm.Set("createTempFile", func(call goja.FunctionCall) (result goja.Value) {
+		target := ""
+		defer func() { sb.auditValue(AuditEvent{Module: "fs", Operation: "createTempFile", Target: target}, &result) }()
 		dir := ""
 		pattern := ""
 
@@ -388,9 +455,22 @@ func (sb *Sandbox) registerFileSystem() {
 			}
 		}
 
-		// 如果没有指定目录，使用系统临时目录
+		// 如果没有指定目录，使用系统临时目录；使用虚拟根目录时使用沙盒内的 /tmp
 		if dir == "" {
 			dir = os.TempDir()
+			if sb.hasVirtualRoot() {
+				dir = "/tmp"
+			}
+		}
+		target = dir
+		dir, err := sb.resolvePath(dir, fsWrite)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if sb.hasVirtualRoot() {
+			if err := sb.fs.MkdirAll(dir, 0755); err != nil {
+				return sb.vm.ToValue(sb.errorResult(err))
+			}
 		}
 
 		// 如果没有指定模式，使用默认模式
@@ -398,16 +478,18 @@ func (sb *Sandbox) registerFileSystem() {
 			pattern = "temp-*.tmp"
 		}
 
-		file, err := os.CreateTemp(dir, pattern)
+		if err := sb.checkOutputName(pattern); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		file, err := createTempFS(sb.fs, dir, pattern)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		defer file.Close()
 
-		filePath := file.Name()
+		filePath := sb.virtualPath(file.Name())
+		target = filePath
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
 			"path":    filePath,
@@ -416,13 +498,17 @@ func (sb *Sandbox) registerFileSystem() {
 
 	// 获取当前工作目录
 	getCurrentDir := func() map[string]interface{} {
-		dir, err := os.Getwd()
-		if err != nil {
+		// 使用虚拟根目录时，工作目录固定为沙盒根目录
+		if sb.hasVirtualRoot() {
 			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
+				"success": true,
+				"path":    "/",
 			}
 		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return sb.errorResult(err)
+		}
 		return map[string]interface{}{
 			"success": true,
 			"path":    dir,
@@ -432,47 +518,52 @@ func (sb *Sandbox) registerFileSystem() {
 	sb.vm.Set("pwd", getCurrentDir)
 
 	// 创建目录
-	makeDir := func(call goja.FunctionCall) map[string]interface{} {
+	makeDir := func(call goja.FunctionCall) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "fs", Operation: "makeDir", Target: call.Argument(0).String()}, &result)
 		if len(call.Arguments) < 1 {
 			return map[string]interface{}{
 				"success": false,
 				"error":   "需要提供目录路径",
 			}
 		}
-		dirPath := call.Arguments[0].String()
+		dirPath, err := sb.resolvePath(call.Arguments[0].String(), fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
 		recursive := false
 		if len(call.Arguments) > 1 {
 			recursive = call.Arguments[1].ToBoolean()
 		}
 
-		var err error
 		if recursive {
-			err = os.MkdirAll(dirPath, 0755)
+			err = sb.fs.MkdirAll(dirPath, 0755)
 		} else {
-			err = os.Mkdir(dirPath, 0755)
+			err = sb.fs.Mkdir(dirPath, 0755)
 		}
 
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
 		}
 	}
-	sb.vm.Set("makeDir", makeDir)
-	sb.vm.Set("mkdir", makeDir)
+	sb.vm.Set("makeDir", func(call goja.FunctionCall) goja.Value {
+		return sb.vm.ToValue(makeDir(call))
+	})
+	sb.vm.Set("mkdir", func(call goja.FunctionCall) goja.Value {
+		return sb.vm.ToValue(makeDir(call))
+	})
 
 	// 列出目录内容
 	listDir := func(dirPath string) map[string]interface{} {
-		entries, err := os.ReadDir(dirPath)
+		dirPath, err := sb.resolvePath(dirPath, fsRead)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		entries, err := sb.fs.ReadDir(dirPath)
+		if err != nil {
+			return sb.errorResult(err)
 		}
 
 		var result []map[string]interface{}
@@ -499,50 +590,59 @@ func (sb *Sandbox) registerFileSystem() {
 
 	// 检查路径是否存在
 	sb.vm.Set("pathExists", func(path string) bool {
-		_, err := os.Stat(path)
+		path, err := sb.resolvePath(path, fsRead)
+		if err != nil {
+			return false
+		}
+		_, err = sb.fs.Stat(path)
 		return err == nil || os.IsExist(err)
 	})
 
 	// 删除目录
-	sb.vm.Set("removeDir", func(call goja.FunctionCall) map[string]interface{} {
+	removeDir := func(call goja.FunctionCall) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "fs", Operation: "removeDir", Target: call.Argument(0).String()}, &result)
 		if len(call.Arguments) < 1 {
 			return map[string]interface{}{
 				"success": false,
 				"error":   "需要提供目录路径",
 			}
 		}
-		dirPath := call.Arguments[0].String()
+		dirPath, err := sb.resolvePath(call.Arguments[0].String(), fsRemove)
+		if err != nil {
+			return sb.errorResult(err)
+		}
 		recursive := false
 		if len(call.Arguments) > 1 {
 			recursive = call.Arguments[1].ToBoolean()
 		}
 
-		var err error
 		if recursive {
-			err = os.RemoveAll(dirPath)
+			err = sb.fs.RemoveAll(dirPath)
 		} else {
-			err = os.Remove(dirPath)
+			err = sb.fs.Remove(dirPath)
 		}
 
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
 		}
+	}
+	sb.vm.Set("removeDir", func(call goja.FunctionCall) goja.Value {
+		return sb.vm.ToValue(removeDir(call))
 	})
 
 	// 删除文件
-	sb.vm.Set("deleteFile", func(filePath string) map[string]interface{} {
-		err := os.Remove(filePath)
+	sb.vm.Set("deleteFile", func(filePath string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "fs", Operation: "deleteFile", Target: filePath}, &result)
+		filePath, err := sb.resolvePath(filePath, fsRemove)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		err = sb.fs.Remove(filePath)
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
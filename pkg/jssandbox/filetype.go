@@ -1,7 +1,6 @@
 package jssandbox
 
 import (
-	"os"
 
 	"github.com/dop251/goja"
 	"github.com/h2non/filetype"
@@ -11,11 +10,13 @@ import (
 func (sb *Sandbox) registerFileTypeDetection() {
 	// 检测文件类型
 	sb.vm.Set("detectFileType", func(filePath string) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		file, err := sb.fs.Open(filePath)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		defer file.Close()
 
@@ -23,9 +24,7 @@ func (sb *Sandbox) registerFileTypeDetection() {
 		buf := make([]byte, 261)
 		n, err := file.Read(buf)
 		if err != nil && n == 0 {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		// 检测文件类型
@@ -56,7 +55,11 @@ func (sb *Sandbox) registerFileTypeDetection() {
 
 	// 检测是否为图片
 	sb.vm.Set("isImage", func(filePath string) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		file, err := sb.fs.Open(filePath)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"isImage": false,
@@ -82,7 +85,11 @@ func (sb *Sandbox) registerFileTypeDetection() {
 
 	// 检测是否为音频
 	sb.vm.Set("isAudio", func(filePath string) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		file, err := sb.fs.Open(filePath)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"isAudio": false,
@@ -108,7 +115,11 @@ func (sb *Sandbox) registerFileTypeDetection() {
 
 	// 检测是否为文档
 	sb.vm.Set("isDocument", func(filePath string) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		file, err := sb.fs.Open(filePath)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"isDocument": false,
@@ -134,7 +145,11 @@ func (sb *Sandbox) registerFileTypeDetection() {
 
 	// 检测是否为字体
 	sb.vm.Set("isFont", func(filePath string) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		file, err := sb.fs.Open(filePath)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"isFont": false,
@@ -160,7 +175,11 @@ func (sb *Sandbox) registerFileTypeDetection() {
 
 	// 检测是否为归档文件
 	sb.vm.Set("isArchive", func(filePath string) goja.Value {
-		file, err := os.Open(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		file, err := sb.fs.Open(filePath)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"isArchive": false,
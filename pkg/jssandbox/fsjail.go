@@ -0,0 +1,216 @@
+package jssandbox
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fsAccess 文件访问类型
+type fsAccess int
+
+const (
+	// fsRead 只读访问
+	fsRead fsAccess = iota
+	// fsWrite 创建或修改文件
+	fsWrite
+	// fsRemove 删除或重命名，不允许作用于挂载点本身
+	fsRemove
+)
+
+// fsJail 文件系统隔离，把脚本看到的虚拟路径映射到宿主机目录
+//
+// 虚拟路径以 "/" 为根，相对路径相对于 "/"，".." 在根目录处被截断，无法越过根目录。
+// 解析时会展开符号链接，指向挂载点之外的符号链接会被拒绝。
+type fsJail struct {
+	mounts []jailMount // 按虚拟路径长度降序排列，优先匹配最长前缀
+}
+
+// jailMount 已解析的挂载点
+type jailMount struct {
+	virtual  string // 清理后的虚拟路径，如 "/" 或 "/data"
+	host     string // 展开符号链接后的宿主机绝对路径
+	readOnly bool
+}
+
+// newFSJail 根据配置创建文件系统隔离，未配置根目录和挂载点或使用非宿主机文件系统时返回 nil
+func newFSJail(config *Config) *fsJail {
+	if config.FileSystem != nil && !isOSFileSystem(config.FileSystem) {
+		return nil
+	}
+	var mounts []Mount
+	if config.FileSystemRoot != "" {
+		mounts = append(mounts, Mount{VirtualPath: "/", HostPath: config.FileSystemRoot})
+	}
+	mounts = append(mounts, config.Mounts...)
+	if len(mounts) == 0 {
+		return nil
+	}
+
+	jail := &fsJail{}
+	for _, m := range mounts {
+		jail.mounts = append(jail.mounts, jailMount{
+			virtual:  cleanVirtualPath(m.VirtualPath),
+			host:     realHostPath(m.HostPath),
+			readOnly: m.ReadOnly,
+		})
+	}
+	sort.SliceStable(jail.mounts, func(i, j int) bool {
+		return len(jail.mounts[i].virtual) > len(jail.mounts[j].virtual)
+	})
+	return jail
+}
+
+// cleanVirtualPath 把脚本传入的路径规范化为以 "/" 开头的虚拟路径
+func cleanVirtualPath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	return path.Clean("/" + p)
+}
+
+// realHostPath 返回宿主机目录展开符号链接后的绝对路径，目录不存在时返回清理后的绝对路径
+func realHostPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = filepath.Clean(dir)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// match 返回虚拟路径所属的挂载点以及在挂载点内的相对路径
+func (j *fsJail) match(virtual string) (*jailMount, string, bool) {
+	for i := range j.mounts {
+		m := &j.mounts[i]
+		if m.virtual == "/" {
+			return m, strings.TrimPrefix(virtual, "/"), true
+		}
+		if virtual == m.virtual {
+			return m, "", true
+		}
+		if strings.HasPrefix(virtual, m.virtual+"/") {
+			return m, strings.TrimPrefix(virtual, m.virtual+"/"), true
+		}
+	}
+	return nil, "", false
+}
+
+// resolve 把虚拟路径解析为宿主机上的真实路径
+func (j *fsJail) resolve(p string, access fsAccess) (string, error) {
+	virtual := cleanVirtualPath(p)
+	m, rel, ok := j.match(virtual)
+	if !ok {
+		return "", newAccessDeniedError("路径不在沙盒文件系统内: %s", p)
+	}
+	if access != fsRead && m.readOnly {
+		return "", newAccessDeniedError("挂载点为只读: %s", p)
+	}
+	if access == fsRemove && rel == "" {
+		return "", newAccessDeniedError("不能删除或移动挂载点: %s", p)
+	}
+
+	real, err := evalExistingPath(filepath.Join(m.host, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", newAccessDeniedError("无法解析路径 %s: %v", p, err)
+	}
+	if !pathWithin(real, m.host) {
+		return "", newAccessDeniedError("符号链接指向沙盒文件系统之外: %s", p)
+	}
+	return real, nil
+}
+
+// virtualPath 把宿主机路径转换回脚本可见的虚拟路径
+func (j *fsJail) virtualPath(host string) string {
+	var best *jailMount
+	for i := range j.mounts {
+		m := &j.mounts[i]
+		if pathWithin(host, m.host) && (best == nil || len(m.host) > len(best.host)) {
+			best = m
+		}
+	}
+	if best == nil {
+		return host
+	}
+	rel, err := filepath.Rel(best.host, host)
+	if err != nil {
+		return host
+	}
+	return path.Join(best.virtual, filepath.ToSlash(rel))
+}
+
+// evalExistingPath 展开路径中已存在部分的符号链接，不存在的尾部原样拼接
+// 末尾是悬空符号链接时返回错误，避免写入时穿过符号链接创建外部文件
+func evalExistingPath(p string) (string, error) {
+	rest := ""
+	cur := p
+	for {
+		real, err := filepath.EvalSymlinks(cur)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if _, lerr := os.Lstat(cur); lerr == nil {
+			return "", &os.PathError{Op: "resolve", Path: cur, Err: os.ErrNotExist}
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return p, nil
+		}
+		rest = filepath.Join(filepath.Base(cur), rest)
+		cur = parent
+	}
+}
+
+// pathWithin 判断 p 是否等于 dir 或位于 dir 之下
+func pathWithin(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel))
+}
+
+// newAccessDeniedError 创建访问被拒绝错误
+func newAccessDeniedError(format string, args ...interface{}) *SandboxError {
+	return NewSandboxError(ErrCodeAccessDenied, fmt.Sprintf(format, args...))
+}
+
+// resolvePath 把脚本传入的路径解析为宿主机路径
+// 未启用文件系统隔离时原样返回
+func (sb *Sandbox) resolvePath(p string, access fsAccess) (string, error) {
+	if err := sb.checkPathPermission(p, access); err != nil {
+		return "", err
+	}
+	if sb.jail == nil {
+		return p, nil
+	}
+	return sb.jail.resolve(p, access)
+}
+
+// resolvePaths 依次解析多个路径
+func (sb *Sandbox) resolvePaths(paths []string, access fsAccess) ([]string, error) {
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
+		hostPath, err := sb.resolvePath(p, access)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, hostPath)
+	}
+	return resolved, nil
+}
+
+// virtualPath 把宿主机路径转换为返回给脚本的路径
+// 未启用文件系统隔离时原样返回
+func (sb *Sandbox) virtualPath(hostPath string) string {
+	if sb.jail == nil {
+		return hostPath
+	}
+	return sb.jail.virtualPath(hostPath)
+}
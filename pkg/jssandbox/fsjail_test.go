@@ -0,0 +1,287 @@
+package jssandbox
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// expectAccessDenied 断言结果对象是访问被拒绝错误
+func expectAccessDenied(t *testing.T, vm *goja.Runtime, result goja.Value) {
+	t.Helper()
+	resultObj := result.ToObject(vm)
+	if code := resultObj.Get("code"); code == nil || code.String() != string(ErrCodeAccessDenied) {
+		t.Errorf("应该返回 code=%s, got %v", ErrCodeAccessDenied, resultObj.Export())
+	}
+}
+
+func TestFSJail_Root(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	os.MkdirAll(root, 0755)
+	secret := filepath.Join(base, "secret.txt")
+	os.WriteFile(secret, []byte("secret"), 0644)
+
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithFileSystemRoot(root))
+	defer sb.Close()
+
+	t.Run("相对路径写入根目录", func(t *testing.T) {
+		result, err := sb.Run(`writeFile("notes.txt", "hello")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		if !result.ToObject(sb.vm).Get("success").ToBoolean() {
+			t.Fatalf("writeFile()应该成功, got %v", result.Export())
+		}
+		if data, _ := os.ReadFile(filepath.Join(root, "notes.txt")); string(data) != "hello" {
+			t.Errorf("文件应该写入根目录, got %q", string(data))
+		}
+	})
+
+	t.Run("路径穿越被截断在根目录", func(t *testing.T) {
+		result, err := sb.Run(`writeFile("../../escape.txt", "x")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		if !result.ToObject(sb.vm).Get("success").ToBoolean() {
+			t.Fatalf("writeFile()应该成功, got %v", result.Export())
+		}
+		if _, err := os.Stat(filepath.Join(root, "escape.txt")); err != nil {
+			t.Error("穿越路径应该被截断到根目录内")
+		}
+		if _, err := os.Stat(filepath.Join(base, "escape.txt")); !os.IsNotExist(err) {
+			t.Error("不应该在根目录之外创建文件")
+		}
+	})
+
+	t.Run("宿主机绝对路径映射到根目录内", func(t *testing.T) {
+		result, err := sb.Run(`readFile("` + secret + `")`)
+		if err != nil {
+			t.Fatalf("readFile() error = %v", err)
+		}
+		if data := result.ToObject(sb.vm).Get("data"); data != nil && data.String() == "secret" {
+			t.Error("不应该读取到根目录之外的文件")
+		}
+	})
+
+	t.Run("返回虚拟路径", func(t *testing.T) {
+		result, err := sb.Run(`[pwd().path, pathAbs("a/../b").path, createTempFile().path]`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		paths := result.Export().([]interface{})
+		if paths[0] != "/" || paths[1] != "/b" {
+			t.Errorf("pwd/pathAbs 应该返回虚拟路径, got %v", paths)
+		}
+		if tmp, _ := paths[2].(string); !strings.HasPrefix(tmp, "/tmp/") {
+			t.Errorf("createTempFile 应该返回虚拟路径, got %v", paths[2])
+		}
+	})
+
+	t.Run("不能删除根目录", func(t *testing.T) {
+		result, err := sb.Run(`removeDir("/", true)`)
+		if err != nil {
+			t.Fatalf("removeDir() error = %v", err)
+		}
+		expectAccessDenied(t, sb.vm, result)
+		if _, err := os.Stat(root); err != nil {
+			t.Error("根目录不应该被删除")
+		}
+	})
+}
+
+func TestFSJail_Symlink(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	os.MkdirAll(root, 0755)
+	os.MkdirAll(outside, 0755)
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+	os.Symlink(filepath.Join(outside, "missing.txt"), filepath.Join(root, "dangling"))
+	os.MkdirAll(filepath.Join(root, "inner"), 0755)
+	os.Symlink(filepath.Join(root, "inner"), filepath.Join(root, "inner-link"))
+
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithFileSystemRoot(root))
+	defer sb.Close()
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"读取", `readFile("/link/secret.txt")`},
+		{"写入", `writeFile("/link/new.txt", "x")`},
+		{"列目录", `listDir("/link")`},
+		{"悬空符号链接", `writeFile("/dangling", "x")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sb.Run(tt.code)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			expectAccessDenied(t, sb.vm, result)
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); !os.IsNotExist(err) {
+		t.Error("不应该通过符号链接在根目录之外创建文件")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "missing.txt")); !os.IsNotExist(err) {
+		t.Error("不应该通过悬空符号链接在根目录之外创建文件")
+	}
+
+	t.Run("根目录内的符号链接可以使用", func(t *testing.T) {
+		result, err := sb.Run(`writeFile("/inner-link/ok.txt", "ok")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		if !result.ToObject(sb.vm).Get("success").ToBoolean() {
+			t.Errorf("writeFile()应该成功, got %v", result.Export())
+		}
+	})
+}
+
+func TestFSJail_Mounts(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	data := filepath.Join(base, "data")
+	os.MkdirAll(root, 0755)
+	os.MkdirAll(data, 0755)
+	os.WriteFile(filepath.Join(data, "input.txt"), []byte("input"), 0644)
+
+	t.Run("只读挂载点", func(t *testing.T) {
+		sb := NewSandboxWithConfig(context.Background(), DefaultConfig().
+			WithFileSystemRoot(root).
+			WithReadOnlyMount("/data", data))
+		defer sb.Close()
+
+		result, err := sb.Run(`readFile("/data/input.txt").data`)
+		if err != nil {
+			t.Fatalf("readFile() error = %v", err)
+		}
+		if result.String() != "input" {
+			t.Errorf("应该能读取只读挂载点中的文件, got %s", result.String())
+		}
+
+		for _, code := range []string{
+			`writeFile("/data/output.txt", "x")`,
+			`deleteFile("/data/input.txt")`,
+			`renameFile("/data/input.txt", "/moved.txt")`,
+			`removeDir("/data", true)`,
+		} {
+			result, err := sb.Run(code)
+			if err != nil {
+				t.Fatalf("%s error = %v", code, err)
+			}
+			expectAccessDenied(t, sb.vm, result)
+		}
+		if _, err := os.Stat(filepath.Join(data, "input.txt")); err != nil {
+			t.Error("只读挂载点中的文件不应该被修改")
+		}
+	})
+
+	t.Run("仅挂载点时其他路径不可访问", func(t *testing.T) {
+		sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithMount("/data", data))
+		defer sb.Close()
+
+		result, err := sb.Run(`writeFile("/data/output.txt", "x")`)
+		if err != nil {
+			t.Fatalf("writeFile() error = %v", err)
+		}
+		if !result.ToObject(sb.vm).Get("success").ToBoolean() {
+			t.Errorf("可写挂载点应该允许写入, got %v", result.Export())
+		}
+
+		result, err = sb.Run(`readFile("/etc/hostname")`)
+		if err != nil {
+			t.Fatalf("readFile() error = %v", err)
+		}
+		expectAccessDenied(t, sb.vm, result)
+	})
+}
+
+func TestExtractZip_Unsafe(t *testing.T) {
+	base := t.TempDir()
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	t.Run("zip-slip", func(t *testing.T) {
+		zipPath := filepath.Join(base, "slip.zip")
+		writeTestZip(t, zipPath, map[string]string{"../evil.txt": "evil"})
+		outDir := filepath.Join(base, "slip")
+
+		result, err := sb.Run(`extractZip("` + zipPath + `", "` + outDir + `")`)
+		if err != nil {
+			t.Fatalf("extractZip() error = %v", err)
+		}
+		expectAccessDenied(t, sb.vm, result)
+		if _, err := os.Stat(filepath.Join(base, "evil.txt")); !os.IsNotExist(err) {
+			t.Error("zip-slip 条目不应该被解压")
+		}
+	})
+
+	t.Run("符号链接条目", func(t *testing.T) {
+		zipPath := filepath.Join(base, "symlink.zip")
+		file, _ := os.Create(zipPath)
+		writer := zip.NewWriter(file)
+		header := &zip.FileHeader{Name: "link"}
+		header.SetMode(os.ModeSymlink | 0777)
+		w, _ := writer.CreateHeader(header)
+		w.Write([]byte("/etc/passwd"))
+		writer.Close()
+		file.Close()
+
+		result, err := sb.Run(`extractZip("` + zipPath + `", "` + filepath.Join(base, "symlink") + `")`)
+		if err != nil {
+			t.Fatalf("extractZip() error = %v", err)
+		}
+		expectAccessDenied(t, sb.vm, result)
+	})
+
+	t.Run("通过输出目录中的符号链接逃逸", func(t *testing.T) {
+		outDir := filepath.Join(base, "out")
+		outside := filepath.Join(base, "outside")
+		os.MkdirAll(outDir, 0755)
+		os.MkdirAll(outside, 0755)
+		if err := os.Symlink(outside, filepath.Join(outDir, "sub")); err != nil {
+			t.Skipf("无法创建符号链接: %v", err)
+		}
+		zipPath := filepath.Join(base, "via-link.zip")
+		writeTestZip(t, zipPath, map[string]string{"sub/evil.txt": "evil"})
+
+		result, err := sb.Run(`extractZip("` + zipPath + `", "` + outDir + `")`)
+		if err != nil {
+			t.Fatalf("extractZip() error = %v", err)
+		}
+		expectAccessDenied(t, sb.vm, result)
+		if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+			t.Error("不应该通过符号链接解压到输出目录之外")
+		}
+	})
+}
+
+func TestCleanVirtualPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "/"},
+		{"a/b", "/a/b"},
+		{"/a/../../b", "/b"},
+		{"../../etc/passwd", "/etc/passwd"},
+		{`dir\file.txt`, "/dir/file.txt"},
+	}
+	for _, tt := range tests {
+		if got := cleanVirtualPath(tt.in); got != tt.want {
+			t.Errorf("cleanVirtualPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -14,9 +14,7 @@ func (sb *Sandbox) registerGoQuery() {
 		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 		if err != nil {
 			sb.logger.WithError(err).Error("解析 HTML 失败")
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		// 创建一个 JavaScript 对象来表示文档
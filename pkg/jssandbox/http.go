@@ -2,112 +2,235 @@ package jssandbox
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dop251/goja"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// registerHTTP 注册HTTP请求功能到JavaScript运行时
-func (sb *Sandbox) registerHTTP() {
-	sb.vm.Set("httpRequest", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) < 1 {
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": "需要提供URL参数",
-			})
-		}
+// httpRequestOptions HTTP请求参数
+type httpRequestOptions struct {
+	url     string
+	method  string
+	headers map[string]string
+	body    []byte
+	timeout time.Duration
+	// binary 为 true 时以 Buffer 返回响应体，对应选项 {responseType: 'arraybuffer'} 或 {encoding: 'binary'}
+	binary bool
+	// op 发起请求的函数名，用于审计记录
+	op string
+}
 
-		url := call.Arguments[0].String()
-		method := "GET"
-		headers := make(map[string]string)
-		body := ""
-		// 使用配置中的默认超时时间（秒）
-		timeout := int(sb.config.HTTPTimeout.Seconds())
+// httpResponse HTTP响应
+type httpResponse struct {
+	status     int
+	statusText string
+	header     http.Header
+	body       []byte
+}
 
-		if len(call.Arguments) > 1 {
-			options := call.Arguments[1].ToObject(sb.vm)
-			if methodVal := options.Get("method"); methodVal != nil && !goja.IsUndefined(methodVal) {
-				method = methodVal.String()
-			}
-			if headersVal := options.Get("headers"); headersVal != nil && !goja.IsUndefined(headersVal) {
-				headersObj := headersVal.ToObject(sb.vm)
-				for _, key := range headersObj.Keys() {
-					headers[key] = headersObj.Get(key).String()
-				}
-			}
-			if bodyVal := options.Get("body"); bodyVal != nil && !goja.IsUndefined(bodyVal) {
-				body = bodyVal.String()
-			}
-			if timeoutVal := options.Get("timeout"); timeoutVal != nil && !goja.IsUndefined(timeoutVal) {
-				timeout = int(timeoutVal.ToInteger())
+// parseHTTPOptions 从 (url, options) 参数解析HTTP请求参数
+func (sb *Sandbox) parseHTTPOptions(call goja.FunctionCall) httpRequestOptions {
+	opts := httpRequestOptions{
+		url:     call.Arguments[0].String(),
+		method:  "GET",
+		headers: make(map[string]string),
+		// 使用配置中的默认超时时间
+		timeout: sb.config.HTTPTimeout,
+	}
+
+	if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
+		options := call.Arguments[1].ToObject(sb.vm)
+		if methodVal := options.Get("method"); methodVal != nil && !goja.IsUndefined(methodVal) {
+			opts.method = methodVal.String()
+		}
+		if headersVal := options.Get("headers"); headersVal != nil && !goja.IsUndefined(headersVal) {
+			headersObj := headersVal.ToObject(sb.vm)
+			for _, key := range headersObj.Keys() {
+				opts.headers[key] = headersObj.Get(key).String()
 			}
 		}
+		if bodyVal := options.Get("body"); bodyVal != nil && !goja.IsUndefined(bodyVal) {
+			opts.body = sb.bytesArg(bodyVal)
+		}
+		opts.binary = sb.binaryOption(options)
+		if timeoutVal := options.Get("timeout"); timeoutVal != nil && !goja.IsUndefined(timeoutVal) {
+			opts.timeout = time.Duration(timeoutVal.ToInteger()) * time.Second
+		}
+	}
+	return opts
+}
 
-		client := &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
+// doHTTPRequest 执行HTTP请求，不访问JavaScript运行时，可在任意 goroutine 调用
+// 读取响应体失败时同时返回已收到的响应和错误
+func (sb *Sandbox) doHTTPRequest(ctx context.Context, opts httpRequestOptions) (res *httpResponse, err error) {
+	defer func() {
+		event := AuditEvent{Module: "http", Operation: opts.op, Target: opts.url, BytesOut: int64(len(opts.body)),
+			Details: map[string]interface{}{"method": opts.method}}
+		if res != nil {
+			event.BytesIn = int64(len(res.body))
+			event.Details["status"] = res.status
+			sb.config.Metrics.addHTTPBytes(event.BytesOut, event.BytesIn)
 		}
+		sb.audit(ctx, event, err)
+	}()
+
+	client := &http.Client{
+		Timeout:       opts.timeout,
+		Transport:     sb.httpTransport,
+		CheckRedirect: sb.egress.checkRedirect,
+	}
 
-		var reqBody io.Reader
-		if body != "" {
-			reqBody = bytes.NewBufferString(body)
+	if err := sb.checkFileSize("HTTP请求体", int64(len(opts.body))); err != nil {
+		return nil, err
+	}
+
+	var reqBody io.Reader
+	if len(opts.body) > 0 {
+		reqBody = bytes.NewReader(opts.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, opts.method, opts.url, reqBody)
+	if err != nil {
+		sb.logger.WithError(err).Error("创建HTTP请求失败")
+		return nil, err
+	}
+	if err := sb.egress.checkURL(req.URL); err != nil {
+		sb.logger.WithError(err).WithField("url", opts.url).Warn("HTTP请求被拒绝")
+		return nil, err
+	}
+
+	// 创建客户端 span 并把追踪信息注入请求头，脚本设置的同名请求头优先
+	ctx, span := sb.startHTTPSpan(ctx, req)
+	req = req.WithContext(ctx)
+	defer func() {
+		if res != nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", res.status))
 		}
+		endSpan(span, err)
+	}()
 
-		req, err := http.NewRequest(method, url, reqBody)
-		if err != nil {
-			sb.logger.WithError(err).Error("创建HTTP请求失败")
-			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
-			})
+	for k, v := range opts.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if policyErr := policyError(err); policyErr != nil {
+			sb.logger.WithError(policyErr).WithField("url", opts.url).Warn("HTTP请求被拒绝")
+			return nil, policyErr
 		}
+		sb.logger.WithError(err).Error("执行HTTP请求失败")
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		for k, v := range headers {
-			req.Header.Set(k, v)
+	res = &httpResponse{
+		status:     resp.StatusCode,
+		statusText: resp.Status,
+		header:     resp.Header,
+	}
+	res.body, err = sb.readAllLimited(opts.url, resp.Body)
+	if err == nil {
+		err = sb.checkDataType(opts.url, res.body)
+	}
+	if err != nil {
+		sb.logger.WithError(err).Error("读取响应体失败")
+		if isSandboxError(err) {
+			res.body = nil
 		}
+		return res, err
+	}
+	return res, nil
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			sb.logger.WithError(err).Error("执行HTTP请求失败")
+// registerHTTP 注册HTTP请求功能到JavaScript运行时
+func (sb *Sandbox) registerHTTP() {
+	sb.vm.Set("httpRequest", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
 			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
+				"error": "需要提供URL参数",
 			})
 		}
-		defer resp.Body.Close()
 
-		respBody, err := io.ReadAll(resp.Body)
+		opts := sb.parseHTTPOptions(call)
+		opts.op = "httpRequest"
+		res, err := sb.doHTTPRequest(sb.runContext(), opts)
 		if err != nil {
-			sb.logger.WithError(err).Error("读取响应体失败")
-			return sb.vm.ToValue(map[string]interface{}{
-				"status":  resp.StatusCode,
-				"headers": resp.Header,
-				"error":   err.Error(),
-			})
+			result := map[string]interface{}{
+				"error": err.Error(),
+			}
+			if isSandboxError(err) {
+				result = sb.errorResult(err)
+			}
+			if res != nil {
+				result["status"] = res.status
+				result["headers"] = res.header
+			}
+			return sb.vm.ToValue(result)
 		}
 
 		// 构建响应头对象
 		respHeaders := make(map[string]string)
-		for k, v := range resp.Header {
+		for k, v := range res.header {
 			if len(v) > 0 {
 				respHeaders[k] = v[0]
 			}
 		}
 
 		return sb.vm.ToValue(map[string]interface{}{
-			"status":      resp.StatusCode,
-			"statusText":  resp.Status,
+			"status":      res.status,
+			"statusText":  res.statusText,
 			"headers":     respHeaders,
-			"body":        string(respBody),
-			"contentType": resp.Header.Get("Content-Type"),
+			"body":        sb.bytesResult(res.body, opts.binary),
+			"contentType": res.header.Get("Content-Type"),
 		})
 	})
 
+	// fetch 异步版本，返回 Promise，请求在后台 goroutine 中执行
+	sb.vm.Set("fetch", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(sb.vm.NewTypeError("需要提供URL参数"))
+		}
+
+		opts := sb.parseHTTPOptions(call)
+		opts.op = "fetch"
+		ctx := sb.runContext()
+		promise, resolve, reject := sb.vm.NewPromise()
+		complete := sb.loop.startAsync()
+
+		go func() {
+			res, err := sb.doHTTPRequest(ctx, opts)
+			complete(func() error {
+				if err != nil {
+					typeErr := sb.vm.NewTypeError("fetch 请求失败: %v", err)
+					if policyErr := policyError(err); policyErr != nil {
+						typeErr.Set("code", string(policyErr.Code))
+					}
+					return reject(typeErr)
+				}
+				return resolve(sb.newFetchResponse(opts.url, res))
+			})
+		}()
+
+		return sb.vm.ToValue(promise)
+	})
+
 	// 便捷方法
 	sb.vm.Set("httpGet", func(url string) goja.Value {
 		httpRequestVal := sb.vm.Get("httpRequest")
 		if callable, ok := goja.AssertFunction(httpRequestVal); ok {
-			result, _ := callable(goja.Undefined(), sb.vm.ToValue(url), sb.vm.ToValue(map[string]interface{}{
+			result, err := callable(goja.Undefined(), sb.vm.ToValue(url), sb.vm.ToValue(map[string]interface{}{
 				"method": "GET",
 			}))
+			if err != nil {
+				// httpRequest 抛出的异常（ErrorModeThrow 下的请求错误、执行中断）继续向上传播
+				panic(err)
+			}
 			return result
 		}
 		return sb.vm.ToValue(map[string]interface{}{
@@ -122,45 +245,85 @@ func (sb *Sandbox) registerHTTP() {
 			})
 		}
 		url := call.Arguments[0].String()
-		body := ""
+		var body goja.Value = sb.vm.ToValue("")
+		contentType := "application/json"
 		if len(call.Arguments) > 1 {
-			body = call.Arguments[1].String()
+			body = call.Arguments[1]
+			if sb.isBinary(body) {
+				contentType = "application/octet-stream"
+			} else {
+				body = sb.vm.ToValue(body.String())
+			}
 		}
 		httpRequestVal := sb.vm.Get("httpRequest")
 		if callable, ok := goja.AssertFunction(httpRequestVal); ok {
-			result, _ := callable(goja.Undefined(), sb.vm.ToValue(url), sb.vm.ToValue(map[string]interface{}{
+			result, err := callable(goja.Undefined(), sb.vm.ToValue(url), sb.vm.ToValue(map[string]interface{}{
 				"method": "POST",
 				"body":   body,
 				"headers": map[string]string{
-					"Content-Type": "application/json",
+					"Content-Type": contentType,
 				},
 			}))
+			if err != nil {
+				panic(err)
+			}
 			return result
 		}
 		return sb.vm.ToValue(map[string]interface{}{
 			"error": "httpRequest 不是一个函数",
 		})
 	})
+}
+
+// newFetchResponse 构建 fetch 返回的 Response 对象
+// text()/json()/arrayBuffer()/bytes() 与标准一致返回 Promise
+func (sb *Sandbox) newFetchResponse(url string, res *httpResponse) *goja.Object {
+	resolved := func(v interface{}) goja.Value {
+		promise, resolve, _ := sb.vm.NewPromise()
+		resolve(v)
+		return sb.vm.ToValue(promise)
+	}
+
+	headersObj := sb.vm.NewObject()
+	headersObj.Set("get", func(name string) goja.Value {
+		if values, ok := res.header[http.CanonicalHeaderKey(name)]; ok && len(values) > 0 {
+			return sb.vm.ToValue(strings.Join(values, ", "))
+		}
+		return goja.Null()
+	})
+	headersObj.Set("has", func(name string) bool {
+		_, ok := res.header[http.CanonicalHeaderKey(name)]
+		return ok
+	})
 
-	// 添加 fetch polyfill (同步版本)
-	// 注意：虽然标准 fetch 是异步的，但在本沙盒中为了简单和避免 SyntaxError (await)，
-	// 我们提供一个同步版本。
-	sb.vm.RunString(`
-		function fetch(url, options) {
-			const res = httpRequest(url, options);
-			if (res.error) {
-				throw new Error(res.error);
+	respObj := sb.vm.NewObject()
+	respObj.Set("ok", res.status >= 200 && res.status < 300)
+	respObj.Set("status", res.status)
+	respObj.Set("statusText", res.statusText)
+	respObj.Set("url", url)
+	respObj.Set("headers", headersObj)
+	respObj.Set("text", func() goja.Value {
+		return resolved(string(res.body))
+	})
+	respObj.Set("arrayBuffer", func() goja.Value {
+		return resolved(sb.vm.NewArrayBuffer(append([]byte{}, res.body...)))
+	})
+	respObj.Set("bytes", func() goja.Value {
+		return resolved(sb.newBuffer(append([]byte{}, res.body...)))
+	})
+	respObj.Set("json", func() goja.Value {
+		promise, resolve, reject := sb.vm.NewPromise()
+		parse, _ := goja.AssertFunction(sb.vm.Get("JSON").ToObject(sb.vm).Get("parse"))
+		if v, err := parse(goja.Undefined(), sb.vm.ToValue(string(res.body))); err != nil {
+			if ex, ok := err.(*goja.Exception); ok {
+				reject(ex.Value())
+			} else {
+				panic(err)
 			}
-			return {
-				ok: res.status >= 200 && res.status < 300,
-				status: res.status,
-				statusText: res.statusText,
-				headers: {
-					get: (name) => res.headers[name] || res.headers[name.toLowerCase()]
-				},
-				text: () => res.body,
-				json: () => JSON.parse(res.body)
-			};
+		} else {
+			resolve(v)
 		}
-	`)
+		return sb.vm.ToValue(promise)
+	})
+	return respObj
 }
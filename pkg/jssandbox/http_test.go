@@ -19,7 +19,7 @@ func TestHTTPRequest_GET(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -63,7 +63,7 @@ func TestHTTPRequest_POST(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -96,7 +96,7 @@ func TestHTTPRequest_Headers(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -132,7 +132,7 @@ func TestHTTPRequest_Timeout(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -163,6 +163,92 @@ func TestHTTPRequest_Timeout(t *testing.T) {
 	}
 }
 
+func TestHTTPRequest_AbortedByRunTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx := context.Background()
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
+	defer sb.Close()
+
+	code := `httpRequest("` + server.URL + `", { timeout: 30 });`
+
+	start := time.Now()
+	_, err := sb.RunWithTimeout(code, 200*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("RunWithTimeout()应该返回超时错误")
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("进行中的HTTP请求未被中止, 耗时 %v", elapsed)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message": "hello"}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
+	defer sb.Close()
+
+	code := `
+		(async function() {
+			const res = await fetch("` + server.URL + `");
+			const data = await res.json();
+			return { ok: res.ok, status: res.status, type: res.headers.get("content-type"), message: data.message };
+		})();
+	`
+
+	result, err := sb.RunWithTimeout(code, 5*time.Second)
+	if err != nil {
+		t.Fatalf("fetch() error = %v", err)
+	}
+
+	obj := result.ToObject(sb.vm)
+	if !obj.Get("ok").ToBoolean() || obj.Get("status").ToInteger() != 200 {
+		t.Errorf("fetch()响应状态不正确: %v", result.Export())
+	}
+	if obj.Get("type").String() != "application/json" {
+		t.Errorf("headers.get()结果不正确, got %s", obj.Get("type").String())
+	}
+	if obj.Get("message").String() != "hello" {
+		t.Errorf("json()结果不正确, got %s", obj.Get("message").String())
+	}
+}
+
+func TestFetch_NetworkError(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
+	defer sb.Close()
+
+	code := `
+		fetch("http://127.0.0.1:1").then(
+			function() { return "resolved"; },
+			function(e) { return e instanceof TypeError ? "rejected" : "wrong error"; }
+		);
+	`
+
+	result, err := sb.RunWithTimeout(code, 5*time.Second)
+	if err != nil {
+		t.Fatalf("fetch() error = %v", err)
+	}
+	if result.String() != "rejected" {
+		t.Errorf("网络错误时fetch()应该被拒绝, got %s", result.String())
+	}
+}
+
 func TestHTTPGet(t *testing.T) {
 	// 创建测试服务器
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -172,7 +258,7 @@ func TestHTTPGet(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -206,7 +292,7 @@ func TestHTTPPost(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -228,7 +314,7 @@ func TestHTTPPost(t *testing.T) {
 
 func TestHTTPRequest_ErrorHandling(t *testing.T) {
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	t.Run("缺少URL参数", func(t *testing.T) {
@@ -273,7 +359,7 @@ func TestHTTPRequest_ResponseHeaders(t *testing.T) {
 	defer server.Close()
 
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
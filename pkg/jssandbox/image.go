@@ -1,8 +1,11 @@
 package jssandbox
 
 import (
+	"bytes"
+	"fmt"
 	"image"
 	"path/filepath"
+	"strings"
 
 	"github.com/disintegration/imaging"
 	"github.com/dop251/goja"
@@ -18,8 +21,6 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		inputPath := call.Arguments[0].String()
-		outputPath := call.Arguments[1].String()
 		width := int(call.Arguments[2].ToInteger())
 		height := 0
 
@@ -27,13 +28,15 @@ func (sb *Sandbox) registerImageProcessing() {
 			height = int(call.Arguments[3].ToInteger())
 		}
 
-		img, err := imaging.Open(inputPath)
+		source, target, err := sb.resolveImageArgs(call.Arguments[0], call.Arguments[1])
 		if err != nil {
-			sb.logger.WithError(err).WithField("path", inputPath).Error("打开图片失败")
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		img, err := sb.openImage(source)
+		if err != nil {
+			sb.logger.WithError(err).WithField("path", call.Arguments[0].String()).Error("打开图片失败")
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		var resized image.Image
@@ -43,19 +46,13 @@ func (sb *Sandbox) registerImageProcessing() {
 			resized = imaging.Resize(img, width, 0, imaging.Lanczos)
 		}
 
-		err = imaging.Save(resized, outputPath)
+		data, err := sb.saveImage("imageResize", resized, target)
 		if err != nil {
-			sb.logger.WithError(err).WithField("path", outputPath).Error("保存图片失败")
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			sb.logger.WithError(err).WithField("path", call.Arguments[1].String()).Error("保存图片失败")
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
-		return sb.vm.ToValue(map[string]interface{}{
-			"success": true,
-			"path":    outputPath,
-		})
+		return sb.imageResult(target, data)
 	})
 
 	// 裁剪图片
@@ -66,34 +63,28 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		inputPath := call.Arguments[0].String()
-		outputPath := call.Arguments[1].String()
 		x := int(call.Arguments[2].ToInteger())
 		y := int(call.Arguments[3].ToInteger())
 		width := int(call.Arguments[4].ToInteger())
 		height := int(call.Arguments[5].ToInteger())
 
-		img, err := imaging.Open(inputPath)
+		source, target, err := sb.resolveImageArgs(call.Arguments[0], call.Arguments[1])
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		img, err := sb.openImage(source)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		cropped := imaging.Crop(img, image.Rect(x, y, x+width, y+height))
-		err = imaging.Save(cropped, outputPath)
+		data, err := sb.saveImage("imageCrop", cropped, target)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
-		return sb.vm.ToValue(map[string]interface{}{
-			"success": true,
-			"path":    outputPath,
-		})
+		return sb.imageResult(target, data)
 	})
 
 	// 旋转图片
@@ -104,31 +95,25 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		inputPath := call.Arguments[0].String()
-		outputPath := call.Arguments[1].String()
 		angle := call.Arguments[2].ToFloat()
 
-		img, err := imaging.Open(inputPath)
+		source, target, err := sb.resolveImageArgs(call.Arguments[0], call.Arguments[1])
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		img, err := sb.openImage(source)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		rotated := imaging.Rotate(img, angle, nil)
-		err = imaging.Save(rotated, outputPath)
+		data, err := sb.saveImage("imageRotate", rotated, target)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
-		return sb.vm.ToValue(map[string]interface{}{
-			"success": true,
-			"path":    outputPath,
-		})
+		return sb.imageResult(target, data)
 	})
 
 	// 翻转图片
@@ -139,16 +124,16 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		inputPath := call.Arguments[0].String()
-		outputPath := call.Arguments[1].String()
 		direction := call.Arguments[2].String()
 
-		img, err := imaging.Open(inputPath)
+		source, target, err := sb.resolveImageArgs(call.Arguments[0], call.Arguments[1])
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		img, err := sb.openImage(source)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
 		var flipped image.Image
@@ -163,29 +148,42 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		err = imaging.Save(flipped, outputPath)
+		data, err := sb.saveImage("imageFlip", flipped, target)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
-		return sb.vm.ToValue(map[string]interface{}{
-			"success": true,
-			"path":    outputPath,
-		})
+		return sb.imageResult(target, data)
 	})
 
-	// 获取图片信息
-	sb.vm.Set("imageInfo", func(filePath string) goja.Value {
-		img, err := imaging.Open(filePath)
-		if err != nil {
+	// 获取图片信息，参数可以是文件路径或二进制数据
+	sb.vm.Set("imageInfo", func(input goja.Value) goja.Value {
+		if data, ok := sb.exportBytes(input); ok {
+			config, format, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				return sb.vm.ToValue(sb.errorResult(err))
+			}
 			return sb.vm.ToValue(map[string]interface{}{
-				"error": err.Error(),
+				"width":  config.Width,
+				"height": config.Height,
+				"format": format,
 			})
 		}
 
+		filePath := input.String()
+		hostPath, err := sb.resolvePath(filePath, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(hostPath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		img, err := sb.openImage(imageSource{hostPath: hostPath})
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
 		bounds := img.Bounds()
 		width := bounds.Dx()
 		height := bounds.Dy()
@@ -205,29 +203,22 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		inputPath := call.Arguments[0].String()
-		outputPath := call.Arguments[1].String()
+		source, target, err := sb.resolveImageArgs(call.Arguments[0], call.Arguments[1])
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 
-		img, err := imaging.Open(inputPath)
+		img, err := sb.openImage(source)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
-		err = imaging.Save(img, outputPath)
+		data, err := sb.saveImage("imageConvert", img, target)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
-		return sb.vm.ToValue(map[string]interface{}{
-			"success": true,
-			"path":    outputPath,
-		})
+		return sb.imageResult(target, data)
 	})
 
 	// 调整图片质量（JPEG）
@@ -238,8 +229,6 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		inputPath := call.Arguments[0].String()
-		outputPath := call.Arguments[1].String()
 		quality := int(call.Arguments[2].ToInteger())
 
 		if quality < 1 || quality > 100 {
@@ -249,34 +238,136 @@ func (sb *Sandbox) registerImageProcessing() {
 			})
 		}
 
-		img, err := imaging.Open(inputPath)
+		source, target, err := sb.resolveImageArgs(call.Arguments[0], call.Arguments[1])
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
-		// 根据输出文件扩展名选择编码选项
-		ext := filepath.Ext(outputPath)
-		var err2 error
-		if ext == ".jpg" || ext == ".jpeg" {
-			err2 = imaging.Save(img, outputPath, imaging.JPEGQuality(quality))
+		img, err := sb.openImage(source)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
+		// 根据输出格式选择编码选项
+		var data []byte
+		if target.format == imaging.JPEG {
+			data, err = sb.saveImage("imageQuality", img, target, imaging.JPEGQuality(quality))
 		} else {
-			err2 = imaging.Save(img, outputPath)
+			data, err = sb.saveImage("imageQuality", img, target)
 		}
 
-		if err2 != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err2.Error(),
-			})
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 
+		return sb.imageResult(target, data)
+	})
+}
+
+// imageSource 图片处理的输入：沙盒文件系统中的文件或脚本传入的二进制数据
+type imageSource struct {
+	hostPath string
+	data     []byte
+}
+
+// imageTarget 图片处理的输出：写入文件，或按 format 编码后以 Buffer 返回
+type imageTarget struct {
+	path     string // 脚本中的输出路径
+	hostPath string
+	format   imaging.Format
+	binary   bool
+}
+
+// resolveImageArgs 解析图片处理的输入和输出参数，并校验是否符合文件策略
+// 输入可以是文件路径或二进制数据；输出可以是文件路径，或 {format: 'png'} 这样的选项对象，此时以 Buffer 返回结果
+func (sb *Sandbox) resolveImageArgs(input, output goja.Value) (imageSource, *imageTarget, error) {
+	var source imageSource
+	if data, ok := sb.exportBytes(input); ok {
+		source.data = data
+	} else {
+		hostInput, err := sb.resolvePath(input.String(), fsRead)
+		if err != nil {
+			return source, nil, err
+		}
+		if err := sb.checkFileRead(hostInput); err != nil {
+			return source, nil, err
+		}
+		source.hostPath = hostInput
+	}
+
+	if obj, ok := output.(*goja.Object); ok {
+		name := "png"
+		if f := obj.Get("format"); f != nil && !goja.IsUndefined(f) && !goja.IsNull(f) {
+			name = f.String()
+		}
+		format, err := imaging.FormatFromExtension(name)
+		if err != nil {
+			return source, nil, fmt.Errorf("不支持的图片格式: %s", name)
+		}
+		return source, &imageTarget{format: format, binary: true}, nil
+	}
+
+	outputPath := output.String()
+	hostOutput, err := sb.resolvePath(outputPath, fsWrite)
+	if err != nil {
+		return source, nil, err
+	}
+	if err := sb.checkOutputName(hostOutput); err != nil {
+		return source, nil, err
+	}
+	format, err := imaging.FormatFromFilename(hostOutput)
+	if err != nil {
+		return source, nil, err
+	}
+	return source, &imageTarget{path: outputPath, hostPath: hostOutput, format: format}, nil
+}
+
+// openImage 解码输入的图片，文件通过沙盒文件系统读取
+func (sb *Sandbox) openImage(source imageSource) (image.Image, error) {
+	if source.data != nil {
+		return imaging.Decode(bytes.NewReader(source.data))
+	}
+	file, err := sb.fs.Open(source.hostPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return imaging.Decode(file)
+}
+
+// saveImage 按输出格式编码图片并返回编码后的数据，输出为文件时写入沙盒文件系统并记录审计，op 为宿主函数名
+func (sb *Sandbox) saveImage(op string, img image.Image, target *imageTarget, opts ...imaging.EncodeOption) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, target.format, opts...); err != nil {
+		return nil, err
+	}
+	if target.binary {
+		return buf.Bytes(), nil
+	}
+	err := sb.writeCheckedFile(target.hostPath, buf.Bytes())
+	sb.audit(sb.runContext(), AuditEvent{Module: "image", Operation: op, Target: target.path, BytesOut: int64(buf.Len())}, err)
+	return buf.Bytes(), err
+}
+
+// imageResult 返回图片处理的结果：输出为文件时返回路径，否则以 Buffer 返回编码后的图片
+func (sb *Sandbox) imageResult(target *imageTarget, data []byte) goja.Value {
+	if !target.binary {
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
-			"path":    outputPath,
+			"path":    target.path,
 		})
+	}
+
+	if err := sb.checkFileSize("图片数据", int64(len(data))); err != nil {
+		return sb.vm.ToValue(sb.errorResult(err))
+	}
+	if err := sb.checkDataType("图片数据", data); err != nil {
+		return sb.vm.ToValue(sb.errorResult(err))
+	}
+	return sb.vm.ToValue(map[string]interface{}{
+		"success": true,
+		"data":    sb.newBuffer(data),
+		"format":  strings.ToLower(target.format.String()),
 	})
 }
 
@@ -0,0 +1,169 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dop251/goja"
+)
+
+// HostCall 一次宿主函数调用，拦截器可以查看和修改其中的参数
+type HostCall struct {
+	// Module 函数所属的内置模块（如 "fs"、"http"、"browser"）或扩展的命名空间
+	Module string
+	// Name 函数在脚本中的名称，对象的方法带对象名，如 "readFile"、"console.log"、"math.add"；
+	// 宿主函数返回的对象（如浏览器会话）的方法以返回它的函数名为前缀，如 "createBrowserSession.navigate"
+	Name string
+	// This 调用时的 this
+	This goja.Value
+	// Args 调用参数，拦截器修改后传给后续的拦截器和宿主函数
+	Args []goja.Value
+	// Context 当前执行的上下文，超时或取消时结束
+	Context context.Context
+
+	sb *Sandbox
+}
+
+// ToValue 把 Go 值转换为沙盒运行时中的 JavaScript 值，用于改写参数或直接返回结果
+func (c *HostCall) ToValue(v interface{}) goja.Value {
+	return c.sb.vm.ToValue(v)
+}
+
+// Sandbox 返回发起调用的沙盒
+func (c *HostCall) Sandbox() *Sandbox {
+	return c.sb
+}
+
+// HostInvoker 调用链中的下一个拦截器，最后一个拦截器的 next 调用宿主函数本身
+type HostInvoker func(call *HostCall) (goja.Value, error)
+
+// HostInterceptor 宿主函数拦截器，所有内置宿主函数和扩展函数的调用都会经过 Config.Interceptors 中的拦截器，
+// 先配置的拦截器在外层。拦截器可以在调用 next 前后执行逻辑（审计、计时、配额），修改参数或结果，
+// 也可以不调用 next 直接返回（模拟、拒绝调用）
+//
+// next 返回的错误：宿主函数抛出的异常、执行中断，以及以 { success: false, error } 结果对象表示的失败，
+// 此时同时返回该结果对象。拦截器返回错误且结果为 nil 时，按 Config.ErrorMode 报告给脚本
+// （返回 { success: false, error, code } 或抛出 SandboxError）；结果不为 nil 时直接返回结果。
+// 内置宿主函数的结果是脚本看到的值（通常为 { success, data } 结果对象），扩展函数的结果是 Go 函数的返回值。
+// 构造函数（如 URL、Buffer）和脚本定义的函数不经过拦截器
+type HostInterceptor interface {
+	Intercept(call *HostCall, next HostInvoker) (goja.Value, error)
+}
+
+// HostInterceptorFunc 把函数作为 HostInterceptor 使用
+type HostInterceptorFunc func(call *HostCall, next HostInvoker) (goja.Value, error)
+
+// Intercept 实现 HostInterceptor 接口
+func (f HostInterceptorFunc) Intercept(call *HostCall, next HostInvoker) (goja.Value, error) {
+	return f(call, next)
+}
+
+// intercepting 是否配置了拦截器或指标，都未配置时宿主函数不做包装
+func (sb *Sandbox) intercepting() bool {
+	return len(sb.interceptors) > 0
+}
+
+// newHostCall 为 call 创建 HostCall
+func (sb *Sandbox) newHostCall(module, name string, call goja.FunctionCall) *HostCall {
+	return &HostCall{Module: module, Name: name, This: call.This, Args: call.Arguments, Context: sb.runContext(), sb: sb}
+}
+
+// invokeHost 从第 i 个拦截器开始调用拦截器链，最后调用 last
+func (sb *Sandbox) invokeHost(call *HostCall, i int, last HostInvoker) (goja.Value, error) {
+	if i >= len(sb.interceptors) {
+		return last(call)
+	}
+	return sb.interceptors[i].Intercept(call, func(call *HostCall) (goja.Value, error) {
+		return sb.invokeHost(call, i+1, last)
+	})
+}
+
+// rethrow 脚本异常和执行中断需要原样抛出，不能转换为结果对象
+func rethrow(err error) {
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		panic(ex)
+	}
+	if isUncatchable(err) {
+		panic(err)
+	}
+}
+
+// intercept 让内置模块 module 注册的全局函数或对象中的函数经过拦截器，包装方式与 throwOnError 相同
+func (sb *Sandbox) intercept(module, name string, v goja.Value) goja.Value {
+	if _, ok := goja.AssertConstructor(v); ok {
+		return v
+	}
+	if fn, ok := goja.AssertFunction(v); ok {
+		return sb.interceptedFunc(module, name, fn)
+	}
+	if obj, ok := v.(*goja.Object); ok && obj.ExportType() == plainObjectType {
+		sb.interceptMethods(module, name, obj)
+	}
+	return v
+}
+
+// interceptedFunc 包装宿主函数，调用时经过拦截器链；返回对象中的方法同样被包装
+func (sb *Sandbox) interceptedFunc(module, name string, fn goja.Callable) goja.Value {
+	code, ok := moduleErrorCodes[module]
+	if !ok {
+		code = ErrCodeUnknown
+	}
+	invoke := func(call *HostCall) (goja.Value, error) {
+		result, err := fn(call.This, call.Args...)
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := result.(*goja.Object)
+		if !ok || obj.ExportType() != plainObjectType {
+			return result, nil
+		}
+		sb.interceptMethods(module, name, obj)
+		return result, sb.resultObjectError(code, obj)
+	}
+	wrapped := sb.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		result, err := sb.invokeHost(sb.newHostCall(module, name, call), 0, invoke)
+		if err != nil && result == nil {
+			rethrow(err)
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		return result
+	}).ToObject(sb.vm)
+	wrapped.DefineDataPropertySymbol(sb.interceptMark, sb.vm.ToValue(true), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return wrapped
+}
+
+// interceptMethods 包装对象中尚未包装的函数属性，名称为 prefix.属性名
+func (sb *Sandbox) interceptMethods(module, prefix string, obj *goja.Object) {
+	for _, key := range obj.Keys() {
+		method, ok := obj.Get(key).(*goja.Object)
+		if !ok || method.GetSymbol(sb.interceptMark) != nil {
+			continue
+		}
+		if _, ok := goja.AssertConstructor(method); ok {
+			continue
+		}
+		if fn, ok := goja.AssertFunction(method); ok {
+			obj.Set(key, sb.interceptedFunc(module, prefix+"."+key, fn))
+		}
+	}
+}
+
+// resultObjectError 若 obj 是 { error } 形式的失败结果，返回对应的 SandboxError，否则返回 nil
+// 结果中的 code 优先于模块的默认错误码；errorResult 生成的结果带有原始错误作为原因，见 resultCause
+func (sb *Sandbox) resultObjectError(code ErrorCode, obj *goja.Object) error {
+	errVal := obj.Get("error")
+	if errVal == nil || !errVal.ToBoolean() {
+		return nil
+	}
+	if success := obj.Get("success"); success != nil && success.ToBoolean() {
+		return nil
+	}
+	if c := obj.Get("code"); c != nil && !goja.IsUndefined(c) && !goja.IsNull(c) {
+		code = ErrorCode(c.String())
+	}
+	if cause := sb.resultCause(obj); cause != nil {
+		return NewSandboxErrorWithCause(code, errVal.String(), cause)
+	}
+	return NewSandboxError(code, errVal.String())
+}
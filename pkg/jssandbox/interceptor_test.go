@@ -0,0 +1,177 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func mathExtension() *Extension {
+	return NewExtension("math", "数学").
+		Func("add", func(a, b int) int { return a + b }, "加法", "a", "b").
+		Func("fail", func() error { return errors.New("bad") }, "总是失败")
+}
+
+func TestInterceptor_Record(t *testing.T) {
+	type record struct {
+		module, name string
+		args         int
+		err          string
+		hasResult    bool
+	}
+	var calls []record
+	recorder := HostInterceptorFunc(func(call *HostCall, next HostInvoker) (goja.Value, error) {
+		result, err := next(call)
+		r := record{module: call.Module, name: call.Name, args: len(call.Args), hasResult: result != nil}
+		if err != nil {
+			r.err = err.Error()
+		}
+		calls = append(calls, r)
+		return result, err
+	})
+
+	for _, mode := range []ErrorMode{ErrorModeResult, ErrorModeThrow} {
+		t.Run(string(mode), func(t *testing.T) {
+			calls = nil
+			config := DefaultConfig().WithErrorMode(mode).WithExtension(mathExtension()).WithInterceptor(recorder)
+			sb := NewSandboxWithConfig(context.Background(), config)
+			defer sb.Close()
+
+			v, err := sb.Run(`
+				var out = [require('encoding').encodeBase64('hi') !== undefined];
+				console.log('x');
+				var added = math.add(1, 2);
+				out.push(typeof added === 'object' ? [added.success, added.data] : added);
+				try { out.push(readFile('/no/such/file.txt').success) } catch (e) { out.push(e.name) }
+				try { out.push(math.fail().success) } catch (e) { out.push(e.message) }
+				JSON.stringify(out)
+			`)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			want := `[true,[true,3],false,false]`
+			if mode == ErrorModeThrow {
+				want = `[true,3,"SandboxError","bad"]`
+			}
+			if v.String() != want {
+				t.Errorf("got %s, want %s", v.String(), want)
+			}
+
+			if len(calls) != 5 {
+				t.Fatalf("记录的调用 = %+v", calls)
+			}
+			expected := []record{
+				{module: "encoding", name: "encodeBase64", args: 1, hasResult: true},
+				{module: "system", name: "console.log", args: 1, hasResult: true},
+				{module: "math", name: "math.add", args: 2, hasResult: true},
+				{module: "fs", name: "readFile", args: 1, hasResult: true},
+				{module: "math", name: "math.fail", args: 0, err: "bad"},
+			}
+			for i, want := range expected {
+				got := calls[i]
+				if got.module != want.module || got.name != want.name || got.args != want.args || got.hasResult != want.hasResult {
+					t.Errorf("调用 %d = %+v, want %+v", i, got, want)
+				}
+				if i == 3 && got.err == "" {
+					t.Errorf("readFile 失败时拦截器应收到错误")
+				} else if i != 3 && got.err != want.err {
+					t.Errorf("调用 %d 的错误 = %q, want %q", i, got.err, want.err)
+				}
+			}
+		})
+	}
+}
+
+func TestInterceptor_RewriteAndShortCircuit(t *testing.T) {
+	var order []string
+	outer := HostInterceptorFunc(func(call *HostCall, next HostInvoker) (goja.Value, error) {
+		order = append(order, "outer:"+call.Name)
+		return next(call)
+	})
+	inner := HostInterceptorFunc(func(call *HostCall, next HostInvoker) (goja.Value, error) {
+		order = append(order, "inner:"+call.Name)
+		switch call.Name {
+		case "encodeBase64":
+			call.Args[0] = call.ToValue("redacted")
+		case "httpGet":
+			return call.ToValue(map[string]interface{}{"success": true, "body": "mock"}), nil
+		case "math.add":
+			return call.ToValue(100), nil
+		}
+		return next(call)
+	})
+	config := DefaultConfig().WithExtension(mathExtension()).WithInterceptor(outer, inner)
+	sb := NewSandboxWithConfig(context.Background(), config)
+	defer sb.Close()
+
+	v, err := sb.Run(`[decodeBase64(encodeBase64('secret').data).data, httpGet('http://example.invalid/').body, math.add(1, 2).data].join('|')`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "redacted|mock|100"; v.String() != want {
+		t.Errorf("got %q, want %q", v.String(), want)
+	}
+	if got := strings.Join(order[:2], ","); got != "outer:encodeBase64,inner:encodeBase64" {
+		t.Errorf("拦截器顺序 = %v", order)
+	}
+}
+
+func TestInterceptor_Deny(t *testing.T) {
+	deny := HostInterceptorFunc(func(call *HostCall, next HostInvoker) (goja.Value, error) {
+		if call.Module == "encoding" || call.Module == "math" {
+			return nil, NewSandboxError(ErrCodePermissionDenied, "超出配额: "+call.Name)
+		}
+		return next(call)
+	})
+	tests := []struct {
+		mode ErrorMode
+		code string
+		want string
+	}{
+		{ErrorModeResult, `var r = encodeBase64('a'); var m = math.add(1, 2); [r.success, r.code, r.error, m.code].join('|')`,
+			"false|PERMISSION_DENIED|[PERMISSION_DENIED] 超出配额: encodeBase64|PERMISSION_DENIED"},
+		{ErrorModeThrow, `var out = []; try { encodeBase64('a') } catch (e) { out.push(e.name, e.code) }
+			try { math.add(1, 2) } catch (e) { out.push(e.code) } out.push(hashSHA256('a').hash.length); out.join('|')`,
+			"SandboxError|PERMISSION_DENIED|PERMISSION_DENIED|64"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			config := DefaultConfig().WithErrorMode(tt.mode).WithExtension(mathExtension()).WithInterceptor(deny)
+			sb := NewSandboxWithConfig(context.Background(), config)
+			defer sb.Close()
+			v, err := sb.Run(tt.code)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if v.String() != tt.want {
+				t.Errorf("got %q, want %q", v.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptor_Interrupt(t *testing.T) {
+	var sawErr error
+	config := DefaultConfig().WithInterceptor(HostInterceptorFunc(func(call *HostCall, next HostInvoker) (goja.Value, error) {
+		result, err := next(call)
+		if call.Name == "sleep" {
+			sawErr = call.Context.Err()
+		}
+		return result, err
+	}))
+	sb := NewSandboxWithConfig(context.Background(), config)
+	defer sb.Close()
+
+	_, err := sb.RunWithTimeout(`sleep(5000)`, 100*time.Millisecond)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsTimeout() {
+		t.Fatalf("RunWithTimeout() error = %v, want timeout", err)
+	}
+	if sawErr == nil {
+		t.Error("拦截器中的 Context 应在超时后结束")
+	}
+}
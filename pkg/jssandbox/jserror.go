@@ -0,0 +1,332 @@
+package jssandbox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+// snippetContext 源码片段中出错行前后各显示的行数
+const snippetContext = 2
+
+// ScriptException 语法错误或脚本中未捕获的异常的详细信息，用于定位和修复出错的代码
+type ScriptException struct {
+	// Name 异常类型，如 TypeError、SyntaxError、SandboxError；抛出的不是 Error 对象时为空
+	Name string
+	// Message 异常信息，抛出的不是 Error 对象时为该值的字符串形式
+	Message string
+	// Code 异常对象的 code 属性（如宿主函数抛出的 FILE_NOT_FOUND），没有时为空
+	Code string
+	// File、Line、Column 出错位置，行号和列号从 1 开始；优先取调用栈中位于执行代码本身的最内层位置
+	// File 为空表示直接执行的代码（Run 等），未知时 Line 为 0
+	File   string
+	Line   int
+	Column int
+	// Stack 调用栈，第一帧为最内层的调用，语法错误没有调用栈
+	Stack []StackFrame
+	// Snippet 出错位置附近的源码，带行号，出错行以 ">" 标记；出错位置不在执行代码中时为空
+	Snippet string
+
+	// main 执行代码的文件名，位于其中的位置才能截取源码片段
+	main string
+}
+
+// StackFrame 调用栈中的一帧
+type StackFrame struct {
+	// Function 函数名，顶层代码和匿名函数为空
+	Function string
+	// File 文件名，直接执行的代码为空
+	File   string
+	Line   int
+	Column int
+	// Native 是否为宿主（Go）函数，此时没有位置信息
+	Native bool
+}
+
+// String 返回与 JavaScript 调用栈相同格式的位置，如 foo (<eval>:3:5)
+func (f StackFrame) String() string {
+	loc := "native"
+	if !f.Native {
+		file := f.File
+		if file == "" {
+			file = "<eval>"
+		}
+		loc = fmt.Sprintf("%s:%d:%d", file, f.Line, f.Column)
+	}
+	if f.Function == "" {
+		return loc
+	}
+	return f.Function + " (" + loc + ")"
+}
+
+// String 返回异常的完整描述：类型和信息、调用栈以及源码片段
+func (e *ScriptException) String() string {
+	var b strings.Builder
+	switch {
+	case e.Name == "":
+		b.WriteString(e.Message)
+	case e.Message == "":
+		b.WriteString(e.Name)
+	default:
+		b.WriteString(e.Name + ": " + e.Message)
+	}
+	if e.Code != "" {
+		fmt.Fprintf(&b, " [%s]", e.Code)
+	}
+	if len(e.Stack) == 0 && e.Line > 0 {
+		fmt.Fprintf(&b, "\n    at %s", StackFrame{File: e.File, Line: e.Line, Column: e.Column})
+	}
+	for _, f := range e.Stack {
+		fmt.Fprintf(&b, "\n    at %s", f)
+	}
+	if e.Snippet != "" {
+		b.WriteString("\n\n" + e.Snippet)
+	}
+	return b.String()
+}
+
+// Adjust 把执行代码中的位置换算为调用方原始代码 source 中的位置，并重新生成源码片段
+// 用于调用方在原始代码前添加了 lineOffset 行包装代码（如 "(async function(){\n"）后再执行的情况，
+// 位于包装代码中的调用栈帧会被去掉
+func (e *ScriptException) Adjust(source string, lineOffset int) {
+	lines := strings.Split(source, "\n")
+	stack := e.Stack[:0]
+	for _, f := range e.Stack {
+		if !f.Native && sameFile(f.File, e.main) {
+			f.Line -= lineOffset
+			if f.Line < 1 || f.Line > len(lines) {
+				continue
+			}
+		}
+		stack = append(stack, f)
+	}
+	e.Stack = stack
+	if e.Line > 0 && sameFile(e.File, e.main) {
+		e.Line -= lineOffset
+		switch {
+		case e.Line < 1:
+			e.Line, e.Column = 1, 1
+		case e.Line > len(lines):
+			// 错误位于末尾的包装代码中（如缺少右括号），指向原始代码的末尾
+			e.Line = len(lines)
+			e.Column = len([]rune(lines[e.Line-1])) + 1
+		}
+	}
+	e.Snippet = e.snippet(source)
+}
+
+// locate 从调用栈中确定出错位置并截取源码片段
+func (e *ScriptException) locate(source string) {
+	var loc *StackFrame
+	for i := range e.Stack {
+		f := &e.Stack[i]
+		if f.Native {
+			continue
+		}
+		if loc == nil {
+			loc = f
+		}
+		if sameFile(f.File, e.main) {
+			loc = f
+			break
+		}
+	}
+	if loc != nil {
+		e.File, e.Line, e.Column = loc.File, loc.Line, loc.Column
+	}
+	e.Snippet = e.snippet(source)
+}
+
+// snippet 截取执行代码中出错行附近的源码
+func (e *ScriptException) snippet(source string) string {
+	if e.Line <= 0 || !sameFile(e.File, e.main) {
+		return ""
+	}
+	return formatSnippet(strings.Split(source, "\n"), 1, e.Line, e.Column)
+}
+
+// formatSnippet 截取第 line 行前后的源码，出错行下方用 "^" 标出列位置；lines 为从第 first 行开始的源码
+func formatSnippet(lines []string, first, line, column int) string {
+	if line < first || line >= first+len(lines) {
+		return ""
+	}
+	from := max(line-snippetContext, first)
+	to := min(line+snippetContext, first+len(lines)-1)
+	width := len(strconv.Itoa(to))
+
+	var b strings.Builder
+	for n := from; n <= to; n++ {
+		text := strings.TrimRight(lines[n-first], "\r")
+		marker := "  "
+		if n == line {
+			marker = "> "
+		}
+		fmt.Fprintf(&b, "%s%*d | %s\n", marker, width, n, text)
+		if n == line && column > 0 {
+			// 制表符保持不变，使 "^" 与出错的列对齐
+			var pad strings.Builder
+			for i, r := range []rune(text) {
+				if i >= column-1 {
+					break
+				}
+				if r == '\t' {
+					pad.WriteRune('\t')
+				} else {
+					pad.WriteRune(' ')
+				}
+			}
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", pad.String())
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// sameFile 判断两个文件名是否指向同一个文件，忽略开头的 "/"（source map 中的路径可能没有）
+func sameFile(a, b string) bool {
+	return strings.TrimPrefix(a, "/") == strings.TrimPrefix(b, "/")
+}
+
+// compileSource 解析并编译脚本（非严格模式的全局代码），语法错误返回带位置和源码片段的 ErrCodeSyntaxError
+func compileSource(name, code string) (*goja.Program, *ast.Program, error) {
+	prg, err := parser.ParseFile(nil, name, code, 0)
+	if err != nil {
+		return nil, nil, syntaxError(name, code, err)
+	}
+	program, err := goja.CompileAST(prg, false)
+	if err != nil {
+		return nil, nil, syntaxError(name, code, err)
+	}
+	return program, prg, nil
+}
+
+// syntaxError 把解析或编译错误转换为 ErrCodeSyntaxError
+func syntaxError(name, code string, err error) *SandboxError {
+	exc := &ScriptException{Name: "SyntaxError", Message: err.Error(), File: name, main: name}
+	var list parser.ErrorList
+	var compileErr *goja.CompilerSyntaxError
+	switch {
+	case errors.As(err, &list) && len(list) > 0:
+		exc.Message = list[0].Message
+		exc.Line, exc.Column = list[0].Position.Line, list[0].Position.Column
+	case errors.As(err, &compileErr):
+		exc.Message = compileErr.Message
+		if compileErr.File != nil {
+			p := compileErr.File.Position(compileErr.Offset)
+			exc.Line, exc.Column = p.Line, p.Column
+		}
+	}
+	exc.Snippet = exc.snippet(code)
+	return &SandboxError{Code: ErrCodeSyntaxError, Message: "JavaScript代码语法错误", Cause: err, Exception: exc}
+}
+
+// scriptError 把执行代码时未捕获的 JavaScript 异常和被拒绝的顶层 Promise 转换为 ErrCodeScriptError，
+// 其他错误（语法错误、超时、超出资源限制等）原样返回；main 和 source 为执行代码的文件名和源码，用于定位出错位置
+func (sb *Sandbox) scriptError(err error, main, source string) error {
+	if _, ok := err.(*SandboxError); ok || err == nil || isUncatchable(err) {
+		return err
+	}
+	var exc *ScriptException
+	var ex *goja.Exception
+	var rejected *PromiseRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		exc = sb.valueException(rejected.Reason, nil)
+	case errors.As(err, &ex):
+		exc = sb.valueException(ex.Value(), ex.Stack())
+	default:
+		return err
+	}
+	exc.main = main
+	exc.locate(source)
+	return &SandboxError{Code: ErrCodeScriptError, Message: "执行JavaScript代码失败", Cause: err, Exception: exc}
+}
+
+// valueException 从抛出的值中提取异常信息，frames 为 nil 时从 Error 对象的 stack 属性解析调用栈
+func (sb *Sandbox) valueException(v goja.Value, frames []goja.StackFrame) *ScriptException {
+	exc := &ScriptException{}
+	if v == nil {
+		v = goja.Undefined()
+	}
+	exc.Message = v.String()
+
+	stack := ""
+	if obj, ok := v.(*goja.Object); ok {
+		if message := sb.safeGet(obj, "message"); message != "" || sb.safeGet(obj, "stack") != "" {
+			exc.Name = sb.safeGet(obj, "name")
+			exc.Message = message
+		}
+		exc.Code = sb.safeGet(obj, "code")
+		stack = sb.safeGet(obj, "stack")
+	}
+	if frames != nil {
+		var buf bytes.Buffer
+		for _, f := range frames {
+			buf.WriteString("\tat ")
+			f.Write(&buf)
+			buf.WriteByte('\n')
+		}
+		stack = buf.String()
+	}
+	exc.Stack = parseStack(stack)
+	return exc
+}
+
+// safeGet 读取对象属性的字符串形式，属性不存在或读取时抛出异常（如 getter 出错）时返回空字符串
+func (sb *Sandbox) safeGet(obj *goja.Object, name string) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = ""
+		}
+	}()
+	v := obj.Get(name)
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return ""
+	}
+	return v.String()
+}
+
+// parseStack 解析 goja 调用栈文本中的 "at" 行，如 "at foo (<eval>:3:5(12))"、"at <eval>:1:1(3)"、"at map (native)"
+func parseStack(stack string) []StackFrame {
+	var frames []StackFrame
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "at ") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "at ")
+		var f StackFrame
+		// 带函数名的帧位置在括号中；位置本身以 "(pc)" 结尾，因此只在 " (" 处分割
+		if i := strings.Index(line, " ("); i >= 0 && strings.HasSuffix(line, ")") {
+			f.Function, line = line[:i], line[i+2:len(line)-1]
+		}
+		if line == "native" {
+			f.Native = true
+			frames = append(frames, f)
+			continue
+		}
+		if i := strings.LastIndexByte(line, '('); i >= 0 && strings.HasSuffix(line, ")") {
+			line = line[:i]
+		}
+		colIdx := strings.LastIndexByte(line, ':')
+		if colIdx < 0 {
+			continue
+		}
+		lineIdx := strings.LastIndexByte(line[:colIdx], ':')
+		if lineIdx < 0 {
+			continue
+		}
+		f.Line, _ = strconv.Atoi(line[lineIdx+1 : colIdx])
+		f.Column, _ = strconv.Atoi(line[colIdx+1:])
+		if f.File = line[:lineIdx]; f.File == "<eval>" {
+			f.File = ""
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
@@ -0,0 +1,108 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScriptError_Runtime(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	_, err := sb.Run("function foo(o) {\n  return o.bar.baz;\n}\nfoo({});")
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsScriptError() || sbErr.Exception == nil {
+		t.Fatalf("error = %v, want %s", err, ErrCodeScriptError)
+	}
+	exc := sbErr.Exception
+	if exc.Name != "TypeError" || exc.Line != 2 || exc.Column != 16 {
+		t.Errorf("exception = %s %d:%d, want TypeError 2:16", exc.Name, exc.Line, exc.Column)
+	}
+	if len(exc.Stack) != 2 || exc.Stack[0].Function != "foo" || exc.Stack[1].Line != 4 {
+		t.Errorf("stack = %v", exc.Stack)
+	}
+	if !strings.Contains(exc.Snippet, "> 2 |   return o.bar.baz;") {
+		t.Errorf("snippet = \n%s", exc.Snippet)
+	}
+	if !strings.Contains(err.Error(), "at foo (<eval>:2:16)") {
+		t.Errorf("error = %v, 应该包含调用栈", err)
+	}
+}
+
+func TestScriptError_Syntax(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	_, err := sb.Run("var a = 1;\nvar = 2;")
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsSyntaxError() || sbErr.Exception == nil {
+		t.Fatalf("error = %v, want %s", err, ErrCodeSyntaxError)
+	}
+	if exc := sbErr.Exception; exc.Line != 2 || exc.Column != 5 || len(exc.Stack) != 0 {
+		t.Errorf("exception = %d:%d %v, want 2:5", exc.Line, exc.Column, exc.Stack)
+	}
+
+	// TypeScript 和模块的语法错误同样带位置
+	_, err = sb.RunTypeScript("const a: number = 1;\nconst b: = 2;")
+	if !errors.As(err, &sbErr) || sbErr.Exception == nil || sbErr.Exception.Line != 2 {
+		t.Errorf("error = %v, want 第 2 行的语法错误", err)
+	}
+}
+
+func TestScriptError_Values(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	tests := []struct {
+		name     string
+		code     string
+		wantName string
+		wantLine int
+		wantCode string
+		wantMsg  string
+	}{
+		{"被拒绝的 Promise", "(async function(){\nawait null;\nnull.x;\n})()", "TypeError", 3, "", "Cannot read property 'x' of undefined"},
+		{"非 Error 值", "throw 'oops'", "", 1, "", "oops"},
+		{"SandboxError", "throw new SandboxError('INVALID_INPUT', '参数错误')", "SandboxError", 1, "INVALID_INPUT", "参数错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sb.Run(tt.code)
+			var sbErr *SandboxError
+			if !errors.As(err, &sbErr) || sbErr.Exception == nil {
+				t.Fatalf("error = %v, want %s", err, ErrCodeScriptError)
+			}
+			exc := sbErr.Exception
+			if exc.Name != tt.wantName || exc.Message != tt.wantMsg || exc.Code != tt.wantCode || exc.Line != tt.wantLine {
+				t.Errorf("exception = %#v", exc)
+			}
+		})
+	}
+}
+
+func TestScriptException_Adjust(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	source := "var x = 1;\nreturn x.y.z;"
+	_, err := sb.Run("(function(){\n" + source + "\n})()")
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || sbErr.Exception == nil {
+		t.Fatalf("error = %v, want %s", err, ErrCodeScriptError)
+	}
+	exc := sbErr.Exception
+	exc.Adjust(source, 1)
+	if exc.Line != 2 || exc.Column != 12 {
+		t.Errorf("位置 = %d:%d, want 2:12", exc.Line, exc.Column)
+	}
+	for _, f := range exc.Stack {
+		if f.Line < 1 || f.Line > 2 {
+			t.Errorf("包装代码中的帧应该被去掉: %v", f)
+		}
+	}
+	if !strings.Contains(exc.Snippet, "> 2 | return x.y.z;") || strings.Contains(exc.Snippet, "function") {
+		t.Errorf("snippet = \n%s", exc.Snippet)
+	}
+}
@@ -0,0 +1,284 @@
+package jssandbox
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// memoryCheckInterval 执行期间检查内存增长的间隔
+const memoryCheckInterval = 10 * time.Millisecond
+
+// memoryGCInterval 超过限制时强制回收垃圾的最小间隔，避免整个进程频繁 GC
+const memoryGCInterval = time.Second
+
+// heapObjectsMetric 堆上对象占用的字节数（含尚未回收的垃圾），读取时不需要暂停程序
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// newResourceLimitError 创建超出资源限制的错误
+func newResourceLimitError(format string, args ...interface{}) *SandboxError {
+	return NewSandboxError(ErrCodeResourceLimit, fmt.Sprintf(format, args...))
+}
+
+// isResourceLimitError 判断是否为超出资源限制的错误
+func isResourceLimitError(err error) bool {
+	sbErr, ok := err.(*SandboxError)
+	return ok && sbErr.Code == ErrCodeResourceLimit
+}
+
+// exceedLimit 因超出资源限制终止当前执行，只能在执行 JavaScript 的 goroutine 上调用
+// 宿主函数中调用后应直接返回，虚拟机会在执行下一条指令前停止；
+// 不在 Run/RunWithTimeout 中时（如宿主直接调用 JavaScript 函数）抛出异常
+func (sb *Sandbox) exceedLimit(err *SandboxError) {
+	if sb.cancelRun == nil {
+		panic(sb.vm.NewGoError(err))
+	}
+	sb.abortRun(sb.cancelRun, err)
+}
+
+// abortRun 取消本次执行的上下文并中断虚拟机，可在任意 goroutine 调用
+func (sb *Sandbox) abortRun(cancel context.CancelCauseFunc, err *SandboxError) {
+	sb.logger.WithError(err).Warn("脚本超出资源限制，终止执行")
+	if cancel != nil {
+		cancel(err)
+	}
+	sb.vm.Interrupt(err)
+}
+
+// readHeapBytes 返回当前堆上对象占用的字节数
+func readHeapBytes() int64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(sample[0].Value.Uint64())
+}
+
+// watchMemory 在执行期间定期检查堆增长，超过 Config.MaxMemory 时终止执行
+//
+// 统计的是整个进程的堆增长，宿主在其他 goroutine 上的分配和多个沙盒同时执行都会计入，只能作为近似的限制。
+// 超过限制时先强制回收一次垃圾，回收后仍然超过才终止，避免把未回收的临时对象算进去；
+// 两次强制回收至少间隔 memoryGCInterval，期间只检查不回收。
+func (sb *Sandbox) watchMemory(done <-chan struct{}, cancel context.CancelCauseFunc) {
+	limit := sb.config.MaxMemory
+	base := readHeapBytes()
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	var lastGC time.Time
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if readHeapBytes()-base <= limit || time.Since(lastGC) < memoryGCInterval {
+			continue
+		}
+		runtime.GC()
+		lastGC = time.Now()
+		if grown := readHeapBytes() - base; grown > limit {
+			sb.abortRun(cancel, newResourceLimitError("内存增长超过限制: %d 字节 (最大允许 %d 字节)", grown, limit))
+			return
+		}
+	}
+}
+
+// registerLimits 应用 Config 中的资源限制
+// 调用栈深度由 goja 检查；字符串和数组长度在 repeat、padStart、concat、join、fill、push 等
+// 内置方法中检查，调用前按参数算出结果的长度，超过限制时不再生成结果。
+// 这些检查只是尽力而为：+ 运算符和模板字符串的拼接（如 s += s 倍增）、按下标赋值等
+// 不经过内置方法的增长无法检查，由 MaxMemory 兜底
+func (sb *Sandbox) registerLimits() {
+	if sb.config.MaxCallStackSize > 0 {
+		sb.vm.SetMaxCallStackSize(sb.config.MaxCallStackSize)
+	}
+
+	if maxLen := int64(sb.config.MaxStringLength); maxLen > 0 {
+		stringProto := sb.vm.Get("String").ToObject(sb.vm).Get("prototype").ToObject(sb.vm)
+		checkString := func(length float64) bool {
+			if length > float64(maxLen) {
+				sb.exceedLimit(newResourceLimitError("字符串长度超过限制: %.0f (最大允许 %d)", length, maxLen))
+				return false
+			}
+			return true
+		}
+		sb.guardBuiltin(stringProto, "repeat", func(call *goja.FunctionCall) bool {
+			return checkString(stringLength(call.This) * call.Argument(0).ToFloat())
+		})
+		padCheck := func(call *goja.FunctionCall) bool {
+			return checkString(call.Argument(0).ToFloat())
+		}
+		sb.guardBuiltin(stringProto, "padStart", padCheck)
+		sb.guardBuiltin(stringProto, "padEnd", padCheck)
+		// 先把 this 和参数转换为字符串再交给原方法，对象的 toString 只调用一次
+		sb.guardBuiltin(stringProto, "concat", func(call *goja.FunctionCall) bool {
+			if goja.IsUndefined(call.This) || goja.IsNull(call.This) {
+				return true
+			}
+			call.This = call.This.ToString()
+			total := stringLength(call.This)
+			for i, arg := range call.Arguments {
+				call.Arguments[i] = arg.ToString()
+				total += stringLength(call.Arguments[i])
+			}
+			return checkString(total)
+		})
+		arrayProto := sb.vm.Get("Array").ToObject(sb.vm).Get("prototype").ToObject(sb.vm)
+		sb.defineBuiltin(arrayProto, "join", 1, sb.limitedJoin(checkString))
+	}
+
+	if maxLen := int64(sb.config.MaxArrayLength); maxLen > 0 {
+		arrayCtor := sb.vm.Get("Array").ToObject(sb.vm)
+		arrayProto := arrayCtor.Get("prototype").ToObject(sb.vm)
+		checkArray := func(length int64) bool {
+			if length > maxLen {
+				sb.exceedLimit(newResourceLimitError("数组长度超过限制: %d (最大允许 %d)", length, maxLen))
+				return false
+			}
+			return true
+		}
+		sb.guardBuiltin(arrayProto, "fill", func(call *goja.FunctionCall) bool {
+			return checkArray(lengthOf(call.This))
+		})
+		sb.guardBuiltin(arrayCtor, "from", func(call *goja.FunctionCall) bool {
+			return checkArray(lengthOf(call.Argument(0)))
+		})
+		growCheck := func(call *goja.FunctionCall) bool {
+			return checkArray(lengthOf(call.This) + int64(len(call.Arguments)))
+		}
+		sb.guardBuiltin(arrayProto, "push", growCheck)
+		sb.guardBuiltin(arrayProto, "unshift", growCheck)
+		sb.guardBuiltin(arrayProto, "concat", func(call *goja.FunctionCall) bool {
+			total := spreadLength(call.This)
+			for _, arg := range call.Arguments {
+				total += spreadLength(arg)
+			}
+			return checkArray(total)
+		})
+	}
+}
+
+// limitedJoin 返回 Array.prototype.join 的替代实现，拼接过程中长度超过限制时终止执行，不会先生成超长的结果
+func (sb *Sandbox) limitedJoin(checkString func(length float64) bool) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		obj := call.This.ToObject(sb.vm)
+		length := lengthOf(obj)
+		sep := sb.toJSString(sb.vm.ToValue(","))
+		if arg := call.Argument(0); !goja.IsUndefined(arg) {
+			sep = sb.toJSString(arg)
+		}
+		sepLen := float64(sep.Length())
+		if length > 1 && !checkString(sepLen*float64(length-1)) {
+			return goja.Undefined()
+		}
+
+		var buf goja.StringBuilder
+		total := sepLen * float64(max(length-1, 0))
+		for i := int64(0); i < length; i++ {
+			if i > 0 {
+				buf.WriteString(sep)
+			}
+			elem := obj.Get(strconv.FormatInt(i, 10))
+			if elem == nil || goja.IsUndefined(elem) || goja.IsNull(elem) {
+				continue
+			}
+			str := sb.toJSString(elem)
+			total += float64(str.Length())
+			if !checkString(total) {
+				return goja.Undefined()
+			}
+			buf.WriteString(str)
+		}
+		return buf.String()
+	}
+}
+
+// lengthOf 返回对象的 length 属性，不是对象时为 0
+func lengthOf(v goja.Value) int64 {
+	if obj, ok := v.(*goja.Object); ok {
+		if length := obj.Get("length"); length != nil {
+			return max(length.ToInteger(), 0)
+		}
+	}
+	return 0
+}
+
+// spreadLength 返回 Array.prototype.concat 中一个值展开后的元素个数：数组为其长度，其他值为 1
+func spreadLength(v goja.Value) int64 {
+	if obj, ok := v.(*goja.Object); ok && obj.ClassName() == "Array" {
+		return lengthOf(obj)
+	}
+	return 1
+}
+
+// toJSString 把值转换为 goja.String，数字等原始值的 ToString 返回的不是 goja.String
+func (sb *Sandbox) toJSString(v goja.Value) goja.String {
+	if s, ok := v.ToString().(goja.String); ok {
+		return s
+	}
+	return sb.vm.ToValue(v.String()).(goja.String)
+}
+
+// stringLength 返回值转换为字符串后的长度（UTF-16 码元数）
+func stringLength(v goja.Value) float64 {
+	if s, ok := v.ToString().(goja.String); ok {
+		return float64(s.Length())
+	}
+	return float64(len(v.String()))
+}
+
+// guardBuiltin 用检查函数包装内置方法，before 在调用前检查参数，可以把参数替换为转换后的值，
+// 返回 false 时执行已被终止，不再调用原方法
+func (sb *Sandbox) guardBuiltin(obj *goja.Object, name string, before func(call *goja.FunctionCall) bool) {
+	original, ok := goja.AssertFunction(obj.Get(name))
+	if !ok {
+		return
+	}
+	length := obj.Get(name).ToObject(sb.vm).Get("length").ToInteger()
+	sb.defineBuiltin(obj, name, length, func(call goja.FunctionCall) goja.Value {
+		if !before(&call) {
+			return goja.Undefined()
+		}
+		result, err := original(call.This, call.Arguments...)
+		if err != nil {
+			panic(err)
+		}
+		return result
+	})
+}
+
+// defineBuiltin 用 fn 替换内置方法，保留内置方法的 name 和 length，属性仍然不可枚举
+func (sb *Sandbox) defineBuiltin(obj *goja.Object, name string, length int64, fn func(call goja.FunctionCall) goja.Value) {
+	wrapped := sb.vm.ToValue(fn).ToObject(sb.vm)
+	wrapped.DefineDataProperty("name", sb.vm.ToValue(name), goja.FLAG_FALSE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	wrapped.DefineDataProperty("length", sb.vm.ToValue(length), goja.FLAG_FALSE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	obj.DefineDataProperty(name, wrapped, goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+}
+
+// runLimits 为一次执行启动资源监控，返回的函数在执行结束时调用
+func (sb *Sandbox) runLimits(ctx context.Context) (context.Context, func()) {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	prevCancel := sb.cancelRun
+	sb.cancelRun = cancel
+
+	done := make(chan struct{})
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		if sb.config.MaxMemory > 0 {
+			sb.watchMemory(done, cancel)
+		}
+	}()
+	return runCtx, func() {
+		close(done)
+		<-monitorDone
+		sb.cancelRun = prevCancel
+		cancel(nil)
+	}
+}
@@ -0,0 +1,129 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// assertResourceLimit 检查错误是否为 ErrCodeResourceLimit
+func assertResourceLimit(t *testing.T, err error) {
+	t.Helper()
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsResourceLimit() {
+		t.Fatalf("期望 %s 错误, got %v", ErrCodeResourceLimit, err)
+	}
+}
+
+func TestLimits_Exceeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		code   string
+	}{
+		{"大数组 fill", DefaultConfig(), `new Array(1e9).fill('x')`},
+		{"Array.from", DefaultConfig().WithMaxArrayLength(100), `Array.from({length: 101}, function (_, i) { return i; })`},
+		{"数组 concat", DefaultConfig().WithMaxArrayLength(100), `var a = Array.from({length: 60}); a.concat(a)`},
+		{"字符串 repeat", DefaultConfig().WithMaxStringLength(1024), `'ab'.repeat(513)`},
+		{"字符串 repeat 超大次数", DefaultConfig(), `'x'.repeat(2 ** 60)`},
+		{"字符串 padEnd", DefaultConfig().WithMaxStringLength(1024), `'x'.padEnd(2048, 'y')`},
+		{"数组 join", DefaultConfig().WithMaxStringLength(1024), `Array.from({length: 100}, function () { return 'abcdefghijk'; }).join('')`},
+		{"字符串 concat 参数很长", DefaultConfig().WithMaxStringLength(1024), `'x'.concat('y'.repeat(1000), 'z'.repeat(1000))`},
+		{"length 赋值后 join", DefaultConfig().WithMaxStringLength(1024), `var a = []; a.length = 2 ** 31; a.join('ab')`},
+		{"length 赋值后 fill", DefaultConfig(), `var a = []; a.length = 2 ** 31; a.fill(0)`},
+		{"length 赋值后 push", DefaultConfig().WithMaxArrayLength(100), `var a = []; a.length = 100; a.push(1)`},
+		{"push 循环", DefaultConfig().WithMaxArrayLength(1000), `var a = []; while (true) { a.push(a.length); }`},
+		{"无限递归", DefaultConfig().WithMaxCallStackSize(500), `(function f(n) { return f(n + 1) + 1; })(0)`},
+		{"字符串倍增", DefaultConfig().WithMaxMemory(32 * 1024 * 1024).WithMaxStringLength(0),
+			`var parts = []; var s = 'x'; while (true) { s = s + s; parts.push(s + '!'); }`},
+		{"字符串 += 倍增由 MaxMemory 兜底", DefaultConfig().WithMaxMemory(32 * 1024 * 1024),
+			`var s = 'x'; while (true) { s += s; }`},
+		{"异步回调中超出限制", DefaultConfig().WithMaxStringLength(1024),
+			`new Promise(function (resolve) { setTimeout(function () { resolve('x'.repeat(4096)); }, 1); })`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := NewSandboxWithConfig(context.Background(), tt.config)
+			defer sb.Close()
+
+			_, err := sb.RunWithTimeout(tt.code, 10*time.Second)
+			assertResourceLimit(t, err)
+
+			// 超出限制后沙盒仍然可以继续使用
+			result, err := sb.Run(`1 + 1`)
+			if err != nil {
+				t.Fatalf("超出限制后 Run() error = %v", err)
+			}
+			if result.ToInteger() != 2 {
+				t.Errorf("Run() = %v, want 2", result)
+			}
+		})
+	}
+}
+
+func TestLimits_Uncatchable(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithMaxStringLength(1024))
+	defer sb.Close()
+
+	_, err := sb.Run(`
+		var caught = false;
+		try { 'x'.repeat(4096); } catch (e) { caught = true; }
+		caught;
+	`)
+	assertResourceLimit(t, err)
+}
+
+func TestLimits_WithinLimits(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().
+		WithMaxStringLength(1024).
+		WithMaxArrayLength(100).
+		WithMaxCallStackSize(500))
+	defer sb.Close()
+
+	result, err := sb.Run(`[
+		'ab'.repeat(512).length,
+		'x'.padStart(1024).length,
+		new Array(100).fill(0).length,
+		Array.from({length: 50}).concat([1, 2]).length,
+		['a', 'b'].join('-'),
+		'a'.concat('b', 'c'),
+		(function f(n) { return n === 0 ? 0 : f(n - 1) + 1; })(400),
+		String.prototype.repeat.name,
+		Array.prototype.fill.length,
+		Object.keys(Array.prototype).length,
+		[1, null, undefined, { toString: function () { return 'o'; } }, [2, 3]].join(),
+		Array.prototype.join.call({ length: 2, 0: 'a', 1: 'b' }, '+'),
+		Array.prototype.join.length,
+		[].concat.call('x', 'y').join('|'),
+		[1, 2].push(3, 4)
+	]`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{int64(1024), int64(1024), int64(100), int64(52), "a-b", "abc", int64(400), "repeat", int64(1), int64(0),
+		"1,,,o,2,3", "a+b", int64(1), "x|y", int64(4)}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLimits_PoolDiscardsSandbox(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(0).WithMaxSize(1).
+		WithSandboxConfig(DefaultConfig().WithMaxStringLength(1024)))
+
+	sb, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_, err = sb.Run(`'x'.repeat(4096)`)
+	assertResourceLimit(t, err)
+	pool.Put(sb)
+
+	if stats := pool.Stats(); stats.Discarded != 1 {
+		t.Errorf("Discarded = %d, want 1", stats.Discarded)
+	}
+}
@@ -49,167 +49,23 @@ func (sb *Sandbox) registerLogger() {
 	// 创建 logger 对象
 	loggerObj := sb.vm.NewObject()
 
-	// Debug 级别日志
-	loggerObj.Set("debug", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			sb.logger.Debug()
-			return goja.Undefined()
-		}
-
-		// 检查第一个参数是否是对象（用于结构化日志）
-		firstArg := call.Arguments[0]
-		if firstArgObj := firstArg.ToObject(sb.vm); firstArgObj != nil {
-			// 尝试作为结构化日志处理
-			fields := extractFields(firstArg)
-			if len(fields) > 0 && len(call.Arguments) > 1 {
-				// 有字段和消息
-				msg := formatLogArgs(goja.FunctionCall{
-					Arguments: call.Arguments[1:],
-				})
-				sb.logger.WithFields(fields).Debug(msg)
-			} else if len(fields) > 0 {
-				// 只有字段，没有消息
-				sb.logger.WithFields(fields).Debug()
-			} else {
-				// 普通消息
-				sb.logger.Debug(formatLogArgs(call))
-			}
-		} else {
-			// 普通消息
-			sb.logger.Debug(formatLogArgs(call))
-		}
-		return goja.Undefined()
-	})
-
-	// Info 级别日志
-	loggerObj.Set("info", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			sb.logger.Info()
-			return goja.Undefined()
-		}
-
-		firstArg := call.Arguments[0]
-		if firstArgObj := firstArg.ToObject(sb.vm); firstArgObj != nil {
-			fields := extractFields(firstArg)
-			if len(fields) > 0 && len(call.Arguments) > 1 {
-				msg := formatLogArgs(goja.FunctionCall{
-					Arguments: call.Arguments[1:],
-				})
-				sb.logger.WithFields(fields).Info(msg)
-			} else if len(fields) > 0 {
-				sb.logger.WithFields(fields).Info()
-			} else {
-				sb.logger.Info(formatLogArgs(call))
-			}
-		} else {
-			sb.logger.Info(formatLogArgs(call))
-		}
-		return goja.Undefined()
-	})
-
-	// Warn 级别日志
-	loggerObj.Set("warn", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			sb.logger.Warn()
-			return goja.Undefined()
-		}
-
-		firstArg := call.Arguments[0]
-		if firstArgObj := firstArg.ToObject(sb.vm); firstArgObj != nil {
-			fields := extractFields(firstArg)
-			if len(fields) > 0 && len(call.Arguments) > 1 {
-				msg := formatLogArgs(goja.FunctionCall{
-					Arguments: call.Arguments[1:],
-				})
-				sb.logger.WithFields(fields).Warn(msg)
-			} else if len(fields) > 0 {
-				sb.logger.WithFields(fields).Warn()
-			} else {
-				sb.logger.Warn(formatLogArgs(call))
-			}
-		} else {
-			sb.logger.Warn(formatLogArgs(call))
-		}
-		return goja.Undefined()
-	})
-
-	// Error 级别日志
-	loggerObj.Set("error", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			sb.logger.Error()
-			return goja.Undefined()
-		}
-
-		firstArg := call.Arguments[0]
-		if firstArgObj := firstArg.ToObject(sb.vm); firstArgObj != nil {
-			fields := extractFields(firstArg)
-			if len(fields) > 0 && len(call.Arguments) > 1 {
-				msg := formatLogArgs(goja.FunctionCall{
-					Arguments: call.Arguments[1:],
-				})
-				sb.logger.WithFields(fields).Error(msg)
-			} else if len(fields) > 0 {
-				sb.logger.WithFields(fields).Error()
-			} else {
-				sb.logger.Error(formatLogArgs(call))
+	// 各级别的日志方法，第一个参数是对象时作为结构化日志的字段，其余参数为消息
+	// fatal 不会真正退出程序，只按 error 级别记录
+	for _, level := range []string{"trace", "debug", "info", "warn", "error", "fatal"} {
+		level := level
+		loggerObj.Set(level, func(call goja.FunctionCall) goja.Value {
+			if len(call.Arguments) == 0 {
+				sb.logScript("logger", level, nil, "")
+				return goja.Undefined()
 			}
-		} else {
-			sb.logger.Error(formatLogArgs(call))
-		}
-		return goja.Undefined()
-	})
-
-	// Fatal 级别日志（注意：这不会真正退出程序，只是记录日志）
-	loggerObj.Set("fatal", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			sb.logger.Error("FATAL: (fatal级别日志)")
-			return goja.Undefined()
-		}
-
-		firstArg := call.Arguments[0]
-		if firstArgObj := firstArg.ToObject(sb.vm); firstArgObj != nil {
-			fields := extractFields(firstArg)
-			if len(fields) > 0 && len(call.Arguments) > 1 {
-				msg := formatLogArgs(goja.FunctionCall{
-					Arguments: call.Arguments[1:],
-				})
-				sb.logger.WithFields(fields).Error("FATAL: " + msg)
-			} else if len(fields) > 0 {
-				sb.logger.WithFields(fields).Error("FATAL:")
+			if fields := extractFields(call.Arguments[0]); len(fields) > 0 {
+				sb.logScript("logger", level, fields, formatLogArgs(goja.FunctionCall{Arguments: call.Arguments[1:]}))
 			} else {
-				sb.logger.Error("FATAL: " + formatLogArgs(call))
+				sb.logScript("logger", level, nil, formatLogArgs(call))
 			}
-		} else {
-			sb.logger.Error("FATAL: " + formatLogArgs(call))
-		}
-		return goja.Undefined()
-	})
-
-	// Trace 级别日志
-	loggerObj.Set("trace", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			sb.logger.Trace()
 			return goja.Undefined()
-		}
-
-		firstArg := call.Arguments[0]
-		if firstArgObj := firstArg.ToObject(sb.vm); firstArgObj != nil {
-			fields := extractFields(firstArg)
-			if len(fields) > 0 && len(call.Arguments) > 1 {
-				msg := formatLogArgs(goja.FunctionCall{
-					Arguments: call.Arguments[1:],
-				})
-				sb.logger.WithFields(fields).Trace(msg)
-			} else if len(fields) > 0 {
-				sb.logger.WithFields(fields).Trace()
-			} else {
-				sb.logger.Trace(formatLogArgs(call))
-			}
-		} else {
-			sb.logger.Trace(formatLogArgs(call))
-		}
-		return goja.Undefined()
-	})
+		})
+	}
 
 	// 设置日志级别
 	loggerObj.Set("setLevel", func(level string) goja.Value {
@@ -290,64 +146,16 @@ func (sb *Sandbox) registerLogger() {
 	// 带字段的日志方法（返回一个可以链式调用的对象）
 	loggerObj.Set("withFields", func(fieldsObj goja.Value) goja.Value {
 		fields := extractFields(fieldsObj)
-		entry := sb.logger.WithFields(fields)
 
 		// 创建一个新的对象，包含所有日志级别方法
 		fieldLoggerObj := sb.vm.NewObject()
-
-		fieldLoggerObj.Set("debug", func(call goja.FunctionCall) goja.Value {
-			if len(call.Arguments) == 0 {
-				entry.Debug()
-			} else {
-				entry.Debug(formatLogArgs(call))
-			}
-			return goja.Undefined()
-		})
-
-		fieldLoggerObj.Set("info", func(call goja.FunctionCall) goja.Value {
-			if len(call.Arguments) == 0 {
-				entry.Info()
-			} else {
-				entry.Info(formatLogArgs(call))
-			}
-			return goja.Undefined()
-		})
-
-		fieldLoggerObj.Set("warn", func(call goja.FunctionCall) goja.Value {
-			if len(call.Arguments) == 0 {
-				entry.Warn()
-			} else {
-				entry.Warn(formatLogArgs(call))
-			}
-			return goja.Undefined()
-		})
-
-		fieldLoggerObj.Set("error", func(call goja.FunctionCall) goja.Value {
-			if len(call.Arguments) == 0 {
-				entry.Error()
-			} else {
-				entry.Error(formatLogArgs(call))
-			}
-			return goja.Undefined()
-		})
-
-		fieldLoggerObj.Set("fatal", func(call goja.FunctionCall) goja.Value {
-			if len(call.Arguments) == 0 {
-				entry.Error("FATAL:")
-			} else {
-				entry.Error("FATAL: " + formatLogArgs(call))
-			}
-			return goja.Undefined()
-		})
-
-		fieldLoggerObj.Set("trace", func(call goja.FunctionCall) goja.Value {
-			if len(call.Arguments) == 0 {
-				entry.Trace()
-			} else {
-				entry.Trace(formatLogArgs(call))
-			}
-			return goja.Undefined()
-		})
+		for _, level := range []string{"trace", "debug", "info", "warn", "error", "fatal"} {
+			level := level
+			fieldLoggerObj.Set(level, func(call goja.FunctionCall) goja.Value {
+				sb.logScript("logger", level, fields, formatLogArgs(call))
+				return goja.Undefined()
+			})
+		}
 
 		return fieldLoggerObj
 	})
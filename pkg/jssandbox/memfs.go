@@ -0,0 +1,441 @@
+package jssandbox
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemFileSystem 完全位于内存中的文件系统
+//
+// 路径按虚拟路径处理：相对路径相对于 "/"，".." 在根目录处截断。
+// 执行结束后可以通过 Files、ReadFile 或 Export 查看和导出脚本生成的文件。
+type MemFileSystem struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode // 键为清理后的虚拟路径
+}
+
+// memNode 内存文件系统中的文件或目录
+type memNode struct {
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMemFileSystem 创建只包含根目录的内存文件系统
+func NewMemFileSystem() *MemFileSystem {
+	return &MemFileSystem{
+		nodes: map[string]*memNode{
+			"/": {mode: os.ModeDir | 0755, modTime: time.Now()},
+		},
+	}
+}
+
+// info 返回节点的文件信息，调用方需持有锁
+func (m *MemFileSystem) info(name string, n *memNode) *memFileInfo {
+	base := path.Base(name)
+	return &memFileInfo{name: base, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+// parentDir 校验父目录存在，调用方需持有锁
+func (m *MemFileSystem) parentDir(op, name string) error {
+	parent, ok := m.nodes[path.Dir(name)]
+	if !ok {
+		return &os.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// Open 以只读方式打开文件
+func (m *MemFileSystem) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile 按 os.O_* 标志打开文件，支持 O_CREATE、O_EXCL、O_TRUNC 和 O_APPEND
+func (m *MemFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	p := cleanVirtualPath(name)
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[p]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case ok && n.mode.IsDir() && writable:
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case !ok:
+		if err := m.parentDir("open", p); err != nil {
+			return nil, err
+		}
+		n = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		m.nodes[p] = n
+	}
+	if ok && writable && flag&os.O_TRUNC != 0 {
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	return &memFile{fs: m, node: n, name: name, path: p, flag: flag}, nil
+}
+
+// Stat 返回文件信息
+func (m *MemFileSystem) Stat(name string) (os.FileInfo, error) {
+	p := cleanVirtualPath(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.nodes[p]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return m.info(p, n), nil
+}
+
+// children 返回目录的直接子项路径，按名称排序，调用方需持有锁
+func (m *MemFileSystem) children(dir string) []string {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	var result []string
+	for p := range m.nodes {
+		if p != "/" && strings.HasPrefix(p, prefix) && !strings.Contains(p[len(prefix):], "/") {
+			result = append(result, p)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// descendants 返回目录下的所有子孙路径，调用方需持有锁
+func (m *MemFileSystem) descendants(dir string) []string {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	var result []string
+	for p := range m.nodes {
+		if p != "/" && strings.HasPrefix(p, prefix) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// ReadDir 返回按名称排序的目录项
+func (m *MemFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	p := cleanVirtualPath(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.nodes[p]
+	if !ok {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !n.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	var entries []os.DirEntry
+	for _, child := range m.children(p) {
+		entries = append(entries, fs.FileInfoToDirEntry(m.info(child, m.nodes[child])))
+	}
+	return entries, nil
+}
+
+// Mkdir 创建目录，父目录必须存在
+func (m *MemFileSystem) Mkdir(name string, perm os.FileMode) error {
+	p := cleanVirtualPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.nodes[p]; ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.parentDir("mkdir", p); err != nil {
+		return err
+	}
+	m.nodes[p] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// MkdirAll 递归创建目录
+func (m *MemFileSystem) MkdirAll(name string, perm os.FileMode) error {
+	p := cleanVirtualPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(name, p, perm)
+}
+
+// mkdirAll 递归创建目录，调用方需持有锁
+func (m *MemFileSystem) mkdirAll(name, p string, perm os.FileMode) error {
+	if n, ok := m.nodes[p]; ok {
+		if !n.mode.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if err := m.mkdirAll(name, path.Dir(p), perm); err != nil {
+		return err
+	}
+	m.nodes[p] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// Remove 删除文件或空目录
+func (m *MemFileSystem) Remove(name string) error {
+	p := cleanVirtualPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[p]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if p == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	if n.mode.IsDir() && len(m.children(p)) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+// RemoveAll 递归删除，路径不存在时不返回错误
+func (m *MemFileSystem) RemoveAll(name string) error {
+	p := cleanVirtualPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, child := range m.descendants(p) {
+		delete(m.nodes, child)
+	}
+	if p != "/" {
+		delete(m.nodes, p)
+	}
+	return nil
+}
+
+// Rename 重命名或移动文件、目录，目标为文件时被覆盖
+func (m *MemFileSystem) Rename(oldname, newname string) error {
+	oldPath, newPath := cleanVirtualPath(oldname), cleanVirtualPath(newname)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[oldPath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if oldPath == newPath {
+		return nil
+	}
+	if oldPath == "/" || pathWithin(newPath, oldPath) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	if err := m.parentDir("rename", newPath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err.(*os.PathError).Err}
+	}
+	if target, ok := m.nodes[newPath]; ok {
+		switch {
+		case target.mode.IsDir() && !n.mode.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EISDIR}
+		case !target.mode.IsDir() && n.mode.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.ENOTDIR}
+		case target.mode.IsDir() && len(m.children(newPath)) > 0:
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.ENOTEMPTY}
+		}
+	}
+
+	for _, child := range m.descendants(oldPath) {
+		m.nodes[newPath+strings.TrimPrefix(child, oldPath)] = m.nodes[child]
+		delete(m.nodes, child)
+	}
+	delete(m.nodes, oldPath)
+	m.nodes[newPath] = n
+	return nil
+}
+
+// ReadFile 读取文件的全部内容
+func (m *MemFileSystem) ReadFile(name string) ([]byte, error) {
+	return readFileFS(m, name)
+}
+
+// WriteFile 写入文件，父目录不存在时自动创建，便于在执行前准备输入文件
+func (m *MemFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := m.MkdirAll(path.Dir(cleanVirtualPath(name)), 0755); err != nil {
+		return err
+	}
+	return writeFileFS(m, name, data, perm)
+}
+
+// Files 返回所有普通文件的虚拟路径，按路径排序
+func (m *MemFileSystem) Files() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var files []string
+	for p, n := range m.nodes {
+		if n.mode.IsRegular() {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Export 把内存中的所有文件和目录写入宿主机目录 dir
+func (m *MemFileSystem) Export(dir string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	paths := make([]string, 0, len(m.nodes))
+	for p := range m.nodes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		n := m.nodes[p]
+		target := filepath.Join(dir, filepath.FromSlash(p))
+		if n.mode.IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.WriteFile(target, n.data, n.mode.Perm()|0200); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memFile 内存文件系统中打开的文件
+type memFile struct {
+	fs     *MemFileSystem
+	node   *memNode
+	name   string
+	path   string
+	flag   int
+	offset int64
+	closed bool
+}
+
+func (f *memFile) Name() string { return f.name }
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.fs.info(f.path, f.node), nil
+}
+
+// check 校验文件状态和打开方式
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.node.mode.IsDir() {
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	}
+	writable := f.flag&(os.O_WRONLY|os.O_RDWR) != 0
+	readable := f.flag&os.O_WRONLY == 0
+	if (write && !writable) || (!write && !readable) {
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		if end > int64(cap(f.node.data)) {
+			grown := make([]byte, end, end*2)
+			copy(grown, f.node.data)
+			f.node.data = grown
+		} else {
+			f.node.data = f.node.data[:end]
+		}
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+// memFileInfo 内存文件系统中的文件信息
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() interface{}   { return nil }
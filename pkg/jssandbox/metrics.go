@@ -0,0 +1,406 @@
+package jssandbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// MetricType 指标类型，与 Prometheus 的指标类型对应
+type MetricType string
+
+const (
+	// MetricCounter 只增不减的累计值
+	MetricCounter MetricType = "counter"
+	// MetricGauge 可增可减的当前值
+	MetricGauge MetricType = "gauge"
+	// MetricHistogram 按区间统计的分布，如耗时
+	MetricHistogram MetricType = "histogram"
+)
+
+// MetricFamily 同名的一组指标，对应 Prometheus 文本格式中的一个指标名
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    MetricType
+	Samples []MetricSample
+}
+
+// MetricSample 一组标签下的指标值
+type MetricSample struct {
+	Labels map[string]string
+	// Value 计数器和仪表的值
+	Value float64
+	// Count、Sum 和 Buckets 为直方图的观测次数、观测值之和和各区间的累计次数
+	Count   uint64
+	Sum     float64
+	Buckets []MetricBucket
+}
+
+// MetricBucket 直方图的一个区间，Count 为不大于 UpperBound 的观测次数
+type MetricBucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// MetricsCollector 指标收集器，Metrics 导出指标时调用所有注册的收集器
+type MetricsCollector interface {
+	Collect() []MetricFamily
+}
+
+// defaultDurationBuckets 耗时直方图的区间上限（秒）
+var defaultDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics 沙盒的指标注册表，记录执行次数和耗时、宿主函数调用、HTTP 流量、浏览器会话和沙盒池使用情况，
+// 通过 Config.Metrics 启用，可由多个沙盒和沙盒池共用。
+// Collect 以 Go 结构返回所有指标，WritePrometheus 和 Handler 以 Prometheus 文本格式导出，不依赖外部服务
+type Metrics struct {
+	mu           sync.Mutex
+	runs         *metricVec
+	runDuration  *metricVec
+	interrupted  *metricVec
+	hostCalls    *metricVec
+	hostDuration *metricVec
+	httpOut      *metricVec
+	httpIn       *metricVec
+	browsers     *metricVec
+	collectors   []MetricsCollector
+}
+
+// NewMetrics 创建指标注册表
+func NewMetrics() *Metrics {
+	return &Metrics{
+		runs: newMetricVec("jssandbox_runs_total", "脚本执行次数，按结果（success、error、timeout、resource_limit、canceled、busy）区分",
+			MetricCounter, "outcome"),
+		runDuration: newMetricVec("jssandbox_run_duration_seconds", "脚本执行耗时，按结果区分",
+			MetricHistogram, "outcome"),
+		interrupted: newMetricVec("jssandbox_runs_interrupted_total", "因超时、取消或超出资源限制而被中断的执行次数",
+			MetricCounter, "reason"),
+		hostCalls: newMetricVec("jssandbox_host_calls_total", "宿主函数调用次数，按模块、函数和结果区分",
+			MetricCounter, "module", "function", "outcome"),
+		hostDuration: newMetricVec("jssandbox_host_call_duration_seconds", "宿主函数调用耗时",
+			MetricHistogram, "module", "function"),
+		httpOut:  newMetricVec("jssandbox_http_request_bytes_total", "HTTP 请求体发送的字节数", MetricCounter),
+		httpIn:   newMetricVec("jssandbox_http_response_bytes_total", "HTTP 响应体接收的字节数", MetricCounter),
+		browsers: newMetricVec("jssandbox_browser_sessions_open", "打开的浏览器会话数量", MetricGauge),
+	}
+}
+
+// Register 注册收集器，导出时一并输出其指标；沙盒池在 Config.Metrics 不为 nil 时自动注册
+func (m *Metrics) Register(c MetricsCollector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collectors = append(m.collectors, c)
+}
+
+// Unregister 取消注册收集器
+func (m *Metrics) Unregister(c MetricsCollector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.collectors {
+		if existing == c {
+			m.collectors = append(m.collectors[:i], m.collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// Collect 实现 MetricsCollector 接口，返回按名称排序的所有指标
+// 注册的收集器返回同名、同标签的指标时（如多个同名沙盒池）相加合并
+func (m *Metrics) Collect() []MetricFamily {
+	m.mu.Lock()
+	families := []MetricFamily{
+		m.runs.family(), m.runDuration.family(), m.interrupted.family(),
+		m.hostCalls.family(), m.hostDuration.family(),
+		m.httpOut.family(), m.httpIn.family(), m.browsers.family(),
+	}
+	collectors := append([]MetricsCollector(nil), m.collectors...)
+	m.mu.Unlock()
+
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+	return mergeFamilies(families)
+}
+
+// WritePrometheus 以 Prometheus 文本格式写出所有指标
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+	for _, f := range m.Collect() {
+		writeFamily(&b, f)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler 返回以 Prometheus 文本格式输出指标的 HTTP 处理器，可挂载到 /metrics
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := m.WritePrometheus(w); err != nil {
+			GetLogger().WithError(err).Warn("输出指标失败")
+		}
+	})
+}
+
+// 以下记录方法在 m 为 nil（未启用指标）时不做任何事
+
+// observeRun 记录一次执行的结果和耗时
+func (m *Metrics) observeRun(err error, d time.Duration) {
+	if m == nil {
+		return
+	}
+	outcome := runOutcome(err)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs.get(outcome).value++
+	m.runDuration.get(outcome).observe(d.Seconds())
+}
+
+// runInterrupted 记录一次被中断的执行，reason 为 timeout、canceled 或 resource_limit
+func (m *Metrics) runInterrupted(reason string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.interrupted.get(reason).value++
+}
+
+// observeHostCall 记录一次宿主函数调用
+func (m *Metrics) observeHostCall(module, name, outcome string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hostCalls.get(module, name, outcome).value++
+	m.hostDuration.get(module, name).observe(d.Seconds())
+}
+
+// addHTTPBytes 记录 HTTP 请求发送和接收的字节数
+func (m *Metrics) addHTTPBytes(out, in int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.httpOut.get().value += float64(out)
+	m.httpIn.get().value += float64(in)
+}
+
+// addBrowserSessions 调整打开的浏览器会话数量
+func (m *Metrics) addBrowserSessions(delta int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.browsers.get().value += float64(delta)
+}
+
+// runOutcome 执行结果的标签值
+func runOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	var sbErr *SandboxError
+	if errors.As(err, &sbErr) {
+		switch sbErr.Code {
+		case ErrCodeTimeout:
+			return "timeout"
+		case ErrCodeResourceLimit:
+			return "resource_limit"
+		case ErrCodeCanceled:
+			return "canceled"
+		case ErrCodeBusy:
+			return "busy"
+		}
+	}
+	return "error"
+}
+
+// metricsInterceptor 统计宿主函数的调用次数和耗时，配置了 Config.Metrics 时作为最外层的拦截器
+type metricsInterceptor struct {
+	metrics *Metrics
+}
+
+// Intercept 实现 HostInterceptor 接口，宿主函数抛出异常时记为失败
+func (i metricsInterceptor) Intercept(call *HostCall, next HostInvoker) (goja.Value, error) {
+	start := time.Now()
+	outcome := "error"
+	defer func() { i.metrics.observeHostCall(call.Module, call.Name, outcome, time.Since(start)) }()
+	result, err := next(call)
+	if err == nil {
+		outcome = "success"
+	}
+	return result, err
+}
+
+// metricVec 一个指标名下按标签值区分的一组指标
+type metricVec struct {
+	name       string
+	help       string
+	typ        MetricType
+	labelNames []string
+	series     map[string]*metricSeries
+}
+
+// metricSeries 一组标签值对应的指标
+type metricSeries struct {
+	labels  []string
+	value   float64
+	count   uint64
+	sum     float64
+	buckets []uint64 // 各区间（不累计）的观测次数
+}
+
+func newMetricVec(name, help string, typ MetricType, labelNames ...string) *metricVec {
+	return &metricVec{name: name, help: help, typ: typ, labelNames: labelNames, series: make(map[string]*metricSeries)}
+}
+
+// get 返回标签值对应的指标，不存在时创建
+func (v *metricVec) get(labels ...string) *metricSeries {
+	key := strings.Join(labels, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &metricSeries{labels: labels}
+		if v.typ == MetricHistogram {
+			s.buckets = make([]uint64, len(defaultDurationBuckets))
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+// observe 记录直方图的一次观测
+func (s *metricSeries) observe(value float64) {
+	s.count++
+	s.sum += value
+	if i := sort.SearchFloat64s(defaultDurationBuckets, value); i < len(s.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// family 导出指标，调用方需持有 Metrics.mu
+func (v *metricVec) family() MetricFamily {
+	f := MetricFamily{Name: v.name, Help: v.help, Type: v.typ}
+	if len(v.labelNames) == 0 && len(v.series) == 0 {
+		v.get()
+	}
+	for _, s := range v.series {
+		sample := MetricSample{Labels: make(map[string]string, len(v.labelNames)), Value: s.value, Count: s.count, Sum: s.sum}
+		for i, name := range v.labelNames {
+			sample.Labels[name] = s.labels[i]
+		}
+		var cumulative uint64
+		for i, n := range s.buckets {
+			cumulative += n
+			sample.Buckets = append(sample.Buckets, MetricBucket{UpperBound: defaultDurationBuckets[i], Count: cumulative})
+		}
+		f.Samples = append(f.Samples, sample)
+	}
+	return f
+}
+
+// mergeFamilies 合并同名的指标，同标签的值相加，并按名称和标签排序
+func mergeFamilies(families []MetricFamily) []MetricFamily {
+	byName := make(map[string]*MetricFamily)
+	var names []string
+	for _, f := range families {
+		merged, ok := byName[f.Name]
+		if !ok {
+			merged = &MetricFamily{Name: f.Name, Help: f.Help, Type: f.Type}
+			byName[f.Name] = merged
+			names = append(names, f.Name)
+		}
+		for _, s := range f.Samples {
+			merged.Samples = addSample(merged.Samples, s)
+		}
+	}
+	sort.Strings(names)
+	result := make([]MetricFamily, 0, len(names))
+	for _, name := range names {
+		f := byName[name]
+		sort.Slice(f.Samples, func(i, j int) bool {
+			return formatLabels(f.Samples[i].Labels, "") < formatLabels(f.Samples[j].Labels, "")
+		})
+		result = append(result, *f)
+	}
+	return result
+}
+
+// addSample 把 s 加到标签相同的指标上，没有时追加
+func addSample(samples []MetricSample, s MetricSample) []MetricSample {
+	key := formatLabels(s.Labels, "")
+	for i := range samples {
+		if formatLabels(samples[i].Labels, "") != key || len(samples[i].Buckets) != len(s.Buckets) {
+			continue
+		}
+		samples[i].Value += s.Value
+		samples[i].Count += s.Count
+		samples[i].Sum += s.Sum
+		for j := range s.Buckets {
+			samples[i].Buckets[j].Count += s.Buckets[j].Count
+		}
+		return samples
+	}
+	s.Buckets = append([]MetricBucket(nil), s.Buckets...)
+	return append(samples, s)
+}
+
+// writeFamily 以 Prometheus 文本格式写出一个指标
+func writeFamily(b *strings.Builder, f MetricFamily) {
+	fmt.Fprintf(b, "# HELP %s %s\n", f.Name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.Help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.Name, f.Type)
+	for _, s := range f.Samples {
+		if f.Type != MetricHistogram {
+			fmt.Fprintf(b, "%s%s %s\n", f.Name, formatLabels(s.Labels, ""), formatFloat(s.Value))
+			continue
+		}
+		for _, bucket := range s.Buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.Name, formatLabels(s.Labels, formatFloat(bucket.UpperBound)), bucket.Count)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", f.Name, formatLabels(s.Labels, "+Inf"), s.Count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", f.Name, formatLabels(s.Labels, ""), formatFloat(s.Sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", f.Name, formatLabels(s.Labels, ""), s.Count)
+	}
+}
+
+// formatLabels 按名称排序格式化标签，le 不为空时追加直方图区间标签
+func formatLabels(labels map[string]string, le string) string {
+	if len(labels) == 0 && le == "" {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		parts = append(parts, name+`="`+escape.Replace(labels[name])+`"`)
+	}
+	if le != "" {
+		parts = append(parts, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatFloat 按 Prometheus 文本格式格式化数值
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
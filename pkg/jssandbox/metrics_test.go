@@ -0,0 +1,131 @@
+package jssandbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metricValue 返回指标中标签匹配的值（直方图为观测次数），不存在时返回 -1
+func metricValue(m *Metrics, name string, labels map[string]string) float64 {
+	for _, f := range m.Collect() {
+		if f.Name != name {
+			continue
+		}
+	next:
+		for _, s := range f.Samples {
+			for k, v := range labels {
+				if s.Labels[k] != v {
+					continue next
+				}
+			}
+			if f.Type == MetricHistogram {
+				return float64(s.Count)
+			}
+			return s.Value
+		}
+	}
+	return -1
+}
+
+func TestMetrics_Runs(t *testing.T) {
+	metrics := NewMetrics()
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithMetrics(metrics).WithMaxCallStackSize(64))
+	defer sb.Close()
+
+	sb.Run(`encodeBase64('a'); encodeBase64('b')`)
+	sb.Run(`throw new Error('boom')`)
+	sb.RunWithTimeout(`while (true) {}`, 50*time.Millisecond)
+	sb.Run(`function f() { f() } f()`)
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"jssandbox_runs_total", map[string]string{"outcome": "success"}, 1},
+		{"jssandbox_runs_total", map[string]string{"outcome": "error"}, 1},
+		{"jssandbox_runs_total", map[string]string{"outcome": "timeout"}, 1},
+		{"jssandbox_runs_total", map[string]string{"outcome": "resource_limit"}, 1},
+		{"jssandbox_run_duration_seconds", map[string]string{"outcome": "timeout"}, 1},
+		{"jssandbox_runs_interrupted_total", map[string]string{"reason": "timeout"}, 1},
+		{"jssandbox_runs_interrupted_total", map[string]string{"reason": "resource_limit"}, 1},
+		{"jssandbox_host_calls_total", map[string]string{"module": "encoding", "function": "encodeBase64", "outcome": "success"}, 2},
+		{"jssandbox_host_call_duration_seconds", map[string]string{"module": "encoding", "function": "encodeBase64"}, 2},
+		{"jssandbox_browser_sessions_open", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := metricValue(metrics, tt.name, tt.labels); got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestMetrics_HTTPAndHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics()
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithMetrics(metrics).WithPrivateNetwork(true))
+	defer sb.Close()
+	sb.Set("url", server.URL)
+	if _, err := sb.Run(`httpPost(url, 'abc'); readFile('/no/such/file')`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := metricValue(metrics, "jssandbox_http_request_bytes_total", nil); got != 3 {
+		t.Errorf("请求字节数 = %v", got)
+	}
+	if got := metricValue(metrics, "jssandbox_http_response_bytes_total", nil); got != 10 {
+		t.Errorf("响应字节数 = %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE jssandbox_runs_total counter\n",
+		`jssandbox_runs_total{outcome="success"} 1`,
+		"# TYPE jssandbox_run_duration_seconds histogram\n",
+		`jssandbox_run_duration_seconds_bucket{outcome="success",le="+Inf"} 1`,
+		`jssandbox_run_duration_seconds_count{outcome="success"} 1`,
+		`jssandbox_host_calls_total{function="readFile",module="fs",outcome="error"} 1`,
+		`jssandbox_host_calls_total{function="httpPost",module="http",outcome="success"} 1`,
+		"jssandbox_http_response_bytes_total 10\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics 输出缺少 %q:\n%s", want, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestMetrics_Pool(t *testing.T) {
+	metrics := NewMetrics()
+	config := DefaultPoolConfig().WithSandboxConfig(DefaultConfig().WithMetrics(metrics)).WithName("agents").WithMinIdle(1).WithMaxSize(3)
+	pool, err := NewSandboxPool(context.Background(), config)
+	if err != nil {
+		t.Fatalf("NewSandboxPool() error = %v", err)
+	}
+	sb, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := metricValue(metrics, "jssandbox_pool_sandboxes", map[string]string{"pool": "agents", "state": "in_use"}); got != 1 {
+		t.Errorf("借出的沙盒 = %v", got)
+	}
+	if got := metricValue(metrics, "jssandbox_pool_max_size", map[string]string{"pool": "agents"}); got != 3 {
+		t.Errorf("沙盒数量上限 = %v", got)
+	}
+	pool.Put(sb)
+	pool.Close()
+	if got := metricValue(metrics, "jssandbox_pool_max_size", nil); got != -1 {
+		t.Errorf("关闭的沙盒池不应再导出指标, got %v", got)
+	}
+}
@@ -0,0 +1,279 @@
+package jssandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// moduleExport 内置模块导出的一个宿主函数或对象
+type moduleExport struct {
+	name  string
+	value goja.Value
+}
+
+// moduleRegistry CommonJS 模块注册表和缓存
+//
+// 模块分为三类：
+//   - 内置模块：按功能把已注册的宿主函数归入命名空间，如 require('fs').readFile、require('http').fetch
+//   - 自定义模块：宿主通过 Sandbox.RegisterModule 注册，同名时覆盖内置模块
+//   - 本地模块：以 "./"、"../" 或 "/" 开头的 .js/.json/.ts 文件，只能从 Config.ModuleRoot 中加载
+type moduleRegistry struct {
+	builtins map[string][]moduleExport
+	custom   map[string]interface{}
+	// cache 已加载的模块对象，键为模块名或本地模块的虚拟路径
+	cache map[string]*goja.Object
+	// loading 正在加载的本地模块，用于检测循环依赖
+	loading []string
+	// root 本地模块的根目录，未配置 ModuleRoot 时为 nil
+	root *fsJail
+	// jsonParse 注册时保存的 JSON.parse，不受脚本篡改影响
+	jsonParse goja.Callable
+}
+
+// newModuleRegistry 创建模块注册表
+func newModuleRegistry(config *Config) *moduleRegistry {
+	r := &moduleRegistry{
+		builtins: make(map[string][]moduleExport),
+		custom:   make(map[string]interface{}),
+		cache:    make(map[string]*goja.Object),
+	}
+	if config.ModuleRoot != "" {
+		r.root = &fsJail{mounts: []jailMount{{virtual: "/", host: realHostPath(config.ModuleRoot), readOnly: true}}}
+	}
+	return r
+}
+
+// reset 清空模块缓存，下次 require 时重新加载
+func (r *moduleRegistry) reset() {
+	r.cache = make(map[string]*goja.Object)
+	r.loading = nil
+}
+
+// isLocalModule 判断模块名是否为本地文件路径
+func isLocalModule(name string) bool {
+	return strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") || strings.HasPrefix(name, "/") ||
+		name == "." || name == ".."
+}
+
+// RegisterModule 注册自定义模块，脚本中通过 require(name) 获取 exports
+// exports 为 map[string]interface{} 时每次加载都会复制为新的 JavaScript 对象，
+// 其他值通过 goja 转换；模块名不能是相对或绝对路径，与内置模块同名时覆盖内置模块
+func (sb *Sandbox) RegisterModule(name string, exports interface{}) error {
+	if name == "" || isLocalModule(name) {
+		return NewSandboxError(ErrCodeInvalidInput, fmt.Sprintf("无效的模块名: %q", name))
+	}
+	defer sb.lockRuntime()()
+	sb.modules.custom[name] = exports
+	delete(sb.modules.cache, name)
+	return nil
+}
+
+// registerBuiltinModule 调用 register 注册宿主函数，并把新增的全局变量归入内置模块 name
+// 同一个模块可以由多个注册函数组成；新增的宿主函数经过 Config.Interceptors 中的拦截器，
+// ErrorModeThrow 下还被包装为出错时抛出 SandboxError
+func (sb *Sandbox) registerBuiltinModule(name string, register func()) {
+	global := sb.vm.GlobalObject()
+	before := make(map[string]bool)
+	for _, key := range global.Keys() {
+		before[key] = true
+	}
+	register()
+	for _, key := range global.Keys() {
+		if before[key] {
+			continue
+		}
+		value := global.Get(key)
+		if sb.intercepting() {
+			value = sb.intercept(name, key, value)
+		}
+		if sb.config.ErrorMode == ErrorModeThrow {
+			value = sb.throwOnError(name, value)
+		}
+		if sb.intercepting() || sb.config.ErrorMode == ErrorModeThrow {
+			global.Set(key, value)
+		}
+		sb.modules.builtins[name] = append(sb.modules.builtins[name], moduleExport{name: key, value: value})
+	}
+}
+
+// registerRequire 注册全局 require 函数，顶层代码中的相对路径相对于模块根目录
+func (sb *Sandbox) registerRequire() {
+	jsonParse, _ := goja.AssertFunction(sb.vm.Get("JSON").ToObject(sb.vm).Get("parse"))
+	sb.modules.jsonParse = jsonParse
+	sb.vm.Set("require", sb.newRequire("/"))
+}
+
+// newRequire 返回在目录 dir 中解析相对路径的 require 函数
+func (sb *Sandbox) newRequire(dir string) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		arg := call.Argument(0)
+		if _, ok := arg.Export().(string); !ok {
+			panic(sb.vm.NewTypeError("模块名必须是字符串"))
+		}
+		module, err := sb.require(arg.String(), dir)
+		if err != nil {
+			panic(sb.moduleError(err))
+		}
+		return module.Get("exports")
+	}
+}
+
+// moduleError 把加载模块的错误转换为 JavaScript 异常，保留模块代码中抛出的原始异常
+// 中断和调用栈溢出原样返回，保持不可捕获
+func (sb *Sandbox) moduleError(err error) interface{} {
+	if isUncatchable(err) {
+		return err
+	}
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		return ex.Value()
+	}
+	return sb.newJSError(err)
+}
+
+// require 加载模块并返回 module 对象
+func (sb *Sandbox) require(name, dir string) (*goja.Object, error) {
+	r := sb.modules
+	if !isLocalModule(name) {
+		if module, ok := r.cache[name]; ok {
+			return module, nil
+		}
+		exports, ok := sb.namedModuleExports(name)
+		if !ok {
+			return nil, NewSandboxError(ErrCodeModuleNotFound, fmt.Sprintf("找不到模块 '%s'", name))
+		}
+		module := sb.vm.NewObject()
+		module.Set("id", name)
+		module.Set("exports", exports)
+		module.Set("loaded", true)
+		r.cache[name] = module
+		return module, nil
+	}
+
+	if r.root == nil {
+		return nil, NewSandboxError(ErrCodeModuleNotFound,
+			fmt.Sprintf("找不到模块 '%s'：未配置模块根目录 (Config.ModuleRoot)", name))
+	}
+	id, hostPath, err := r.resolveFile(path.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if hostPath == "" {
+		return nil, NewSandboxError(ErrCodeModuleNotFound, fmt.Sprintf("找不到模块 '%s'（从 %s 引用）", name, dir))
+	}
+	for i, loading := range r.loading {
+		if loading == id {
+			chain := append(append([]string{}, r.loading[i:]...), id)
+			return nil, NewSandboxError(ErrCodeModuleCycle, "检测到模块循环依赖: "+strings.Join(chain, " -> "))
+		}
+	}
+	if module, ok := r.cache[id]; ok {
+		return module, nil
+	}
+
+	src, err := os.ReadFile(hostPath)
+	if err != nil {
+		return nil, NewSandboxErrorWithCause(ErrCodeFileSystemError, "读取模块失败: "+id, err)
+	}
+	module := sb.vm.NewObject()
+	module.Set("id", id)
+	module.Set("filename", id)
+	module.Set("loaded", false)
+
+	if strings.HasSuffix(id, ".json") {
+		exports, err := r.jsonParse(goja.Undefined(), sb.vm.ToValue(string(src)))
+		if err != nil {
+			return nil, err
+		}
+		module.Set("exports", exports)
+	} else {
+		code := string(src)
+		if loader := sourceLoader(id); loader != api.LoaderJS {
+			if code, err = transpile(code, id, loader, api.FormatCommonJS); err != nil {
+				return nil, err
+			}
+		}
+		module.Set("exports", sb.vm.NewObject())
+		r.loading = append(r.loading, id)
+		err := sb.runModule(module, id, code)
+		r.loading = r.loading[:len(r.loading)-1]
+		if err != nil {
+			return nil, err
+		}
+	}
+	module.Set("loaded", true)
+	r.cache[id] = module
+	return module, nil
+}
+
+// runModule 用 CommonJS 包装函数执行本地模块代码
+func (sb *Sandbox) runModule(module *goja.Object, id, src string) error {
+	wrapper, err := sb.vm.RunScript(id, "(function (exports, require, module, __filename, __dirname) {"+src+"\n})")
+	if err != nil {
+		return err
+	}
+	fn, ok := goja.AssertFunction(wrapper)
+	if !ok {
+		return NewSandboxError(ErrCodeUnknown, "模块包装函数无效: "+id)
+	}
+	dir := path.Dir(id)
+	exports := module.Get("exports")
+	_, err = fn(exports, exports, sb.vm.ToValue(sb.newRequire(dir)), module, sb.vm.ToValue(id), sb.vm.ToValue(dir))
+	return err
+}
+
+// namedModuleExports 返回自定义模块或内置模块的 exports
+// 内置模块只包含一个对象时（如 logger）直接导出该对象，否则导出包含所有宿主函数的命名空间
+func (sb *Sandbox) namedModuleExports(name string) (goja.Value, bool) {
+	if exports, ok := sb.modules.custom[name]; ok {
+		if m, ok := exports.(map[string]interface{}); ok {
+			obj := sb.vm.NewObject()
+			for k, v := range m {
+				obj.Set(k, v)
+			}
+			return obj, true
+		}
+		return sb.vm.ToValue(exports), true
+	}
+
+	members, ok := sb.modules.builtins[name]
+	if !ok {
+		return nil, false
+	}
+	if len(members) == 1 {
+		if obj, ok := members[0].value.(*goja.Object); ok {
+			if _, isFunc := goja.AssertFunction(obj); !isFunc {
+				return obj, true
+			}
+		}
+	}
+	obj := sb.vm.NewObject()
+	for _, m := range members {
+		obj.Set(m.name, m.value)
+	}
+	return obj, true
+}
+
+// resolveFile 按 Node.js 的规则查找本地模块文件：原路径、加 .js、.json、.ts、.tsx，目录下的 index.js、index.json、index.ts
+// 返回模块的虚拟路径和宿主机路径，找不到时宿主机路径为空
+func (r *moduleRegistry) resolveFile(virtual string) (string, string, error) {
+	virtual = cleanVirtualPath(virtual)
+	candidates := []string{virtual, virtual + ".js", virtual + ".json", virtual + ".ts", virtual + ".tsx",
+		path.Join(virtual, "index.js"), path.Join(virtual, "index.json"), path.Join(virtual, "index.ts")}
+	for _, candidate := range candidates {
+		hostPath, err := r.root.resolve(candidate, fsRead)
+		if err != nil {
+			return "", "", err
+		}
+		if info, err := os.Stat(hostPath); err == nil && info.Mode().IsRegular() {
+			return candidate, hostPath, nil
+		}
+	}
+	return "", "", nil
+}
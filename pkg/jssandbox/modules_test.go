@@ -0,0 +1,199 @@
+package jssandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newModuleSandbox 创建以临时目录为模块根目录的沙盒，files 为相对路径到文件内容的映射
+func newModuleSandbox(t *testing.T, files map[string]string) *Sandbox {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithModuleRoot(root))
+	t.Cleanup(func() { sb.Close() })
+	return sb
+}
+
+func TestRequire_BuiltinModules(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	result, err := sb.Run(`[
+		require('fs').readFile === readFile,
+		typeof require('http').fetch,
+		typeof require('browser').createBrowserSession,
+		require('logger') === logger,
+		typeof require('path').pathJoin,
+		require('fs') === require('fs'),
+		typeof require('crypto').readFile
+	]`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{true, "function", "function", true, "function", true, "undefined"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRequire_DisabledBuiltin(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().DisableHTTP())
+	defer sb.Close()
+
+	result, err := sb.Run(`try { require('http'); 'loaded' } catch (e) { e.code }`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.String() != string(ErrCodeModuleNotFound) {
+		t.Errorf("require('http') = %v, want %s", result, ErrCodeModuleNotFound)
+	}
+}
+
+func TestRequire_CustomModule(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	if err := sb.RegisterModule("greeter", map[string]interface{}{
+		"greet":   func(name string) string { return "hello " + name },
+		"version": "1.0",
+	}); err != nil {
+		t.Fatalf("RegisterModule() error = %v", err)
+	}
+	if err := sb.RegisterModule("./local", nil); err == nil {
+		t.Error("相对路径不应该可以注册为模块名")
+	}
+
+	result, err := sb.Run(`var g = require('greeter'); g.greet('js') + ' ' + g.version`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.String() != "hello js 1.0" {
+		t.Errorf("result = %v", result)
+	}
+}
+
+func TestRequire_LocalModules(t *testing.T) {
+	sb := newModuleSandbox(t, map[string]string{
+		"lib/math.js":     "exports.add = function (a, b) { return a + b; };\nglobalThis.mathLoads = (globalThis.mathLoads || 0) + 1;",
+		"lib/index.js":    "module.exports = { math: require('./math'), data: require('../data.json'), dir: __dirname, file: __filename };",
+		"data.json":       `{"name": "demo", "items": [1, 2, 3]}`,
+		"main.js":         "var lib = require('./lib'); module.exports = lib.math.add(1, 2) + lib.data.items.length;",
+		"lib/sub/deep.js": "module.exports = require('../../lib/math.js').add(10, 20);",
+	})
+
+	result, err := sb.Run(`[
+		require('./main'),
+		require('./lib').dir,
+		require('./lib').file,
+		require('./lib/sub/deep'),
+		require('/lib/math') === require('./lib/math.js'),
+		mathLoads
+	]`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{int64(6), "/lib", "/lib/index.js", int64(30), true, int64(1)}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRequire_Errors(t *testing.T) {
+	sb := newModuleSandbox(t, map[string]string{
+		"a.js":      "require('./b');",
+		"b.js":      "require('./c');",
+		"c.js":      "require('./a');",
+		"broken.js": "module.exports = {",
+		"throws.js": "throw new RangeError('bad module');",
+		"uses.js":   "require('./missing');",
+	})
+
+	tests := []struct {
+		name     string
+		code     string
+		contains string
+	}{
+		{"循环依赖", `require('./a')`, "/a.js -> /b.js -> /c.js -> /a.js"},
+		{"找不到本地模块", `require('./uses')`, "找不到模块 './missing'（从 / 引用）"},
+		{"找不到命名模块", `require('lodash')`, "找不到模块 'lodash'"},
+		{"越过模块根目录", `require('../../../etc/passwd')`, "找不到模块"},
+		{"语法错误", `require('./broken')`, "SyntaxError"},
+		{"模块抛出异常", `require('./throws')`, "RangeError: bad module"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sb.Run(tt.code)
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("error = %v, 应该包含 %q", err, tt.contains)
+			}
+		})
+	}
+
+	// 加载失败的模块不会被缓存，错误可以在脚本中捕获
+	result, err := sb.Run(`try { require('./a'); } catch (e) { e.code }`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.String() != string(ErrCodeModuleCycle) {
+		t.Errorf("e.code = %v, want %s", result, ErrCodeModuleCycle)
+	}
+}
+
+func TestRequire_NoModuleRoot(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	_, err := sb.Run(`require('./lib')`)
+	if err == nil || !strings.Contains(err.Error(), "Config.ModuleRoot") {
+		t.Errorf("error = %v, 应该提示未配置模块根目录", err)
+	}
+}
+
+func TestRequire_PoolResetClearsCache(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "state.js"), []byte("module.exports = { count: 0 };"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1).WithMaxSize(1).
+		WithSandboxConfig(DefaultConfig().WithModuleRoot(root)))
+
+	for i := 0; i < 2; i++ {
+		err := pool.Do(context.Background(), func(sb *Sandbox) error {
+			result, err := sb.Run(`(function () { var s = require('./state'); s.count++; require('fs').leaked = true; return s.count; })()`)
+			if err != nil {
+				return err
+			}
+			if result.ToInteger() != 1 {
+				t.Errorf("第 %d 次借用 count = %v, want 1", i+1, result)
+			}
+			if leaked, _ := sb.Run(`(function () { return require('fs').leaked; })()`); i == 0 && leaked.Export() != true {
+				t.Errorf("同一次借用中模块应该被缓存")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+}
@@ -11,8 +11,12 @@ import (
 // registerNetwork 注册网络工具功能到JavaScript运行时
 func (sb *Sandbox) registerNetwork() {
 	// DNS解析
+	// 只返回出站策略允许访问的地址，避免脚本借此探测内网
 	sb.vm.Set("resolveDNS", func(hostname string) goja.Value {
-		ips, err := net.LookupIP(hostname)
+		if err := sb.egress.checkHostPort(hostname, 0); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		ips, err := net.DefaultResolver.LookupNetIP(sb.runContext(), "ip", hostname)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": fmt.Sprintf("DNS解析失败: %v", err),
@@ -20,8 +24,16 @@ func (sb *Sandbox) registerNetwork() {
 		}
 
 		var ipList []string
+		var policyErr error
 		for _, ip := range ips {
-			ipList = append(ipList, ip.String())
+			if err := sb.egress.checkAddr(ip); err != nil {
+				policyErr = err
+				continue
+			}
+			ipList = append(ipList, ip.Unmap().String())
+		}
+		if len(ipList) == 0 && policyErr != nil {
+			return sb.vm.ToValue(sb.errorResult(policyErr))
 		}
 
 		return sb.vm.ToValue(map[string]interface{}{
@@ -52,13 +64,22 @@ func (sb *Sandbox) registerNetwork() {
 			}
 		}
 
+		if err := sb.egress.checkHostPort(host, 80); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
 		var successCount int
 		var totalTime time.Duration
 
-		for i := 0; i < count; i++ {
+		ctx := sb.runContext()
+		dialer := sb.egress.dialer(timeout)
+		for i := 0; i < count && ctx.Err() == nil; i++ {
 			start := time.Now()
-			conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "80"), timeout)
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, "80"))
 			if err != nil {
+				if policyErr := policyError(err); policyErr != nil {
+					return sb.vm.ToValue(sb.errorResult(policyErr))
+				}
 				continue
 			}
 			conn.Close()
@@ -98,8 +119,16 @@ func (sb *Sandbox) registerNetwork() {
 			timeout = time.Duration(call.Arguments[2].ToInteger()) * time.Second
 		}
 
+		if err := sb.egress.checkHostPort(host, port); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+
 		address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-		conn, err := net.DialTimeout("tcp", address, timeout)
+		dialer := sb.egress.dialer(timeout)
+		conn, err := dialer.DialContext(sb.runContext(), "tcp", address)
+		if policyErr := policyError(err); policyErr != nil {
+			return sb.vm.ToValue(sb.errorResult(policyErr))
+		}
 
 		open := err == nil
 		if conn != nil {
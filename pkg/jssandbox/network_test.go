@@ -9,7 +9,7 @@ import (
 
 func TestResolveDNS(t *testing.T) {
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -73,7 +73,7 @@ func TestResolveDNS_Google(t *testing.T) {
 
 func TestPing(t *testing.T) {
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -101,7 +101,7 @@ func TestPing(t *testing.T) {
 
 func TestCheckPort(t *testing.T) {
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	// 测试本地回环地址的常见端口
@@ -142,7 +142,7 @@ func TestCheckPort(t *testing.T) {
 
 func TestCheckPort_Localhost(t *testing.T) {
 	ctx := context.Background()
-	sb := NewSandbox(ctx)
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
 	defer sb.Close()
 
 	code := `
@@ -0,0 +1,197 @@
+package jssandbox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+)
+
+// LogEntry 脚本通过 console 或 logger 输出的一条记录
+type LogEntry struct {
+	// Source 输出来源，"console" 或 "logger"
+	Source string
+	// Level 级别：console 为 log、info、debug、warn、error、trace（count、time、table 等按 log，assert 失败按 error），
+	// logger 为 trace、debug、info、warn、error、fatal
+	Level string
+	// Message 参数以空格连接后的文本
+	Message string
+	// Fields logger 的结构化字段，没有时为 nil
+	Fields map[string]interface{}
+	// Time 输出的时间
+	Time time.Time
+}
+
+// Output 一次执行期间捕获的脚本输出
+type Output struct {
+	// Entries 按输出顺序排列的记录
+	Entries []LogEntry
+	// Stdout、Stderr console 的输出，每条一行；与 Node.js 一致，warn、error 和 trace 写入 Stderr，其余写入 Stdout
+	Stdout string
+	Stderr string
+	// Truncated 输出超过 Config.MaxOutputSize 或 Config.MaxOutputEntries 后被截断
+	Truncated bool
+}
+
+// RunResult 执行结果和执行期间捕获的输出
+type RunResult struct {
+	// Value 执行结果，执行失败时为 nil
+	Value goja.Value
+	Output
+}
+
+// String 按输出顺序合并所有记录，每条一行：console.log 原样输出，其他级别带前缀（如 "[warn] "），
+// logger 的字段按名称排序附加在消息之后；被截断时以提示结尾
+func (o *Output) String() string {
+	var b strings.Builder
+	for _, e := range o.Entries {
+		if e.Level != "log" {
+			b.WriteString("[" + e.Level + "] ")
+		}
+		b.WriteString(e.Message)
+		keys := make([]string, 0, len(e.Fields))
+		for k := range e.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, " %s=%v", k, e.Fields[k])
+		}
+		b.WriteByte('\n')
+	}
+	if o.Truncated {
+		b.WriteString("...（输出过多，已截断）\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// outputCapture 收集一次执行的输出，超出限制后丢弃后续记录
+type outputCapture struct {
+	maxSize    int
+	maxEntries int
+	size       int
+	out        Output
+	stdout     strings.Builder
+	stderr     strings.Builder
+}
+
+func newOutputCapture(config *Config) *outputCapture {
+	return &outputCapture{maxSize: config.MaxOutputSize, maxEntries: config.MaxOutputEntries}
+}
+
+// add 记录一条输出，消息的总字节数超过限制时截断当前消息并停止记录
+func (c *outputCapture) add(e LogEntry) {
+	if c.out.Truncated {
+		return
+	}
+	if c.maxEntries > 0 && len(c.out.Entries) >= c.maxEntries {
+		c.out.Truncated = true
+		return
+	}
+	if c.maxSize > 0 && c.size+len(e.Message) > c.maxSize {
+		e.Message = truncateUTF8(e.Message, c.maxSize-c.size)
+		c.out.Truncated = true
+	}
+	c.size += len(e.Message)
+	c.out.Entries = append(c.out.Entries, e)
+	if e.Source == "console" {
+		w := &c.stdout
+		if e.Level == "warn" || e.Level == "error" || e.Level == "trace" {
+			w = &c.stderr
+		}
+		w.WriteString(e.Message + "\n")
+	}
+}
+
+// result 返回捕获的输出
+func (c *outputCapture) result() Output {
+	out := c.out
+	out.Stdout, out.Stderr = c.stdout.String(), c.stderr.String()
+	return out
+}
+
+// truncateUTF8 截取 s 的前 n 个字节，不截断多字节字符
+func truncateUTF8(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// scriptLogLevels 脚本输出的级别对应的 logrus 级别，fatal 不会退出程序，按 error 记录
+var scriptLogLevels = map[string]logrus.Level{
+	"trace": logrus.TraceLevel,
+	"debug": logrus.DebugLevel,
+	"log":   logrus.InfoLevel,
+	"info":  logrus.InfoLevel,
+	"warn":  logrus.WarnLevel,
+	"error": logrus.ErrorLevel,
+	"fatal": logrus.ErrorLevel,
+}
+
+// logScript 把脚本的 console/logger 输出写入沙盒日志，正在捕获输出时（见 RunWithOutput）同时记录到本次执行的结果中
+// logger 的输出受日志级别控制，console 的输出总是被捕获
+func (sb *Sandbox) logScript(source, level string, fields logrus.Fields, msg string) {
+	lvl := scriptLogLevels[level]
+	entry := logrus.NewEntry(sb.logger)
+	if len(fields) > 0 {
+		entry = entry.WithFields(fields)
+	}
+	if level == "fatal" {
+		entry.Log(lvl, strings.TrimSpace("FATAL: "+msg))
+	} else {
+		entry.Log(lvl, msg)
+	}
+
+	if sb.output == nil || (source == "logger" && !sb.logger.IsLevelEnabled(lvl)) {
+		return
+	}
+	e := LogEntry{Source: source, Level: level, Message: msg, Time: time.Now()}
+	if len(fields) > 0 {
+		e.Fields = make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			e.Fields[k] = v
+		}
+	}
+	sb.output.add(e)
+}
+
+// RunWithOutput 与 RunWithTimeout 相同，同时返回执行期间 console 和 logger 的输出
+// 执行失败时仍返回已捕获的输出，便于查看出错前脚本打印的内容
+func (sb *Sandbox) RunWithOutput(code string, timeout time.Duration) (*RunResult, error) {
+	return exclusive(sb, "RunWithOutput", func(sb *Sandbox) (*RunResult, error) {
+		return sb.captureOutput(func() (goja.Value, error) {
+			return sb.runWithTimeout(code, timeout)
+		})
+	})
+}
+
+// RunModuleWithOutput 与 RunModuleWithTimeout 相同，同时返回执行期间的输出，Value 为模块的命名空间对象
+func (sb *Sandbox) RunModuleWithOutput(code string, timeout time.Duration) (*RunResult, error) {
+	return exclusive(sb, "RunModuleWithOutput", func(sb *Sandbox) (*RunResult, error) {
+		return sb.captureOutput(func() (goja.Value, error) {
+			ns, err := sb.runModuleWithTimeout(code, timeout)
+			if err != nil {
+				return nil, err
+			}
+			return ns, nil
+		})
+	})
+}
+
+// captureOutput 在 exec 执行期间捕获脚本输出
+func (sb *Sandbox) captureOutput(exec func() (goja.Value, error)) (*RunResult, error) {
+	prev := sb.output
+	sb.output = newOutputCapture(sb.config)
+	defer func() { sb.output = prev }()
+
+	value, err := exec()
+	return &RunResult{Value: value, Output: sb.output.result()}, err
+}
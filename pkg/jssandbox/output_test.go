@@ -0,0 +1,86 @@
+package jssandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunWithOutput(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	res, err := sb.RunWithOutput(`
+		console.log('hello', 1, null);
+		console.error('bad');
+		logger.info({ user: 'bob' }, 'login');
+		logger.debug('隐藏');
+		logger.withFields({ id: 7 }).warn('slow');
+		42
+	`, 0)
+	if err != nil {
+		t.Fatalf("RunWithOutput() error = %v", err)
+	}
+	if res.Value.ToInteger() != 42 {
+		t.Errorf("Value = %v, want 42", res.Value)
+	}
+	if res.Stdout != "hello 1 null\n" || res.Stderr != "bad\n" {
+		t.Errorf("Stdout = %q, Stderr = %q", res.Stdout, res.Stderr)
+	}
+	want := "hello 1 null\n[error] bad\n[info] login user=bob\n[warn] slow id=7"
+	if got := res.Output.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if len(res.Entries) != 4 || res.Entries[2].Source != "logger" || res.Entries[2].Fields["user"] != "bob" || res.Entries[0].Time.IsZero() {
+		t.Errorf("Entries = %+v", res.Entries)
+	}
+
+	// 输出只属于当次执行
+	res, err = sb.RunWithOutput(`console.log('second')`, 0)
+	if err != nil || res.Stdout != "second\n" {
+		t.Errorf("第二次执行 Stdout = %q, err = %v", res.Stdout, err)
+	}
+	if sb.output != nil {
+		t.Error("执行结束后应该停止捕获")
+	}
+}
+
+func TestRunWithOutput_Error(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	res, err := sb.RunWithOutput(`console.log('before'); throw new Error('boom');`, 0)
+	if err == nil {
+		t.Fatal("期望返回错误")
+	}
+	if res == nil || res.Value != nil || res.Stdout != "before\n" {
+		t.Errorf("出错时应该返回已捕获的输出: %+v", res)
+	}
+
+	res, err = sb.RunModuleWithOutput(`console.log('module'); export default 1;`, 0)
+	if err != nil || res.Stdout != "module\n" {
+		t.Errorf("RunModuleWithOutput() Stdout = %q, err = %v", res.Stdout, err)
+	}
+}
+
+func TestRunWithOutput_Truncate(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithMaxOutputSize(10).WithMaxOutputEntries(3))
+	defer sb.Close()
+
+	res, err := sb.RunWithOutput(`console.log('12345'); console.log('中文字符');`, 0)
+	if err != nil {
+		t.Fatalf("RunWithOutput() error = %v", err)
+	}
+	// 第二条只剩 5 个字节，不截断多字节字符
+	if !res.Truncated || res.Stdout != "12345\n中\n" {
+		t.Errorf("Truncated = %v, Stdout = %q", res.Truncated, res.Stdout)
+	}
+	if !strings.HasSuffix(res.Output.String(), "已截断）") {
+		t.Errorf("String() = %q, 应该提示截断", res.Output.String())
+	}
+
+	res, _ = sb.RunWithOutput(`for (var i = 0; i < 5; i++) console.log(i);`, 0)
+	if !res.Truncated || len(res.Entries) != 3 {
+		t.Errorf("Truncated = %v, Entries = %d, want 3", res.Truncated, len(res.Entries))
+	}
+}
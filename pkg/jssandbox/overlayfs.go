@@ -0,0 +1,444 @@
+package jssandbox
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// OverlayFileSystem 写时复制的叠加文件系统
+//
+// 底层是只读的宿主机目录，上层是内存文件系统。读取时上层优先；写入前把文件从底层复制到上层，
+// 删除底层文件时只记录删除标记，宿主机目录始终不会被修改。
+// 底层路径的解析与 FileSystemRoot 相同：".." 截断在根目录，指向目录之外的符号链接被拒绝。
+type OverlayFileSystem struct {
+	mu    sync.Mutex
+	lower *fsJail
+	upper *MemFileSystem
+	// removed 删除标记，底层中该路径及其子路径不可见
+	removed map[string]bool
+	// opaque 被删除后重新创建的目录，底层中的子项不可见
+	opaque map[string]bool
+}
+
+// NewOverlayFileSystem 创建以宿主机目录 hostDir 为只读底层的叠加文件系统
+func NewOverlayFileSystem(hostDir string) *OverlayFileSystem {
+	return &OverlayFileSystem{
+		lower: &fsJail{mounts: []jailMount{
+			{virtual: "/", host: realHostPath(hostDir), readOnly: true},
+		}},
+		upper:   NewMemFileSystem(),
+		removed: make(map[string]bool),
+		opaque:  make(map[string]bool),
+	}
+}
+
+// Upper 返回保存所有修改的上层内存文件系统，可用于查看或导出脚本写入的文件
+func (o *OverlayFileSystem) Upper() *MemFileSystem {
+	return o.upper
+}
+
+// Removed 返回在底层中被删除的虚拟路径，按路径排序
+func (o *OverlayFileSystem) Removed() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var paths []string
+	for p := range o.removed {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// lowerHidden 判断底层中的路径是否被删除标记或不透明目录遮盖，调用方需持有锁
+func (o *OverlayFileSystem) lowerHidden(p string) bool {
+	for cur := p; ; cur = path.Dir(cur) {
+		if o.removed[cur] || (cur != p && o.opaque[cur]) {
+			return true
+		}
+		if cur == "/" {
+			return false
+		}
+	}
+}
+
+// lowerStat 返回底层中可见的文件信息，调用方需持有锁
+func (o *OverlayFileSystem) lowerStat(name, p string) (os.FileInfo, error) {
+	if o.lowerHidden(p) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	hostPath, err := o.lower.resolve(p, fsRead)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(hostPath)
+}
+
+// stat 返回合并视图中的文件信息，调用方需持有锁
+func (o *OverlayFileSystem) stat(name, p string) (os.FileInfo, error) {
+	if info, err := o.upper.Stat(p); err == nil {
+		return info, nil
+	}
+	return o.lowerStat(name, p)
+}
+
+// unhide 在上层创建路径后清除删除标记，重新创建的路径不再显示底层内容，调用方需持有锁
+func (o *OverlayFileSystem) unhide(p string) {
+	if o.removed[p] {
+		delete(o.removed, p)
+		o.opaque[p] = true
+	}
+}
+
+// copyUpDir 确保目录在上层存在，调用方需持有锁
+func (o *OverlayFileSystem) copyUpDir(name, p string) error {
+	if info, err := o.upper.Stat(p); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	info, err := o.lowerStat(name, p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	if err := o.copyUpDir(name, path.Dir(p)); err != nil {
+		return err
+	}
+	return o.upper.Mkdir(p, info.Mode().Perm())
+}
+
+// copyUp 把底层文件或目录树复制到上层，调用方需持有锁
+func (o *OverlayFileSystem) copyUp(name, p string) error {
+	if info, err := o.upper.Stat(p); err == nil && !info.IsDir() {
+		return nil
+	}
+	info, err := o.stat(name, p)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := o.copyUpDir(name, p); err != nil {
+			return err
+		}
+		entries, err := o.readDir(name, p)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := o.copyUp(name, path.Join(p, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := o.copyUpDir(name, path.Dir(p)); err != nil {
+		return err
+	}
+	hostPath, err := o.lower.resolve(p, fsRead)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(hostPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := o.upper.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Open 以只读方式打开文件
+func (o *OverlayFileSystem) Open(name string) (File, error) {
+	return o.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile 按 os.O_* 标志打开文件，写入底层文件前先复制到上层
+func (o *OverlayFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	p := cleanVirtualPath(name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		if _, err := o.upper.Stat(p); err == nil {
+			return o.upper.OpenFile(name, flag, perm)
+		}
+		if _, err := o.lowerStat(name, p); err != nil {
+			return nil, err
+		}
+		hostPath, err := o.lower.resolve(p, fsRead)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(hostPath)
+		if err != nil {
+			return nil, err
+		}
+		return &overlayLowerFile{File: f, name: name}, nil
+	}
+
+	info, err := o.stat(name, p)
+	switch {
+	case err == nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && info.IsDir():
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case err == nil && flag&os.O_TRUNC == 0:
+		if err := o.copyUp(name, p); err != nil {
+			return nil, err
+		}
+	case err != nil && !os.IsNotExist(err):
+		return nil, err
+	case err != nil && flag&os.O_CREATE == 0:
+		return nil, err
+	}
+
+	if err := o.copyUpDir(name, path.Dir(p)); err != nil {
+		return nil, err
+	}
+	f, err := o.upper.OpenFile(name, flag|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+	o.unhide(p)
+	return f, nil
+}
+
+// Stat 返回文件信息
+func (o *OverlayFileSystem) Stat(name string) (os.FileInfo, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.stat(name, cleanVirtualPath(name))
+}
+
+// readDir 合并上层和底层的目录项，调用方需持有锁
+func (o *OverlayFileSystem) readDir(name, p string) ([]os.DirEntry, error) {
+	info, err := o.stat(name, p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	merged := make(map[string]os.DirEntry)
+	if !o.opaque[p] {
+		if lowerInfo, err := o.lowerStat(name, p); err == nil && lowerInfo.IsDir() {
+			hostPath, err := o.lower.resolve(p, fsRead)
+			if err != nil {
+				return nil, err
+			}
+			entries, err := os.ReadDir(hostPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if !o.lowerHidden(path.Join(p, entry.Name())) {
+					merged[entry.Name()] = entry
+				}
+			}
+		}
+	}
+	if upperEntries, err := o.upper.ReadDir(p); err == nil {
+		for _, entry := range upperEntries {
+			merged[entry.Name()] = entry
+		}
+	}
+
+	entries := make([]os.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadDir 返回合并后按名称排序的目录项
+func (o *OverlayFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.readDir(name, cleanVirtualPath(name))
+}
+
+// Mkdir 创建目录，父目录必须存在
+func (o *OverlayFileSystem) Mkdir(name string, perm os.FileMode) error {
+	p := cleanVirtualPath(name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.stat(name, p); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := o.copyUpDir(name, path.Dir(p)); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(p, perm); err != nil {
+		return err
+	}
+	o.unhide(p)
+	return nil
+}
+
+// MkdirAll 递归创建目录
+func (o *OverlayFileSystem) MkdirAll(name string, perm os.FileMode) error {
+	p := cleanVirtualPath(name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.mkdirAll(name, p, perm)
+}
+
+// mkdirAll 递归创建目录，调用方需持有锁
+func (o *OverlayFileSystem) mkdirAll(name, p string, perm os.FileMode) error {
+	if info, err := o.stat(name, p); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if err := o.mkdirAll(name, path.Dir(p), perm); err != nil {
+		return err
+	}
+	if err := o.copyUpDir(name, path.Dir(p)); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(p, perm); err != nil {
+		return err
+	}
+	o.unhide(p)
+	return nil
+}
+
+// remove 删除路径并在底层中留下删除标记，调用方需持有锁
+func (o *OverlayFileSystem) remove(p string) {
+	o.upper.RemoveAll(p)
+	if _, err := o.lowerStat(p, p); err == nil {
+		o.removed[p] = true
+	}
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	for q := range o.removed {
+		if strings.HasPrefix(q, prefix) {
+			delete(o.removed, q)
+		}
+	}
+	for q := range o.opaque {
+		if q == p || strings.HasPrefix(q, prefix) {
+			delete(o.opaque, q)
+		}
+	}
+}
+
+// Remove 删除文件或空目录
+func (o *OverlayFileSystem) Remove(name string) error {
+	p := cleanVirtualPath(name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if p == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	info, err := o.stat(name, p)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := o.readDir(name, p)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	o.remove(p)
+	return nil
+}
+
+// RemoveAll 递归删除，路径不存在时不返回错误
+func (o *OverlayFileSystem) RemoveAll(name string) error {
+	p := cleanVirtualPath(name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if p == "/" {
+		return &os.PathError{Op: "removeall", Path: name, Err: fs.ErrPermission}
+	}
+	if _, err := o.stat(name, p); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	o.remove(p)
+	return nil
+}
+
+// Rename 重命名或移动文件、目录，底层内容先复制到上层再移动
+func (o *OverlayFileSystem) Rename(oldname, newname string) error {
+	oldPath, newPath := cleanVirtualPath(oldname), cleanVirtualPath(newname)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if oldPath == newPath {
+		_, err := o.stat(oldname, oldPath)
+		return err
+	}
+	if oldPath == "/" || pathWithin(newPath, oldPath) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	if err := o.copyUp(oldname, oldPath); err != nil {
+		return err
+	}
+	if err := o.copyUpDir(newname, path.Dir(newPath)); err != nil {
+		return err
+	}
+	if target, err := o.stat(newname, newPath); err == nil {
+		source, err := o.upper.Stat(oldPath)
+		if err != nil {
+			return err
+		}
+		switch {
+		case target.IsDir() && !source.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EISDIR}
+		case !target.IsDir() && source.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.ENOTDIR}
+		case target.IsDir():
+			entries, err := o.readDir(newname, newPath)
+			if err != nil {
+				return err
+			}
+			if len(entries) > 0 {
+				return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.ENOTEMPTY}
+			}
+			o.upper.RemoveAll(newPath)
+		}
+	}
+	if err := o.upper.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	o.remove(oldPath)
+	if _, err := o.lowerStat(newname, newPath); err == nil || o.removed[newPath] {
+		delete(o.removed, newPath)
+		o.opaque[newPath] = true
+	}
+	return nil
+}
+
+// overlayLowerFile 从底层只读打开的文件，Name 返回虚拟路径而不是宿主机路径
+type overlayLowerFile struct {
+	*os.File
+	name string
+}
+
+func (f *overlayLowerFile) Name() string { return f.name }
@@ -59,6 +59,14 @@ func (sb *Sandbox) registerPath() {
 
 	// 获取绝对路径
 	sb.vm.Set("pathAbs", func(path string) goja.Value {
+		// 使用虚拟根目录时返回沙盒内的虚拟绝对路径
+		if sb.hasVirtualRoot() {
+			return sb.vm.ToValue(map[string]interface{}{
+				"success": true,
+				"path":    cleanVirtualPath(path),
+			})
+		}
+
 		abs, err := filepath.Abs(path)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -1,12 +1,17 @@
 package jssandbox
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/dop251/goja"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
@@ -14,12 +19,20 @@ import (
 func (sb *Sandbox) registerDocuments() {
 	// 获取PDF页数
 	sb.vm.Set("pdfGetPageCount", func(filePath string) goja.Value {
-		n, err := api.PageCountFile(filePath)
+		filePath, err := sb.resolvePath(filePath, fsRead)
 		if err != nil {
-			return sb.vm.ToValue(map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			})
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		if err := sb.checkFileRead(filePath); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
+		var n int
+		err = sb.withPDF(filePath, func(rs io.ReadSeeker) (err error) {
+			n, err = api.PageCount(rs, nil)
+			return err
+		})
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
 		}
 		return sb.vm.ToValue(map[string]interface{}{
 			"success": true,
@@ -28,13 +41,25 @@ func (sb *Sandbox) registerDocuments() {
 	})
 
 	// 合并PDF
-	sb.vm.Set("pdfMerge", func(inFiles []string, outFile string) map[string]interface{} {
-		err := api.MergeCreateFile(inFiles, outFile, false, nil)
+	sb.vm.Set("pdfMerge", func(inFiles []string, outFile string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "pdf", Operation: "pdfMerge", Target: outFile, Details: map[string]interface{}{"source": inFiles}}, &result)
+		inFiles, err := sb.resolvePaths(inFiles, fsRead)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		outFile, err = sb.resolvePath(outFile, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileReads(inFiles...); err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkOutputName(outFile); err != nil {
+			return sb.errorResult(err)
+		}
+		err = sb.mergePDF(inFiles, outFile)
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
@@ -42,20 +67,29 @@ func (sb *Sandbox) registerDocuments() {
 	})
 
 	// 拆分PDF (每页一个文件)
-	sb.vm.Set("pdfSplit", func(inFile string, outDir string) map[string]interface{} {
+	sb.vm.Set("pdfSplit", func(inFile string, outDir string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "pdf", Operation: "pdfSplit", Target: outDir, Details: map[string]interface{}{"source": inFile}}, &result)
+		inFile, err := sb.resolvePath(inFile, fsRead)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		outDir, err = sb.resolvePath(outDir, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
 		// 确保输出目录存在
-		if err := os.MkdirAll(outDir, 0755); err != nil {
+		if err := sb.fs.MkdirAll(outDir, 0755); err != nil {
 			return map[string]interface{}{
 				"success": false,
 				"error":   fmt.Sprintf("创建输出目录失败: %v", err),
 			}
 		}
-		err := api.SplitFile(inFile, outDir, 1, nil)
+		if err := sb.checkFileRead(inFile); err != nil {
+			return sb.errorResult(err)
+		}
+		err = sb.splitPDF(inFile, outDir)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
@@ -64,19 +98,28 @@ func (sb *Sandbox) registerDocuments() {
 
 	// 提取指定页面
 	// pages: []string, e.g. ["1", "2-5", "8"]
-	sb.vm.Set("pdfExtractPages", func(inFile string, outDir string, pages []string) map[string]interface{} {
-		if err := os.MkdirAll(outDir, 0755); err != nil {
+	sb.vm.Set("pdfExtractPages", func(inFile string, outDir string, pages []string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "pdf", Operation: "pdfExtractPages", Target: outDir, Details: map[string]interface{}{"source": inFile, "pages": pages}}, &result)
+		inFile, err := sb.resolvePath(inFile, fsRead)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		outDir, err = sb.resolvePath(outDir, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.fs.MkdirAll(outDir, 0755); err != nil {
 			return map[string]interface{}{
 				"success": false,
 				"error":   fmt.Sprintf("创建输出目录失败: %v", err),
 			}
 		}
-		err := api.ExtractPagesFile(inFile, outDir, pages, nil)
+		if err := sb.checkFileRead(inFile); err != nil {
+			return sb.errorResult(err)
+		}
+		err = sb.extractPDFPages(inFile, outDir, pages)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
@@ -84,13 +127,29 @@ func (sb *Sandbox) registerDocuments() {
 	})
 
 	// 优化PDF
-	sb.vm.Set("pdfOptimize", func(inFile string, outFile string) map[string]interface{} {
-		err := api.OptimizeFile(inFile, outFile, nil)
+	sb.vm.Set("pdfOptimize", func(inFile string, outFile string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "pdf", Operation: "pdfOptimize", Target: outFile, Details: map[string]interface{}{"source": inFile}}, &result)
+		inFile, err := sb.resolvePath(inFile, fsRead)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		outFile, err = sb.resolvePath(outFile, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileRead(inFile); err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkOutputName(outFile); err != nil {
+			return sb.errorResult(err)
+		}
+		err = sb.withPDF(inFile, func(rs io.ReadSeeker) error {
+			return sb.writePDF(outFile, func(w io.Writer) error {
+				return api.Optimize(rs, w, nil)
+			})
+		})
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
@@ -99,12 +158,18 @@ func (sb *Sandbox) registerDocuments() {
 
 	// 验证PDF
 	sb.vm.Set("pdfValidate", func(inFile string) map[string]interface{} {
-		err := api.ValidateFile(inFile, nil)
+		inFile, err := sb.resolvePath(inFile, fsRead)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileRead(inFile); err != nil {
+			return sb.errorResult(err)
+		}
+		err = sb.withPDF(inFile, func(rs io.ReadSeeker) error {
+			return api.Validate(rs, nil)
+		})
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
@@ -113,7 +178,23 @@ func (sb *Sandbox) registerDocuments() {
 
 	// 添加文本水印
 	// options: { onTop: true, opacity: 0.5, scale: 0.5, rotation: 45 }
-	sb.vm.Set("pdfAddTextWatermark", func(inFile, outFile string, text string, options map[string]interface{}) map[string]interface{} {
+	sb.vm.Set("pdfAddTextWatermark", func(inFile, outFile string, text string, options map[string]interface{}) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "pdf", Operation: "pdfAddTextWatermark", Target: outFile, Details: map[string]interface{}{"source": inFile}}, &result)
+		inFile, err := sb.resolvePath(inFile, fsRead)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		outFile, err = sb.resolvePath(outFile, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileRead(inFile); err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkOutputName(outFile); err != nil {
+			return sb.errorResult(err)
+		}
+
 		wm, err := pdfcpu.ParseTextWatermarkDetails(text, "", true, types.POINTS)
 		if err != nil {
 			return map[string]interface{}{
@@ -136,12 +217,13 @@ func (sb *Sandbox) registerDocuments() {
 			wm.Rotation = rotation
 		}
 
-		err = api.AddWatermarksFile(inFile, outFile, nil, wm, nil)
+		err = sb.withPDF(inFile, func(rs io.ReadSeeker) error {
+			return sb.writePDF(outFile, func(w io.Writer) error {
+				return api.AddWatermarks(rs, w, nil, wm, nil)
+			})
+		})
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
@@ -149,19 +231,28 @@ func (sb *Sandbox) registerDocuments() {
 	})
 
 	// 导出图片
-	sb.vm.Set("pdfExportImages", func(inFile string, outDir string) map[string]interface{} {
-		if err := os.MkdirAll(outDir, 0755); err != nil {
+	sb.vm.Set("pdfExportImages", func(inFile string, outDir string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "pdf", Operation: "pdfExportImages", Target: outDir, Details: map[string]interface{}{"source": inFile}}, &result)
+		inFile, err := sb.resolvePath(inFile, fsRead)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		outDir, err = sb.resolvePath(outDir, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.fs.MkdirAll(outDir, 0755); err != nil {
 			return map[string]interface{}{
 				"success": false,
 				"error":   fmt.Sprintf("创建输出目录失败: %v", err),
 			}
 		}
-		err := api.ExtractImagesFile(inFile, outDir, nil, nil)
+		if err := sb.checkFileRead(inFile); err != nil {
+			return sb.errorResult(err)
+		}
+		err = sb.extractPDFImages(inFile, outDir)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
@@ -169,16 +260,179 @@ func (sb *Sandbox) registerDocuments() {
 	})
 
 	// 将图片导入为PDF
-	sb.vm.Set("pdfImportImages", func(imgFiles []string, outFile string) map[string]interface{} {
-		err := api.ImportImagesFile(imgFiles, outFile, nil, nil)
+	sb.vm.Set("pdfImportImages", func(imgFiles []string, outFile string) (result map[string]interface{}) {
+		defer sb.auditMap(AuditEvent{Module: "pdf", Operation: "pdfImportImages", Target: outFile, Details: map[string]interface{}{"source": imgFiles}}, &result)
+		imgFiles, err := sb.resolvePaths(imgFiles, fsRead)
 		if err != nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}
+			return sb.errorResult(err)
+		}
+		outFile, err = sb.resolvePath(outFile, fsWrite)
+		if err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkFileReads(imgFiles...); err != nil {
+			return sb.errorResult(err)
+		}
+		if err := sb.checkOutputName(outFile); err != nil {
+			return sb.errorResult(err)
+		}
+		err = sb.importPDFImages(imgFiles, outFile)
+		if err != nil {
+			return sb.errorResult(err)
 		}
 		return map[string]interface{}{
 			"success": true,
 		}
 	})
 }
+
+// withPDF 从沙盒文件系统打开 PDF 文件并交给 fn 处理
+func (sb *Sandbox) withPDF(inFile string, fn func(rs io.ReadSeeker) error) error {
+	f, err := sb.fs.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn(f)
+}
+
+// writePDF 把 write 生成的内容写入沙盒文件系统
+// 先写入内存缓冲区，输入和输出为同一文件时也不会破坏源文件；内容不符合文件策略时不写入
+func (sb *Sandbox) writePDF(outFile string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return sb.writeCheckedFile(outFile, buf.Bytes())
+}
+
+// writePDFReader 把 r 的内容写入沙盒文件系统
+func (sb *Sandbox) writePDFReader(outFile string, r io.Reader) error {
+	return sb.writePDF(outFile, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// mergePDF 合并多个 PDF 文件
+func (sb *Sandbox) mergePDF(inFiles []string, outFile string) error {
+	if len(inFiles) == 0 {
+		return fmt.Errorf("需要提供至少一个PDF文件")
+	}
+	var rsc []io.ReadSeeker
+	for _, inFile := range inFiles {
+		f, err := sb.fs.Open(inFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		rsc = append(rsc, f)
+	}
+	return sb.writePDF(outFile, func(w io.Writer) error {
+		return api.MergeRaw(rsc, w, false, nil)
+	})
+}
+
+// splitPDF 按单页拆分 PDF，输出文件名与 api.SplitFile 相同：<名称>_<页码>.pdf
+func (sb *Sandbox) splitPDF(inFile, outDir string) error {
+	return sb.withPDF(inFile, func(rs io.ReadSeeker) error {
+		spans, err := api.SplitRaw(rs, 1, nil)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(inFile), ".pdf")
+		for _, span := range spans {
+			fileName := fmt.Sprintf("%s_%d.pdf", name, span.From)
+			if span.From != span.Thru {
+				fileName = fmt.Sprintf("%s_%d-%d.pdf", name, span.From, span.Thru)
+			}
+			if err := sb.writePDFReader(filepath.Join(outDir, fileName), span.Reader); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// extractPDFPages 提取指定页面，输出文件名与 api.ExtractPagesFile 相同：<名称>_page_<页码>.pdf
+func (sb *Sandbox) extractPDFPages(inFile, outDir string, selectedPages []string) error {
+	return sb.withPDF(inFile, func(rs io.ReadSeeker) error {
+		conf := model.NewDefaultConfiguration()
+		conf.Cmd = model.EXTRACTPAGES
+		ctx, err := api.ReadValidateAndOptimize(rs, conf)
+		if err != nil {
+			return err
+		}
+		pages, err := api.PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(inFile), ".pdf")
+		for _, pageNr := range selectedPageNumbers(pages) {
+			r, err := api.ExtractPage(ctx, pageNr)
+			if err != nil {
+				return err
+			}
+			fileName := fmt.Sprintf("%s_page_%d.pdf", name, pageNr)
+			if err := sb.writePDFReader(filepath.Join(outDir, fileName), r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// extractPDFImages 导出 PDF 中的图片，输出文件名与 api.ExtractImagesFile 相同
+func (sb *Sandbox) extractPDFImages(inFile, outDir string) error {
+	name := strings.TrimSuffix(filepath.Base(inFile), ".pdf")
+	return sb.withPDF(inFile, func(rs io.ReadSeeker) error {
+		return api.ExtractImages(rs, nil, func(img model.Image, singleImgPerPage bool, maxPageDigits int) error {
+			if img.Reader == nil {
+				return nil
+			}
+			qual := img.Name
+			if img.Thumb {
+				qual = "thumb"
+			}
+			fileName := fmt.Sprintf("%s_%0*d_%s.%s", name, maxPageDigits, img.PageNr, qual, img.FileType)
+			return sb.writePDFReader(filepath.Join(outDir, fileName), img)
+		}, nil)
+	})
+}
+
+// importPDFImages 把图片导入为 PDF，outFile 已存在时追加页面，与 api.ImportImagesFile 相同
+func (sb *Sandbox) importPDFImages(imgFiles []string, outFile string) error {
+	var imgs []io.Reader
+	for _, imgFile := range imgFiles {
+		f, err := sb.fs.Open(imgFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		imgs = append(imgs, f)
+	}
+
+	var rs io.ReadSeeker
+	if info, err := sb.fs.Stat(outFile); err == nil && !info.IsDir() {
+		data, err := readFileFS(sb.fs, outFile)
+		if err != nil {
+			return err
+		}
+		rs = bytes.NewReader(data)
+	}
+	return sb.writePDF(outFile, func(w io.Writer) error {
+		return api.ImportImages(rs, w, imgs, nil, nil)
+	})
+}
+
+// selectedPageNumbers 返回选中的页码，按升序排列
+func selectedPageNumbers(pages types.IntSet) []int {
+	var pageNrs []int
+	for pageNr, selected := range pages {
+		if selected {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+	sort.Ints(pageNrs)
+	return pageNrs
+}
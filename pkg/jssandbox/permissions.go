@@ -0,0 +1,335 @@
+package jssandbox
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PermissionKind 权限类型
+type PermissionKind string
+
+const (
+	// PermRead 读取文件
+	PermRead PermissionKind = "read"
+	// PermWrite 创建、修改、删除文件
+	PermWrite PermissionKind = "write"
+	// PermNet 访问网络主机（HTTP、fetch、浏览器和网络工具）
+	PermNet PermissionKind = "net"
+	// PermRun 执行外部命令
+	PermRun PermissionKind = "run"
+	// PermEnv 读取环境变量
+	PermEnv PermissionKind = "env"
+	// PermKill 终止进程
+	PermKill PermissionKind = "kill"
+	// PermSysInfo 获取 CPU、内存、磁盘和进程列表等系统信息
+	PermSysInfo PermissionKind = "sysinfo"
+)
+
+// PermissionRequest 一次被拒绝的权限请求，传给 Permissions.Prompt
+type PermissionRequest struct {
+	// Kind 权限类型
+	Kind PermissionKind
+	// Target 访问目标：路径、主机（带端口时为 host:port）、命令、环境变量名或进程 ID，
+	// 系统信息权限为函数名
+	Target string
+}
+
+// Permissions 宿主函数的权限集合，思路与 Deno 的 --allow-* 参数相同
+//
+// Config.Permissions 为 nil 时不做限制；设置后未列出的访问一律拒绝，宿主函数在每次调用时检查。
+// 列表中的 "*" 表示允许全部。注意：允许执行命令相当于放开了所有限制。
+type Permissions struct {
+	// Read 允许读取的路径，目录包含其下所有文件
+	Read []string
+	// Write 允许写入的路径，目录包含其下所有文件
+	Write []string
+	// Net 允许访问的主机，支持 "example.com"、"*.example.com"、"example.com:443"
+	Net []string
+	// Run 允许执行的命令，可以是命令名或绝对路径
+	Run []string
+	// Env 允许读取的环境变量，支持 "APP_*" 前缀匹配
+	Env []string
+	// Kill 是否允许终止进程
+	Kill bool
+	// SysInfo 是否允许获取系统信息
+	SysInfo bool
+	// Prompt 权限不足时调用，返回 true 表示允许本次访问，可用于向用户询问或动态审批。
+	// 可能在多个 goroutine 中并发调用
+	Prompt func(req PermissionRequest) bool
+}
+
+// NewPermissions 创建不包含任何权限的权限集合
+func NewPermissions() *Permissions {
+	return &Permissions{}
+}
+
+// AllowRead 添加允许读取的路径
+func (p *Permissions) AllowRead(paths ...string) *Permissions {
+	p.Read = append(p.Read, paths...)
+	return p
+}
+
+// AllowWrite 添加允许写入的路径
+func (p *Permissions) AllowWrite(paths ...string) *Permissions {
+	p.Write = append(p.Write, paths...)
+	return p
+}
+
+// AllowNet 添加允许访问的主机
+func (p *Permissions) AllowNet(hosts ...string) *Permissions {
+	p.Net = append(p.Net, hosts...)
+	return p
+}
+
+// AllowRun 添加允许执行的命令
+func (p *Permissions) AllowRun(commands ...string) *Permissions {
+	p.Run = append(p.Run, commands...)
+	return p
+}
+
+// AllowEnv 添加允许读取的环境变量
+func (p *Permissions) AllowEnv(names ...string) *Permissions {
+	p.Env = append(p.Env, names...)
+	return p
+}
+
+// AllowKill 允许终止进程
+func (p *Permissions) AllowKill() *Permissions {
+	p.Kill = true
+	return p
+}
+
+// AllowSysInfo 允许获取系统信息
+func (p *Permissions) AllowSysInfo() *Permissions {
+	p.SysInfo = true
+	return p
+}
+
+// WithPrompt 设置权限不足时的审批回调
+func (p *Permissions) WithPrompt(prompt func(req PermissionRequest) bool) *Permissions {
+	p.Prompt = prompt
+	return p
+}
+
+// netPermission 已解析的主机权限
+type netPermission struct {
+	host string
+	port int // 0 表示任意端口
+}
+
+// permissionSet 编译后的权限集合
+type permissionSet struct {
+	read     []string
+	write    []string
+	net      []netPermission
+	run      []string
+	env      []string
+	kill     bool
+	sysInfo  bool
+	prompt   func(req PermissionRequest) bool
+	virtual  bool // 路径是否为以 "/" 为根的虚拟路径
+	allowAll map[PermissionKind]bool
+}
+
+// newPermissionSet 编译权限配置，未配置时返回 nil（不做限制）
+// 宿主机路径会展开符号链接，与访问时解析出的真实路径比较
+func newPermissionSet(config *Config, virtual bool) *permissionSet {
+	p := config.Permissions
+	if p == nil {
+		return nil
+	}
+	ps := &permissionSet{
+		run:      p.Run,
+		env:      p.Env,
+		kill:     p.Kill,
+		sysInfo:  p.SysInfo,
+		prompt:   p.Prompt,
+		virtual:  virtual,
+		allowAll: make(map[PermissionKind]bool),
+	}
+	lists := map[PermissionKind][]string{
+		PermRead: p.Read, PermWrite: p.Write, PermNet: p.Net, PermRun: p.Run, PermEnv: p.Env,
+	}
+	for kind, list := range lists {
+		for _, entry := range list {
+			if entry == "*" {
+				ps.allowAll[kind] = true
+			}
+		}
+	}
+	for _, path := range p.Read {
+		ps.read = append(ps.read, ps.normalizePath(path))
+	}
+	for _, path := range p.Write {
+		ps.write = append(ps.write, ps.normalizePath(path))
+	}
+	for _, entry := range p.Net {
+		ps.net = append(ps.net, parseNetPermission(entry))
+	}
+	return ps
+}
+
+// normalizePath 把路径转换为用于比较的绝对路径
+func (ps *permissionSet) normalizePath(p string) string {
+	if ps.virtual {
+		return cleanVirtualPath(p)
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	if real, err := evalExistingPath(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// parseNetPermission 解析 "host"、"host:port"、"[::1]:port" 形式的主机权限
+func parseNetPermission(entry string) netPermission {
+	if host, port, err := net.SplitHostPort(entry); err == nil {
+		if n, err := strconv.Atoi(port); err == nil {
+			return netPermission{host: normalizeHost(host), port: n}
+		}
+	}
+	return netPermission{host: normalizeHost(entry)}
+}
+
+// allowsPath 判断路径是否位于允许列表中的某个路径之下
+func (ps *permissionSet) allowsPath(list []string, p string) bool {
+	for _, dir := range list {
+		if ps.virtual {
+			if p == dir || dir == "/" || strings.HasPrefix(p, dir+"/") {
+				return true
+			}
+		} else if pathWithin(p, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsNet 判断主机和端口是否被允许，port 为 0（如 DNS 解析）时只比较主机
+func (ps *permissionSet) allowsNet(host string, port int) bool {
+	host = normalizeHost(host)
+	for _, np := range ps.net {
+		if matchHost(np.host, host) && (np.port == 0 || port == 0 || np.port == port) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRun 判断命令是否被允许，命令名和路径都按 PATH 解析后比较，避免同名程序冒充
+func (ps *permissionSet) allowsRun(command string) bool {
+	resolved, _ := exec.LookPath(command)
+	if resolved != "" {
+		resolved, _ = filepath.Abs(resolved)
+	}
+	for _, entry := range ps.run {
+		if entry == command && !strings.ContainsAny(command, `/\`) {
+			return true
+		}
+		if resolved == "" {
+			continue
+		}
+		if allowed, err := exec.LookPath(entry); err == nil {
+			if allowed, _ = filepath.Abs(allowed); allowed == resolved {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsEnv 判断环境变量是否被允许
+func (ps *permissionSet) allowsEnv(name string) bool {
+	for _, entry := range ps.env {
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+		if entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+// allows 判断权限集合是否包含本次访问，不调用审批回调
+func (ps *permissionSet) allows(kind PermissionKind, target string) bool {
+	if ps.allowAll[kind] {
+		return true
+	}
+	switch kind {
+	case PermRead:
+		return ps.allowsPath(ps.read, target)
+	case PermWrite:
+		return ps.allowsPath(ps.write, target)
+	case PermNet:
+		host, port := target, 0
+		if h, p, err := net.SplitHostPort(target); err == nil {
+			host = h
+			port, _ = strconv.Atoi(p)
+		}
+		return ps.allowsNet(host, port)
+	case PermRun:
+		return ps.allowsRun(target)
+	case PermEnv:
+		return ps.allowsEnv(target)
+	case PermKill:
+		return ps.kill
+	case PermSysInfo:
+		return ps.sysInfo
+	}
+	return false
+}
+
+// permissionNames 权限类型的中文名称，用于错误信息
+var permissionNames = map[PermissionKind]string{
+	PermRead:    "读取",
+	PermWrite:   "写入",
+	PermNet:     "网络访问",
+	PermRun:     "执行命令",
+	PermEnv:     "环境变量",
+	PermKill:    "终止进程",
+	PermSysInfo: "系统信息",
+}
+
+// checkPermission 检查本次访问的权限，权限集合中没有时调用审批回调
+func (sb *Sandbox) checkPermission(kind PermissionKind, target string) error {
+	ps := sb.perms
+	if ps == nil || ps.allows(kind, target) {
+		return nil
+	}
+	if ps.prompt != nil && ps.prompt(PermissionRequest{Kind: kind, Target: target}) {
+		return nil
+	}
+	return NewSandboxError(ErrCodePermissionDenied, fmt.Sprintf("没有%s权限: %s", permissionNames[kind], target))
+}
+
+// checkPathPermission 检查文件访问权限，读取需要 read 权限，创建、修改和删除需要 write 权限
+func (sb *Sandbox) checkPathPermission(p string, access fsAccess) error {
+	if sb.perms == nil {
+		return nil
+	}
+	kind := PermRead
+	if access != fsRead {
+		kind = PermWrite
+	}
+	return sb.checkPermission(kind, sb.perms.normalizePath(p))
+}
+
+// checkNetPermission 检查主机访问权限，port 为 0 时只检查主机
+func (sb *Sandbox) checkNetPermission(host string, port int) error {
+	if sb.perms == nil {
+		return nil
+	}
+	target := normalizeHost(host)
+	if port != 0 {
+		target = net.JoinHostPort(target, strconv.Itoa(port))
+	}
+	return sb.checkPermission(PermNet, target)
+}
@@ -0,0 +1,229 @@
+package jssandbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// expectPermissionDenied 断言结果对象是缺少权限的错误
+func expectPermissionDenied(t *testing.T, vm *goja.Runtime, result goja.Value) {
+	t.Helper()
+	resultObj := result.ToObject(vm)
+	if code := resultObj.Get("code"); code == nil || code.String() != string(ErrCodePermissionDenied) {
+		t.Errorf("应该返回 code=%s, got %v", ErrCodePermissionDenied, resultObj.Export())
+	}
+}
+
+func TestPermissions_FileSystem(t *testing.T) {
+	base := t.TempDir()
+	data := filepath.Join(base, "data")
+	out := filepath.Join(base, "out")
+	os.MkdirAll(data, 0755)
+	os.MkdirAll(out, 0755)
+	os.WriteFile(filepath.Join(data, "input.txt"), []byte("input"), 0644)
+	os.WriteFile(filepath.Join(base, "secret.txt"), []byte("secret"), 0644)
+
+	perms := NewPermissions().AllowRead(data, out).AllowWrite(out)
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithPermissions(perms))
+	defer sb.Close()
+
+	t.Run("允许的路径", func(t *testing.T) {
+		result, err := sb.Run(`[readFile("` + filepath.Join(data, "input.txt") + `").data, writeFile("` + filepath.Join(out, "a.txt") + `", "a").success]`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if got := result.Export().([]interface{}); got[0] != "input" || got[1] != true {
+			t.Errorf("允许的路径应该可以访问, got %v", got)
+		}
+	})
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"读取未授权的文件", `readFile("` + filepath.Join(base, "secret.txt") + `")`},
+		{"写入只读授权的目录", `writeFile("` + filepath.Join(data, "new.txt") + `", "x")`},
+		{"删除只读授权的文件", `deleteFile("` + filepath.Join(data, "input.txt") + `")`},
+		{"路径穿越", `readFile("` + filepath.Join(data, "..", "secret.txt") + `")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sb.Run(tt.code)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			expectPermissionDenied(t, sb.vm, result)
+		})
+	}
+
+	t.Run("符号链接指向未授权的位置", func(t *testing.T) {
+		if err := os.Symlink(base, filepath.Join(data, "link")); err != nil {
+			t.Skipf("无法创建符号链接: %v", err)
+		}
+		result, err := sb.Run(`readFile("` + filepath.Join(data, "link", "secret.txt") + `")`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		expectPermissionDenied(t, sb.vm, result)
+	})
+}
+
+func TestPermissions_VirtualFileSystem(t *testing.T) {
+	fsys := NewMemFileSystem()
+	fsys.WriteFile("/data/input.txt", []byte("input"), 0644)
+	fsys.WriteFile("/private/key.txt", []byte("key"), 0644)
+
+	perms := NewPermissions().AllowRead("/data").AllowWrite("/data/out")
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithFileSystem(fsys).WithPermissions(perms))
+	defer sb.Close()
+
+	result, err := sb.Run(`[readFile("data/input.txt").data, makeDir("/data/out").success]`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := result.Export().([]interface{}); got[0] != "input" || got[1] != true {
+		t.Errorf("虚拟路径权限检查不正确, got %v", got)
+	}
+
+	result, err = sb.Run(`readFile("/data/../private/key.txt")`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	expectPermissionDenied(t, sb.vm, result)
+}
+
+func TestPermissions_Net(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://localhost:"+r.URL.Query().Get("port")+"/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	perms := NewPermissions().AllowNet(serverURL.Host)
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithPrivateNetwork(true).WithPermissions(perms))
+	defer sb.Close()
+
+	result, err := sb.Run(`httpRequest("` + server.URL + `").body`)
+	if err != nil || result.String() != "ok" {
+		t.Errorf("允许的主机应该可以访问, got %v, %v", result, err)
+	}
+
+	for _, code := range []string{
+		`httpRequest("http://localhost:` + serverURL.Port() + `")`,
+		`httpRequest("http://127.0.0.1:1")`,
+		`httpRequest("` + server.URL + `/redirect?port=` + serverURL.Port() + `")`,
+		`checkPort("localhost", 80)`,
+		`resolveDNS("localhost")`,
+	} {
+		result, err := sb.Run(code)
+		if err != nil {
+			t.Fatalf("%s error = %v", code, err)
+		}
+		expectPermissionDenied(t, sb.vm, result)
+	}
+}
+
+func TestPermissions_RunEnvAndSystem(t *testing.T) {
+	t.Setenv("JSSB_PERM_A", "a")
+	t.Setenv("JSSB_OTHER", "other")
+
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithPermissions(
+		NewPermissions().AllowRun("echo").AllowEnv("JSSB_PERM_*")))
+	defer sb.Close()
+
+	t.Run("允许的命令", func(t *testing.T) {
+		result, err := sb.Run(`execCommand("echo hello")`)
+		if err != nil {
+			t.Fatalf("execCommand() error = %v", err)
+		}
+		if resultObj := result.ToObject(sb.vm); !resultObj.Get("success").ToBoolean() {
+			t.Skipf("echo 不可用: %v", resultObj.Export())
+		}
+	})
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"未授权的命令", `execCommand(["sh", "-c", "echo hacked"])`},
+		{"同名的其他程序", `execCommand("./echo hello")`},
+		{"未授权的环境变量", `getEnv("JSSB_OTHER")`},
+		{"终止进程", `killProcess(999999)`},
+		{"系统信息", `getMemorySize()`},
+		{"进程列表", `listProcesses()`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sb.Run(tt.code)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			expectPermissionDenied(t, sb.vm, result)
+		})
+	}
+
+	t.Run("环境变量", func(t *testing.T) {
+		result, err := sb.Run(`[getEnv("JSSB_PERM_A").value, getEnvAll().env]`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		got := result.Export().([]interface{})
+		env := got[1].(map[string]string)
+		if got[0] != "a" || env["JSSB_PERM_A"] != "a" || len(env) != 1 {
+			t.Errorf("getEnvAll()应该只返回允许的环境变量, got %v", got)
+		}
+	})
+
+	t.Run("返回值不是对象的函数抛出异常", func(t *testing.T) {
+		result, err := sb.Run(`try { getCPUNum(); "no error" } catch (e) { String(e) }`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if result.String() == "no error" {
+			t.Error("没有系统信息权限时 getCPUNum() 应该抛出异常")
+		}
+	})
+}
+
+func TestPermissions_Prompt(t *testing.T) {
+	t.Setenv("JSSB_PROMPTED", "yes")
+
+	var mu sync.Mutex
+	var requests []PermissionRequest
+	perms := NewPermissions().WithPrompt(func(req PermissionRequest) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, req)
+		return req.Kind == PermEnv && req.Target == "JSSB_PROMPTED"
+	})
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithPermissions(perms))
+	defer sb.Close()
+
+	result, err := sb.Run(`getEnv("JSSB_PROMPTED").value`)
+	if err != nil || result.String() != "yes" {
+		t.Errorf("审批通过后应该允许访问, got %v, %v", result, err)
+	}
+
+	result, err = sb.Run(`getMemorySize()`)
+	if err != nil {
+		t.Fatalf("getMemorySize() error = %v", err)
+	}
+	expectPermissionDenied(t, sb.vm, result)
+
+	want := []PermissionRequest{{PermEnv, "JSSB_PROMPTED"}, {PermSysInfo, "getMemorySize"}}
+	if len(requests) != len(want) || requests[0] != want[0] || requests[1] != want[1] {
+		t.Errorf("审批回调收到的请求不正确, got %v", requests)
+	}
+}
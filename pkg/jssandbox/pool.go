@@ -0,0 +1,483 @@
+package jssandbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PoolConfig 沙盒池配置
+type PoolConfig struct {
+	// Config 池中沙盒使用的配置，为 nil 时使用默认配置。
+	// 所有沙盒共用同一个 Config；未设置 FileSystem 时其中的 Config.FileSystem（如内存文件系统，
+	// 为 nil 时为宿主机磁盘）也是共享的，一次借出写入的文件对之后的借出可见
+	Config *Config
+	// FileSystem 为每个沙盒创建独立的文件系统（如 NewMemFileSystem），沙盒归还时换成新创建的，
+	// 一次借出写入的文件对之后的借出不可见；应返回非宿主机文件系统，为 nil 时使用 Config.FileSystem
+	FileSystem func() FileSystem
+	// MinIdle 预热并保持的空闲沙盒数量
+	MinIdle int
+	// MaxSize 沙盒数量上限（空闲和借出的总和），借出的沙盒达到上限时 Get 会等待归还
+	MaxSize int
+	// IdleTimeout 超过 MinIdle 的空闲沙盒在空闲这么久之后被关闭，0 表示不关闭
+	IdleTimeout time.Duration
+	// Setup 沙盒创建后、记录初始状态前调用，可用于注册自定义的全局变量和函数，
+	// 这些变量在每次归还后都会恢复为 Setup 之后的状态
+	Setup func(sb *Sandbox) error
+	// Name 沙盒池的名称，作为指标的 pool 标签，为空时为 "default"；同名沙盒池的指标相加合并
+	Name string
+}
+
+// DefaultPoolConfig 返回默认的沙盒池配置
+func DefaultPoolConfig() *PoolConfig {
+	return &PoolConfig{
+		Config:      DefaultConfig(),
+		MinIdle:     1,
+		MaxSize:     8,
+		IdleTimeout: 5 * time.Minute,
+	}
+}
+
+// WithFileSystem 设置为每个沙盒创建独立文件系统的函数
+func (c *PoolConfig) WithFileSystem(newFS func() FileSystem) *PoolConfig {
+	c.FileSystem = newFS
+	return c
+}
+
+// WithName 设置沙盒池的名称
+func (c *PoolConfig) WithName(name string) *PoolConfig {
+	c.Name = name
+	return c
+}
+
+// WithSandboxConfig 设置池中沙盒使用的配置
+func (c *PoolConfig) WithSandboxConfig(config *Config) *PoolConfig {
+	c.Config = config
+	return c
+}
+
+// WithMinIdle 设置预热并保持的空闲沙盒数量
+func (c *PoolConfig) WithMinIdle(n int) *PoolConfig {
+	c.MinIdle = n
+	return c
+}
+
+// WithMaxSize 设置沙盒数量上限
+func (c *PoolConfig) WithMaxSize(n int) *PoolConfig {
+	c.MaxSize = n
+	return c
+}
+
+// WithIdleTimeout 设置空闲沙盒的回收时间
+func (c *PoolConfig) WithIdleTimeout(timeout time.Duration) *PoolConfig {
+	c.IdleTimeout = timeout
+	return c
+}
+
+// WithSetup 设置沙盒创建后的初始化函数
+func (c *PoolConfig) WithSetup(setup func(sb *Sandbox) error) *PoolConfig {
+	c.Setup = setup
+	return c
+}
+
+// PoolStats 沙盒池的运行指标
+type PoolStats struct {
+	// Idle 当前空闲的沙盒数量
+	Idle int
+	// InUse 当前借出的沙盒数量
+	InUse int
+	// MaxSize 沙盒数量上限
+	MaxSize int
+	// Created 累计创建的沙盒数量
+	Created int64
+	// Reused 累计复用空闲沙盒的次数
+	Reused int64
+	// Discarded 累计因执行被中断或无法重置而丢弃的沙盒数量
+	Discarded int64
+	// Evicted 累计因空闲超时而关闭的沙盒数量
+	Evicted int64
+	// Waits 累计因达到上限而等待的次数
+	Waits int64
+	// WaitDuration 累计等待时间
+	WaitDuration time.Duration
+}
+
+// idleSandbox 空闲的沙盒
+type idleSandbox struct {
+	sb       *Sandbox
+	idleFrom time.Time
+}
+
+// SandboxPool 预热的沙盒池
+//
+// 创建沙盒需要注册数百个宿主函数，开销较大；而复用同一个沙盒会让上一次执行定义的全局变量泄漏到下一次执行。
+// 沙盒池保留若干个已创建好的沙盒，每次执行借出一个，归还时把全局变量、内置对象和宿主函数恢复到创建时的状态，
+// 并关闭脚本打开的浏览器会话；执行被中断（超时、取消）或无法恢复（如顶层 let/const 声明、冻结了内置对象）的沙盒直接关闭，不再复用。
+// 文件系统默认在所有沙盒之间共享，需要隔离时设置 PoolConfig.FileSystem。
+//
+// SandboxPool 可以在多个 goroutine 中并发使用，但借出的沙盒同一时间只能由一个 goroutine 使用。
+type SandboxPool struct {
+	config *PoolConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+	// slots 借出名额，容量为 MaxSize
+	slots  chan struct{}
+	refill chan struct{}
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	idle      []idleSandbox // 按归还时间排序，最近归还的在末尾
+	inUse     map[*Sandbox]bool
+	creating  int // 正在创建的沙盒数量
+	returning int // 正在重置的沙盒数量
+	closed    bool
+	stats     PoolStats
+}
+
+// NewSandboxPool 创建沙盒池并预热 MinIdle 个沙盒
+// ctx 被取消或调用 Close 后沙盒池关闭，正在执行的脚本会被中断
+func NewSandboxPool(ctx context.Context, config *PoolConfig) (*SandboxPool, error) {
+	if config == nil {
+		config = DefaultPoolConfig()
+	}
+	cfg := *config
+	if cfg.Config == nil {
+		cfg.Config = DefaultConfig()
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultPoolConfig().MaxSize
+	}
+	if cfg.MinIdle > cfg.MaxSize {
+		cfg.MinIdle = cfg.MaxSize
+	}
+	if cfg.Name == "" {
+		cfg.Name = "default"
+	}
+
+	poolCtx, cancel := context.WithCancel(ctx)
+	p := &SandboxPool{
+		config: &cfg,
+		ctx:    poolCtx,
+		cancel: cancel,
+		slots:  make(chan struct{}, cfg.MaxSize),
+		refill: make(chan struct{}, 1),
+		inUse:  make(map[*Sandbox]bool),
+	}
+	p.stats.MaxSize = cfg.MaxSize
+
+	for i := 0; i < cfg.MinIdle; i++ {
+		sb, err := p.newSandbox()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.idle = append(p.idle, idleSandbox{sb: sb, idleFrom: time.Now()})
+	}
+
+	// 沙盒的 Config.Metrics 不为 nil 时，沙盒池的使用情况一并导出
+	if m := cfg.Config.Metrics; m != nil {
+		m.Register(p)
+	}
+
+	p.wg.Add(1)
+	go p.maintain()
+	return p, nil
+}
+
+// newSandbox 创建沙盒、执行初始化函数并记录初始状态
+func (p *SandboxPool) newSandbox() (*Sandbox, error) {
+	config := p.config.Config
+	if p.config.FileSystem != nil {
+		c := *config
+		c.FileSystem = p.config.FileSystem()
+		config = &c
+	}
+	sb := NewSandboxWithConfig(p.ctx, config)
+	if p.config.Setup != nil {
+		if err := p.config.Setup(sb); err != nil {
+			sb.Close()
+			return nil, NewSandboxErrorWithCause(ErrCodeUnknown, "初始化沙盒失败", err)
+		}
+	}
+	if err := sb.captureGlobals(); err != nil {
+		sb.Close()
+		return nil, NewSandboxErrorWithCause(ErrCodeUnknown, "记录沙盒初始状态失败", err)
+	}
+	p.mu.Lock()
+	p.stats.Created++
+	p.mu.Unlock()
+	return sb, nil
+}
+
+// Get 借出一个沙盒，用完后必须调用 Put 归还
+// 借出的沙盒达到 MaxSize 时等待，直到有沙盒归还或 ctx 结束；
+// 归还前沙盒的执行以 ctx 为父上下文，ctx 被取消时中断正在执行的脚本，执行的 span 以 ctx 中的 span 为父 span
+func (p *SandboxPool) Get(ctx context.Context) (*Sandbox, error) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		if err := p.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mu.Lock()
+	if p.closed || p.ctx.Err() != nil {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, NewSandboxError(ErrCodeCanceled, "沙盒池已关闭")
+	}
+	if n := len(p.idle); n > 0 {
+		sb := p.idle[n-1].sb
+		p.idle = p.idle[:n-1]
+		p.inUse[sb] = true
+		p.stats.Reused++
+		p.mu.Unlock()
+		p.requestRefill()
+		sb.borrowCtx = ctx
+		return sb, nil
+	}
+	p.creating++
+	p.mu.Unlock()
+
+	sb, err := p.newSandbox()
+	p.mu.Lock()
+	p.creating--
+	if err == nil {
+		p.inUse[sb] = true
+	}
+	p.mu.Unlock()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	sb.borrowCtx = ctx
+	return sb, nil
+}
+
+// wait 等待借出名额，并记录等待次数和时间
+func (p *SandboxPool) wait(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		p.mu.Lock()
+		p.stats.Waits++
+		p.stats.WaitDuration += time.Since(start)
+		p.mu.Unlock()
+	}()
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx, 0)
+	case <-p.ctx.Done():
+		return NewSandboxError(ErrCodeCanceled, "沙盒池已关闭")
+	}
+}
+
+// Put 归还借出的沙盒
+// 沙盒会被重置为初始状态后放回池中，执行被中断或无法重置时直接关闭
+func (p *SandboxPool) Put(sb *Sandbox) {
+	if sb == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.inUse[sb] {
+		p.mu.Unlock()
+		GetLogger().Warn("归还的沙盒不属于该沙盒池或已经归还")
+		return
+	}
+	delete(p.inUse, sb)
+	p.returning++
+	closed := p.closed
+	p.mu.Unlock()
+	defer func() { <-p.slots }()
+	sb.borrowCtx = nil
+
+	reason := ""
+	if closed {
+		reason = "沙盒池已关闭"
+	} else if sb.interrupted {
+		reason = "执行被中断"
+	} else if err := sb.reset(); err != nil {
+		reason = err.Error()
+	}
+
+	if reason == "" && p.config.FileSystem != nil {
+		sb.fs = p.config.FileSystem()
+	}
+
+	p.mu.Lock()
+	p.returning--
+	if reason == "" && !p.closed && p.size() < p.config.MaxSize {
+		p.idle = append(p.idle, idleSandbox{sb: sb, idleFrom: time.Now()})
+		p.mu.Unlock()
+		return
+	}
+	if reason != "" && !closed {
+		p.stats.Discarded++
+	}
+	p.mu.Unlock()
+
+	if reason != "" {
+		GetLogger().WithField("reason", reason).Debug("丢弃沙盒")
+	}
+	sb.Close()
+	p.requestRefill()
+}
+
+// Do 借出一个沙盒执行 fn，结束后自动归还
+// fn 返回后沙盒会被重置，fn 不应在返回后继续持有沙盒或其中的 goja 值；
+// 设置了 PoolConfig.FileSystem 时，脚本写入的文件需要在 fn 中通过 sb.FileSystem() 取出
+func (p *SandboxPool) Do(ctx context.Context, fn func(sb *Sandbox) error) error {
+	sb, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Put(sb)
+	return fn(sb)
+}
+
+// Stats 返回沙盒池的运行指标
+func (p *SandboxPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Idle = len(p.idle)
+	stats.InUse = len(p.inUse)
+	return stats
+}
+
+// Collect 实现 MetricsCollector 接口，以 pool 标签导出 Stats 中的指标
+func (p *SandboxPool) Collect() []MetricFamily {
+	stats := p.Stats()
+	pool := map[string]string{"pool": p.config.Name}
+	gauge := func(name, help string, value float64) MetricFamily {
+		return MetricFamily{Name: name, Help: help, Type: MetricGauge, Samples: []MetricSample{{Labels: pool, Value: value}}}
+	}
+	counter := func(name, help string, value float64) MetricFamily {
+		return MetricFamily{Name: name, Help: help, Type: MetricCounter, Samples: []MetricSample{{Labels: pool, Value: value}}}
+	}
+	return []MetricFamily{
+		{Name: "jssandbox_pool_sandboxes", Help: "沙盒池中的沙盒数量，按状态（idle、in_use）区分", Type: MetricGauge, Samples: []MetricSample{
+			{Labels: map[string]string{"pool": p.config.Name, "state": "idle"}, Value: float64(stats.Idle)},
+			{Labels: map[string]string{"pool": p.config.Name, "state": "in_use"}, Value: float64(stats.InUse)},
+		}},
+		gauge("jssandbox_pool_max_size", "沙盒池的沙盒数量上限", float64(stats.MaxSize)),
+		counter("jssandbox_pool_created_total", "沙盒池累计创建的沙盒数量", float64(stats.Created)),
+		counter("jssandbox_pool_reused_total", "沙盒池累计复用空闲沙盒的次数", float64(stats.Reused)),
+		counter("jssandbox_pool_discarded_total", "沙盒池累计丢弃的沙盒数量", float64(stats.Discarded)),
+		counter("jssandbox_pool_evicted_total", "沙盒池累计因空闲超时关闭的沙盒数量", float64(stats.Evicted)),
+		counter("jssandbox_pool_waits_total", "借出沙盒时因达到上限而等待的次数", float64(stats.Waits)),
+		counter("jssandbox_pool_wait_seconds_total", "借出沙盒时累计等待的时间", stats.WaitDuration.Seconds()),
+	}
+}
+
+// Close 关闭沙盒池和所有空闲的沙盒，借出的沙盒在归还时关闭
+func (p *SandboxPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	if m := p.config.Config.Metrics; m != nil {
+		m.Unregister(p)
+	}
+
+	p.cancel()
+	p.wg.Wait()
+	for _, s := range idle {
+		s.sb.Close()
+	}
+	return nil
+}
+
+// size 返回沙盒总数，调用方需持有 mu
+func (p *SandboxPool) size() int {
+	return len(p.idle) + len(p.inUse) + p.creating + p.returning
+}
+
+// requestRefill 通知后台补充空闲沙盒
+func (p *SandboxPool) requestRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+// maintain 后台补充空闲沙盒到 MinIdle，并关闭空闲超时的沙盒
+func (p *SandboxPool) maintain() {
+	defer p.wg.Done()
+
+	var tick <-chan time.Time
+	if p.config.IdleTimeout > 0 {
+		interval := p.config.IdleTimeout / 2
+		if interval < time.Second {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.refill:
+			p.fill()
+		case <-tick:
+			p.evict()
+		}
+	}
+}
+
+// fill 补充空闲沙盒到 MinIdle，不超过 MaxSize
+func (p *SandboxPool) fill() {
+	for p.ctx.Err() == nil {
+		p.mu.Lock()
+		if p.closed || len(p.idle)+p.creating >= p.config.MinIdle || p.size() >= p.config.MaxSize {
+			p.mu.Unlock()
+			return
+		}
+		p.creating++
+		p.mu.Unlock()
+
+		sb, err := p.newSandbox()
+		p.mu.Lock()
+		p.creating--
+		if err != nil {
+			p.mu.Unlock()
+			GetLogger().WithError(err).Warn("预热沙盒失败")
+			return
+		}
+		if p.closed {
+			p.mu.Unlock()
+			sb.Close()
+			return
+		}
+		p.idle = append(p.idle, idleSandbox{sb: sb, idleFrom: time.Now()})
+		p.mu.Unlock()
+	}
+}
+
+// evict 关闭空闲超过 IdleTimeout 的沙盒，至少保留 MinIdle 个
+func (p *SandboxPool) evict() {
+	deadline := time.Now().Add(-p.config.IdleTimeout)
+	var expired []*Sandbox
+	p.mu.Lock()
+	for len(p.idle) > p.config.MinIdle && p.idle[0].idleFrom.Before(deadline) {
+		expired = append(expired, p.idle[0].sb)
+		p.idle = p.idle[1:]
+	}
+	p.stats.Evicted += int64(len(expired))
+	p.mu.Unlock()
+
+	for _, sb := range expired {
+		sb.Close()
+	}
+}
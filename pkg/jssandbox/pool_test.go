@@ -0,0 +1,342 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestPool 创建测试用的沙盒池，测试结束时自动关闭
+func newTestPool(t *testing.T, config *PoolConfig) *SandboxPool {
+	t.Helper()
+	pool, err := NewSandboxPool(context.Background(), config)
+	if err != nil {
+		t.Fatalf("NewSandboxPool() error = %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+func TestSandboxPool_Reset(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1).WithMaxSize(1))
+
+	sb, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_, err = sb.Run(`
+		globalThis.leaked = "secret";
+		(function () { this.implicit = 1; })();
+		Array.prototype.evil = function () { return "evil"; };
+		Object.prototype.polluted = true;
+		JSON.parse = function () { return "hijacked"; };
+		Reflect.ownKeys = null;
+		delete globalThis.Math;
+		httpGet = null;
+		logger.info = null;
+	`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	sb.Set("fromHost", 1)
+	pool.Put(sb)
+
+	sb2, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer pool.Put(sb2)
+	if sb2 != sb {
+		t.Fatal("可以重置的沙盒应该被复用")
+	}
+	result, err := sb2.Run(`[
+		typeof leaked, typeof implicit, typeof fromHost, [].evil, ({}).polluted,
+		JSON.parse("[1]")[0], typeof Reflect.ownKeys, Math.max(1, 2), typeof httpGet, typeof logger.info
+	]`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{"undefined", "undefined", "undefined", nil, nil, int64(1), "function", int64(2), "function", "function"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("重置后第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if stats := pool.Stats(); stats.Reused != 2 || stats.Created != 1 || stats.Discarded != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestSandboxPool_Discard(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(sb *Sandbox) error
+	}{
+		{"var 声明的全局变量无法删除", func(sb *Sandbox) error {
+			_, err := sb.Run(`var counter = 1`)
+			return err
+		}},
+		{"冻结内置对象", func(sb *Sandbox) error {
+			_, err := sb.Run(`Array.prototype.evil = 1; Object.freeze(Array.prototype)`)
+			return err
+		}},
+		{"执行超时", func(sb *Sandbox) error {
+			_, err := sb.RunWithTimeout(`while (true) {}`, 50*time.Millisecond)
+			if err == nil {
+				return errors.New("应该超时")
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(0).WithMaxSize(1))
+
+			sb, err := pool.Get(context.Background())
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if err := tt.run(sb); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			pool.Put(sb)
+
+			sb2, err := pool.Get(context.Background())
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer pool.Put(sb2)
+			if sb2 == sb {
+				t.Error("无法重置的沙盒不应该被复用")
+			}
+			if _, err := sb2.Run(`let counter = 2; [].evil`); err != nil {
+				t.Errorf("新的沙盒应该是干净的, got %v", err)
+			}
+			if stats := pool.Stats(); stats.Discarded != 1 {
+				t.Errorf("Discarded = %d, want 1", stats.Discarded)
+			}
+		})
+	}
+}
+
+func TestSandboxPool_LexicalReuse(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(0).WithMaxSize(1))
+
+	sb, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	result, err := sb.Run(`"use strict"; const x = 1; class Point {} function double() { return x * 2; } double()`)
+	if err != nil || result.ToInteger() != 2 {
+		t.Fatalf("Run() = %v, %v", result, err)
+	}
+	pool.Put(sb)
+
+	sb2, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer pool.Put(sb2)
+	if sb2 != sb {
+		t.Fatal("顶层声明 const 的沙盒应该被复用")
+	}
+	result, err = sb2.Run(`[typeof x, typeof Point, typeof double, (function () { const x = 2; return x; })()].join()`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.String() != "undefined,undefined,undefined,2" {
+		t.Errorf("复用后 = %q", result.String())
+	}
+	if _, err := sb2.Run(`const x = 3; x`); err != nil {
+		t.Errorf("复用后再次声明 const x 失败: %v", err)
+	}
+	if stats := pool.Stats(); stats.Discarded != 0 || stats.Reused != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestSandboxPool_Setup(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1).WithMaxSize(1).WithSetup(func(sb *Sandbox) error {
+		sb.Set("appName", "demo")
+		_, err := sb.Run(`globalThis.helpers = { greet: function (name) { return "hello " + name; } }`)
+		return err
+	}))
+
+	for i := 0; i < 2; i++ {
+		err := pool.Do(context.Background(), func(sb *Sandbox) error {
+			result, err := sb.Run(`var r = appName + ":" + helpers.greet("js"); helpers.greet = null; appName = "changed"; r`)
+			if err != nil {
+				return err
+			}
+			if result.String() != "demo:hello js" {
+				t.Errorf("第 %d 次执行 = %s, Setup 定义的变量应该恢复", i, result.String())
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+}
+
+func TestSandboxPool_MaxSize(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(0).WithMaxSize(2))
+
+	t.Run("达到上限时等待", func(t *testing.T) {
+		sb1, _ := pool.Get(context.Background())
+		sb2, _ := pool.Get(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		var sbErr *SandboxError
+		if _, err := pool.Get(ctx); !errors.As(err, &sbErr) || !sbErr.IsTimeout() {
+			t.Errorf("达到上限时 Get() 应该等待到超时, got %v", err)
+		}
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			pool.Put(sb1)
+		}()
+		sb3, err := pool.Get(context.Background())
+		if err != nil {
+			t.Fatalf("归还后 Get() error = %v", err)
+		}
+		pool.Put(sb2)
+		pool.Put(sb3)
+		if stats := pool.Stats(); stats.Waits != 2 || stats.WaitDuration <= 0 || stats.Idle != 2 || stats.InUse != 0 {
+			t.Errorf("Stats() = %+v", stats)
+		}
+	})
+
+	t.Run("并发借用", func(t *testing.T) {
+		var inUse, maxInUse int32
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := pool.Do(context.Background(), func(sb *Sandbox) error {
+					n := atomic.AddInt32(&inUse, 1)
+					defer atomic.AddInt32(&inUse, -1)
+					for {
+						m := atomic.LoadInt32(&maxInUse)
+						if n <= m || atomic.CompareAndSwapInt32(&maxInUse, m, n) {
+							break
+						}
+					}
+					_, err := sb.Run(`globalThis.x = 1; 1 + 1`)
+					return err
+				})
+				if err != nil {
+					t.Errorf("Do() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if maxInUse > 2 {
+			t.Errorf("同时借出的沙盒数量 = %d, 不应该超过 MaxSize", maxInUse)
+		}
+		if stats := pool.Stats(); stats.Created > 2 {
+			t.Errorf("创建的沙盒数量 = %d, 不应该超过 MaxSize", stats.Created)
+		}
+	})
+}
+
+func TestSandboxPool_IdleEviction(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1).WithMaxSize(3).WithIdleTimeout(10*time.Millisecond))
+
+	var sbs []*Sandbox
+	for i := 0; i < 3; i++ {
+		sb, err := pool.Get(context.Background())
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		sbs = append(sbs, sb)
+	}
+	for _, sb := range sbs {
+		pool.Put(sb)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	pool.evict()
+	if stats := pool.Stats(); stats.Idle != 1 || stats.Evicted != 2 {
+		t.Errorf("空闲超时后应该只保留 MinIdle 个沙盒, got %+v", stats)
+	}
+}
+
+func TestSandboxPool_Close(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1))
+
+	sb, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	pool.Close()
+	if _, err := pool.Get(context.Background()); err == nil {
+		t.Error("关闭后 Get() 应该返回错误")
+	}
+	if _, err := sb.Run(`1`); err == nil {
+		t.Error("关闭后借出的沙盒应该无法继续执行")
+	}
+	pool.Put(sb)
+	if stats := pool.Stats(); stats.Idle != 0 || stats.InUse != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestSandboxPool_GetContext(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1).WithMaxSize(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sb, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = sb.RunWithTimeout(`while (true) {}`, 10*time.Second)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsCanceled() {
+		t.Errorf("取消 Get 的 ctx 后 RunWithTimeout() error = %v, want %s", err, ErrCodeCanceled)
+	}
+	pool.Put(sb)
+
+	// 沙盒池本身不受影响，新借出的沙盒可以正常执行
+	sb2, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer pool.Put(sb2)
+	if v, err := sb2.Run(`1 + 1`); err != nil || v.ToInteger() != 2 {
+		t.Errorf("Run() = %v, %v", v, err)
+	}
+}
+
+func TestSandboxPool_FileSystem(t *testing.T) {
+	shared := NewMemFileSystem()
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(1).WithMaxSize(1).
+		WithSandboxConfig(DefaultConfig().WithFileSystem(shared)).
+		WithFileSystem(func() FileSystem { return NewMemFileSystem() }))
+
+	for i := 0; i < 2; i++ {
+		err := pool.Do(context.Background(), func(sb *Sandbox) error {
+			v, err := sb.Run(`var exists = readFile('/a.txt').success; writeFile('/a.txt', 'x'); exists`)
+			if err != nil {
+				return err
+			}
+			if v.ToBoolean() {
+				t.Errorf("第 %d 次借出看到了之前写入的文件", i+1)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+	if files := shared.Files(); len(files) != 0 {
+		t.Errorf("共享的文件系统不应被写入: %v", files)
+	}
+}
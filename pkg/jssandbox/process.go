@@ -15,7 +15,9 @@ import (
 // registerProcess 注册进程管理功能到JavaScript运行时
 func (sb *Sandbox) registerProcess() {
 	// 执行系统命令
-	sb.vm.Set("execCommand", func(call goja.FunctionCall) goja.Value {
+	sb.vm.Set("execCommand", func(call goja.FunctionCall) (result goja.Value) {
+		event := AuditEvent{Module: "process", Operation: "execCommand", Target: call.Argument(0).String()}
+		defer func() { sb.auditValue(event, &result) }()
 		if len(call.Arguments) < 1 {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": "需要提供命令参数",
@@ -67,6 +69,10 @@ func (sb *Sandbox) registerProcess() {
 				"error": "无效的命令",
 			})
 		}
+		event.Target = strings.Join(cmd.Args, " ")
+		if err := sb.checkPermission(PermRun, cmd.Args[0]); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 
 		// 设置超时（默认30秒）
 		timeout := 30 * time.Second
@@ -78,12 +84,16 @@ func (sb *Sandbox) registerProcess() {
 			}
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		ctx, cancel := context.WithTimeout(sb.runContext(), timeout)
 		defer cancel()
 
 		cmd = exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
 
 		output, err := cmd.CombinedOutput()
+		event.BytesIn = int64(len(output))
+		if cmd.ProcessState != nil {
+			event.Details = map[string]interface{}{"exitCode": cmd.ProcessState.ExitCode()}
+		}
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error":   err.Error(),
@@ -101,6 +111,9 @@ func (sb *Sandbox) registerProcess() {
 
 	// 列出运行中的进程
 	sb.vm.Set("listProcesses", func() goja.Value {
+		if err := sb.checkPermission(PermSysInfo, "listProcesses"); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 		processes, err := process.Processes()
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -138,7 +151,8 @@ func (sb *Sandbox) registerProcess() {
 	})
 
 	// 终止进程
-	sb.vm.Set("killProcess", func(call goja.FunctionCall) goja.Value {
+	sb.vm.Set("killProcess", func(call goja.FunctionCall) (result goja.Value) {
+		defer sb.auditValue(AuditEvent{Module: "process", Operation: "killProcess", Target: call.Argument(0).String()}, &result)
 		if len(call.Arguments) < 1 {
 			return sb.vm.ToValue(map[string]interface{}{
 				"error": "需要提供进程ID参数",
@@ -146,6 +160,9 @@ func (sb *Sandbox) registerProcess() {
 		}
 
 		pid := int32(call.Arguments[0].ToInteger())
+		if err := sb.checkPermission(PermKill, fmt.Sprintf("%d", pid)); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 		p, err := process.NewProcess(pid)
 		if err != nil {
 			return sb.vm.ToValue(map[string]interface{}{
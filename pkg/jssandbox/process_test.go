@@ -4,6 +4,7 @@ import (
 	"context"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/dop251/goja"
 )
@@ -75,6 +76,27 @@ func TestExecCommand_WithTimeout(t *testing.T) {
 	_ = resultObj
 }
 
+func TestExecCommand_AbortedByRunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("跳过Windows平台")
+	}
+
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	start := time.Now()
+	_, err := sb.RunWithTimeout(`execCommand("sleep 10", {timeout: 30})`, 200*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("RunWithTimeout()应该返回超时错误")
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("正在执行的命令未被终止, 耗时 %v", elapsed)
+	}
+}
+
 func TestListProcesses(t *testing.T) {
 	ctx := context.Background()
 	sb := NewSandbox(ctx)
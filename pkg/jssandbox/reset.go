@@ -0,0 +1,168 @@
+package jssandbox
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+)
+
+// globalsSnapshotScript 记录全局对象以及从它可达的所有对象（内置对象、宿主函数、它们的 prototype 和原型链）
+// 的属性描述符、原型和可扩展性，返回把它们恢复原状的函数
+//
+// 恢复函数只使用快照时保存下来的 Reflect 方法，快照中的描述符去掉了原型，并且最先恢复 Object.prototype，
+// 脚本篡改 Reflect、Object.prototype 等内置对象不会影响恢复过程，恢复时也不会执行脚本定义的 getter。
+// 无法恢复的修改（如冻结内置对象、添加不可配置的全局属性）会让恢复函数返回 false。
+const globalsSnapshotScript = `(function () {
+	var ownKeys = Reflect.ownKeys, getDesc = Reflect.getOwnPropertyDescriptor,
+		defineProperty = Reflect.defineProperty, deleteProperty = Reflect.deleteProperty,
+		getProto = Reflect.getPrototypeOf, setProto = Reflect.setPrototypeOf,
+		isExtensible = Reflect.isExtensible;
+
+	function descriptor(obj, key) {
+		var d = getDesc(obj, key);
+		if (d !== undefined) setProto(d, null);
+		return d;
+	}
+
+	var entries = [], seen = new Set();
+	function capture(obj) {
+		if ((typeof obj !== "object" && typeof obj !== "function") || obj === null || seen.has(obj)) return;
+		seen.add(obj);
+		var keys = ownKeys(obj), descs = Object.create(null);
+		for (var i = 0; i < keys.length; i++) descs[keys[i]] = descriptor(obj, keys[i]);
+		entries.push({obj: obj, keys: keys, descs: descs, proto: getProto(obj), extensible: isExtensible(obj)});
+	}
+	capture(globalThis);
+	for (var i = 0; i < entries.length; i++) {
+		var e = entries[i];
+		capture(e.proto);
+		for (var j = 0; j < e.keys.length; j++) {
+			var d = e.descs[e.keys[j]];
+			capture(d.value);
+			capture(d.get);
+			capture(d.set);
+		}
+	}
+
+	// Object.prototype 排在最前面，先恢复它，之后读取描述符的属性时不会受到原型上被添加的属性影响
+	var objectProto = getProto({});
+	for (var i = 0; i < entries.length; i++) {
+		if (entries[i].obj === objectProto) {
+			var first = entries[i];
+			entries[i] = entries[0];
+			entries[0] = first;
+			break;
+		}
+	}
+
+	return function restore() {
+		var clean = true;
+		for (var i = 0; i < entries.length; i++) {
+			var e = entries[i], obj = e.obj, descs = e.descs;
+			if (getProto(obj) !== e.proto && !setProto(obj, e.proto)) clean = false;
+			var keys = ownKeys(obj);
+			for (var j = 0; j < keys.length; j++) {
+				if (!(keys[j] in descs) && !deleteProperty(obj, keys[j])) clean = false;
+			}
+			keys = e.keys;
+			for (var j = 0; j < keys.length; j++) {
+				var key = keys[j], want = descs[key], got = getDesc(obj, key);
+				if (i === 0 && got !== undefined) setProto(got, null);
+				if (got !== undefined && (got.value === want.value || got.value !== got.value && want.value !== want.value) &&
+					got.get === want.get && got.set === want.set && got.writable === want.writable &&
+					got.enumerable === want.enumerable && got.configurable === want.configurable) continue;
+				if (!defineProperty(obj, key, want)) clean = false;
+			}
+			if (isExtensible(obj) !== e.extensible) clean = false;
+		}
+		return clean;
+	};
+})()`
+
+var globalsSnapshotProgram = goja.MustCompile("globals-snapshot.js", globalsSnapshotScript, true)
+
+// errDirtyGlobals 运行时无法恢复到初始状态
+var errDirtyGlobals = errors.New("运行时的全局状态无法恢复")
+
+// captureGlobals 记录当前的全局状态，之后可以用 reset 恢复
+// 需要在注册完宿主函数、执行任何脚本之前调用
+func (sb *Sandbox) captureGlobals() error {
+	defer sb.lockRuntime()()
+	restore, err := sb.vm.RunProgram(globalsSnapshotProgram)
+	if err != nil {
+		return err
+	}
+	fn, ok := goja.AssertFunction(restore)
+	if !ok {
+		return errors.New("全局状态快照没有返回函数")
+	}
+	sb.restoreGlobals = fn
+	sb.interrupted = false
+	return nil
+}
+
+// reset 把运行时恢复到 captureGlobals 时的状态：删除脚本添加的全局变量，
+// 还原被修改、删除的内置对象和宿主函数，清空事件循环和模块缓存，并关闭脚本打开的浏览器会话
+//
+// 顶层的 let/const/class 声明在块作用域中执行，不会留下（见 scopeLexical）；
+// 无法恢复的修改（如 var 声明的全局变量、冻结内置对象）会返回错误，此时运行时不应继续使用
+func (sb *Sandbox) reset() error {
+	defer sb.lockRuntime()()
+	if sb.restoreGlobals == nil {
+		return errors.New("没有记录全局状态")
+	}
+	sb.loop.reset()
+	sb.modules.reset()
+	sb.console.reset()
+	sb.closeBrowserSessions()
+	sb.vm.ClearInterrupt()
+	sb.lastFailure.Store(nil)
+	clean, err := sb.restoreGlobals(goja.Undefined())
+	if err != nil {
+		return err
+	}
+	if !clean.ToBoolean() {
+		return errDirtyGlobals
+	}
+	return nil
+}
+
+// declaresGlobalLexical 判断脚本是否在顶层声明了 let/const/class
+func declaresGlobalLexical(prg *ast.Program) bool {
+	for _, stmt := range prg.Body {
+		switch stmt.(type) {
+		case *ast.LexicalDeclaration, *ast.ClassDeclaration:
+			return true
+		}
+	}
+	return false
+}
+
+// scopeLexical 把顶层声明了 let/const/class 的脚本放进一个块中重新编译，
+// 这些声明只在本次执行中有效，不会留在无法删除的全局词法环境中；var 声明仍然是全局变量，
+// 顶层的函数声明随块作用域不再是全局对象的属性。开头的指令（如 "use strict"）保留在块外。
+// 没有这类声明时返回 nil，用于沙盒池中的沙盒
+func scopeLexical(prg *ast.Program) (*goja.Program, error) {
+	if !declaresGlobalLexical(prg) {
+		return nil, nil
+	}
+	body := prg.Body
+	directives := 0
+	for ; directives < len(body); directives++ {
+		stmt, ok := body[directives].(*ast.ExpressionStatement)
+		if !ok {
+			break
+		}
+		if _, ok := stmt.Expression.(*ast.StringLiteral); !ok {
+			break
+		}
+	}
+	scoped := *prg
+	scoped.Body = append(append([]ast.Statement{}, body[:directives]...), &ast.BlockStatement{
+		LeftBrace:  body[directives].Idx0(),
+		List:       body[directives:],
+		RightBrace: body[len(body)-1].Idx1(),
+	})
+	return goja.CompileAST(&scoped, false)
+}
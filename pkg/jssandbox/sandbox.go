@@ -2,12 +2,17 @@ package jssandbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Sandbox 表示一个JavaScript沙盒环境
@@ -16,11 +21,65 @@ type Sandbox struct {
 	logger *logrus.Logger
 	ctx    context.Context
 	config *Config
+	// cancel 取消沙盒的上下文，Close 时中断正在进行的执行和排队中的调用
+	cancel context.CancelFunc
+	// lock 执行锁，保证同一时刻只有一个 goroutine 使用运行时，见 exclusive
+	lock *runLock
+	// owner 持有执行锁的执行的标记，不在执行中时为 nil，见 ownsLock
+	owner atomic.Pointer[lockOwner]
+	// overflow ConcurrencySpill 使用的溢出沙盒池，第一次溢出时创建
+	overflow   *SandboxPool
+	overflowMu sync.Mutex
+	// runCtx 当前执行的上下文，超时或取消时宿主函数据此中止阻塞操作
+	runCtx context.Context
+	// cancelRun 取消当前执行，原因为超出资源限制的错误，见 exceedLimit
+	cancelRun context.CancelCauseFunc
+	// loop 事件循环，驱动定时器、Promise 和异步宿主函数
+	loop *eventLoop
+	// fs 文件操作使用的文件系统
+	fs FileSystem
+	// jail 文件系统隔离，未配置根目录和挂载点时为 nil
+	jail *fsJail
+	// perms 宿主函数的权限集合，未配置时为 nil
+	perms *permissionSet
+	// egress 网络出站策略
+	egress *egressGuard
+	// modules CommonJS 模块注册表和缓存
+	modules *moduleRegistry
+	// httpTransport 受出站策略约束的 HTTP Transport，在请求之间复用连接
+	httpTransport *http.Transport
+	// errorClass 脚本中的 SandboxError 构造函数，不受脚本覆盖全局变量的影响
+	errorClass *goja.Object
+	// bufferClass 脚本中的 Buffer 构造函数，宿主函数用它返回二进制数据
+	bufferClass *goja.Object
+	// throwMark 标记 ErrorModeThrow 下已经包装过的宿主函数，避免重复包装
+	throwMark *goja.Symbol
+	// interceptMark 标记已经经过拦截器包装的宿主函数，避免重复包装
+	interceptMark *goja.Symbol
+	// lastFailure 最近一次 errorResult 生成的失败结果，见 resultCause
+	lastFailure atomic.Pointer[failedResult]
+	// interceptors 宿主函数调用经过的拦截器，配置了 Config.Metrics 和 Config.TracerProvider 时
+	// 外层依次为统计指标和创建 span 的拦截器
+	interceptors []HostInterceptor
+	// borrowCtx 从沙盒池借出时 Get 的 ctx，作为执行和执行 span 的父上下文，归还时清除，见 runParent
+	borrowCtx context.Context
+	// traceCtx 当前执行的 span 所在的上下文，见 startRunSpan
+	traceCtx context.Context
+	// output 正在捕获的脚本输出，不在 RunWithOutput 等方法中时为 nil
+	output *outputCapture
+	// console console.time、console.count、console.group 的状态
+	console *consoleState
 	// 浏览器相关的共享资源
 	browserAllocator context.Context
 	browserCancel    context.CancelFunc
 	browserMu        sync.Mutex
 	browserInit      bool
+	browserProxy     *egressProxy
+	// browserSessions 脚本打开的浏览器会话，会话结束时移除，沙盒池归还时全部关闭
+	browserSessions map[*BrowserSession]struct{}
+	// 以下字段用于沙盒池在两次借用之间重置运行时，见 captureGlobals
+	restoreGlobals goja.Callable
+	interrupted    bool // 是否有脚本因超时、取消或超出资源限制被中断
 }
 
 // NewSandbox 创建一个新的沙盒实例（使用默认配置）
@@ -32,13 +91,24 @@ func NewSandbox(ctx context.Context) *Sandbox {
 func NewSandboxWithConfig(ctx context.Context, config *Config) *Sandbox {
 	vm := goja.New()
 	logger := GetLogger()
+	ctx, cancel := context.WithCancel(ctx)
 
 	sb := &Sandbox{
-		vm:     vm,
-		logger: logger,
-		ctx:    ctx,
-		config: config,
+		vm:      vm,
+		logger:  logger,
+		ctx:     ctx,
+		config:  config,
+		cancel:  cancel,
+		lock:    newRunLock(),
+		loop:    newEventLoop(),
+		fs:      newSandboxFS(config),
+		jail:    newFSJail(config),
+		egress:  newEgressGuard(config.Egress, logger),
+		modules: newModuleRegistry(config),
 	}
+	sb.perms = newPermissionSet(config, sb.hasVirtualRoot())
+	sb.egress.permit = sb.checkNetPermission
+	sb.httpTransport = sb.egress.newTransport()
 
 	// 注册所有扩展功能
 	sb.registerExtensions()
@@ -54,12 +124,23 @@ func NewSandboxWithLogger(ctx context.Context, logger *logrus.Logger) *Sandbox {
 // NewSandboxWithLoggerAndConfig 使用自定义logger和配置创建沙盒
 func NewSandboxWithLoggerAndConfig(ctx context.Context, logger *logrus.Logger, config *Config) *Sandbox {
 	vm := goja.New()
+	ctx, cancel := context.WithCancel(ctx)
 	sb := &Sandbox{
-		vm:     vm,
-		logger: logger,
-		ctx:    ctx,
-		config: config,
+		vm:      vm,
+		logger:  logger,
+		ctx:     ctx,
+		config:  config,
+		cancel:  cancel,
+		lock:    newRunLock(),
+		loop:    newEventLoop(),
+		fs:      newSandboxFS(config),
+		jail:    newFSJail(config),
+		egress:  newEgressGuard(config.Egress, logger),
+		modules: newModuleRegistry(config),
 	}
+	sb.perms = newPermissionSet(config, sb.hasVirtualRoot())
+	sb.egress.permit = sb.checkNetPermission
+	sb.httpTransport = sb.egress.newTransport()
 	sb.registerExtensions()
 	return sb
 }
@@ -67,107 +148,388 @@ func NewSandboxWithLoggerAndConfig(ctx context.Context, logger *logrus.Logger, c
 // registerExtensions 注册所有扩展功能到JavaScript运行时
 // 根据配置选择性注册功能模块
 func (sb *Sandbox) registerExtensions() {
+	sb.interceptors = sb.config.Interceptors
+	if sb.tracing() {
+		sb.interceptors = append([]HostInterceptor{tracingInterceptor{sb}}, sb.interceptors...)
+	}
+	if sb.config.Metrics != nil {
+		sb.interceptors = append([]HostInterceptor{metricsInterceptor{sb.config.Metrics}}, sb.interceptors...)
+	}
+
+	// 应用资源限制（调用栈深度、字符串和数组长度），需要在注册宿主函数之前完成
+	sb.registerLimits()
+
+	// 注册 SandboxError 异常类型（始终启用），宿主函数按 Config.ErrorMode 抛出
+	sb.registerSandboxError()
+
 	// 注册系统操作（始终启用）
-	sb.registerSystemOps()
-
-	// 注册基础工具功能（始终启用）
-	sb.registerLogger()     // 日志功能
-	sb.registerCrypto()     // 加密/解密
-	sb.registerCompress()   // 压缩/解压缩
-	sb.registerCSV()        // CSV处理
-	sb.registerEnv()        // 环境变量和配置
-	sb.registerValidation() // 数据验证
-	sb.registerDateTime()   // 日期时间增强
-	sb.registerEncoding()   // 编码/解码增强
-	sb.registerProcess()    // 进程管理
-	sb.registerNetwork()    // 网络工具
-	sb.registerPath()       // 路径处理增强
-	sb.registerText()       // 文本操作
+	sb.registerBuiltinModule("system", sb.registerSystemOps)
+
+	// 注册事件循环（setTimeout、setInterval、queueMicrotask，始终启用）
+	sb.registerBuiltinModule("timers", sb.registerEventLoop)
+
+	// 注册 Buffer（始终启用），宿主函数通过它接收和返回二进制数据
+	sb.registerBuiltinModule("buffer", sb.registerBuffer)
+
+	// 注册基础工具功能（始终启用，命令执行、环境变量、网络和系统信息等受 Config.Permissions 控制）
+	// 每组宿主函数同时作为内置模块，可通过 require('crypto') 等方式获取
+	sb.registerBuiltinModule("logger", sb.registerLogger)         // 日志功能
+	sb.registerBuiltinModule("crypto", sb.registerCrypto)         // 加密/解密
+	sb.registerBuiltinModule("compress", sb.registerCompress)     // 压缩/解压缩
+	sb.registerBuiltinModule("csv", sb.registerCSV)               // CSV处理
+	sb.registerBuiltinModule("env", sb.registerEnv)               // 环境变量和配置
+	sb.registerBuiltinModule("validation", sb.registerValidation) // 数据验证
+	sb.registerBuiltinModule("datetime", sb.registerDateTime)     // 日期时间增强
+	sb.registerBuiltinModule("encoding", sb.registerEncoding)     // 编码/解码增强
+	sb.registerBuiltinModule("process", sb.registerProcess)       // 进程管理
+	sb.registerBuiltinModule("network", sb.registerNetwork)       // 网络工具
+	sb.registerBuiltinModule("path", sb.registerPath)             // 路径处理增强
+	sb.registerBuiltinModule("text", sb.registerText)             // 文本操作
 
 	// 根据配置选择性注册功能
 	if sb.config.EnableHTTP {
-		sb.registerHTTP()
+		sb.registerBuiltinModule("http", sb.registerHTTP)
 	}
 	if sb.config.EnableFileSystem {
-		sb.registerFileSystem()
+		sb.registerBuiltinModule("fs", sb.registerFileSystem)
 	}
 	if sb.config.EnableBrowser {
-		sb.registerBrowser()
+		sb.registerBuiltinModule("browser", sb.registerBrowser)
 	}
 	if sb.config.EnableDocuments {
-		sb.registerDocuments()
-		sb.registerDocx()
-		sb.registerExcel()
+		sb.registerBuiltinModule("pdf", sb.registerDocuments)
+		sb.registerBuiltinModule("docx", sb.registerDocx)
+		sb.registerBuiltinModule("excel", sb.registerExcel)
 	}
 	if sb.config.EnableImageProcessing {
-		sb.registerImageProcessing()
+		sb.registerBuiltinModule("image", sb.registerImageProcessing)
 	}
 	if sb.config.EnableGoQuery {
-		sb.registerGoQuery()
+		sb.registerBuiltinModule("goquery", sb.registerGoQuery)
 	}
 	// 文件类型检测始终启用（文件系统功能依赖它）
-	sb.registerFileTypeDetection()
+	sb.registerBuiltinModule("filetype", sb.registerFileTypeDetection)
+
+	// 注册 Config.Extensions 中的宿主扩展
+	sb.registerConfigExtensions()
+
+	// 注册 CommonJS require（始终启用，本地模块需要配置 Config.ModuleRoot）
+	sb.registerRequire()
 }
 
 // Run 执行JavaScript代码
+// 父上下文（从沙盒池借出时还包括 Get 的 ctx）被取消时会中断正在执行的脚本
+// 语法错误返回 ErrCodeSyntaxError，未捕获的异常返回 ErrCodeScriptError，
+// 两者的 SandboxError.Exception 中包含异常类型、调用栈、出错位置和源码片段
+// 可以在多个 goroutine 中调用，同一时刻只有一个执行，其他调用按 Config.Concurrency 处理；
+// 宿主代码中再次执行脚本要使用 RunContext，否则会一直排队
 func (sb *Sandbox) Run(code string) (goja.Value, error) {
-	return sb.vm.RunString(code)
+	return exclusive(sb, "Run", func(sb *Sandbox) (goja.Value, error) {
+		parent, release := sb.runParent()
+		defer release()
+		result, err := sb.runString(parent, code)
+		if err = runError(parent, sb.scriptError(err, "", code), 0); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
+
+// RunContext 与 Run 相同，ctx 结束时停止排队并中断执行
+// 在宿主代码中以收到的执行上下文调用时（见 SetContext）直接在当前执行中执行，不等待执行锁，
+// 脚本产生的 Promise 和定时器由当前执行的事件循环处理
+func (sb *Sandbox) RunContext(ctx context.Context, code string) (goja.Value, error) {
+	if sb.ownsLock(ctx) {
+		program, err := sb.compile(code)
+		if err != nil {
+			return nil, err
+		}
+		result, err := sb.vm.RunProgram(program)
+		return result, wrapRunError(sb.scriptError(err, "", code))
+	}
+	return exclusiveContext(sb, ctx, "RunContext", func(sb *Sandbox) (goja.Value, error) {
+		parent, release := sb.runParent()
+		defer release()
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		defer context.AfterFunc(parent, cancel)()
+		result, err := sb.runString(runCtx, code)
+		if err = runError(runCtx, sb.scriptError(err, "", code), 0); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
 }
 
 // RunWithTimeout 在指定超时时间内执行JavaScript代码
 // 如果 timeout 为 0，则使用配置中的默认超时时间
+// 超时或父上下文被取消时，脚本会通过 goja 的中断机制被真正停止，
+// 正在进行的宿主调用（HTTP请求、命令执行、sleep、浏览器操作等）也会被中止
+// 超时时间从取得执行权开始计算，排队等待的时间不计入
 func (sb *Sandbox) RunWithTimeout(code string, timeout time.Duration) (goja.Value, error) {
+	return exclusive(sb, "RunWithTimeout", func(sb *Sandbox) (goja.Value, error) {
+		return sb.runWithTimeout(code, timeout)
+	})
+}
+
+// runWithTimeout 实现 RunWithTimeout，调用方需持有执行锁
+func (sb *Sandbox) runWithTimeout(code string, timeout time.Duration) (goja.Value, error) {
 	if timeout == 0 {
 		timeout = sb.config.DefaultTimeout
 	}
 
-	ctx, cancel := context.WithTimeout(sb.ctx, timeout)
+	parent, release := sb.runParent()
+	defer release()
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
-	done := make(chan error, 1)
-	var result goja.Value
-	var err error
+	result, err := sb.runString(ctx, code)
+	if err = runError(ctx, sb.scriptError(err, "", code), timeout); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// runError 转换 run 返回的错误：执行被中断时按 ctx 返回超时或取消错误（见 contextError），
+// 脚本出错后才到期的上下文不影响错误的类型；其他错误见 wrapRunError
+func runError(ctx context.Context, err error, timeout time.Duration) error {
+	if errors.Is(err, errRunInterrupted) {
+		return contextError(ctx, timeout)
+	}
+	return wrapRunError(err)
+}
+
+// wrapRunError 把 scriptError 之后仍不是 SandboxError 的错误（如 Promise 未完成）包装为 ErrCodeUnknown
+func wrapRunError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*SandboxError); ok {
+		return err
+	}
+	return NewSandboxErrorWithCause(ErrCodeUnknown, "执行JavaScript代码失败", err)
+}
+
+// runString 在给定上下文中执行代码，语法错误返回 ErrCodeSyntaxError，见 run
+func (sb *Sandbox) runString(parent context.Context, code string) (goja.Value, error) {
+	return sb.run(parent, func() (goja.Value, error) {
+		program, err := sb.compile(code)
+		if err != nil {
+			return nil, err
+		}
+		return sb.vm.RunProgram(program)
+	})
+}
+
+// compile 编译 Run 等方法执行的代码，沙盒池中的沙盒把顶层的 let/const/class 声明放进块作用域，见 scopeLexical
+func (sb *Sandbox) compile(code string) (*goja.Program, error) {
+	program, prg, err := compileSource("", code)
+	if err != nil || sb.restoreGlobals == nil {
+		return program, err
+	}
+	scoped, err := scopeLexical(prg)
+	if err != nil {
+		return nil, syntaxError("", code, err)
+	}
+	if scoped != nil {
+		return scoped, nil
+	}
+	return program, nil
+}
+
+// run 在给定上下文中调用 exec 执行脚本，上下文结束时中断虚拟机
+// 脚本执行完后会持续运行事件循环直到空闲，若结果是 Promise 则返回其最终值
+// 超出资源限制时返回 ErrCodeResourceLimit 错误
+// 返回前会等待虚拟机真正停止并清除中断标记、重置事件循环，保证运行时可以继续复用
+func (sb *Sandbox) run(parent context.Context, exec func() (goja.Value, error)) (goja.Value, error) {
+	if parent.Err() != nil {
+		return nil, fmt.Errorf("%w: %w", errRunInterrupted, parent.Err())
+	}
+
+	ctx, stopLimits := sb.runLimits(parent)
+	ctx = sb.withLockOwner(sb.withTrace(ctx))
+	if RunIDFromContext(ctx) == "" {
+		ctx = withRunID(ctx)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("jssandbox.run_id", RunIDFromContext(ctx)))
+	}
+	prevCtx := sb.runCtx
+	sb.runCtx = ctx
+	defer func() { sb.runCtx = prevCtx }()
 
+	done := make(chan struct{})
+	watcherDone := make(chan struct{})
 	go func() {
-		result, err = sb.vm.RunString(code)
-		done <- err
+		defer close(watcherDone)
+		select {
+		case <-ctx.Done():
+			sb.vm.Interrupt(context.Cause(ctx))
+		case <-done:
+		}
 	}()
 
-	select {
-	case <-ctx.Done():
-		return nil, NewSandboxError(ErrCodeTimeout, fmt.Sprintf("执行超时: %v", timeout))
-	case err := <-done:
-		if err != nil {
-			return nil, NewSandboxErrorWithCause(ErrCodeUnknown, "执行JavaScript代码失败", err)
+	result, err := exec()
+	if err == nil {
+		err = sb.loop.run(ctx)
+	}
+	if err == nil {
+		result, err = sb.settle(result)
+	}
+	// 在执行结束时判断是否被中断，之后才到期的上下文不影响已经完成的执行
+	interrupted := ctx.Err() != nil
+
+	close(done)
+	<-watcherDone
+	var stackOverflow *goja.StackOverflowError
+	if errors.As(err, &stackOverflow) {
+		err = newResourceLimitError("调用栈深度超过限制 (最大允许 %d)", sb.config.MaxCallStackSize)
+		sb.logger.WithError(err).Warn("脚本超出资源限制，终止执行")
+		sb.interrupted = true
+		sb.config.Metrics.runInterrupted("resource_limit")
+	}
+	if interrupted {
+		sb.interrupted = true
+		reason := "canceled"
+		if cause := context.Cause(ctx); isResourceLimitError(cause) {
+			result, err = nil, cause
+			reason = "resource_limit"
+		} else if !isResourceLimitError(err) {
+			// 脚本被中断，或宿主函数中被中止的阻塞操作（如 sleep）正常返回、脚本在检查中断之前就执行完了，结果不完整
+			result, err = nil, fmt.Errorf("%w: %w", errRunInterrupted, ctx.Err())
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				reason = "timeout"
+			}
 		}
-		return result, nil
+		sb.config.Metrics.runInterrupted(reason)
+	}
+	stopLimits()
+	sb.vm.ClearInterrupt()
+	sb.loop.reset()
+
+	return result, err
+}
+
+// runContext 返回当前执行的上下文，不在执行中时返回沙盒的上下文
+// 宿主函数中的阻塞操作应使用它，以便在超时或取消时及时返回
+func (sb *Sandbox) runContext() context.Context {
+	if sb.runCtx != nil {
+		return sb.runCtx
+	}
+	return sb.ctx
+}
+
+// runParent 返回执行的父上下文和释放函数
+// 从沙盒池借出时为 Get 的 ctx，沙盒的上下文结束（如沙盒池关闭）时一并结束；否则为沙盒的上下文
+func (sb *Sandbox) runParent() (context.Context, context.CancelFunc) {
+	if sb.borrowCtx == nil {
+		return sb.ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(sb.borrowCtx)
+	stop := context.AfterFunc(sb.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// errRunInterrupted run 在执行结束时发现上下文已经结束（超时或取消），或上下文在执行前就已结束，见 runError
+var errRunInterrupted = errors.New("执行被中断")
+
+// contextError 将上下文结束的原因转换为沙盒错误
+func contextError(ctx context.Context, timeout time.Duration) *SandboxError {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		if timeout > 0 {
+			return NewSandboxError(ErrCodeTimeout, fmt.Sprintf("执行超时: %v", timeout))
+		}
+		return NewSandboxErrorWithCause(ErrCodeTimeout, "执行超时", ctx.Err())
 	}
+	return NewSandboxErrorWithCause(ErrCodeCanceled, "执行已取消", ctx.Err())
 }
 
 // Set 在JavaScript运行时中设置变量
+// 沙盒正在执行时等待执行结束，不受 Config.Concurrency 影响；宿主代码中请使用 SetContext，否则会一直等待。
+// value 为第一个参数是 context.Context 的 Go 函数时，脚本调用它会自动传入当前执行的上下文
 func (sb *Sandbox) Set(name string, value interface{}) {
-	sb.vm.Set(name, value)
+	defer sb.lockRuntime()()
+	sb.vm.Set(name, sb.hostFunc(value))
 }
 
-// Get 从JavaScript运行时中获取变量
+// Get 从JavaScript运行时中获取变量，等待方式与 Set 相同
 func (sb *Sandbox) Get(name string) goja.Value {
+	defer sb.lockRuntime()()
 	return sb.vm.Get(name)
 }
 
-// Delete 从JavaScript运行时中删除变量，返回是否删除成功
+// Delete 从JavaScript运行时中删除变量，返回是否删除成功，等待方式与 Set 相同
 func (sb *Sandbox) Delete(name string) error {
+	defer sb.lockRuntime()()
+	return sb.vm.GlobalObject().Delete(name)
+}
+
+// SetContext 与 Set 相同，ctx 为宿主代码收到的执行上下文时直接在当前执行中设置，不等待；
+// 等待期间 ctx 或沙盒的上下文结束时返回错误
+func (sb *Sandbox) SetContext(ctx context.Context, name string, value interface{}) error {
+	unlock, err := sb.lockRuntimeContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return sb.vm.Set(name, sb.hostFunc(value))
+}
+
+// GetContext 与 Get 相同，等待方式与 SetContext 相同
+func (sb *Sandbox) GetContext(ctx context.Context, name string) (goja.Value, error) {
+	unlock, err := sb.lockRuntimeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return sb.vm.Get(name), nil
+}
+
+// DeleteContext 与 Delete 相同，等待方式与 SetContext 相同
+func (sb *Sandbox) DeleteContext(ctx context.Context, name string) error {
+	unlock, err := sb.lockRuntimeContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	return sb.vm.GlobalObject().Delete(name)
 }
 
+// FileSystem 返回沙盒使用的文件系统，可在执行后查看或导出脚本生成的文件
+func (sb *Sandbox) FileSystem() FileSystem {
+	return sb.fs
+}
+
 // Close 关闭沙盒并清理资源
+// 正在进行的执行会被中断，排队中的调用返回 ErrCodeCanceled，Close 在执行停止后才清理资源，
+// 因此不能在宿主函数中调用
 func (sb *Sandbox) Close() error {
+	sb.cancel()
+	sb.lock.lock()
+	defer sb.lock.unlock()
+
+	sb.overflowMu.Lock()
+	if sb.overflow != nil {
+		sb.overflow.Close()
+	}
+	sb.overflowMu.Unlock()
+
+	// 停止未完成的定时器
+	sb.loop.reset()
+
 	// 关闭浏览器 allocator（如果已初始化）
 	sb.browserMu.Lock()
 	if sb.browserInit && sb.browserCancel != nil {
 		sb.browserCancel()
 		sb.browserInit = false
 	}
+	if sb.browserProxy != nil {
+		sb.browserProxy.Close()
+		sb.browserProxy = nil
+	}
 	sb.browserMu.Unlock()
+	sb.httpTransport.CloseIdleConnections()
 	// logrus 不需要显式同步
 	return nil
 }
@@ -2,10 +2,13 @@ package jssandbox
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"testing"
 	"time"
 
+	"github.com/dop251/goja"
 	"github.com/sirupsen/logrus"
 )
 
@@ -250,3 +253,120 @@ func TestSandbox_ContextCancellation(t *testing.T) {
 		t.Logf("RunWithTimeout在context取消后返回错误: %v", err)
 	}
 }
+
+func TestSandbox_RunWithTimeout_Interrupt(t *testing.T) {
+	ctx := context.Background()
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	t.Run("死循环被中断", func(t *testing.T) {
+		start := time.Now()
+		_, err := sb.RunWithTimeout("while(true){}", 100*time.Millisecond)
+		if err == nil {
+			t.Fatal("RunWithTimeout()应该返回超时错误")
+		}
+		sandboxErr, ok := err.(*SandboxError)
+		if !ok || !sandboxErr.IsTimeout() {
+			t.Fatalf("错误类型不正确, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("中断耗时过长: %v", elapsed)
+		}
+	})
+
+	t.Run("sleep被中断", func(t *testing.T) {
+		start := time.Now()
+		_, err := sb.RunWithTimeout("sleep(10000); 'done'", 100*time.Millisecond)
+		if err == nil {
+			t.Fatal("RunWithTimeout()应该返回超时错误")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("sleep未被中止, 耗时: %v", elapsed)
+		}
+	})
+
+	t.Run("中断后运行时可复用", func(t *testing.T) {
+		result, err := sb.RunWithTimeout("1 + 1", time.Second)
+		if err != nil {
+			t.Fatalf("中断后再次执行失败: %v", err)
+		}
+		if result.ToInteger() != 2 {
+			t.Errorf("执行结果不正确, got %d, want 2", result.ToInteger())
+		}
+	})
+}
+
+func TestSandbox_RunWithTimeout_ParentCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := sb.RunWithTimeout("while(true){}", 10*time.Second)
+	if err == nil {
+		t.Fatal("父上下文取消后应该返回错误")
+	}
+	sandboxErr, ok := err.(*SandboxError)
+	if !ok || !sandboxErr.IsCanceled() {
+		t.Fatalf("错误类型不正确, got %v", err)
+	}
+}
+
+func TestSandbox_Run_ParentCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sb := NewSandbox(ctx)
+	defer sb.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := sb.Run("while(true){}")
+	if err == nil {
+		t.Fatal("父上下文取消后Run()应该返回错误")
+	}
+	sandboxErr, ok := err.(*SandboxError)
+	if !ok || !sandboxErr.IsCanceled() {
+		t.Fatalf("错误类型不正确, got %v", err)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+
+	// 脚本出错后才到期的上下文不影响错误的类型
+	scriptErr := &SandboxError{Code: ErrCodeScriptError, Message: "执行JavaScript代码失败"}
+	if err := runError(expired, scriptErr, time.Second); err != scriptErr {
+		t.Errorf("runError(脚本错误) = %v, want %v", err, scriptErr)
+	}
+
+	err := runError(expired, fmt.Errorf("%w: %w", errRunInterrupted, expired.Err()), time.Second)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsTimeout() {
+		t.Errorf("runError(被中断) = %v, want %s", err, ErrCodeTimeout)
+	}
+}
+
+func TestSandbox_Run_WrapsErrors(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	// Run 与 RunWithTimeout 一样把不是 SandboxError 的错误包装为 ErrCodeUnknown
+	for name, run := range map[string]func(string) (goja.Value, error){
+		"Run":            sb.Run,
+		"RunWithTimeout": func(code string) (goja.Value, error) { return sb.RunWithTimeout(code, time.Second) },
+	} {
+		_, err := run(`new Promise(function () {})`)
+		var sbErr *SandboxError
+		if !errors.As(err, &sbErr) || sbErr.Code != ErrCodeUnknown {
+			t.Errorf("%s() error = %v, want %s", name, err, ErrCodeUnknown)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package jssandbox
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultScriptCacheSize 默认脚本缓存可以保存的编译结果数量
+const DefaultScriptCacheSize = 256
+
+// Script 预编译的 JavaScript 脚本
+// 编译结果与运行时无关，可以在任意沙盒（包括沙盒池中的不同沙盒）中并发执行
+type Script struct {
+	program *goja.Program
+	// source 源码，用于在错误中截取出错位置附近的代码
+	source string
+	hash   string
+	// scoped 顶层声明了 let/const/class 时放进块作用域编译的版本，沙盒池中的沙盒执行它，见 scopeLexical
+	scoped *goja.Program
+}
+
+// Hash 返回脚本源码的 SHA-256 十六进制摘要，即脚本在缓存中的键
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// ScriptCacheStats 脚本缓存的运行指标
+type ScriptCacheStats struct {
+	// Size 当前缓存的脚本数量
+	Size int
+	// Capacity 缓存容量
+	Capacity int
+	// Hits 累计命中次数
+	Hits int64
+	// Misses 累计未命中（需要编译）的次数
+	Misses int64
+	// Evictions 累计因超出容量而淘汰的脚本数量
+	Evictions int64
+}
+
+// ScriptCache 按源码哈希缓存编译结果的 LRU 缓存，可以被多个 goroutine 同时使用
+type ScriptCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 元素为 *Script，最近使用的在前
+	items    map[string]*list.Element
+	stats    ScriptCacheStats
+}
+
+// NewScriptCache 创建最多保存 capacity 个脚本的缓存，capacity <= 0 时使用 DefaultScriptCacheSize
+func NewScriptCache(capacity int) *ScriptCache {
+	if capacity <= 0 {
+		capacity = DefaultScriptCacheSize
+	}
+	return &ScriptCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+var defaultScriptCache = NewScriptCache(DefaultScriptCacheSize)
+
+// DefaultScriptCache 返回 Compile 使用的全局脚本缓存
+func DefaultScriptCache() *ScriptCache {
+	return defaultScriptCache
+}
+
+// Compile 使用全局脚本缓存编译脚本，相同源码只编译一次
+func Compile(code string) (*Script, error) {
+	return defaultScriptCache.Compile(code)
+}
+
+// Compile 编译脚本，源码相同时直接返回缓存的编译结果
+// 语法错误返回 ErrCodeSyntaxError，编译失败的源码不会被缓存
+func (c *ScriptCache) Compile(code string) (*Script, error) {
+	sum := sha256.Sum256([]byte(code))
+	hash := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	if elem, ok := c.items[hash]; ok {
+		c.order.MoveToFront(elem)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return elem.Value.(*Script), nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	script, err := compileScript(code, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 并发编译同一段源码时保留先放入缓存的结果
+	if elem, ok := c.items[hash]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*Script), nil
+	}
+	c.items[hash] = c.order.PushFront(script)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*Script).hash)
+		c.stats.Evictions++
+	}
+	return script, nil
+}
+
+// Stats 返回脚本缓存的运行指标
+func (c *ScriptCache) Stats() ScriptCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.order.Len()
+	stats.Capacity = c.capacity
+	return stats
+}
+
+// Purge 清空缓存，累计指标保持不变
+func (c *ScriptCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// compileScript 解析并编译脚本，与 Run 一样按非严格模式的全局代码编译
+func compileScript(code, hash string) (*Script, error) {
+	program, prg, err := compileSource("", code)
+	if err != nil {
+		return nil, err
+	}
+	scoped, err := scopeLexical(prg)
+	if err != nil {
+		return nil, syntaxError("", code, err)
+	}
+	return &Script{program: program, scoped: scoped, source: code, hash: hash}, nil
+}
+
+// RunScript 执行预编译的脚本，inputs 中的值在执行期间作为全局变量提供给脚本，
+// 执行结束后恢复为执行前的状态；返回值与 Run 相同
+func (sb *Sandbox) RunScript(script *Script, inputs map[string]interface{}) (goja.Value, error) {
+	return exclusive(sb, "RunScript", func(sb *Sandbox) (goja.Value, error) {
+		parent, release := sb.runParent()
+		defer release()
+		result, err := sb.runScript(parent, script, inputs)
+		if err = runError(parent, err, 0); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
+
+// RunScriptWithTimeout 在指定超时时间内执行预编译的脚本，超时处理与 RunWithTimeout 相同
+func (sb *Sandbox) RunScriptWithTimeout(script *Script, inputs map[string]interface{}, timeout time.Duration) (goja.Value, error) {
+	return exclusive(sb, "RunScriptWithTimeout", func(sb *Sandbox) (goja.Value, error) {
+		if timeout == 0 {
+			timeout = sb.config.DefaultTimeout
+		}
+
+		parent, release := sb.runParent()
+		defer release()
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		result, err := sb.runScript(ctx, script, inputs)
+		if err = runError(ctx, err, timeout); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
+
+// runScript 设置输入变量后执行预编译的脚本，见 run
+func (sb *Sandbox) runScript(ctx context.Context, script *Script, inputs map[string]interface{}) (goja.Value, error) {
+	if script == nil {
+		return nil, NewSandboxError(ErrCodeInvalidInput, "脚本不能为空")
+	}
+	defer sb.setInputs(inputs)()
+	result, err := sb.run(ctx, func() (goja.Value, error) {
+		if script.scoped != nil && sb.restoreGlobals != nil {
+			return sb.vm.RunProgram(script.scoped)
+		}
+		return sb.vm.RunProgram(script.program)
+	})
+	return result, sb.scriptError(err, "", script.source)
+}
+
+// setInputs 把 inputs 设置为全局变量，返回恢复原有全局变量的函数
+func (sb *Sandbox) setInputs(inputs map[string]interface{}) func() {
+	if len(inputs) == 0 {
+		return func() {}
+	}
+	global := sb.vm.GlobalObject()
+	existing := make(map[string]bool)
+	for _, name := range global.GetOwnPropertyNames() {
+		existing[name] = true
+	}
+	previous := make(map[string]goja.Value, len(inputs))
+	for name, value := range inputs {
+		if existing[name] {
+			previous[name] = global.Get(name)
+		}
+		global.Set(name, value)
+	}
+	return func() {
+		for name := range inputs {
+			if value, ok := previous[name]; ok {
+				global.Set(name, value)
+			} else {
+				global.Delete(name)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScriptCache(t *testing.T) {
+	cache := NewScriptCache(2)
+
+	a1, err := cache.Compile(`1 + 1`)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	a2, _ := cache.Compile(`1 + 1`)
+	if a1 != a2 {
+		t.Error("相同源码应该返回缓存的脚本")
+	}
+	cache.Compile(`2 + 2`)
+	cache.Compile(`1 + 1`) // 使 "1 + 1" 成为最近使用
+	cache.Compile(`3 + 3`) // 淘汰 "2 + 2"
+
+	if _, err := cache.Compile(`var = 1`); err == nil {
+		t.Error("语法错误应该返回错误")
+	} else if sbErr, ok := err.(*SandboxError); !ok || sbErr.Code != ErrCodeSyntaxError {
+		t.Errorf("error = %v, want %s", err, ErrCodeSyntaxError)
+	}
+
+	want := ScriptCacheStats{Size: 2, Capacity: 2, Hits: 2, Misses: 4, Evictions: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if a3, _ := cache.Compile(`1 + 1`); a3 != a1 {
+		t.Error("最近使用的脚本不应该被淘汰")
+	}
+
+	cache.Purge()
+	if got := cache.Stats(); got.Size != 0 || got.Hits != 3 {
+		t.Errorf("Purge() 后 Stats() = %+v", got)
+	}
+}
+
+func TestRunScript_Inputs(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	script, err := Compile(`greeting + ', ' + name + '!'`)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if _, err := sb.Run(`var greeting = 'hi'`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"alice", "bob"} {
+		result, err := sb.RunScript(script, map[string]interface{}{"greeting": "hello", "name": name})
+		if err != nil {
+			t.Fatalf("RunScript() error = %v", err)
+		}
+		if want := "hello, " + name + "!"; result.String() != want {
+			t.Errorf("result = %q, want %q", result, want)
+		}
+	}
+
+	// 执行结束后恢复原有的全局变量，删除新增的输入变量
+	result, err := sb.Run(`greeting + ' ' + typeof name`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.String() != "hi undefined" {
+		t.Errorf("result = %q, want %q", result, "hi undefined")
+	}
+}
+
+func TestRunScriptWithTimeout(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	script, _ := Compile(`while (true) {}`)
+	_, err := sb.RunScriptWithTimeout(script, nil, 100*time.Millisecond)
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || !sbErr.IsTimeout() {
+		t.Fatalf("error = %v, want timeout", err)
+	}
+
+	script, _ = Compile(`new Promise(function (resolve) { setTimeout(function () { resolve(n * 2); }, 1); })`)
+	result, err := sb.RunScriptWithTimeout(script, map[string]interface{}{"n": 21}, time.Second)
+	if err != nil {
+		t.Fatalf("RunScriptWithTimeout() error = %v", err)
+	}
+	if result.ToInteger() != 42 {
+		t.Errorf("result = %v, want 42", result)
+	}
+
+	if _, err := sb.RunScript(nil, nil); err == nil {
+		t.Error("nil 脚本应该返回错误")
+	}
+}
+
+func TestRunScript_Pool(t *testing.T) {
+	pool := newTestPool(t, DefaultPoolConfig().WithMinIdle(2).WithMaxSize(4))
+	cache := NewScriptCache(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			script, err := cache.Compile(`var doubled = n * 2; 'result:' + doubled`)
+			if err != nil {
+				t.Errorf("Compile() error = %v", err)
+				return
+			}
+			err = pool.Do(context.Background(), func(sb *Sandbox) error {
+				result, err := sb.RunScript(script, map[string]interface{}{"n": i})
+				if err != nil {
+					return err
+				}
+				if want := fmt.Sprintf("result:%d", i*2); result.String() != want {
+					t.Errorf("result = %q, want %q", result, want)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Do() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Size != 1 || stats.Hits+stats.Misses != 16 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// 顶层声明 let/const 的脚本在块作用域中执行，沙盒可以重置和复用，多次执行不会重复声明
+	lexical, _ := cache.Compile(`const total = 1; total`)
+	before := pool.Stats().Discarded
+	for i := 0; i < 2; i++ {
+		if err := pool.Do(context.Background(), func(sb *Sandbox) error {
+			result, err := sb.RunScript(lexical, nil)
+			if err == nil && result.ToInteger() != 1 {
+				err = fmt.Errorf("RunScript() = %v", result)
+			}
+			return err
+		}); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+	if got := pool.Stats().Discarded; got != before {
+		t.Errorf("Discarded = %d, want %d", got, before)
+	}
+}
@@ -1,9 +1,7 @@
 package jssandbox
 
 import (
-	"fmt"
 	"runtime"
-	"strings"
 	"time"
 
 	"github.com/dop251/goja"
@@ -26,11 +24,17 @@ func (sb *Sandbox) registerSystemOps() {
 		return time.Now().Format("2006-01-02 15:04:05")
 	})
 
-	sb.vm.Set("getCPUNum", func() int {
-		return runtime.NumCPU()
+	sb.vm.Set("getCPUNum", func() (int, error) {
+		if err := sb.checkPermission(PermSysInfo, "getCPUNum"); err != nil {
+			return 0, err
+		}
+		return runtime.NumCPU(), nil
 	})
 
 	sb.vm.Set("getMemorySize", func(call goja.FunctionCall) goja.Value {
+		if err := sb.checkPermission(PermSysInfo, "getMemorySize"); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 		vm, _ := mem.VirtualMemory()
 		if vm == nil {
 			return sb.vm.ToValue(map[string]interface{}{
@@ -53,10 +57,17 @@ func (sb *Sandbox) registerSystemOps() {
 	})
 
 	sb.vm.Set("getDiskSize", func(call goja.FunctionCall) goja.Value {
+		if err := sb.checkPermission(PermSysInfo, "getDiskSize"); err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 		path := "/"
 		if len(call.Arguments) > 0 {
 			path = call.Arguments[0].String()
 		}
+		path, err := sb.resolvePath(path, fsRead)
+		if err != nil {
+			return sb.vm.ToValue(sb.errorResult(err))
+		}
 
 		usage, err := disk.Usage(path)
 		if err != nil {
@@ -82,49 +93,15 @@ func (sb *Sandbox) registerSystemOps() {
 	})
 
 	sb.vm.Set("sleep", func(ms int) {
-		time.Sleep(time.Duration(ms) * time.Millisecond)
+		timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+		defer timer.Stop()
+		// 执行超时或被取消时立即返回，由虚拟机中断机制终止脚本
+		select {
+		case <-timer.C:
+		case <-sb.runContext().Done():
+		}
 	})
 
 	// 注册 console 对象
-	consoleObj := sb.vm.NewObject()
-	
-	// 辅助函数：格式化 console 参数
-	formatConsoleArgs := func(call goja.FunctionCall) string {
-		if len(call.Arguments) == 0 {
-			return ""
-		}
-		parts := make([]string, len(call.Arguments))
-		for i, arg := range call.Arguments {
-			if goja.IsUndefined(arg) {
-				parts[i] = "undefined"
-			} else if goja.IsNull(arg) {
-				parts[i] = "null"
-			} else {
-				parts[i] = fmt.Sprintf("%v", arg.Export())
-			}
-		}
-		return strings.Join(parts, " ")
-	}
-	
-	consoleObj.Set("log", func(call goja.FunctionCall) goja.Value {
-		sb.logger.Info(formatConsoleArgs(call))
-		return goja.Undefined()
-	})
-	consoleObj.Set("error", func(call goja.FunctionCall) goja.Value {
-		sb.logger.Error(formatConsoleArgs(call))
-		return goja.Undefined()
-	})
-	consoleObj.Set("warn", func(call goja.FunctionCall) goja.Value {
-		sb.logger.Warn(formatConsoleArgs(call))
-		return goja.Undefined()
-	})
-	consoleObj.Set("info", func(call goja.FunctionCall) goja.Value {
-		sb.logger.Info(formatConsoleArgs(call))
-		return goja.Undefined()
-	})
-	consoleObj.Set("debug", func(call goja.FunctionCall) goja.Value {
-		sb.logger.Debug(formatConsoleArgs(call))
-		return goja.Undefined()
-	})
-	sb.vm.Set("console", consoleObj)
+	sb.registerConsole()
 }
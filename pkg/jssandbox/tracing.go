@@ -0,0 +1,181 @@
+package jssandbox
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dop251/goja"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName 沙盒创建 span 使用的 Tracer 名称
+const tracerName = "github.com/mozhou-tech/jssandbox-go/pkg/jssandbox"
+
+// defaultPropagator 未配置 Config.Propagator 时使用的 W3C Trace Context 和 Baggage 传播器
+var defaultPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// targetAttributes 宿主函数第一个字符串参数对应的 span 属性，按模块区分
+var targetAttributes = map[string]attribute.Key{
+	"fs":       "file.path",
+	"csv":      "file.path",
+	"pdf":      "file.path",
+	"docx":     "file.path",
+	"excel":    "file.path",
+	"image":    "file.path",
+	"compress": "file.path",
+	"filetype": "file.path",
+	"http":     "url.full",
+	"process":  "process.command_line",
+	"network":  "server.address",
+}
+
+// tracing 是否配置了 Config.TracerProvider，未配置时不为宿主函数调用创建 span
+func (sb *Sandbox) tracing() bool {
+	return sb.config.TracerProvider != nil
+}
+
+// tracer 返回创建 span 的 Tracer，未配置 TracerProvider 时返回的 span 不记录，
+// 但仍携带父 span 的上下文，HTTP 请求照常传播调用方的追踪信息
+func (sb *Sandbox) tracer() trace.Tracer {
+	tp := sb.config.TracerProvider
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(tracerName, trace.WithInstrumentationVersion(Version))
+}
+
+// propagator 返回向 HTTP 请求头注入追踪信息的传播器
+func (sb *Sandbox) propagator() propagation.TextMapPropagator {
+	if sb.config.Propagator != nil {
+		return sb.config.Propagator
+	}
+	return defaultPropagator
+}
+
+// callerContext 返回执行 span 的父上下文：从沙盒池借出时为 Get 的 ctx，否则为创建沙盒的 ctx
+func (sb *Sandbox) callerContext() context.Context {
+	if sb.borrowCtx != nil {
+		return sb.borrowCtx
+	}
+	return sb.ctx
+}
+
+// startRunSpan 为执行方法 op 创建 span，返回的函数按执行结果结束 span
+func (sb *Sandbox) startRunSpan(op string) func(err error) {
+	ctx, span := sb.tracer().Start(sb.callerContext(), "jssandbox."+op, trace.WithAttributes(attribute.String("jssandbox.method", op)))
+	prev := sb.traceCtx
+	sb.traceCtx = ctx
+	return func(err error) {
+		sb.traceCtx = prev
+		span.SetAttributes(attribute.String("jssandbox.outcome", runOutcome(err)))
+		endSpan(span, err)
+	}
+}
+
+// withTrace 把当前执行的 span 和 baggage 放入执行上下文 ctx，宿主函数和 HTTP 请求据此创建子 span
+func (sb *Sandbox) withTrace(ctx context.Context) context.Context {
+	if sb.traceCtx == nil {
+		return ctx
+	}
+	ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(sb.traceCtx))
+	if b := baggage.FromContext(sb.traceCtx); b.Len() > 0 {
+		ctx = baggage.ContextWithBaggage(ctx, b)
+	}
+	return ctx
+}
+
+// endSpan 按错误设置 span 的状态并结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startHTTPSpan 为 HTTP 请求创建客户端 span，并把追踪信息注入请求头
+// 请求头中脚本显式设置的同名字段优先
+func (sb *Sandbox) startHTTPSpan(ctx context.Context, req *http.Request) (context.Context, trace.Span) {
+	ctx, span := sb.tracer().Start(ctx, "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.String()),
+			attribute.String("server.address", req.URL.Hostname()),
+		))
+	sb.propagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return ctx, span
+}
+
+// tracingInterceptor 为每次宿主函数调用创建当前执行的子 span，配置了 Config.TracerProvider 时启用
+type tracingInterceptor struct {
+	sb *Sandbox
+}
+
+// Intercept 实现 HostInterceptor 接口
+// 调用期间执行上下文替换为宿主函数的 span，宿主函数中的 HTTP 请求等操作成为它的子 span
+func (i tracingInterceptor) Intercept(call *HostCall, next HostInvoker) (goja.Value, error) {
+	sb := i.sb
+	attrs := []attribute.KeyValue{
+		attribute.String("jssandbox.module", call.Module),
+		attribute.String("jssandbox.function", call.Name),
+	}
+	if key, ok := targetAttribute(call); ok {
+		attrs = append(attrs, key.String(call.Args[0].String()))
+	}
+	ctx, span := sb.tracer().Start(call.Context, call.Name, trace.WithAttributes(attrs...))
+	prev := sb.runCtx
+	sb.runCtx, call.Context = ctx, ctx
+	returned := false
+	defer func() {
+		sb.runCtx = prev
+		if !returned {
+			// 宿主函数抛出了脚本异常或执行被中断
+			span.SetStatus(codes.Error, "宿主函数抛出异常")
+			span.End()
+		}
+	}()
+
+	result, err := next(call)
+	returned = true
+	if obj, ok := result.(*goja.Object); ok {
+		setResultAttributes(span, obj)
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+// targetAttribute 返回调用的目标（文件路径、URL、命令）对应的 span 属性
+func targetAttribute(call *HostCall) (attribute.Key, bool) {
+	if len(call.Args) == 0 {
+		return "", false
+	}
+	if _, ok := call.Args[0].Export().(string); !ok {
+		return "", false
+	}
+	if call.Module == "browser" && strings.HasSuffix(call.Name, ".navigate") {
+		return "url.full", true
+	}
+	key, ok := targetAttributes[call.Module]
+	return key, ok
+}
+
+// setResultAttributes 从 { success, status, code } 结果对象中记录 HTTP 状态码和命令退出码
+func setResultAttributes(span trace.Span, obj *goja.Object) {
+	if v := obj.Get("status"); v != nil && !goja.IsUndefined(v) {
+		if _, ok := v.Export().(int64); ok {
+			span.SetAttributes(attribute.Int64("http.response.status_code", v.ToInteger()))
+		}
+	}
+	if v := obj.Get("code"); v != nil && !goja.IsUndefined(v) {
+		if _, ok := v.Export().(int64); ok {
+			span.SetAttributes(attribute.Int64("process.exit.code", v.ToInteger()))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package jssandbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerServer 记录收到的 traceparent 请求头
+func headerServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var headers []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Get("traceparent"))
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), headers...)
+	}
+}
+
+// findSpan 按名称查找 span
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	t.Fatalf("没有找到 span %q, got %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+// spanAttr 返回 span 的属性值
+func spanAttr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_RunAndHostCalls(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	server, headers := headerServer(t)
+
+	ctx, step := tp.Tracer("test").Start(context.Background(), "agent-step")
+	config := DefaultConfig().WithTracerProvider(tp).WithPrivateNetwork(true)
+	sb := NewSandboxWithConfig(ctx, config)
+	defer sb.Close()
+	sb.Set("url", server.URL)
+
+	if _, err := sb.Run(`httpGet(url); readFile('/no/such/file.txt'); encodeBase64('x')`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	step.End()
+	spans := exporter.GetSpans()
+
+	run := findSpan(t, spans, "jssandbox.Run")
+	if run.Parent.SpanID() != step.SpanContext().SpanID() || run.SpanContext.TraceID() != step.SpanContext().TraceID() {
+		t.Errorf("执行的 span 应是调用方 span 的子 span")
+	}
+	if spanAttr(run, "jssandbox.run_id").AsString() == "" || spanAttr(run, "jssandbox.outcome").AsString() != "success" {
+		t.Errorf("执行 span 的属性 = %v", run.Attributes)
+	}
+
+	httpGet := findSpan(t, spans, "httpGet")
+	httpRequest := findSpan(t, spans, "httpRequest")
+	client := findSpan(t, spans, "HTTP GET")
+	if httpGet.Parent.SpanID() != run.SpanContext.SpanID() || httpRequest.Parent.SpanID() != httpGet.SpanContext.SpanID() ||
+		client.Parent.SpanID() != httpRequest.SpanContext.SpanID() {
+		t.Errorf("宿主函数的 span 应依次嵌套: run -> httpGet -> httpRequest -> HTTP GET")
+	}
+	if spanAttr(httpGet, "url.full").AsString() != server.URL || spanAttr(httpRequest, "http.response.status_code").AsInt64() != 200 {
+		t.Errorf("httpGet/httpRequest 属性 = %v / %v", httpGet.Attributes, httpRequest.Attributes)
+	}
+	if client.SpanKind != trace.SpanKindClient || spanAttr(client, "http.response.status_code").AsInt64() != 200 {
+		t.Errorf("HTTP 客户端 span = %+v", client)
+	}
+
+	got := headers()
+	if len(got) != 1 || !strings.Contains(got[0], client.SpanContext.TraceID().String()+"-"+client.SpanContext.SpanID().String()) {
+		t.Errorf("traceparent 请求头 = %v, want 包含 HTTP 客户端 span", got)
+	}
+
+	readFile := findSpan(t, spans, "readFile")
+	if readFile.Status.Code != codes.Error || spanAttr(readFile, "file.path").AsString() != "/no/such/file.txt" {
+		t.Errorf("readFile span = %v %v", readFile.Status, readFile.Attributes)
+	}
+	if encode := findSpan(t, spans, "encodeBase64"); encode.Status.Code == codes.Error || spanAttr(encode, "jssandbox.module").AsString() != "encoding" {
+		t.Errorf("encodeBase64 span = %v %v", encode.Status, encode.Attributes)
+	}
+}
+
+func TestTracing_PoolAndTimeout(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	pool, err := NewSandboxPool(context.Background(), DefaultPoolConfig().WithSandboxConfig(DefaultConfig().WithTracerProvider(tp)))
+	if err != nil {
+		t.Fatalf("NewSandboxPool() error = %v", err)
+	}
+	defer pool.Close()
+
+	ctx, step := tp.Tracer("test").Start(context.Background(), "agent-step")
+	sb, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	sb.RunWithTimeout(`while (true) {}`, 50*time.Millisecond)
+	pool.Put(sb)
+	step.End()
+
+	run := findSpan(t, exporter.GetSpans(), "jssandbox.RunWithTimeout")
+	if run.Parent.SpanID() != step.SpanContext().SpanID() {
+		t.Errorf("借出的沙盒执行的 span 应是 Get 的 ctx 中 span 的子 span")
+	}
+	if run.Status.Code != codes.Error || spanAttr(run, "jssandbox.outcome").AsString() != "timeout" {
+		t.Errorf("超时执行的 span = %v %v", run.Status, run.Attributes)
+	}
+}
+
+func TestTracing_PropagateWithoutProvider(t *testing.T) {
+	server, headers := headerServer(t)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+
+	sb := NewSandboxWithConfig(ctx, DefaultConfig().WithPrivateNetwork(true))
+	defer sb.Close()
+	sb.Set("url", server.URL)
+	if _, err := sb.Run(`fetch(url).then(r => r.text())`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := headers(); len(got) != 1 || got[0] != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("traceparent 请求头 = %v", got)
+	}
+}
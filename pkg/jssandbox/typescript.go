@@ -0,0 +1,151 @@
+package jssandbox
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// tsMainPath TypeScript 代码字符串的虚拟路径，用于错误位置和解析相对导入
+const tsMainPath = "/main.ts"
+
+// RunTypeScript 把 TypeScript 代码转译为 JavaScript 后执行，返回值与 Run 相同
+// 类型注解在执行前被去除，新语法降级为 goja 支持的 ES2017；错误调用栈中的位置对应 TypeScript 源码的行列。
+// 使用 import/export 的代码按 ES 模块执行（见 RunModule），返回模块的命名空间对象
+func (sb *Sandbox) RunTypeScript(code string) (goja.Value, error) {
+	return sb.runSourceInContext("RunTypeScript", moduleEntry{code: code, path: tsMainPath, loader: api.LoaderTS})
+}
+
+// RunTypeScriptWithTimeout 在指定超时时间内执行 TypeScript 代码，超时处理与 RunWithTimeout 相同
+func (sb *Sandbox) RunTypeScriptWithTimeout(code string, timeout time.Duration) (goja.Value, error) {
+	return sb.runSourceWithTimeout("RunTypeScriptWithTimeout", moduleEntry{code: code, path: tsMainPath, loader: api.LoaderTS}, timeout)
+}
+
+// RunFile 读取并执行宿主机上的脚本文件，按扩展名选择语言：
+// .ts/.mts/.cts 为 TypeScript，.tsx 为 TSX，.jsx 为 JSX，其他按 JavaScript 执行
+// 文件位于 Config.ModuleRoot 中时，其中的相对导入相对于文件所在目录解析，否则相对于模块根目录
+func (sb *Sandbox) RunFile(filename string) (goja.Value, error) {
+	entry, err := sb.fileEntry(filename)
+	if err != nil {
+		return nil, err
+	}
+	return sb.runSourceInContext("RunFile", entry)
+}
+
+// RunFileWithTimeout 在指定超时时间内执行脚本文件，超时处理与 RunWithTimeout 相同
+func (sb *Sandbox) RunFileWithTimeout(filename string, timeout time.Duration) (goja.Value, error) {
+	entry, err := sb.fileEntry(filename)
+	if err != nil {
+		return nil, err
+	}
+	return sb.runSourceWithTimeout("RunFileWithTimeout", entry, timeout)
+}
+
+// fileEntry 读取脚本文件，确定其语言和虚拟路径
+func (sb *Sandbox) fileEntry(filename string) (moduleEntry, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return moduleEntry{}, NewSandboxErrorWithCause(ErrCodeFileSystemError, "读取脚本文件失败: "+filename, err)
+	}
+	virtual := "/" + filepath.Base(filename)
+	if root := sb.modules.root; root != nil {
+		if host := realHostPath(filename); pathWithin(host, root.mounts[0].host) {
+			virtual = root.virtualPath(host)
+		}
+	}
+	return moduleEntry{code: string(src), path: virtual, loader: sourceLoader(filename)}, nil
+}
+
+// runSourceInContext 在执行的父上下文（见 runParent）中执行 runSource，父上下文被取消时会中断正在执行的脚本，op 为执行方法名
+func (sb *Sandbox) runSourceInContext(op string, entry moduleEntry) (goja.Value, error) {
+	return exclusive(sb, op, func(sb *Sandbox) (goja.Value, error) {
+		parent, release := sb.runParent()
+		defer release()
+		result, err := sb.runSource(parent, entry)
+		if err = runError(parent, err, 0); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
+
+// runSource 执行脚本或模块：JavaScript 脚本直接执行，其他语言先转译，ES 模块打包后执行并返回命名空间对象
+func (sb *Sandbox) runSource(ctx context.Context, entry moduleEntry) (goja.Value, error) {
+	if isModuleSource(entry.code, entry.loader) {
+		ns, err := sb.runModuleCode(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		return ns, nil
+	}
+	// 转译后的代码通过 source map 把位置对应到 entry.path，直接执行的 JavaScript 没有文件名
+	code, main := entry.code, ""
+	if entry.loader != api.LoaderJS {
+		var err error
+		if code, err = transpile(code, entry.path, entry.loader, api.FormatDefault); err != nil {
+			return nil, err
+		}
+		main = entry.path
+	}
+	result, err := sb.runString(ctx, code)
+	return result, sb.scriptError(err, main, entry.code)
+}
+
+// runSourceWithTimeout 在指定超时时间内执行 runSource，错误处理与 RunWithTimeout 相同
+func (sb *Sandbox) runSourceWithTimeout(op string, entry moduleEntry, timeout time.Duration) (goja.Value, error) {
+	return exclusive(sb, op, func(sb *Sandbox) (goja.Value, error) {
+		if timeout == 0 {
+			timeout = sb.config.DefaultTimeout
+		}
+
+		parent, release := sb.runParent()
+		defer release()
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		result, err := sb.runSource(ctx, entry)
+		if err = runError(ctx, err, timeout); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
+
+// sourceLoader 按文件扩展名选择 esbuild 加载器
+func sourceLoader(name string) api.Loader {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".ts", ".mts", ".cts":
+		return api.LoaderTS
+	case ".tsx":
+		return api.LoaderTSX
+	case ".jsx":
+		return api.LoaderJSX
+	case ".json":
+		return api.LoaderJSON
+	default:
+		return api.LoaderJS
+	}
+}
+
+// transpile 把 TypeScript/JSX 代码转译为 goja 可以执行的 JavaScript，并在末尾内联 source map
+// format 为 api.FormatDefault 时保留脚本语义（最后一个表达式的值仍是执行结果）
+func transpile(code, name string, loader api.Loader, format api.Format) (string, error) {
+	result := api.Transform(code, api.TransformOptions{
+		Loader:     loader,
+		Format:     format,
+		Target:     api.ES2017,
+		Sourcefile: name,
+		Sourcemap:  api.SourceMapInline,
+		LogLevel:   api.LogLevelSilent,
+	})
+	if len(result.Errors) > 0 {
+		return "", buildError("代码编译失败", result.Errors)
+	}
+	return string(result.Code), nil
+}
@@ -0,0 +1,139 @@
+package jssandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTypeScript(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	result, err := sb.RunTypeScript(`
+		enum Level { Low = 1, High }
+		interface Item<T> { value: T; level?: Level }
+		class Box<T> {
+			constructor(private readonly items: Item<T>[]) {}
+			get total(): number { return this.items.length * Level.High; }
+			first = (): T | undefined => this.items[0]?.value;
+		}
+		const box = new Box<string>([{ value: 'a' }, { value: 'b', level: Level.Low }]);
+		const { total } = box;
+		[total, box.first() ?? 'none', typeof (null as unknown as string)];
+	`)
+	if err != nil {
+		t.Fatalf("RunTypeScript() error = %v", err)
+	}
+	got := result.Export().([]interface{})
+	want := []interface{}{int64(4), "a", "object"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 项 = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	result, err = sb.RunTypeScriptWithTimeout(`
+		async function double(n: number): Promise<number> { return n * 2; }
+		double(21);
+	`, time.Second)
+	if err != nil {
+		t.Fatalf("RunTypeScriptWithTimeout() error = %v", err)
+	}
+	if result.ToInteger() != 42 {
+		t.Errorf("result = %v, want 42", result)
+	}
+}
+
+func TestRunTypeScript_SourceMap(t *testing.T) {
+	sb := newModuleSandbox(t, map[string]string{
+		"lib/util.ts": "interface P { n: number }\n\nexport function boom(p: P): number {\n  throw new Error('bad ' + p.n);\n}\n",
+	})
+
+	tests := []struct {
+		name     string
+		run      func() error
+		contains []string
+	}{
+		{"脚本", func() error {
+			_, err := sb.RunTypeScript("type X = { a: number };\nconst x: X = { a: 1 };\n\n\nfunction f(v: number): never {\n  throw new Error('fail ' + v);\n}\nf(x.a);\n")
+			return err
+		}, []string{"main.ts:6:"}},
+		{"require 加载 .ts 模块", func() error {
+			_, err := sb.Run(`require('./lib/util').boom({ n: 5 })`)
+			return err
+		}, []string{"/lib/util.ts:4:"}},
+		{"ES 模块", func() error {
+			result, err := sb.RunTypeScript("import { boom } from './lib/util';\nconst n: number = 3;\nlet stack = '';\ntry { boom({ n }); } catch (e) { stack = e.stack; }\nexport default stack;\n")
+			if err != nil {
+				return err
+			}
+			return errors.New(result.ToObject(sb.vm).Get("default").String())
+		}, []string{"lib/util.ts:4:", "main.ts:4:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("error = %v, 应该包含 %q", err, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTypeScript_CompileError(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	_, err := sb.RunTypeScript("const a: number = 1;\nconst b: = 2;")
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || sbErr.Code != ErrCodeSyntaxError {
+		t.Fatalf("error = %v, want %s", err, ErrCodeSyntaxError)
+	}
+	if !strings.Contains(err.Error(), "main.ts:2:") {
+		t.Errorf("error = %v, 应该包含错误位置", err)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	sb := newModuleSandbox(t, map[string]string{
+		"scripts/helper.ts": "export const greet = (name: string): string => `hello ${name}`;",
+		"scripts/main.ts":   "import { greet } from './helper';\nexport default greet('ts');",
+	})
+	root := sb.config.ModuleRoot
+
+	result, err := sb.RunFile(filepath.Join(root, "scripts", "main.ts"))
+	if err != nil {
+		t.Fatalf("RunFile(main.ts) error = %v", err)
+	}
+	if got := result.ToObject(sb.vm).Get("default").String(); got != "hello ts" {
+		t.Errorf("default = %q, want hello ts", got)
+	}
+
+	outside := filepath.Join(t.TempDir(), "plain.js")
+	if err := os.WriteFile(outside, []byte("var n = 20; n + 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = sb.RunFileWithTimeout(outside, time.Second)
+	if err != nil {
+		t.Fatalf("RunFileWithTimeout(plain.js) error = %v", err)
+	}
+	if result.ToInteger() != 21 {
+		t.Errorf("result = %v, want 21", result)
+	}
+
+	_, err = sb.RunFile(filepath.Join(root, "missing.ts"))
+	var sbErr *SandboxError
+	if !errors.As(err, &sbErr) || sbErr.Code != ErrCodeFileSystemError {
+		t.Errorf("error = %v, want %s", err, ErrCodeFileSystemError)
+	}
+}
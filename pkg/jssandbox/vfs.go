@@ -0,0 +1,163 @@
+package jssandbox
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileSystem 沙盒文件操作使用的文件系统
+//
+// 文件系统、CSV、Excel、Word、PDF、图片和压缩等宿主函数都通过该接口访问文件。
+// 内置三种实现：
+//   - NewOSFileSystem：直接访问宿主机磁盘（默认），可配合 FileSystemRoot / Mounts 进行隔离
+//   - NewMemFileSystem：完全在内存中，不接触磁盘
+//   - NewOverlayFileSystem：以宿主机目录为只读底层，所有修改写入内存（写时复制）
+//
+// 路径使用正斜杠分隔；内存和叠加文件系统把相对路径视为相对于 "/"。
+type FileSystem interface {
+	// Open 以只读方式打开文件
+	Open(name string) (File, error)
+	// OpenFile 按 os.O_* 标志打开文件
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	// Stat 返回文件信息
+	Stat(name string) (os.FileInfo, error)
+	// ReadDir 返回按名称排序的目录项
+	ReadDir(name string) ([]os.DirEntry, error)
+	// Mkdir 创建目录，父目录必须存在
+	Mkdir(name string, perm os.FileMode) error
+	// MkdirAll 递归创建目录
+	MkdirAll(name string, perm os.FileMode) error
+	// Remove 删除文件或空目录
+	Remove(name string) error
+	// RemoveAll 递归删除，路径不存在时不返回错误
+	RemoveAll(name string) error
+	// Rename 重命名或移动文件、目录
+	Rename(oldname, newname string) error
+}
+
+// File 由 FileSystem 打开的文件，*os.File 满足该接口
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	// Name 返回打开时使用的路径
+	Name() string
+	// Stat 返回文件信息
+	Stat() (os.FileInfo, error)
+}
+
+// osFileSystem 直接访问宿主机磁盘的文件系统
+type osFileSystem struct{}
+
+// NewOSFileSystem 创建访问宿主机磁盘的文件系统，这是沙盒的默认文件系统
+func NewOSFileSystem() FileSystem {
+	return osFileSystem{}
+}
+
+func (osFileSystem) Open(name string) (File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+func (osFileSystem) ReadDir(name string) ([]os.DirEntry, error) { return os.ReadDir(name) }
+
+func (osFileSystem) Mkdir(name string, perm os.FileMode) error { return os.Mkdir(name, perm) }
+
+func (osFileSystem) MkdirAll(name string, perm os.FileMode) error { return os.MkdirAll(name, perm) }
+
+func (osFileSystem) Remove(name string) error { return os.Remove(name) }
+
+func (osFileSystem) RemoveAll(name string) error { return os.RemoveAll(name) }
+
+func (osFileSystem) Rename(oldname, newname string) error { return os.Rename(oldname, newname) }
+
+// isOSFileSystem 判断文件系统是否直接访问宿主机磁盘
+func isOSFileSystem(fsys FileSystem) bool {
+	_, ok := fsys.(osFileSystem)
+	return ok
+}
+
+// newSandboxFS 返回配置中的文件系统，未配置时使用宿主机文件系统
+func newSandboxFS(config *Config) FileSystem {
+	if config.FileSystem != nil {
+		return config.FileSystem
+	}
+	return osFileSystem{}
+}
+
+// hasVirtualRoot 判断脚本看到的是否为以 "/" 为根的虚拟文件系统（启用隔离或使用非宿主机文件系统）
+func (sb *Sandbox) hasVirtualRoot() bool {
+	return sb.jail != nil || !isOSFileSystem(sb.fs)
+}
+
+// realPath 展开宿主机路径中已存在部分的符号链接，用于判断路径是否仍在目标目录内
+// 非宿主机文件系统没有符号链接，返回清理后的虚拟路径
+func (sb *Sandbox) realPath(p string) (string, error) {
+	if isOSFileSystem(sb.fs) {
+		return evalExistingPath(p)
+	}
+	return cleanVirtualPath(p), nil
+}
+
+// readFileFS 读取文件的全部内容
+func readFileFS(fsys FileSystem, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// writeFileFS 写入文件，文件存在时覆盖
+func writeFileFS(fsys FileSystem, name string, data []byte, perm os.FileMode) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// createTempFS 在 dir 中创建新的临时文件，pattern 的规则与 os.CreateTemp 相同
+func createTempFS(fsys FileSystem, dir, pattern string) (File, error) {
+	if strings.ContainsAny(pattern, `/\`) {
+		return nil, &os.PathError{Op: "createtemp", Path: pattern, Err: errors.New("pattern contains path separator")}
+	}
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		f, err := fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
@@ -0,0 +1,280 @@
+package jssandbox
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMemFileSystem(t *testing.T) {
+	fsys := NewMemFileSystem()
+
+	t.Run("读写和追加", func(t *testing.T) {
+		if err := writeFileFS(fsys, "notes.txt", []byte("hello"), 0644); err != nil {
+			t.Fatalf("writeFileFS() error = %v", err)
+		}
+		f, err := fsys.OpenFile("/notes.txt", os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		io.WriteString(f, " world")
+		f.Close()
+
+		data, err := fsys.ReadFile("/notes.txt")
+		if err != nil || string(data) != "hello world" {
+			t.Errorf("ReadFile() = %q, %v", data, err)
+		}
+	})
+
+	t.Run("Seek和ReadAt", func(t *testing.T) {
+		f, err := fsys.Open("notes.txt")
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer f.Close()
+		f.Seek(6, io.SeekStart)
+		buf := make([]byte, 5)
+		if n, _ := f.Read(buf); string(buf[:n]) != "world" {
+			t.Errorf("Seek后读取内容不正确, got %q", buf[:n])
+		}
+		if n, _ := f.ReadAt(buf[:4], 1); string(buf[:n]) != "ello" {
+			t.Errorf("ReadAt()内容不正确, got %q", buf[:n])
+		}
+		if _, err := f.Write([]byte("x")); err == nil {
+			t.Error("只读打开的文件不应该允许写入")
+		}
+	})
+
+	t.Run("目录操作", func(t *testing.T) {
+		if err := fsys.Mkdir("/a/b", 0755); !os.IsNotExist(err) {
+			t.Errorf("父目录不存在时Mkdir应该失败, got %v", err)
+		}
+		if err := fsys.MkdirAll("/a/b", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeFileFS(fsys, "/a/b/z.txt", []byte("z"), 0644)
+		writeFileFS(fsys, "/a/b/y.txt", []byte("y"), 0644)
+
+		entries, err := fsys.ReadDir("/a/b")
+		if err != nil || len(entries) != 2 || entries[0].Name() != "y.txt" {
+			t.Errorf("ReadDir()应该按名称排序, got %v, %v", entries, err)
+		}
+		if err := fsys.Remove("/a"); err == nil {
+			t.Error("非空目录不应该被Remove删除")
+		}
+		if err := fsys.Rename("/a", "/c"); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+		if data, _ := fsys.ReadFile("/c/b/z.txt"); string(data) != "z" {
+			t.Error("重命名目录后子项应该随之移动")
+		}
+		if _, err := fsys.Stat("/a/b/z.txt"); !os.IsNotExist(err) {
+			t.Error("重命名后原路径不应该存在")
+		}
+		if err := fsys.RemoveAll("/c"); err != nil {
+			t.Fatalf("RemoveAll() error = %v", err)
+		}
+		if _, err := fsys.Stat("/c/b"); !os.IsNotExist(err) {
+			t.Error("RemoveAll应该删除所有子项")
+		}
+	})
+
+	t.Run("导出到宿主机目录", func(t *testing.T) {
+		fsys.WriteFile("/out/report.txt", []byte("report"), 0644)
+		dir := t.TempDir()
+		if err := fsys.Export(dir); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "out", "report.txt")); string(data) != "report" {
+			t.Errorf("导出的文件内容不正确, got %q", data)
+		}
+		if files := fsys.Files(); !reflect.DeepEqual(files, []string{"/notes.txt", "/out/report.txt"}) {
+			t.Errorf("Files() = %v", files)
+		}
+	})
+}
+
+func TestOverlayFileSystem(t *testing.T) {
+	host := t.TempDir()
+	os.MkdirAll(filepath.Join(host, "docs"), 0755)
+	os.WriteFile(filepath.Join(host, "docs", "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(host, "docs", "b.txt"), []byte("b"), 0644)
+
+	overlay := NewOverlayFileSystem(host)
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithFileSystem(overlay))
+	defer sb.Close()
+
+	t.Run("读取宿主机文件", func(t *testing.T) {
+		result, err := sb.Run(`readFile("/docs/a.txt").data`)
+		if err != nil || result.String() != "a" {
+			t.Errorf("应该能读取底层文件, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("修改和删除不影响宿主机", func(t *testing.T) {
+		_, err := sb.Run(`
+			appendFile("/docs/a.txt", "+");
+			deleteFile("/docs/b.txt");
+			writeFile("/docs/c.txt", "c");
+			renameFile("/docs", "/moved");
+		`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		result, err := sb.Run(`[listDir("/moved").entries.map(e => e.name), readFile("/moved/a.txt").data, pathExists("/docs")]`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		got := result.Export().([]interface{})
+		if names := got[0].([]interface{}); len(names) != 2 || names[0] != "a.txt" || names[1] != "c.txt" {
+			t.Errorf("合并后的目录内容不正确, got %v", names)
+		}
+		if got[1] != "a+" || got[2] != false {
+			t.Errorf("叠加视图不正确, got %v", got)
+		}
+
+		if data, _ := os.ReadFile(filepath.Join(host, "docs", "a.txt")); string(data) != "a" {
+			t.Errorf("宿主机文件不应该被修改, got %q", data)
+		}
+		if _, err := os.Stat(filepath.Join(host, "docs", "b.txt")); err != nil {
+			t.Error("宿主机文件不应该被删除")
+		}
+		if _, err := os.Stat(filepath.Join(host, "moved")); !os.IsNotExist(err) {
+			t.Error("不应该在宿主机上创建文件")
+		}
+		if removed := overlay.Removed(); !reflect.DeepEqual(removed, []string{"/docs"}) {
+			t.Errorf("Removed() = %v", removed)
+		}
+		if files := overlay.Upper().Files(); !reflect.DeepEqual(files, []string{"/moved/a.txt", "/moved/c.txt"}) {
+			t.Errorf("Upper().Files() = %v", files)
+		}
+	})
+
+	t.Run("重新创建被删除的目录不显示底层内容", func(t *testing.T) {
+		if err := overlay.Mkdir("/docs", 0755); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		entries, err := overlay.ReadDir("/docs")
+		if err != nil || len(entries) != 0 {
+			t.Errorf("重新创建的目录应该为空, got %v, %v", entries, err)
+		}
+	})
+
+	t.Run("符号链接不能逃逸底层目录", func(t *testing.T) {
+		outside := t.TempDir()
+		os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+		if err := os.Symlink(outside, filepath.Join(host, "link")); err != nil {
+			t.Skipf("无法创建符号链接: %v", err)
+		}
+		result, err := sb.Run(`readFile("/link/secret.txt")`)
+		if err != nil {
+			t.Fatalf("readFile() error = %v", err)
+		}
+		resultObj := result.ToObject(sb.vm)
+		if errVal := resultObj.Get("error"); errVal == nil || !strings.Contains(errVal.String(), string(ErrCodeAccessDenied)) {
+			t.Errorf("应该拒绝访问底层目录之外的文件, got %v", resultObj.Export())
+		}
+	})
+}
+
+func TestSandbox_MemFileSystem(t *testing.T) {
+	fsys := NewMemFileSystem()
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	fsys.WriteFile("/input/photo.png", img.Bytes(), 0644)
+
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithFileSystem(fsys))
+	defer sb.Close()
+
+	t.Run("文件系统函数", func(t *testing.T) {
+		result, err := sb.Run(`
+			writeFile("hello.txt", "hello");
+			appendFile("hello.txt", "!");
+			makeDir("/work");
+			const tmp = createTempFile({dir: "/work"}).path;
+			[readFile("/hello.txt").data, pwd().path, tmp.startsWith("/work/"), getFileInfo("/hello.txt").size];
+		`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		got := result.Export().([]interface{})
+		if got[0] != "hello!" || got[1] != "/" || got[2] != true || got[3] != int64(6) {
+			t.Errorf("内存文件系统中的文件操作结果不正确, got %v", got)
+		}
+		if _, err := os.Stat("hello.txt"); !os.IsNotExist(err) {
+			os.Remove("hello.txt")
+			t.Error("不应该在宿主机磁盘上创建文件")
+		}
+	})
+
+	t.Run("CSV和压缩", func(t *testing.T) {
+		result, err := sb.Run(`
+			writeCSV("/table.csv", [["a", "b"], ["1", "2"]]);
+			compressZip(["/table.csv", "/hello.txt"], "/archive.zip");
+			[readCSV(extractZip("/archive.zip", "/unzipped").files[0]).count, listDir("/unzipped").entries.length];
+		`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		got := result.Export().([]interface{})
+		if got[0] != int64(2) || got[1] != int64(2) {
+			t.Errorf("CSV和ZIP操作结果不正确, got %v", got)
+		}
+	})
+
+	t.Run("图片和文档", func(t *testing.T) {
+		result, err := sb.Run(`
+			makeDir("/output");
+			imageResize("/input/photo.png", "/output/small.png", 4);
+
+			const f = excelNew();
+			excelSetCellValue(f, "Sheet1", "A1", "内存");
+			excelSave(f, "/output/book.xlsx");
+			excelClose(f);
+
+			const doc = docxNew();
+			docxAddParagraph(doc, "内存文档");
+			docxSave(doc, "/output/doc.docx");
+
+			[imageInfo("/output/small.png").width, readExcel("/output/book.xlsx", {}).rows[0][0], docxReadText("/output/doc.docx")];
+		`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		got := result.Export().([]interface{})
+		if got[0] != int64(4) || got[1] != "内存" || !strings.Contains(got[2].(string), "内存文档") {
+			t.Errorf("图片和文档操作结果不正确, got %v", got)
+		}
+	})
+
+	t.Run("PDF", func(t *testing.T) {
+		result, err := sb.Run(`
+			[
+				pdfImportImages(["/input/photo.png", "/input/photo.png"], "/output/doc.pdf").success,
+				pdfGetPageCount("/output/doc.pdf").pages,
+				pdfSplit("/output/doc.pdf", "/output/split").success,
+				pdfExtractPages("/output/doc.pdf", "/output/pages", ["2"]).success,
+			];
+		`)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		got := result.Export().([]interface{})
+		if got[0] != true || got[1] != int64(2) || got[2] != true || got[3] != true {
+			t.Fatalf("PDF操作结果不正确, got %v", got)
+		}
+		for _, name := range []string{"/output/split/doc_1.pdf", "/output/split/doc_2.pdf", "/output/pages/doc_page_2.pdf"} {
+			if _, err := fsys.Stat(name); err != nil {
+				t.Errorf("应该生成 %s: %v", name, err)
+			}
+		}
+	})
+}
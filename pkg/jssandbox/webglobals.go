@@ -0,0 +1,1052 @@
+package jssandbox
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// webGlobalsSource 定义浏览器和 Node.js 中通用的 Web 全局对象，解析和编解码由 native 中的宿主函数完成
+// 返回的对象中的每个属性注册为同名全局变量
+const webGlobalsSource = `(function (native) {
+	'use strict';
+
+	var enqueue = native.queueMicrotask;
+	var typedArrays = {
+		Int8Array: Int8Array, Uint8Array: Uint8Array, Uint8ClampedArray: Uint8ClampedArray,
+		Int16Array: Int16Array, Uint16Array: Uint16Array, Int32Array: Int32Array, Uint32Array: Uint32Array,
+		Float32Array: Float32Array, Float64Array: Float64Array, BigInt64Array: BigInt64Array, BigUint64Array: BigUint64Array
+	};
+	var errorTypes = {
+		Error: Error, EvalError: EvalError, RangeError: RangeError, ReferenceError: ReferenceError,
+		SyntaxError: SyntaxError, TypeError: TypeError, URIError: URIError
+	};
+	var toTag = Object.prototype.toString;
+
+	// 各类对象的内部状态，脚本无法访问
+	var events = new WeakMap();
+	var targets = new WeakMap();
+	var signals = new WeakMap();
+	var controllers = new WeakMap();
+	var urls = new WeakMap();
+	var params = new WeakMap();
+	var decoders = new WeakMap();
+
+	function internal(map, obj) {
+		var s = map.get(obj);
+		if (s === undefined) {
+			throw new TypeError('Illegal invocation');
+		}
+		return s;
+	}
+
+	function requireArgs(args, n, name) {
+		if (args.length < n) {
+			throw new TypeError(name + ': ' + n + ' argument' + (n > 1 ? 's' : '') + ' required, but only ' + args.length + ' present.');
+		}
+	}
+
+	// usv 转换为 USVString，单独的代理项替换为 U+FFFD
+	function usv(v) {
+		return native.usv(String(v));
+	}
+
+	function define(proto, tag) {
+		Object.defineProperty(proto, Symbol.toStringTag, { value: tag, configurable: true });
+	}
+
+	var domExceptionCodes = {
+		IndexSizeError: 1, HierarchyRequestError: 3, WrongDocumentError: 4, InvalidCharacterError: 5,
+		NoModificationAllowedError: 7, NotFoundError: 8, NotSupportedError: 9, InvalidStateError: 11,
+		SyntaxError: 12, InvalidModificationError: 13, NamespaceError: 14, InvalidAccessError: 15,
+		TypeMismatchError: 17, SecurityError: 18, NetworkError: 19, AbortError: 20, URLMismatchError: 21,
+		QuotaExceededError: 22, TimeoutError: 23, InvalidNodeTypeError: 24, DataCloneError: 25
+	};
+
+	class DOMException extends Error {
+		constructor(message, name) {
+			super(message === undefined ? '' : String(message));
+			Object.defineProperty(this, 'name', { value: name === undefined ? 'Error' : String(name), writable: true, configurable: true });
+		}
+		get code() {
+			return domExceptionCodes[this.name] || 0;
+		}
+	}
+	define(DOMException.prototype, 'DOMException');
+	Object.keys(domExceptionCodes).forEach(function (name) {
+		var constant = name.replace(/([a-z])([A-Z])/g, '$1_$2').replace(/([A-Z]+)([A-Z][a-z])/g, '$1_$2').toUpperCase().replace(/_ERROR$/, '_ERR');
+		Object.defineProperty(DOMException, constant, { value: domExceptionCodes[name], enumerable: true });
+	});
+
+	class Event {
+		constructor(type, init) {
+			requireArgs(arguments, 1, "Failed to construct 'Event'");
+			init = init || {};
+			events.set(this, {
+				type: String(type), bubbles: !!init.bubbles, cancelable: !!init.cancelable, composed: !!init.composed,
+				target: null, currentTarget: null, phase: 0, timeStamp: native.now(),
+				canceled: false, passive: false, stop: false, stopImmediate: false, dispatching: false
+			});
+		}
+		get type() { return internal(events, this).type; }
+		get bubbles() { return internal(events, this).bubbles; }
+		get cancelable() { return internal(events, this).cancelable; }
+		get composed() { return internal(events, this).composed; }
+		get target() { return internal(events, this).target; }
+		get srcElement() { return internal(events, this).target; }
+		get currentTarget() { return internal(events, this).currentTarget; }
+		get eventPhase() { return internal(events, this).phase; }
+		get timeStamp() { return internal(events, this).timeStamp; }
+		get isTrusted() { return false; }
+		get defaultPrevented() { return internal(events, this).canceled; }
+		get returnValue() { return !internal(events, this).canceled; }
+		get cancelBubble() { return internal(events, this).stop; }
+		set cancelBubble(v) { if (v) { internal(events, this).stop = true; } }
+		preventDefault() {
+			var e = internal(events, this);
+			if (e.cancelable && !e.passive) {
+				e.canceled = true;
+			}
+		}
+		stopPropagation() { internal(events, this).stop = true; }
+		stopImmediatePropagation() {
+			var e = internal(events, this);
+			e.stop = e.stopImmediate = true;
+		}
+		composedPath() {
+			var e = internal(events, this);
+			return e.dispatching ? [e.currentTarget] : [];
+		}
+	}
+	define(Event.prototype, 'Event');
+	['NONE', 'CAPTURING_PHASE', 'AT_TARGET', 'BUBBLING_PHASE'].forEach(function (name, i) {
+		Object.defineProperty(Event, name, { value: i, enumerable: true });
+		Object.defineProperty(Event.prototype, name, { value: i, enumerable: true });
+	});
+
+	function listenerOptions(options) {
+		if (typeof options === 'boolean' || options === undefined || options === null) {
+			return { capture: !!options };
+		}
+		return { capture: !!options.capture, once: !!options.once, passive: !!options.passive, signal: options.signal };
+	}
+
+	// reportError 监听器中未捕获的异常与 Node.js 相同作为未捕获的异常处理，不影响其他监听器的执行
+	function reportError(err) {
+		enqueue(function () {
+			throw err;
+		});
+	}
+
+	class EventTarget {
+		constructor() {
+			targets.set(this, Object.create(null));
+		}
+		addEventListener(type, callback, options) {
+			requireArgs(arguments, 2, "Failed to execute 'addEventListener' on 'EventTarget'");
+			var listeners = internal(targets, this);
+			var opts = listenerOptions(options);
+			if (callback === null || callback === undefined) {
+				return;
+			}
+			var signal = opts.signal;
+			if (signal !== undefined && internal(signals, signal).aborted) {
+				return;
+			}
+			type = String(type);
+			var list = listeners[type] || (listeners[type] = []);
+			for (var i = 0; i < list.length; i++) {
+				if (list[i].callback === callback && list[i].capture === opts.capture) {
+					return;
+				}
+			}
+			list.push({ callback: callback, capture: opts.capture, once: !!opts.once, passive: !!opts.passive, removed: false });
+			if (signal !== undefined) {
+				var self = this;
+				dispatchOn(signal).push({
+					callback: function () { removeListener(self, type, callback, opts.capture); },
+					capture: false, once: true, passive: false, removed: false
+				});
+			}
+		}
+		removeEventListener(type, callback, options) {
+			requireArgs(arguments, 2, "Failed to execute 'removeEventListener' on 'EventTarget'");
+			internal(targets, this);
+			removeListener(this, String(type), callback, listenerOptions(options).capture);
+		}
+		dispatchEvent(event) {
+			requireArgs(arguments, 1, "Failed to execute 'dispatchEvent' on 'EventTarget'");
+			var listeners = internal(targets, this);
+			var e = events.get(event);
+			if (e === undefined) {
+				throw new TypeError("Failed to execute 'dispatchEvent' on 'EventTarget': parameter 1 is not of type 'Event'.");
+			}
+			if (e.dispatching) {
+				throw new DOMException('The event is already being dispatched.', 'InvalidStateError');
+			}
+			e.dispatching = true;
+			e.target = e.currentTarget = this;
+			e.phase = 2;
+			var list = (listeners[e.type] || []).slice();
+			for (var i = 0; i < list.length && !e.stopImmediate; i++) {
+				var l = list[i];
+				if (l.removed) {
+					continue;
+				}
+				if (l.once) {
+					removeListener(this, e.type, l.callback, l.capture);
+				}
+				e.passive = l.passive;
+				try {
+					if (typeof l.callback === 'function') {
+						l.callback.call(this, event);
+					} else if (typeof l.callback.handleEvent === 'function') {
+						l.callback.handleEvent(event);
+					}
+				} catch (err) {
+					reportError(err);
+				}
+				e.passive = false;
+			}
+			e.dispatching = false;
+			e.currentTarget = null;
+			e.phase = 0;
+			e.stop = e.stopImmediate = false;
+			return !e.canceled;
+		}
+	}
+	define(EventTarget.prototype, 'EventTarget');
+
+	function dispatchOn(target) {
+		var listeners = internal(targets, target);
+		return listeners.abort || (listeners.abort = []);
+	}
+
+	function removeListener(target, type, callback, capture) {
+		var list = internal(targets, target)[type];
+		if (list === undefined) {
+			return;
+		}
+		for (var i = 0; i < list.length; i++) {
+			if (list[i].callback === callback && list[i].capture === capture) {
+				list[i].removed = true;
+				list.splice(i, 1);
+				return;
+			}
+		}
+	}
+
+	var dispatchEvent = EventTarget.prototype.dispatchEvent;
+	var signalToken = {};
+
+	class AbortSignal extends EventTarget {
+		constructor(token) {
+			if (token !== signalToken) {
+				throw new TypeError('Illegal constructor');
+			}
+			super();
+			signals.set(this, { aborted: false, reason: undefined, onabort: null });
+		}
+		get aborted() { return internal(signals, this).aborted; }
+		get reason() { return internal(signals, this).reason; }
+		get onabort() { return internal(signals, this).onabort; }
+		set onabort(handler) {
+			internal(signals, this).onabort = typeof handler === 'function' ? handler : null;
+		}
+		throwIfAborted() {
+			var s = internal(signals, this);
+			if (s.aborted) {
+				throw s.reason;
+			}
+		}
+		static abort(reason) {
+			var signal = new AbortSignal(signalToken);
+			abortSignal(signal, reason);
+			return signal;
+		}
+		static timeout(ms) {
+			requireArgs(arguments, 1, "Failed to execute 'timeout' on 'AbortSignal'");
+			var signal = new AbortSignal(signalToken);
+			native.timeout(function () {
+				abortSignal(signal, new DOMException('The operation was aborted due to timeout', 'TimeoutError'));
+			}, Number(ms));
+			return signal;
+		}
+		static any(list) {
+			requireArgs(arguments, 1, "Failed to execute 'any' on 'AbortSignal'");
+			var result = new AbortSignal(signalToken);
+			var sources = Array.from(list);
+			for (var i = 0; i < sources.length; i++) {
+				var s = internal(signals, sources[i]);
+				if (s.aborted) {
+					abortSignal(result, s.reason);
+					return result;
+				}
+			}
+			sources.forEach(function (source) {
+				dispatchOn(source).push({
+					callback: function () { abortSignal(result, internal(signals, source).reason); },
+					capture: false, once: true, passive: false, removed: false
+				});
+			});
+			return result;
+		}
+	}
+	define(AbortSignal.prototype, 'AbortSignal');
+
+	function abortSignal(signal, reason) {
+		var s = internal(signals, signal);
+		if (s.aborted) {
+			return;
+		}
+		s.aborted = true;
+		s.reason = reason === undefined ? new DOMException('This operation was aborted', 'AbortError') : reason;
+		var event = new Event('abort');
+		if (s.onabort !== null) {
+			try {
+				s.onabort.call(signal, event);
+			} catch (err) {
+				reportError(err);
+			}
+		}
+		dispatchEvent.call(signal, event);
+	}
+
+	class AbortController {
+		constructor() {
+			controllers.set(this, new AbortSignal(signalToken));
+		}
+		get signal() { return internal(controllers, this); }
+		abort(reason) {
+			abortSignal(internal(controllers, this), reason);
+		}
+	}
+	define(AbortController.prototype, 'AbortController');
+
+	function invalidURL(input, base) {
+		var err = new TypeError('Invalid URL');
+		err.code = 'ERR_INVALID_URL';
+		err.input = input;
+		if (base !== undefined) {
+			err.base = base;
+		}
+		return err;
+	}
+
+	function parsePairs(query) {
+		var flat = native.parseForm(query);
+		var list = [];
+		for (var i = 0; i < flat.length; i += 2) {
+			list.push([flat[i], flat[i + 1]]);
+		}
+		return list;
+	}
+
+	class URL {
+		constructor(url, base) {
+			requireArgs(arguments, 1, "Failed to construct 'URL'");
+			url = usv(url);
+			if (base !== undefined) {
+				base = usv(base);
+			}
+			var parts = native.parseURL(url, base);
+			if (parts === null) {
+				throw invalidURL(url, base);
+			}
+			var query = new URLSearchParams();
+			var p = params.get(query);
+			p.list = parsePairs(parts.search.slice(1));
+			p.url = this;
+			urls.set(this, { parts: parts, query: query });
+		}
+		static canParse(url, base) {
+			requireArgs(arguments, 1, "Failed to execute 'canParse' on 'URL'");
+			return native.parseURL(usv(url), base === undefined ? undefined : usv(base)) !== null;
+		}
+		static parse(url, base) {
+			requireArgs(arguments, 1, "Failed to execute 'parse' on 'URL'");
+			return URL.canParse(url, base) ? new URL(url, base) : null;
+		}
+		get href() { return internal(urls, this).parts.href; }
+		set href(value) {
+			var u = internal(urls, this);
+			value = usv(value);
+			var parts = native.parseURL(value);
+			if (parts === null) {
+				throw invalidURL(value);
+			}
+			u.parts = parts;
+			params.get(u.query).list = parsePairs(parts.search.slice(1));
+		}
+		get origin() { return internal(urls, this).parts.origin; }
+		get searchParams() { return internal(urls, this).query; }
+		toString() { return internal(urls, this).parts.href; }
+		toJSON() { return internal(urls, this).parts.href; }
+	}
+	define(URL.prototype, 'URL');
+	['protocol', 'username', 'password', 'host', 'hostname', 'port', 'pathname', 'search', 'hash'].forEach(function (name) {
+		Object.defineProperty(URL.prototype, name, {
+			get: function () { return internal(urls, this).parts[name]; },
+			set: function (value) {
+				var u = internal(urls, this);
+				u.parts = native.setURL(u.parts.href, name, usv(value));
+				if (name === 'search') {
+					params.get(u.query).list = parsePairs(u.parts.search.slice(1));
+				}
+			},
+			enumerable: true,
+			configurable: true
+		});
+	});
+
+	// updateURL URLSearchParams 修改后同步到关联的 URL
+	function updateURL(p) {
+		if (p.url !== null) {
+			var u = urls.get(p.url);
+			u.parts = native.setURL(u.parts.href, 'search', serializePairs(p.list));
+		}
+	}
+
+	function serializePairs(list) {
+		var flat = [];
+		list.forEach(function (pair) {
+			flat.push(pair[0], pair[1]);
+		});
+		return native.serializeForm(flat);
+	}
+
+	function iterator(p, kind) {
+		var index = 0;
+		var it = Object.create(paramsIteratorPrototype);
+		it.next = function () {
+			if (index >= p.list.length) {
+				return { value: undefined, done: true };
+			}
+			var pair = p.list[index++];
+			return { value: kind === 'keys' ? pair[0] : kind === 'values' ? pair[1] : [pair[0], pair[1]], done: false };
+		};
+		Object.defineProperty(it, 'next', { enumerable: false });
+		return it;
+	}
+	var paramsIteratorPrototype = Object.create(Object.getPrototypeOf(Object.getPrototypeOf([][Symbol.iterator]())));
+	define(paramsIteratorPrototype, 'URLSearchParams Iterator');
+
+	class URLSearchParams {
+		constructor(init) {
+			var p = { list: [], url: null };
+			params.set(this, p);
+			if (init === undefined || init === null) {
+				return;
+			}
+			if (typeof init === 'object' || typeof init === 'function') {
+				if (typeof init[Symbol.iterator] === 'function') {
+					for (var pair of init) {
+						if (pair === null || typeof pair !== 'object' && typeof pair !== 'function' || typeof pair[Symbol.iterator] !== 'function') {
+							throw new TypeError('Each query pair must be an iterable [name, value] tuple');
+						}
+						var values = Array.from(pair);
+						if (values.length !== 2) {
+							throw new TypeError('Each query pair must be an iterable [name, value] tuple');
+						}
+						p.list.push([usv(values[0]), usv(values[1])]);
+					}
+				} else {
+					Object.keys(init).forEach(function (key) {
+						p.list.push([usv(key), usv(init[key])]);
+					});
+				}
+				return;
+			}
+			var query = usv(init);
+			p.list = parsePairs(query.charAt(0) === '?' ? query.slice(1) : query);
+		}
+		get size() { return internal(params, this).list.length; }
+		append(name, value) {
+			requireArgs(arguments, 2, "Failed to execute 'append' on 'URLSearchParams'");
+			var p = internal(params, this);
+			p.list.push([usv(name), usv(value)]);
+			updateURL(p);
+		}
+		delete(name, value) {
+			requireArgs(arguments, 1, "Failed to execute 'delete' on 'URLSearchParams'");
+			var p = internal(params, this);
+			name = usv(name);
+			value = value === undefined ? undefined : usv(value);
+			p.list = p.list.filter(function (pair) {
+				return pair[0] !== name || value !== undefined && pair[1] !== value;
+			});
+			updateURL(p);
+		}
+		get(name) {
+			requireArgs(arguments, 1, "Failed to execute 'get' on 'URLSearchParams'");
+			name = usv(name);
+			var pair = internal(params, this).list.find(function (pair) { return pair[0] === name; });
+			return pair === undefined ? null : pair[1];
+		}
+		getAll(name) {
+			requireArgs(arguments, 1, "Failed to execute 'getAll' on 'URLSearchParams'");
+			name = usv(name);
+			return internal(params, this).list.filter(function (pair) { return pair[0] === name; }).map(function (pair) { return pair[1]; });
+		}
+		has(name, value) {
+			requireArgs(arguments, 1, "Failed to execute 'has' on 'URLSearchParams'");
+			name = usv(name);
+			value = value === undefined ? undefined : usv(value);
+			return internal(params, this).list.some(function (pair) {
+				return pair[0] === name && (value === undefined || pair[1] === value);
+			});
+		}
+		set(name, value) {
+			requireArgs(arguments, 2, "Failed to execute 'set' on 'URLSearchParams'");
+			var p = internal(params, this);
+			name = usv(name);
+			value = usv(value);
+			var found = false;
+			p.list = p.list.filter(function (pair) {
+				if (pair[0] !== name) {
+					return true;
+				}
+				if (found) {
+					return false;
+				}
+				found = true;
+				pair[1] = value;
+				return true;
+			});
+			if (!found) {
+				p.list.push([name, value]);
+			}
+			updateURL(p);
+		}
+		sort() {
+			var p = internal(params, this);
+			// 按名称的 UTF-16 码元稳定排序
+			p.list = p.list.map(function (pair, i) { return [pair, i]; }).sort(function (a, b) {
+				if (a[0][0] !== b[0][0]) {
+					return a[0][0] < b[0][0] ? -1 : 1;
+				}
+				return a[1] - b[1];
+			}).map(function (entry) { return entry[0]; });
+			updateURL(p);
+		}
+		forEach(callback, thisArg) {
+			requireArgs(arguments, 1, "Failed to execute 'forEach' on 'URLSearchParams'");
+			if (typeof callback !== 'function') {
+				throw new TypeError("Failed to execute 'forEach' on 'URLSearchParams': parameter 1 is not of type 'Function'.");
+			}
+			var p = internal(params, this);
+			for (var i = 0; i < p.list.length; i++) {
+				callback.call(thisArg, p.list[i][1], p.list[i][0], this);
+			}
+		}
+		keys() { return iterator(internal(params, this), 'keys'); }
+		values() { return iterator(internal(params, this), 'values'); }
+		entries() { return iterator(internal(params, this), 'entries'); }
+		toString() { return serializePairs(internal(params, this).list); }
+	}
+	URLSearchParams.prototype[Symbol.iterator] = URLSearchParams.prototype.entries;
+	define(URLSearchParams.prototype, 'URLSearchParams');
+
+	class TextEncoder {
+		get encoding() { return 'utf-8'; }
+		encode(input) {
+			return new Uint8Array(native.encodeUTF8(input === undefined ? '' : String(input)));
+		}
+		encodeInto(source, destination) {
+			requireArgs(arguments, 2, "Failed to execute 'encodeInto' on 'TextEncoder'");
+			if (!(destination instanceof Uint8Array)) {
+				throw new TypeError("Failed to execute 'encodeInto' on 'TextEncoder': parameter 2 is not of type 'Uint8Array'.");
+			}
+			var r = native.encodeInto(String(source), destination);
+			return { read: r[0], written: r[1] };
+		}
+	}
+	define(TextEncoder.prototype, 'TextEncoder');
+
+	class TextDecoder {
+		constructor(label, options) {
+			label = label === undefined ? 'utf-8' : String(label);
+			options = options === undefined || options === null ? {} : options;
+			var encoding = native.encodingName(label);
+			if (encoding === '') {
+				throw new RangeError("The encoding label provided ('" + label + "') is invalid.");
+			}
+			var fatal = !!options.fatal;
+			var ignoreBOM = !!options.ignoreBOM;
+			decoders.set(this, {
+				encoding: encoding, fatal: fatal, ignoreBOM: ignoreBOM,
+				handle: native.newDecoder(encoding, fatal, ignoreBOM)
+			});
+		}
+		get encoding() { return internal(decoders, this).encoding; }
+		get fatal() { return internal(decoders, this).fatal; }
+		get ignoreBOM() { return internal(decoders, this).ignoreBOM; }
+		decode(input, options) {
+			var d = internal(decoders, this);
+			var bytes;
+			if (input === undefined) {
+				bytes = new Uint8Array(0);
+			} else if (input instanceof ArrayBuffer) {
+				bytes = new Uint8Array(input);
+			} else if (ArrayBuffer.isView(input)) {
+				bytes = new Uint8Array(input.buffer, input.byteOffset, input.byteLength);
+			} else {
+				throw new TypeError("Failed to execute 'decode' on 'TextDecoder': parameter 1 is not of type 'ArrayBuffer' or 'ArrayBufferView'.");
+			}
+			var text = native.decode(d.handle, bytes, !!(options && options.stream));
+			if (text === null) {
+				throw new TypeError('The encoded data was not valid for encoding ' + d.encoding);
+			}
+			return text;
+		}
+	}
+	define(TextDecoder.prototype, 'TextDecoder');
+
+	function atob(data) {
+		requireArgs(arguments, 1, "Failed to execute 'atob'");
+		var decoded = native.atob(String(data));
+		if (decoded === null) {
+			throw new DOMException('The string to be decoded is not correctly encoded.', 'InvalidCharacterError');
+		}
+		return decoded;
+	}
+
+	function btoa(data) {
+		requireArgs(arguments, 1, "Failed to execute 'btoa'");
+		var encoded = native.btoa(String(data));
+		if (encoded === null) {
+			throw new DOMException('The string to be encoded contains characters outside of the Latin1 range.', 'InvalidCharacterError');
+		}
+		return encoded;
+	}
+
+	// structuredClone 按结构化克隆算法深拷贝，保留循环引用；函数、Symbol、Promise 等不可克隆的值抛出 DataCloneError
+	function structuredClone(value) {
+		requireArgs(arguments, 1, "Failed to execute 'structuredClone'");
+		var memory = new Map();
+		function uncloneable(v) {
+			return new DOMException((typeof v === 'symbol' ? v.toString() : String(v)) + ' could not be cloned.', 'DataCloneError');
+		}
+		function properties(src, dst) {
+			Object.keys(src).forEach(function (key) {
+				dst[key] = clone(src[key]);
+			});
+			return dst;
+		}
+		function clone(v) {
+			if (typeof v === 'symbol' || typeof v === 'function') {
+				throw uncloneable(v);
+			}
+			if (v === null || typeof v !== 'object') {
+				return v;
+			}
+			if (memory.has(v)) {
+				return memory.get(v);
+			}
+			var tag = toTag.call(v).slice(8, -1);
+			var out;
+			switch (tag) {
+			case 'Boolean':
+			case 'Number':
+			case 'String':
+			case 'BigInt':
+				out = Object(v.valueOf());
+				break;
+			case 'Date':
+				out = new Date(v.getTime());
+				break;
+			case 'RegExp':
+				out = new RegExp(v.source, v.flags);
+				break;
+			case 'ArrayBuffer':
+				out = v.slice(0);
+				break;
+			case 'DataView':
+				out = new DataView(clone(v.buffer), v.byteOffset, v.byteLength);
+				break;
+			case 'Map':
+				out = new Map();
+				memory.set(v, out);
+				v.forEach(function (val, key) {
+					out.set(clone(key), clone(val));
+				});
+				return out;
+			case 'Set':
+				out = new Set();
+				memory.set(v, out);
+				v.forEach(function (val) {
+					out.add(clone(val));
+				});
+				return out;
+			case 'Error':
+				var Ctor = errorTypes[v.name] || Error;
+				out = Object.create(Ctor.prototype);
+				memory.set(v, out);
+				if ('message' in v) {
+					Object.defineProperty(out, 'message', { value: String(v.message), writable: true, configurable: true });
+				}
+				if (v.stack !== undefined) {
+					Object.defineProperty(out, 'stack', { value: String(v.stack), writable: true, configurable: true });
+				}
+				if ('cause' in v) {
+					Object.defineProperty(out, 'cause', { value: clone(v.cause), writable: true, configurable: true });
+				}
+				return out;
+			case 'Array':
+				out = new Array(v.length);
+				memory.set(v, out);
+				return properties(v, out);
+			case 'Object':
+				out = {};
+				memory.set(v, out);
+				return properties(v, out);
+			default:
+				if (typedArrays[tag] !== undefined) {
+					out = new typedArrays[tag](clone(v.buffer), v.byteOffset, v.length);
+					break;
+				}
+				throw uncloneable(v);
+			}
+			memory.set(v, out);
+			return out;
+		}
+		return clone(value);
+	}
+
+	var performance = {
+		timeOrigin: native.timeOrigin,
+		now: function now() {
+			return native.now();
+		},
+		toJSON: function toJSON() {
+			return { timeOrigin: native.timeOrigin };
+		}
+	};
+	define(performance, 'Performance');
+
+	return {
+		URL: URL, URLSearchParams: URLSearchParams, TextEncoder: TextEncoder, TextDecoder: TextDecoder,
+		atob: atob, btoa: btoa, structuredClone: structuredClone, DOMException: DOMException,
+		Event: Event, EventTarget: EventTarget, AbortController: AbortController, AbortSignal: AbortSignal,
+		performance: performance
+	};
+})`
+
+// registerWebGlobals 注册 URL、URLSearchParams、TextEncoder、TextDecoder、atob、btoa、structuredClone、
+// AbortController、performance 等 Web 标准全局对象，行为与浏览器和 Node.js 一致
+func (sb *Sandbox) registerWebGlobals() {
+	timeOrigin := time.Now()
+	native := sb.vm.NewObject()
+	native.Set("queueMicrotask", sb.vm.Get("queueMicrotask"))
+	native.Set("timeOrigin", float64(timeOrigin.UnixMicro())/1e3)
+	native.Set("now", func() float64 {
+		return float64(time.Since(timeOrigin).Nanoseconds()) / 1e6
+	})
+	native.Set("timeout", func(call goja.FunctionCall) goja.Value {
+		fn, _ := goja.AssertFunction(call.Argument(0))
+		delay := time.Duration(call.Argument(1).ToFloat() * float64(time.Millisecond))
+		sb.loop.unrefTimer(sb.loop.setTimer(fn, delay, nil, false))
+		return goja.Undefined()
+	})
+	native.Set("usv", func(s string) string { return s })
+
+	native.Set("parseURL", func(call goja.FunctionCall) goja.Value {
+		var base *whatwgURL
+		if arg := call.Argument(1); !goja.IsUndefined(arg) {
+			var err error
+			if base, err = parseURL(arg.String(), nil); err != nil {
+				return goja.Null()
+			}
+		}
+		u, err := parseURL(call.Argument(0).String(), base)
+		if err != nil {
+			return goja.Null()
+		}
+		return sb.vm.ToValue(urlParts(u))
+	})
+	native.Set("setURL", func(href, name, value string) map[string]interface{} {
+		u, _ := parseURL(href, nil)
+		return urlParts(u.setComponent(name, value))
+	})
+	native.Set("parseForm", parseForm)
+	native.Set("serializeForm", serializeForm)
+
+	native.Set("encodeUTF8", func(s string) goja.ArrayBuffer {
+		return sb.vm.NewArrayBuffer([]byte(s))
+	})
+	native.Set("encodeInto", func(call goja.FunctionCall) goja.Value {
+		dst, _ := call.Argument(1).Export().([]byte)
+		read, written := 0, 0
+		for _, r := range call.Argument(0).String() {
+			n := utf8.RuneLen(r)
+			if written+n > len(dst) {
+				break
+			}
+			utf8.EncodeRune(dst[written:], r)
+			written += n
+			read++
+			if r > 0xffff {
+				// 代理对占两个 UTF-16 码元
+				read++
+			}
+		}
+		return sb.vm.ToValue([]int{read, written})
+	})
+	native.Set("encodingName", encodingName)
+	native.Set("newDecoder", newTextDecoder)
+	native.Set("decode", func(call goja.FunctionCall) goja.Value {
+		d, _ := call.Argument(0).Export().(*textDecoder)
+		data, _ := call.Argument(1).Export().([]byte)
+		text, ok := d.decode(data, call.Argument(2).ToBoolean())
+		if !ok {
+			return goja.Null()
+		}
+		return sb.vm.ToValue(text)
+	})
+
+	native.Set("atob", func(s string) goja.Value {
+		decoded, ok := forgivingBase64Decode(s)
+		if !ok {
+			return goja.Null()
+		}
+		runes := make([]rune, len(decoded))
+		for i, c := range decoded {
+			runes[i] = rune(c)
+		}
+		return sb.vm.ToValue(string(runes))
+	})
+	native.Set("btoa", func(s string) goja.Value {
+		data := make([]byte, 0, len(s))
+		for _, r := range s {
+			if r > 0xff {
+				return goja.Null()
+			}
+			data = append(data, byte(r))
+		}
+		return sb.vm.ToValue(base64.StdEncoding.EncodeToString(data))
+	})
+
+	define, err := sb.vm.RunScript("<web>", webGlobalsSource)
+	if err != nil {
+		panic(err)
+	}
+	fn, _ := goja.AssertFunction(define)
+	globals, err := fn(goja.Undefined(), native)
+	if err != nil {
+		panic(err)
+	}
+	obj := globals.ToObject(sb.vm)
+	for _, key := range obj.Keys() {
+		sb.vm.Set(key, obj.Get(key))
+	}
+}
+
+// urlParts 返回 URL 各属性的值
+func urlParts(u *whatwgURL) map[string]interface{} {
+	port, search, hash := "", "", ""
+	if u.port != -1 {
+		port = strconv.Itoa(u.port)
+	}
+	host := u.hostString()
+	if port != "" {
+		host += ":" + port
+	}
+	if u.query != nil && *u.query != "" {
+		search = "?" + *u.query
+	}
+	if u.fragment != nil && *u.fragment != "" {
+		hash = "#" + *u.fragment
+	}
+	return map[string]interface{}{
+		"href":     u.String(),
+		"origin":   u.origin(),
+		"protocol": u.scheme + ":",
+		"username": u.username,
+		"password": u.password,
+		"host":     host,
+		"hostname": u.hostString(),
+		"port":     port,
+		"pathname": u.pathString(),
+		"search":   search,
+		"hash":     hash,
+	}
+}
+
+// forgivingBase64Decode 按 HTML 标准的 forgiving-base64 解码：忽略空白，可以省略末尾的 "="
+func forgivingBase64Decode(s string) ([]byte, bool) {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("\t\n\f\r ", r) {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s)%4 == 0 {
+		s = strings.TrimSuffix(s, "=")
+		s = strings.TrimSuffix(s, "=")
+	}
+	if len(s)%4 == 1 || strings.ContainsRune(s, '=') {
+		return nil, false
+	}
+	data, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// encodingName 返回 WHATWG Encoding 标准中标签对应的编码名称（如 "gb2312" 对应 "gbk"），不支持的标签返回空字符串
+func encodingName(label string) string {
+	enc, err := htmlindex.Get(strings.TrimSpace(label))
+	if err != nil {
+		return ""
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil || name == "replacement" {
+		return ""
+	}
+	return name
+}
+
+// textDecoder TextDecoder 的解码状态，流式解码时保存末尾不完整的字节
+type textDecoder struct {
+	name      string
+	fatal     bool
+	ignoreBOM bool
+	dec       *encoding.Decoder
+	pending   []byte
+	// started 是否已经输出过字符，只有开头的 BOM 会被去掉
+	started bool
+}
+
+// newTextDecoder 创建解码器，name 为 encodingName 返回的编码名称
+func newTextDecoder(name string, fatal, ignoreBOM bool) *textDecoder {
+	d := &textDecoder{name: name, fatal: fatal, ignoreBOM: ignoreBOM}
+	d.reset()
+	return d
+}
+
+func (d *textDecoder) reset() {
+	d.pending, d.started = nil, false
+	if d.name != "utf-8" {
+		enc, _ := htmlindex.Get(d.name)
+		d.dec = enc.NewDecoder()
+	}
+}
+
+// decode 解码 data，stream 为 true 时末尾不完整的字符留到下一次解码
+// fatal 模式下遇到无效数据时返回 false 并重置解码状态
+func (d *textDecoder) decode(data []byte, stream bool) (string, bool) {
+	input := append(d.pending, data...)
+	d.pending = nil
+	var text string
+	var consumed int
+	var invalid bool
+	if d.dec == nil {
+		text, consumed, invalid = decodeUTF8(input, stream)
+	} else {
+		text, consumed, invalid = d.decodeLegacy(input, stream)
+	}
+	if invalid && d.fatal {
+		d.reset()
+		return "", false
+	}
+	d.pending = append([]byte(nil), input[consumed:]...)
+	if !d.started && text != "" {
+		d.started = true
+		if !d.ignoreBOM && strings.HasPrefix(d.name, "utf-") {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+	}
+	if !stream {
+		d.reset()
+	}
+	return text, true
+}
+
+// decodeLegacy 使用 golang.org/x/text 的解码器解码 UTF-8 以外的编码，无效的字节被替换为 U+FFFD
+func (d *textDecoder) decodeLegacy(input []byte, stream bool) (string, int, bool) {
+	dst := make([]byte, 3*len(input)+utf8.UTFMax)
+	var out []byte
+	consumed := 0
+	for {
+		nDst, nSrc, err := d.dec.Transform(dst, input[consumed:], !stream)
+		out = append(out, dst[:nDst]...)
+		consumed += nSrc
+		if err != transform.ErrShortDst {
+			break
+		}
+	}
+	text := string(out)
+	return text, consumed, strings.ContainsRune(text, utf8.RuneError)
+}
+
+// decodeUTF8 按 WHATWG Encoding 标准解码 UTF-8，每个无效的最长子序列替换为一个 U+FFFD
+// stream 为 true 时末尾不完整的字符不解码；返回解码的文本、已处理的字节数和是否有无效数据
+func decodeUTF8(data []byte, stream bool) (string, int, bool) {
+	var b strings.Builder
+	invalid := false
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c < 0x80 {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		need, lower, upper := 0, byte(0x80), byte(0xbf)
+		var cp rune
+		switch {
+		case c >= 0xc2 && c <= 0xdf:
+			need, cp = 1, rune(c&0x1f)
+		case c >= 0xe0 && c <= 0xef:
+			if c == 0xe0 {
+				lower = 0xa0
+			} else if c == 0xed {
+				upper = 0x9f
+			}
+			need, cp = 2, rune(c&0x0f)
+		case c >= 0xf0 && c <= 0xf4:
+			if c == 0xf0 {
+				lower = 0x90
+			} else if c == 0xf4 {
+				upper = 0x8f
+			}
+			need, cp = 3, rune(c&0x07)
+		default:
+			b.WriteRune(utf8.RuneError)
+			invalid = true
+			i++
+			continue
+		}
+		j := i + 1
+		for ; j <= i+need; j++ {
+			if j == len(data) {
+				if stream {
+					return b.String(), i, invalid
+				}
+				break
+			}
+			if data[j] < lower || data[j] > upper {
+				break
+			}
+			lower, upper = 0x80, 0xbf
+			cp = cp<<6 | rune(data[j]&0x3f)
+		}
+		if j <= i+need {
+			// 无效的字节不属于当前字符，从它开始重新解码
+			b.WriteRune(utf8.RuneError)
+			invalid = true
+			i = j
+			continue
+		}
+		b.WriteRune(cp)
+		i = j
+	}
+	return b.String(), len(data), invalid
+}
@@ -0,0 +1,182 @@
+package jssandbox
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWebGlobals(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		// URL
+		{"URL 属性", `var u = new URL('HTTPS://user:pw@例子.com:8443/a/../b?x=1#h');
+			[u.href, u.origin, u.protocol, u.username, u.password, u.host, u.hostname, u.port, u.pathname, u.search, u.hash].join('|')`,
+			"https://user:pw@xn--fsqu00a.com:8443/b?x=1#h|https://xn--fsqu00a.com:8443|https:|user|pw|xn--fsqu00a.com:8443|xn--fsqu00a.com|8443|/b|?x=1|#h"},
+		{"URL 相对解析", `new URL('../c?q', 'http://example.com/a/b/').href`, "http://example.com/a/c?q"},
+		{"URL 基准为 URL 对象", `new URL('x', new URL('http://example.com/a/')).href`, "http://example.com/a/x"},
+		{"URL 无效", `try { new URL('/relative') } catch (e) { e.name + ':' + e.message + ':' + e.code + ':' + e.input }`,
+			"TypeError:Invalid URL:ERR_INVALID_URL:/relative"},
+		{"URL.canParse", `[URL.canParse('http://a'), URL.canParse('a'), URL.canParse('a', 'http://b/'), URL.parse('x') === null].join()`,
+			"true,false,true,true"},
+		{"URL setter", `var u = new URL('http://example.com/');
+			u.protocol = 'https'; u.hostname = 'other.com'; u.port = '444'; u.pathname = '/p q'; u.search = 'a=1'; u.hash = 'top';
+			u.port = 'x'; u.href`, "https://other.com:444/p%20q?a=1#top"},
+		{"URL href 无效", `var u = new URL('http://a/'); try { u.href = 'nope' } catch (e) { e.name + ' ' + u.href }`, "TypeError http://a/"},
+		{"URL JSON", `JSON.stringify({ u: new URL('http://a/b') }) + String(new URL('http://a')) + Object.prototype.toString.call(new URL('http://a'))`,
+			`{"u":"http://a/b"}http://a/[object URL]`},
+		{"URL 非特殊协议", `var u = new URL('mailto:a@b.com?subject=hi'); [u.origin, u.pathname, u.search, u.host].join('|')`,
+			"null|a@b.com|?subject=hi|"},
+
+		// URLSearchParams
+		{"查询参数", `var p = new URLSearchParams('?a=1&b=x+y&a=%E4%BD%A0');
+			[p.get('a'), p.getAll('a').join('/'), p.get('b'), String(p.get('c')), p.has('b'), p.has('a', '2'), p.size].join('|')`,
+			"1|1/你|x y|null|true|false|3"},
+		{"查询参数修改", `var p = new URLSearchParams({ b: '2', a: '1' });
+			p.append('c', 'x y'); p.append('a', '3'); p.set('b', '&'); p.delete('a', '1'); p.sort(); p.toString()`,
+			"a=3&b=%26&c=x+y"},
+		{"查询参数迭代", `var p = new URLSearchParams([['a', '1'], ['b', '2']]); var out = [];
+			for (var [k, v] of p) out.push(k + '=' + v);
+			p.forEach(function (v, k) { out.push(k); });
+			out.concat(Array.from(p.keys()), Array.from(p.values())).join()`, "a=1,b=2,a,b,a,b,1,2"},
+		{"查询参数格式错误", `try { new URLSearchParams([['a']]) } catch (e) { e.name }`, "TypeError"},
+		{"查询参数与 URL 联动", `var u = new URL('http://a/?x=1');
+			u.searchParams.append('y', '2 3'); var s1 = u.href;
+			u.search = '?z=9'; var s2 = u.searchParams.get('z');
+			u.searchParams.delete('z'); [s1, s2, u.href].join('|')`, "http://a/?x=1&y=2+3|9|http://a/"},
+
+		// TextEncoder / TextDecoder
+		{"TextEncoder", `Array.from(new TextEncoder().encode('a你😀\uD800')).join()`,
+			"97,228,189,160,240,159,152,128,239,191,189"},
+		{"encodeInto", `var buf = new Uint8Array(5); var r = new TextEncoder().encodeInto('a你好', buf);
+			[r.read, r.written, Array.from(buf).join(' ')].join('|')`, "2|4|97 228 189 160 0"},
+		{"TextDecoder", `var d = new TextDecoder(); [d.encoding, d.decode(new Uint8Array([0xEF, 0xBB, 0xBF, 0x68, 0xC3, 0xA9])),
+			new TextDecoder('utf-8', { ignoreBOM: true }).decode(new Uint8Array([0xEF, 0xBB, 0xBF, 0x61])).length].join('|')`, "utf-8|hé|2"},
+		{"TextDecoder 替换", `new TextDecoder().decode(new Uint8Array([0x61, 0xF0, 0x9F, 0x98, 0x62, 0xFF, 0xED, 0xA0, 0x80]))`,
+			"a�b����"},
+		{"TextDecoder 流式", `var d = new TextDecoder(); var bytes = new TextEncoder().encode('你好');
+			d.decode(bytes.subarray(0, 2), { stream: true }) + '|' + d.decode(bytes.subarray(2, 4), { stream: true }) + '|' + d.decode(bytes.subarray(4))`,
+			"|你|好"},
+		{"TextDecoder fatal", `try { new TextDecoder('utf-8', { fatal: true }).decode(new Uint8Array([0xC3])) } catch (e) { e.name }`, "TypeError"},
+		{"TextDecoder GBK", `var d = new TextDecoder('GB2312'); d.encoding + '|' + d.decode(new Uint8Array([0xC4, 0xE3, 0xBA, 0xC3, 0x41]))`, "gbk|你好A"},
+		{"TextDecoder GB18030", `new TextDecoder('gb18030').decode(new Uint8Array([0x94, 0x39, 0xFC, 0x36]))`, "\U0001F600"},
+		{"TextDecoder UTF-16", `var d = new TextDecoder('utf-16le'); d.decode(new Uint8Array([0xFF, 0xFE, 0x60, 0x4F, 0x3D, 0xD8, 0x00, 0xDE]).buffer)`, "你\U0001F600"},
+		{"TextDecoder 无效标签", `try { new TextDecoder('nope') } catch (e) { e.name }`, "RangeError"},
+
+		// atob / btoa
+		{"btoa", `btoa('hello\xff')`, "aGVsbG//"},
+		{"atob", `[atob('aGVs bG8=\n'), atob('YQ'), atob('')].join('|')`, "hello|a|"},
+		{"atob 无效", `try { atob('a') } catch (e) { e.name + ':' + e.code + ':' + (e instanceof DOMException) + ':' + (e instanceof Error) }`,
+			"InvalidCharacterError:5:true:true"},
+		{"btoa 非 Latin1", `try { btoa('你') } catch (e) { e.name }`, "InvalidCharacterError"},
+
+		// structuredClone
+		{"structuredClone", `var src = { d: new Date(0), r: /a/gi, m: new Map([[1, { x: 1 }]]), s: new Set(['a']), a: [1, , 3],
+				b: new Uint8Array([1, 2]), e: new RangeError('bad'), n: null, u: undefined };
+			src.self = src;
+			var c = structuredClone(src);
+			[c !== src, c.self === c, c.d instanceof Date && c.d.getTime() === 0, String(c.r), c.m.get(1).x, c.m.get(1) !== src.m.get(1),
+				c.s.has('a'), c.a.length, 1 in c.a, c.b[1], c.b.buffer !== src.b.buffer, c.e instanceof RangeError, c.e.message, 'u' in c].join()`,
+			"true,true,true,/a/gi,1,true,true,3,false,2,true,true,bad,true"},
+		{"structuredClone 函数", `try { structuredClone({ f: function () {} }) } catch (e) { e.name + ':' + e.code }`, "DataCloneError:25"},
+		{"structuredClone 类实例", `class P { constructor() { this.x = 1 } } var c = structuredClone(new P()); (c instanceof P) + ':' + c.x`, "false:1"},
+
+		// AbortController / EventTarget
+		{"AbortController", `var c = new AbortController(); var log = [];
+			c.signal.addEventListener('abort', function (e) { log.push(e.type + ':' + (e.target === c.signal)); });
+			c.signal.onabort = function () { log.push('onabort'); };
+			c.abort(); c.abort();
+			log.push(c.signal.aborted, c.signal.reason.name);
+			try { c.signal.throwIfAborted() } catch (e) { log.push(e.name) }
+			log.join()`, "onabort,abort:true,true,AbortError,AbortError"},
+		{"AbortSignal.abort", `var s = AbortSignal.abort('why'); s.aborted + ':' + s.reason`, "true:why"},
+		{"AbortSignal.any", `var a = new AbortController(), b = new AbortController(); var s = AbortSignal.any([a.signal, b.signal]);
+			b.abort('b'); s.aborted + ':' + s.reason`, "true:b"},
+		{"AbortSignal 构造", `try { new AbortSignal() } catch (e) { e.name }`, "TypeError"},
+		{"EventTarget", `var t = new EventTarget(); var n = 0;
+			function f() { n++; }
+			t.addEventListener('x', f); t.addEventListener('x', f); t.addEventListener('x', { handleEvent: function () { n += 10; } }, { once: true });
+			t.dispatchEvent(new Event('x')); t.dispatchEvent(new Event('x'));
+			t.removeEventListener('x', f); t.dispatchEvent(new Event('x'));
+			var e = new Event('y', { cancelable: true }); t.addEventListener('y', function (ev) { ev.preventDefault(); });
+			n + ':' + t.dispatchEvent(e) + ':' + e.defaultPrevented`, "12:false:true"},
+		{"监听器随 signal 移除", `var t = new EventTarget(), c = new AbortController(), n = 0;
+			t.addEventListener('x', function () { n++; }, { signal: c.signal });
+			t.dispatchEvent(new Event('x')); c.abort(); t.dispatchEvent(new Event('x')); n`, "1"},
+
+		// performance
+		{"performance", `var a = performance.now(); var b = performance.now();
+			(typeof a === 'number' && b >= a && a >= 0) + ':' + (performance.timeOrigin > 1.6e12)`, "true:true"},
+	}
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sb.Run(tt.code)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("got %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWebGlobals_AbortSignalTimeout(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	// 未到期的 AbortSignal.timeout 不阻止执行结束
+	start := time.Now()
+	if _, err := sb.Run(`AbortSignal.timeout(10000)`); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %v, AbortSignal.timeout should not keep the event loop alive", elapsed)
+	}
+
+	v, err := sb.Run(`new Promise(function (resolve) {
+		var s = AbortSignal.timeout(10);
+		s.addEventListener('abort', function () { resolve(s.reason.name); });
+		setTimeout(function () {}, 100);
+	})`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if v.String() != "TimeoutError" {
+		t.Errorf("reason = %q, want TimeoutError", v.String())
+	}
+}
+
+func TestWebGlobals_ThrowMode(t *testing.T) {
+	sb := NewSandboxWithConfig(context.Background(), DefaultConfig().WithErrorMode(ErrorModeThrow))
+	defer sb.Close()
+
+	v, err := sb.Run(`var u = new URL('http://a/b'); u.pathname + new TextDecoder().decode(new TextEncoder().encode('!')) + atob(btoa('x'))`)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if v.String() != "/b!x" {
+		t.Errorf("got %q, want %q", v.String(), "/b!x")
+	}
+}
+
+func TestWebGlobals_ListenerError(t *testing.T) {
+	sb := NewSandbox(context.Background())
+	defer sb.Close()
+
+	_, err := sb.Run(`var t = new EventTarget(); var n = 0;
+		t.addEventListener('x', function () { throw new Error('boom'); });
+		t.addEventListener('x', function () { n++; });
+		t.dispatchEvent(new Event('x'));`)
+	if err == nil {
+		t.Fatal("Run() error = nil, want uncaught listener error")
+	}
+	if v, _ := sb.Run(`n`); v == nil || v.ToInteger() != 1 {
+		t.Errorf("n = %v, want 1: other listeners should still run", v)
+	}
+}
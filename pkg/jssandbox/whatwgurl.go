@@ -0,0 +1,1128 @@
+package jssandbox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// 本文件按 WHATWG URL 标准（https://url.spec.whatwg.org/）实现 URL 解析和序列化，供脚本中的 URL、URLSearchParams 使用。
+// Go 的 net/url 按 RFC 3986 解析，对反斜杠、制表符、IPv4 简写、国际化域名等的处理与浏览器和 Node.js 不同。
+
+// errInvalidURL URL 解析失败
+var errInvalidURL = errors.New("Invalid URL")
+
+// specialSchemes 特殊协议及其默认端口，file 没有默认端口
+var specialSchemes = map[string]int{"ftp": 21, "file": -1, "http": 80, "https": 443, "ws": 80, "wss": 443}
+
+// urlIDNA 按 UTS #46 把域名转换为 ASCII，选项与 WHATWG 的 domain to ASCII 一致
+var urlIDNA = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.Transitional(false),
+	idna.CheckHyphens(false), idna.CheckJoiners(true), idna.StrictDomainName(false), idna.VerifyDNSLength(false))
+
+// whatwgURL 解析后的 URL 记录
+type whatwgURL struct {
+	scheme   string
+	username string
+	password string
+	// host 为 nil 表示没有主机（如 mailto:、data:）
+	host *string
+	// port 为 -1 表示没有端口或等于默认端口
+	port int
+	// path 路径的各段；opaque 为 true 时（如 mailto:x@y）只有一个元素，即不透明路径本身
+	path   []string
+	opaque bool
+	// query、fragment 为 nil 表示没有 "?"、"#"
+	query    *string
+	fragment *string
+}
+
+// urlState 解析器的状态，setter 用它从指定状态开始解析（state override）
+type urlState int
+
+const (
+	stateSchemeStart urlState = iota + 1
+	stateScheme
+	stateNoScheme
+	stateSpecialRelativeOrAuthority
+	statePathOrAuthority
+	stateRelative
+	stateRelativeSlash
+	stateSpecialAuthoritySlashes
+	stateSpecialAuthorityIgnoreSlashes
+	stateAuthority
+	stateHost
+	stateHostname
+	statePort
+	stateFile
+	stateFileSlash
+	stateFileHost
+	statePathStart
+	statePath
+	stateOpaquePath
+	stateQuery
+	stateFragment
+)
+
+// eof 输入结束时的当前字符
+const eof = -1
+
+// percentEncodeSet 判断字符是否需要百分号编码
+type percentEncodeSet func(r rune) bool
+
+func c0ControlSet(r rune) bool { return r < 0x20 || r > 0x7e }
+func fragmentSet(r rune) bool  { return c0ControlSet(r) || strings.ContainsRune(" \"<>`", r) }
+func querySet(r rune) bool     { return c0ControlSet(r) || strings.ContainsRune(" \"#<>", r) }
+func specialQuerySet(r rune) bool {
+	return querySet(r) || r == '\''
+}
+func pathSet(r rune) bool     { return querySet(r) || strings.ContainsRune("?`{}", r) }
+func userinfoSet(r rune) bool { return pathSet(r) || strings.ContainsRune("/:;=@[\\]^|", r) }
+
+// formSet application/x-www-form-urlencoded 的编码集合，空格单独转换为 "+"
+func formSet(r rune) bool {
+	return !(r < 0x80 && (isASCIIAlphanumeric(r) || strings.ContainsRune("*-._", r)))
+}
+
+func isASCIIAlpha(r rune) bool        { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' }
+func isASCIIDigit(r rune) bool        { return r >= '0' && r <= '9' }
+func isASCIIAlphanumeric(r rune) bool { return isASCIIAlpha(r) || isASCIIDigit(r) }
+func isASCIIHexDigit(r rune) bool {
+	return isASCIIDigit(r) || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
+}
+
+// percentEncode 按 UTF-8 编码 s，set 中的字节编码为 %XX
+func percentEncode(s string, set percentEncodeSet) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !set(r) {
+			b.WriteRune(r)
+			continue
+		}
+		var buf [utf8.UTFMax]byte
+		for _, c := range buf[:utf8.EncodeRune(buf[:], r)] {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// percentDecode 把 %XX 解码为字节，不合法的 % 原样保留
+func percentDecode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isASCIIHexDigit(rune(s[i+1])) && isASCIIHexDigit(rune(s[i+2])) {
+			v, _ := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			out = append(out, byte(v))
+			i += 2
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return out
+}
+
+func (u *whatwgURL) isSpecial() bool {
+	_, ok := specialSchemes[u.scheme]
+	return ok
+}
+
+func (u *whatwgURL) includesCredentials() bool {
+	return u.username != "" || u.password != ""
+}
+
+// cannotHaveCredentialsOrPort 没有主机、主机为空或 file 协议的 URL 不能设置用户名、密码和端口
+func (u *whatwgURL) cannotHaveCredentialsOrPort() bool {
+	return u.host == nil || *u.host == "" || u.scheme == "file"
+}
+
+// schemeDefaultPort 返回协议的默认端口，没有时为 -1
+func schemeDefaultPort(scheme string) int {
+	if port, ok := specialSchemes[scheme]; ok {
+		return port
+	}
+	return -1
+}
+
+// clone 返回 URL 记录的副本
+func (u *whatwgURL) clone() *whatwgURL {
+	c := *u
+	c.path = append([]string(nil), u.path...)
+	c.host = cloneString(u.host)
+	c.query = cloneString(u.query)
+	c.fragment = cloneString(u.fragment)
+	return &c
+}
+
+func cloneString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	v := *s
+	return &v
+}
+
+func stringPtr(s string) *string { return &s }
+
+// shortenPath 去掉路径的最后一段，file 协议中只剩 Windows 盘符时保留
+func (u *whatwgURL) shortenPath() {
+	if u.scheme == "file" && len(u.path) == 1 && isNormalizedWindowsDriveLetter(u.path[0]) {
+		return
+	}
+	if len(u.path) > 0 {
+		u.path = u.path[:len(u.path)-1]
+	}
+}
+
+func isWindowsDriveLetter(s string) bool {
+	return len(s) == 2 && isASCIIAlpha(rune(s[0])) && (s[1] == ':' || s[1] == '|')
+}
+
+func isNormalizedWindowsDriveLetter(s string) bool {
+	return isWindowsDriveLetter(s) && s[1] == ':'
+}
+
+// startsWithWindowsDriveLetter 判断剩余输入是否以 Windows 盘符开头（如 "C:"、"C:/"）
+func startsWithWindowsDriveLetter(c []rune) bool {
+	if len(c) < 2 || !isASCIIAlpha(c[0]) || (c[1] != ':' && c[1] != '|') {
+		return false
+	}
+	return len(c) == 2 || strings.ContainsRune("/\\?#", c[2])
+}
+
+func isSingleDot(s string) bool {
+	return s == "." || strings.EqualFold(s, "%2e")
+}
+
+func isDoubleDot(s string) bool {
+	switch strings.ToLower(s) {
+	case "..", ".%2e", "%2e.", "%2e%2e":
+		return true
+	}
+	return false
+}
+
+// parseURL 解析 input，base 不为 nil 时作为相对 URL 的基准
+func parseURL(input string, base *whatwgURL) (*whatwgURL, error) {
+	input = strings.TrimFunc(input, func(r rune) bool { return r <= 0x20 })
+	return basicParse(input, base, &whatwgURL{port: -1}, 0)
+}
+
+// basicParse 标准中的 basic URL parser
+// override 不为 0 时从该状态开始解析并直接修改 u（用于 setter），返回的 URL 即 u
+func basicParse(input string, base, u *whatwgURL, override urlState) (*whatwgURL, error) {
+	input = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, input)
+	c := []rune(input)
+	at := func(i int) rune {
+		if i >= 0 && i < len(c) {
+			return c[i]
+		}
+		return eof
+	}
+
+	state := override
+	if state == 0 {
+		state = stateSchemeStart
+	}
+	var buf strings.Builder
+	var atSignSeen, insideBrackets, passwordTokenSeen bool
+
+	for p := 0; p <= len(c); p++ {
+		r := at(p)
+		switch state {
+		case stateSchemeStart:
+			switch {
+			case isASCIIAlpha(r):
+				buf.WriteRune(r | 0x20)
+				state = stateScheme
+			case override == 0:
+				state = stateNoScheme
+				p--
+			default:
+				return nil, errInvalidURL
+			}
+
+		case stateScheme:
+			switch {
+			case isASCIIAlphanumeric(r) || r == '+' || r == '-' || r == '.':
+				if isASCIIAlpha(r) {
+					r |= 0x20
+				}
+				buf.WriteRune(r)
+			case r == ':':
+				scheme := buf.String()
+				if override != 0 {
+					_, special := specialSchemes[scheme]
+					if u.isSpecial() != special ||
+						(u.includesCredentials() || u.port != -1) && scheme == "file" ||
+						u.scheme == "file" && u.host != nil && *u.host == "" {
+						return u, nil
+					}
+				}
+				u.scheme = scheme
+				if override != 0 {
+					if u.port == schemeDefaultPort(u.scheme) {
+						u.port = -1
+					}
+					return u, nil
+				}
+				buf.Reset()
+				switch {
+				case u.scheme == "file":
+					state = stateFile
+				case u.isSpecial() && base != nil && base.scheme == u.scheme:
+					state = stateSpecialRelativeOrAuthority
+				case u.isSpecial():
+					state = stateSpecialAuthoritySlashes
+				case at(p+1) == '/':
+					state = statePathOrAuthority
+					p++
+				default:
+					u.path, u.opaque = []string{""}, true
+					state = stateOpaquePath
+				}
+			case override == 0:
+				buf.Reset()
+				state = stateNoScheme
+				p = -1
+			default:
+				return nil, errInvalidURL
+			}
+
+		case stateNoScheme:
+			switch {
+			case base == nil || base.opaque && r != '#':
+				return nil, errInvalidURL
+			case base.opaque && r == '#':
+				u.scheme = base.scheme
+				u.path, u.opaque = append([]string(nil), base.path...), true
+				u.query = cloneString(base.query)
+				u.fragment = stringPtr("")
+				state = stateFragment
+			case base.scheme != "file":
+				state = stateRelative
+				p--
+			default:
+				state = stateFile
+				p--
+			}
+
+		case stateSpecialRelativeOrAuthority:
+			if r == '/' && at(p+1) == '/' {
+				state = stateSpecialAuthorityIgnoreSlashes
+				p++
+			} else {
+				state = stateRelative
+				p--
+			}
+
+		case statePathOrAuthority:
+			if r == '/' {
+				state = stateAuthority
+			} else {
+				state = statePath
+				p--
+			}
+
+		case stateRelative:
+			u.scheme = base.scheme
+			if r == '/' || u.isSpecial() && r == '\\' {
+				state = stateRelativeSlash
+				break
+			}
+			u.username, u.password, u.host, u.port = base.username, base.password, cloneString(base.host), base.port
+			u.path = append([]string(nil), base.path...)
+			u.query = cloneString(base.query)
+			switch {
+			case r == '?':
+				u.query = stringPtr("")
+				state = stateQuery
+			case r == '#':
+				u.fragment = stringPtr("")
+				state = stateFragment
+			case r != eof:
+				u.query = nil
+				u.shortenPath()
+				state = statePath
+				p--
+			}
+
+		case stateRelativeSlash:
+			switch {
+			case u.isSpecial() && (r == '/' || r == '\\'):
+				state = stateSpecialAuthorityIgnoreSlashes
+			case r == '/':
+				state = stateAuthority
+			default:
+				u.username, u.password, u.host, u.port = base.username, base.password, cloneString(base.host), base.port
+				state = statePath
+				p--
+			}
+
+		case stateSpecialAuthoritySlashes:
+			state = stateSpecialAuthorityIgnoreSlashes
+			if r == '/' && at(p+1) == '/' {
+				p++
+			} else {
+				p--
+			}
+
+		case stateSpecialAuthorityIgnoreSlashes:
+			if r != '/' && r != '\\' {
+				state = stateAuthority
+				p--
+			}
+
+		case stateAuthority:
+			switch {
+			case r == '@':
+				s := buf.String()
+				if atSignSeen {
+					s = "%40" + s
+				}
+				atSignSeen = true
+				for _, cp := range s {
+					if cp == ':' && !passwordTokenSeen {
+						passwordTokenSeen = true
+						continue
+					}
+					if passwordTokenSeen {
+						u.password += percentEncode(string(cp), userinfoSet)
+					} else {
+						u.username += percentEncode(string(cp), userinfoSet)
+					}
+				}
+				buf.Reset()
+			case r == eof || r == '/' || r == '?' || r == '#' || u.isSpecial() && r == '\\':
+				if atSignSeen && buf.Len() == 0 {
+					return nil, errInvalidURL
+				}
+				p -= utf8.RuneCountInString(buf.String()) + 1
+				buf.Reset()
+				state = stateHost
+			default:
+				buf.WriteRune(r)
+			}
+
+		case stateHost, stateHostname:
+			switch {
+			case override != 0 && u.scheme == "file":
+				p--
+				state = stateFileHost
+			case r == ':' && !insideBrackets:
+				if buf.Len() == 0 || override == stateHostname {
+					return nil, errInvalidURL
+				}
+				host, err := parseHost(buf.String(), !u.isSpecial())
+				if err != nil {
+					return nil, err
+				}
+				u.host = &host
+				buf.Reset()
+				state = statePort
+			case r == eof || r == '/' || r == '?' || r == '#' || u.isSpecial() && r == '\\':
+				p--
+				if u.isSpecial() && buf.Len() == 0 {
+					return nil, errInvalidURL
+				}
+				if override != 0 && buf.Len() == 0 && (u.includesCredentials() || u.port != -1) {
+					return u, nil
+				}
+				host, err := parseHost(buf.String(), !u.isSpecial())
+				if err != nil {
+					return nil, err
+				}
+				u.host = &host
+				buf.Reset()
+				state = statePathStart
+				if override != 0 {
+					return u, nil
+				}
+			default:
+				if r == '[' {
+					insideBrackets = true
+				} else if r == ']' {
+					insideBrackets = false
+				}
+				buf.WriteRune(r)
+			}
+
+		case statePort:
+			switch {
+			case isASCIIDigit(r):
+				buf.WriteRune(r)
+			case r == eof || r == '/' || r == '?' || r == '#' || u.isSpecial() && r == '\\' || override != 0:
+				if buf.Len() != 0 {
+					port, err := strconv.Atoi(buf.String())
+					if err != nil || port > 65535 {
+						return nil, errInvalidURL
+					}
+					if port == schemeDefaultPort(u.scheme) {
+						port = -1
+					}
+					u.port = port
+					buf.Reset()
+					if override != 0 {
+						return u, nil
+					}
+				}
+				if override != 0 {
+					return nil, errInvalidURL
+				}
+				state = statePathStart
+				p--
+			default:
+				return nil, errInvalidURL
+			}
+
+		case stateFile:
+			u.scheme = "file"
+			u.host = stringPtr("")
+			switch {
+			case r == '/' || r == '\\':
+				state = stateFileSlash
+			case base != nil && base.scheme == "file":
+				u.host = cloneString(base.host)
+				u.path = append([]string(nil), base.path...)
+				u.query = cloneString(base.query)
+				switch {
+				case r == '?':
+					u.query = stringPtr("")
+					state = stateQuery
+				case r == '#':
+					u.fragment = stringPtr("")
+					state = stateFragment
+				case r != eof:
+					u.query = nil
+					if !startsWithWindowsDriveLetter(c[p:]) {
+						u.shortenPath()
+					} else {
+						u.path = nil
+					}
+					state = statePath
+					p--
+				}
+			default:
+				state = statePath
+				p--
+			}
+
+		case stateFileSlash:
+			if r == '/' || r == '\\' {
+				state = stateFileHost
+				break
+			}
+			if base != nil && base.scheme == "file" {
+				u.host = cloneString(base.host)
+				if !startsWithWindowsDriveLetter(c[p:]) && len(base.path) > 0 && isNormalizedWindowsDriveLetter(base.path[0]) {
+					u.path = append(u.path, base.path[0])
+				}
+			}
+			state = statePath
+			p--
+
+		case stateFileHost:
+			if r != eof && r != '/' && r != '\\' && r != '?' && r != '#' {
+				buf.WriteRune(r)
+				break
+			}
+			p--
+			switch {
+			case override == 0 && isWindowsDriveLetter(buf.String()):
+				// 缓冲区中的盘符留给路径状态处理
+				state = statePath
+			case buf.Len() == 0:
+				u.host = stringPtr("")
+				if override != 0 {
+					return u, nil
+				}
+				state = statePathStart
+			default:
+				host, err := parseHost(buf.String(), false)
+				if err != nil {
+					return nil, err
+				}
+				if host == "localhost" {
+					host = ""
+				}
+				u.host = &host
+				if override != 0 {
+					return u, nil
+				}
+				buf.Reset()
+				state = statePathStart
+			}
+
+		case statePathStart:
+			switch {
+			case u.isSpecial():
+				state = statePath
+				if r != '/' && r != '\\' {
+					p--
+				}
+			case override == 0 && r == '?':
+				u.query = stringPtr("")
+				state = stateQuery
+			case override == 0 && r == '#':
+				u.fragment = stringPtr("")
+				state = stateFragment
+			case r != eof:
+				state = statePath
+				if r != '/' {
+					p--
+				}
+			case override != 0 && u.host == nil:
+				u.path = append(u.path, "")
+			}
+
+		case statePath:
+			slash := r == '/' || u.isSpecial() && r == '\\'
+			if r != eof && !slash && (override != 0 || r != '?' && r != '#') {
+				buf.WriteString(percentEncode(string(r), pathSet))
+				break
+			}
+			segment := buf.String()
+			switch {
+			case isDoubleDot(segment):
+				u.shortenPath()
+				if !slash {
+					u.path = append(u.path, "")
+				}
+			case isSingleDot(segment):
+				if !slash {
+					u.path = append(u.path, "")
+				}
+			default:
+				if u.scheme == "file" && len(u.path) == 0 && isWindowsDriveLetter(segment) {
+					segment = segment[:1] + ":"
+				}
+				u.path = append(u.path, segment)
+			}
+			buf.Reset()
+			if r == '?' {
+				u.query = stringPtr("")
+				state = stateQuery
+			} else if r == '#' {
+				u.fragment = stringPtr("")
+				state = stateFragment
+			}
+
+		case stateOpaquePath:
+			switch r {
+			case '?':
+				u.query = stringPtr("")
+				state = stateQuery
+			case '#':
+				u.fragment = stringPtr("")
+				state = stateFragment
+			case eof:
+			default:
+				u.path[0] += percentEncode(string(r), c0ControlSet)
+			}
+
+		case stateQuery:
+			if r != eof && (override != 0 || r != '#') {
+				buf.WriteRune(r)
+				break
+			}
+			set := querySet
+			if u.isSpecial() {
+				set = specialQuerySet
+			}
+			*u.query += percentEncode(buf.String(), set)
+			buf.Reset()
+			if r == '#' {
+				u.fragment = stringPtr("")
+				state = stateFragment
+			}
+
+		case stateFragment:
+			if r != eof {
+				*u.fragment += percentEncode(string(r), fragmentSet)
+			}
+		}
+	}
+	return u, nil
+}
+
+// forbiddenHostCodePoints 主机名中不允许出现的字符
+const forbiddenHostCodePoints = "\x00\t\n\r #/:<>?@[\\]^|"
+
+func isForbiddenDomainCodePoint(r rune) bool {
+	return strings.ContainsRune(forbiddenHostCodePoints, r) || c0ControlSet(r) && r < 0x80 || r == '%'
+}
+
+// parseHost 解析主机：IPv6 地址、非特殊协议的不透明主机、IPv4 地址或域名
+func parseHost(input string, opaque bool) (string, error) {
+	if strings.HasPrefix(input, "[") {
+		if !strings.HasSuffix(input, "]") {
+			return "", errInvalidURL
+		}
+		addr, err := parseIPv6(input[1 : len(input)-1])
+		if err != nil {
+			return "", err
+		}
+		return "[" + serializeIPv6(addr) + "]", nil
+	}
+	if opaque {
+		if strings.ContainsAny(input, forbiddenHostCodePoints) {
+			return "", errInvalidURL
+		}
+		return percentEncode(input, c0ControlSet), nil
+	}
+	domain, _, _ := decodeUTF8(percentDecode(input), false)
+	ascii, err := domainToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	if strings.IndexFunc(ascii, isForbiddenDomainCodePoint) >= 0 {
+		return "", errInvalidURL
+	}
+	if endsInNumber(ascii) {
+		addr, err := parseIPv4(ascii)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d.%d.%d.%d", addr>>24, addr>>16&0xff, addr>>8&0xff, addr&0xff), nil
+	}
+	return ascii, nil
+}
+
+// domainToASCII 把域名转换为小写的 ASCII 形式（国际化域名转换为 punycode）
+func domainToASCII(domain string) (string, error) {
+	ascii := true
+	for i := 0; i < len(domain); i++ {
+		if domain[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	lower := strings.ToLower(domain)
+	if ascii && !strings.HasPrefix(lower, "xn--") && !strings.Contains(lower, ".xn--") {
+		if lower == "" {
+			return "", errInvalidURL
+		}
+		return lower, nil
+	}
+	result, err := urlIDNA.ToASCII(domain)
+	if err != nil || result == "" {
+		return "", errInvalidURL
+	}
+	return result, nil
+}
+
+// endsInNumber 判断域名的最后一段是否为数字，此时按 IPv4 地址解析
+func endsInNumber(s string) bool {
+	parts := strings.Split(s, ".")
+	if parts[len(parts)-1] == "" {
+		if len(parts) == 1 {
+			return false
+		}
+		parts = parts[:len(parts)-1]
+	}
+	last := parts[len(parts)-1]
+	if last != "" && strings.Trim(last, "0123456789") == "" {
+		return true
+	}
+	_, err := parseIPv4Number(last)
+	return err == nil
+}
+
+// parseIPv4Number 解析 IPv4 地址的一段，支持 0x 开头的十六进制和 0 开头的八进制
+func parseIPv4Number(s string) (uint64, error) {
+	if s == "" {
+		return 0, errInvalidURL
+	}
+	base := 10
+	if len(s) >= 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		s, base = s[2:], 16
+	} else if len(s) >= 2 && s[0] == '0' {
+		s, base = s[1:], 8
+	}
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, base, 64)
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) && numErr.Err == strconv.ErrRange {
+			return math.MaxUint64, nil
+		}
+		return 0, errInvalidURL
+	}
+	return n, nil
+}
+
+// parseIPv4 解析 IPv4 地址，支持 "127.1"、"0x7f000001" 等简写
+func parseIPv4(s string) (uint32, error) {
+	parts := strings.Split(s, ".")
+	if parts[len(parts)-1] == "" && len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) > 4 {
+		return 0, errInvalidURL
+	}
+	numbers := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := parseIPv4Number(part)
+		if err != nil {
+			return 0, err
+		}
+		numbers[i] = n
+	}
+	for _, n := range numbers[:len(numbers)-1] {
+		if n > 255 {
+			return 0, errInvalidURL
+		}
+	}
+	last := numbers[len(numbers)-1]
+	if last >= uint64(1)<<(8*(5-len(numbers))) {
+		return 0, errInvalidURL
+	}
+	addr := last
+	for i, n := range numbers[:len(numbers)-1] {
+		addr += n << (8 * (3 - i))
+	}
+	return uint32(addr), nil
+}
+
+// parseIPv6 解析 IPv6 地址，支持 "::" 压缩和末尾嵌入的 IPv4 地址
+func parseIPv6(s string) ([8]uint16, error) {
+	var addr [8]uint16
+	c := []rune(s)
+	at := func(i int) rune {
+		if i < len(c) {
+			return c[i]
+		}
+		return eof
+	}
+	piece, compress := 0, -1
+	p := 0
+	if at(p) == ':' {
+		if at(p+1) != ':' {
+			return addr, errInvalidURL
+		}
+		p += 2
+		piece++
+		compress = piece
+	}
+	for at(p) != eof {
+		if piece == 8 {
+			return addr, errInvalidURL
+		}
+		if at(p) == ':' {
+			if compress != -1 {
+				return addr, errInvalidURL
+			}
+			p++
+			piece++
+			compress = piece
+			continue
+		}
+		value, length := 0, 0
+		for length < 4 && isASCIIHexDigit(at(p)) {
+			v, _ := strconv.ParseUint(string(at(p)), 16, 8)
+			value = value*0x10 + int(v)
+			p++
+			length++
+		}
+		if at(p) == '.' {
+			if length == 0 {
+				return addr, errInvalidURL
+			}
+			p -= length
+			if piece > 6 {
+				return addr, errInvalidURL
+			}
+			seen := 0
+			for at(p) != eof {
+				ipv4Piece := -1
+				if seen > 0 {
+					if at(p) == '.' && seen < 4 {
+						p++
+					} else {
+						return addr, errInvalidURL
+					}
+				}
+				if !isASCIIDigit(at(p)) {
+					return addr, errInvalidURL
+				}
+				for isASCIIDigit(at(p)) {
+					n := int(at(p) - '0')
+					switch {
+					case ipv4Piece == -1:
+						ipv4Piece = n
+					case ipv4Piece == 0:
+						return addr, errInvalidURL
+					default:
+						ipv4Piece = ipv4Piece*10 + n
+					}
+					if ipv4Piece > 255 {
+						return addr, errInvalidURL
+					}
+					p++
+				}
+				addr[piece] = addr[piece]*0x100 + uint16(ipv4Piece)
+				seen++
+				if seen == 2 || seen == 4 {
+					piece++
+				}
+			}
+			if seen != 4 {
+				return addr, errInvalidURL
+			}
+			break
+		}
+		if at(p) == ':' {
+			p++
+			if at(p) == eof {
+				return addr, errInvalidURL
+			}
+		} else if at(p) != eof {
+			return addr, errInvalidURL
+		}
+		addr[piece] = uint16(value)
+		piece++
+	}
+	if compress != -1 {
+		swaps := piece - compress
+		piece = 7
+		for piece != 0 && swaps > 0 {
+			addr[piece], addr[compress+swaps-1] = addr[compress+swaps-1], addr[piece]
+			piece--
+			swaps--
+		}
+	} else if piece != 8 {
+		return addr, errInvalidURL
+	}
+	return addr, nil
+}
+
+// serializeIPv6 序列化 IPv6 地址，最长的一段连续 0（至少两段）压缩为 "::"
+func serializeIPv6(addr [8]uint16) string {
+	compress, longest := -1, 1
+	for i := 0; i < 8; {
+		if addr[i] != 0 {
+			i++
+			continue
+		}
+		j := i
+		for j < 8 && addr[j] == 0 {
+			j++
+		}
+		if j-i > longest {
+			compress, longest = i, j-i
+		}
+		i = j
+	}
+	var b strings.Builder
+	ignore0 := false
+	for i := 0; i < 8; i++ {
+		if ignore0 && addr[i] == 0 {
+			continue
+		}
+		ignore0 = false
+		if compress == i {
+			if i == 0 {
+				b.WriteString("::")
+			} else {
+				b.WriteString(":")
+			}
+			ignore0 = true
+			continue
+		}
+		b.WriteString(strconv.FormatUint(uint64(addr[i]), 16))
+		if i != 7 {
+			b.WriteByte(':')
+		}
+	}
+	return b.String()
+}
+
+// hostString 返回主机，没有主机时为空字符串
+func (u *whatwgURL) hostString() string {
+	if u.host == nil {
+		return ""
+	}
+	return *u.host
+}
+
+// pathString 序列化路径
+func (u *whatwgURL) pathString() string {
+	if u.opaque {
+		return u.path[0]
+	}
+	var b strings.Builder
+	for _, segment := range u.path {
+		b.WriteString("/" + segment)
+	}
+	return b.String()
+}
+
+// String 序列化 URL
+func (u *whatwgURL) String() string {
+	var b strings.Builder
+	b.WriteString(u.scheme + ":")
+	if u.host != nil {
+		b.WriteString("//")
+		if u.includesCredentials() {
+			b.WriteString(u.username)
+			if u.password != "" {
+				b.WriteString(":" + u.password)
+			}
+			b.WriteByte('@')
+		}
+		b.WriteString(*u.host)
+		if u.port != -1 {
+			b.WriteString(":" + strconv.Itoa(u.port))
+		}
+	} else if !u.opaque && len(u.path) > 1 && u.path[0] == "" {
+		// 避免 "web+demo:/.//not-a-host/" 被再次解析时把路径当作主机
+		b.WriteString("/.")
+	}
+	b.WriteString(u.pathString())
+	if u.query != nil {
+		b.WriteString("?" + *u.query)
+	}
+	if u.fragment != nil {
+		b.WriteString("#" + *u.fragment)
+	}
+	return b.String()
+}
+
+// origin 返回 URL 的源，非特殊协议（blob: 中的 http/https URL 除外）和 file 协议为 "null"
+func (u *whatwgURL) origin() string {
+	switch u.scheme {
+	case "blob":
+		if inner, err := parseURL(u.pathString(), nil); err == nil && (inner.scheme == "http" || inner.scheme == "https") {
+			return inner.origin()
+		}
+		return "null"
+	case "ftp", "http", "https", "ws", "wss":
+		origin := u.scheme + "://" + *u.host
+		if u.port != -1 {
+			origin += ":" + strconv.Itoa(u.port)
+		}
+		return origin
+	}
+	return "null"
+}
+
+// setComponent 按 URL 各属性的 setter 规则修改 URL，无效的值被忽略
+func (u *whatwgURL) setComponent(name, value string) *whatwgURL {
+	c := u.clone()
+	var err error
+	switch name {
+	case "protocol":
+		_, err = basicParse(value+":", nil, c, stateSchemeStart)
+	case "username", "password":
+		if c.cannotHaveCredentialsOrPort() {
+			return u
+		}
+		if name == "username" {
+			c.username = percentEncode(value, userinfoSet)
+		} else {
+			c.password = percentEncode(value, userinfoSet)
+		}
+	case "host", "hostname":
+		if c.opaque {
+			return u
+		}
+		state := stateHost
+		if name == "hostname" {
+			state = stateHostname
+		}
+		_, err = basicParse(value, nil, c, state)
+	case "port":
+		if c.cannotHaveCredentialsOrPort() {
+			return u
+		}
+		if value == "" {
+			c.port = -1
+		} else {
+			_, err = basicParse(value, nil, c, statePort)
+		}
+	case "pathname":
+		if c.opaque {
+			return u
+		}
+		c.path = nil
+		_, err = basicParse(value, nil, c, statePathStart)
+	case "search":
+		if value == "" {
+			c.query = nil
+			c.stripTrailingSpacesFromOpaquePath()
+			break
+		}
+		c.query = stringPtr("")
+		_, err = basicParse(strings.TrimPrefix(value, "?"), nil, c, stateQuery)
+	case "hash":
+		if value == "" {
+			c.fragment = nil
+			c.stripTrailingSpacesFromOpaquePath()
+			break
+		}
+		c.fragment = stringPtr("")
+		_, err = basicParse(strings.TrimPrefix(value, "#"), nil, c, stateFragment)
+	default:
+		return u
+	}
+	if err != nil {
+		return u
+	}
+	return c
+}
+
+// stripTrailingSpacesFromOpaquePath 去掉查询和片段后，不透明路径末尾的空格没有意义
+func (u *whatwgURL) stripTrailingSpacesFromOpaquePath() {
+	if u.opaque && u.fragment == nil && u.query == nil {
+		u.path[0] = strings.TrimRight(u.path[0], " ")
+	}
+}
+
+// parseForm 按 application/x-www-form-urlencoded 解析查询字符串，返回名称和值交替排列的列表
+func parseForm(s string) []string {
+	var pairs []string
+	for _, seq := range strings.Split(s, "&") {
+		if seq == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(seq, "=")
+		decode := func(v string) string {
+			text, _, _ := decodeUTF8(percentDecode(strings.ReplaceAll(v, "+", " ")), false)
+			return text
+		}
+		pairs = append(pairs, decode(name), decode(value))
+	}
+	return pairs
+}
+
+// serializeForm 按 application/x-www-form-urlencoded 序列化名称和值交替排列的列表
+func serializeForm(pairs []string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(encodeFormComponent(pairs[i]) + "=" + encodeFormComponent(pairs[i+1]))
+	}
+	return b.String()
+}
+
+// encodeFormComponent 编码名称或值，空格编码为 "+"
+func encodeFormComponent(s string) string {
+	parts := strings.Split(s, " ")
+	for i, part := range parts {
+		parts[i] = percentEncode(part, formSet)
+	}
+	return strings.Join(parts, "+")
+}